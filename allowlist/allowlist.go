@@ -0,0 +1,266 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package allowlist generates firewall rules and allow lists from the IP
+// ranges of Datadog.
+//
+// A Snapshot holds the IPv4 and IPv6 prefixes of every product returned by
+// GetIPRanges. A Generator turns the prefixes of selected products into
+// iptables or nftables rule sets, AWS security group or GCP firewall JSON, a
+// Kubernetes NetworkPolicy or an nginx allow list. Traffic to the intake of
+// products is egress, and traffic from Synthetics and webhooks is ingress.
+//
+// Snapshots are saved to a file, and Diff compares a snapshot with the
+// previous one when its modified timestamp changed, so rules are only
+// regenerated and reviewed when the ranges change.
+package allowlist
+
+import (
+	_context "context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/statefile"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/syncplan"
+)
+
+// Product is a product of the IP ranges.
+type Product string
+
+// List of Product.
+const (
+	ProductAgents                     Product = "agents"
+	ProductAPI                        Product = "api"
+	ProductAPM                        Product = "apm"
+	ProductLogs                       Product = "logs"
+	ProductProcess                    Product = "process"
+	ProductSynthetics                 Product = "synthetics"
+	ProductSyntheticsPrivateLocations Product = "synthetics-private-locations"
+	ProductWebhooks                   Product = "webhooks"
+)
+
+// Products are all the products, in the order rules are generated.
+var Products = []Product{
+	ProductAgents,
+	ProductAPI,
+	ProductAPM,
+	ProductLogs,
+	ProductProcess,
+	ProductSynthetics,
+	ProductSyntheticsPrivateLocations,
+	ProductWebhooks,
+}
+
+// Direction is the direction of the traffic of a product.
+type Direction string
+
+// List of Direction.
+const (
+	DirectionEgress  Direction = "egress"
+	DirectionIngress Direction = "ingress"
+)
+
+// Direction returns the direction of the traffic of the product: ingress
+// for the Synthetics tests and webhooks sent by Datadog, egress for the
+// others.
+func (p Product) Direction() Direction {
+	if p == ProductSynthetics || p == ProductWebhooks {
+		return DirectionIngress
+	}
+	return DirectionEgress
+}
+
+// Prefixes are the sorted IP prefixes of a product.
+type Prefixes struct {
+	IPv4 []string `json:"ipv4,omitempty"`
+	IPv6 []string `json:"ipv6,omitempty"`
+}
+
+// modifiedLayout is the layout of the modified timestamp of IP ranges.
+const modifiedLayout = "2006-01-02-15-04-05"
+
+// Snapshot is the IP ranges of Datadog at a time.
+type Snapshot struct {
+	// Modified is the time the ranges were modified, such as
+	// "2023-06-22-16-00-00".
+	Modified string                `json:"modified"`
+	Version  int64                 `json:"version,omitempty"`
+	Products map[Product]*Prefixes `json:"products"`
+}
+
+// NewSnapshot returns the snapshot of IP ranges.
+func NewSnapshot(ranges datadogV1.IPRanges) *Snapshot {
+	s := &Snapshot{Modified: ranges.GetModified(), Version: ranges.GetVersion(), Products: map[Product]*Prefixes{}}
+	add := func(p Product, ipv4, ipv6 []string) {
+		if len(ipv4) > 0 || len(ipv6) > 0 {
+			s.Products[p] = &Prefixes{IPv4: normalize(ipv4), IPv6: normalize(ipv6)}
+		}
+	}
+	if r := ranges.Agents; r != nil {
+		add(ProductAgents, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	if r := ranges.Api; r != nil {
+		add(ProductAPI, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	if r := ranges.Apm; r != nil {
+		add(ProductAPM, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	if r := ranges.Logs; r != nil {
+		add(ProductLogs, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	if r := ranges.Process; r != nil {
+		add(ProductProcess, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	if r := ranges.Synthetics; r != nil {
+		add(ProductSynthetics, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	if r := ranges.SyntheticsPrivateLocations; r != nil {
+		add(ProductSyntheticsPrivateLocations, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	if r := ranges.Webhooks; r != nil {
+		add(ProductWebhooks, r.PrefixesIpv4, r.PrefixesIpv6)
+	}
+	return s
+}
+
+// normalize returns the sorted prefixes, without duplicates.
+func normalize(prefixes []string) []string {
+	var sorted []string
+	seen := map[string]bool{}
+	for _, p := range prefixes {
+		if p = strings.TrimSpace(p); p != "" && !seen[p] {
+			seen[p] = true
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Fetch returns the current snapshot of IP ranges.
+func Fetch(ctx _context.Context, api *datadogV1.IPRangesApi) (*Snapshot, error) {
+	ranges, _, err := api.GetIPRanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting IP ranges: %w", err)
+	}
+	return NewSnapshot(ranges), nil
+}
+
+// LoadSnapshot reads a snapshot file. A missing file is an empty snapshot.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	s := &Snapshot{Products: map[Product]*Prefixes{}}
+	if err := statefile.Load(path, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes the snapshot to a file, atomically.
+func (s *Snapshot) Save(path string) error {
+	return statefile.Save(path, s)
+}
+
+// ModifiedTime returns the time the ranges were modified, the zero time for
+// an empty snapshot.
+func (s *Snapshot) ModifiedTime() (time.Time, error) {
+	if s.Modified == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(modifiedLayout, s.Modified)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing modified time %q: %w", s.Modified, err)
+	}
+	return t, nil
+}
+
+// Prefix is a prefix of a product.
+type Prefix struct {
+	Product Product
+	Prefix  string
+}
+
+// Diff is the prefixes added and removed between two snapshots.
+type Diff struct {
+	// From and To are the modified timestamps of the previous and current
+	// snapshots.
+	From, To string
+	Added    []Prefix
+	Removed  []Prefix
+}
+
+// Empty returns whether no prefix changed.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// Diff returns the prefixes of the given products, or of all products when
+// none are given, added and removed since a previous snapshot. Snapshots
+// modified at the same time have no changes, and a snapshot older than the
+// previous one is an error.
+func (s *Snapshot) Diff(previous *Snapshot, products ...Product) (*Diff, error) {
+	d := &Diff{From: previous.Modified, To: s.Modified}
+	if s.Modified == previous.Modified {
+		return d, nil
+	}
+	from, err := previous.ModifiedTime()
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.ModifiedTime()
+	if err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("snapshot modified at %s is older than the previous one, modified at %s", s.Modified, previous.Modified)
+	}
+	if len(products) == 0 {
+		products = Products
+	}
+	for _, p := range products {
+		added, removed := syncplan.Difference(s.prefixes(p), previous.prefixes(p))
+		for _, prefix := range added {
+			d.Added = append(d.Added, Prefix{Product: p, Prefix: prefix})
+		}
+		for _, prefix := range removed {
+			d.Removed = append(d.Removed, Prefix{Product: p, Prefix: prefix})
+		}
+	}
+	return d, nil
+}
+
+// prefixes returns the IPv4 and IPv6 prefixes of a product.
+func (s *Snapshot) prefixes(p Product) []string {
+	prefixes := s.Products[p]
+	if prefixes == nil {
+		return nil
+	}
+	return append(append([]string{}, prefixes.IPv4...), prefixes.IPv6...)
+}
+
+// WriteText writes the prefixes added and removed, one per line.
+func (d *Diff) WriteText(w io.Writer) error {
+	if d.Empty() {
+		_, err := io.WriteString(w, "No changes.\n")
+		return err
+	}
+	var b strings.Builder
+	from := d.From
+	if from == "" {
+		from = "(none)"
+	}
+	fmt.Fprintf(&b, "IP ranges modified %s -> %s\n", from, d.To)
+	for _, p := range d.Added {
+		fmt.Fprintf(&b, "+ %s %s\n", p.Product, p.Prefix)
+	}
+	for _, p := range d.Removed {
+		fmt.Fprintf(&b, "- %s %s\n", p.Product, p.Prefix)
+	}
+	fmt.Fprintf(&b, "\n%d added, %d removed.\n", len(d.Added), len(d.Removed))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,469 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package allowlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of generated rules.
+type Format string
+
+// List of Format.
+const (
+	// FormatIPTables is a shell script of iptables and ip6tables commands
+	// filling a chain per direction, to jump to from INPUT or OUTPUT.
+	FormatIPTables Format = "iptables"
+	// FormatNFTables is an nftables table with a set of prefixes and a chain
+	// per direction, replaced as a whole when loaded with nft -f.
+	FormatNFTables Format = "nftables"
+	// FormatAWSSecurityGroup is the IpPermissions and IpPermissionsEgress of
+	// an AWS security group, as JSON.
+	FormatAWSSecurityGroup Format = "aws-security-group"
+	// FormatGCPFirewall is a list of GCP firewall rules, as JSON.
+	FormatGCPFirewall Format = "gcp-firewall"
+	// FormatNetworkPolicy is a Kubernetes NetworkPolicy, as YAML.
+	FormatNetworkPolicy Format = "network-policy"
+	// FormatNginx is a list of nginx allow directives followed by deny all,
+	// for the ingress products only.
+	FormatNginx Format = "nginx"
+)
+
+// Generator generates the rules allowing the traffic of products.
+type Generator struct {
+	Format Format
+	// Products are the products rules are generated for, all products when
+	// empty.
+	Products []Product
+	// Name names the chains, tables, rules and policies generated.
+	Name string
+	// Ports are the TCP ports allowed.
+	Ports []int
+	// Network is the network of GCP firewall rules.
+	Network string
+	// Namespace is the namespace of NetworkPolicies, and PodSelector the
+	// labels of the pods they apply to, all the pods of the namespace when
+	// empty. As NetworkPolicies add up, the other traffic of the pods, such
+	// as DNS, needs policies of its own.
+	Namespace   string
+	PodSelector map[string]string
+}
+
+// NewGenerator returns a generator of rules to the format for the products,
+// named "datadog", allowing port 443 on the default GCP network.
+func NewGenerator(format Format, products ...Product) *Generator {
+	return &Generator{
+		Format:   format,
+		Products: products,
+		Name:     "datadog",
+		Ports:    []int{443},
+		Network:  "global/networks/default",
+	}
+}
+
+// rule allows a prefix in a direction, for the products it is a prefix of.
+type rule struct {
+	direction Direction
+	prefix    string
+	ipv6      bool
+	products  []Product
+}
+
+func (r rule) description(name string) string {
+	products := make([]string, len(r.products))
+	for i, p := range r.products {
+		products[i] = string(p)
+	}
+	return name + " " + strings.Join(products, ",")
+}
+
+// rules returns the rules of the products, egress then ingress, IPv4 then
+// IPv6, in the order of the products.
+func (g *Generator) rules(s *Snapshot) []rule {
+	products := g.Products
+	if len(products) == 0 {
+		products = Products
+	}
+	var rules []rule
+	index := map[string]int{}
+	for _, d := range []Direction{DirectionEgress, DirectionIngress} {
+		for _, ipv6 := range []bool{false, true} {
+			for _, p := range products {
+				prefixes := s.Products[p]
+				if p.Direction() != d || prefixes == nil {
+					continue
+				}
+				list := prefixes.IPv4
+				if ipv6 {
+					list = prefixes.IPv6
+				}
+				for _, prefix := range list {
+					key := string(d) + " " + prefix
+					if i, ok := index[key]; ok {
+						rules[i].products = append(rules[i].products, p)
+						continue
+					}
+					index[key] = len(rules)
+					rules = append(rules, rule{direction: d, prefix: prefix, ipv6: ipv6, products: []Product{p}})
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// selectRules returns the rules of a direction and family.
+func selectRules(rules []rule, d Direction, ipv6 bool) []rule {
+	var selected []rule
+	for _, r := range rules {
+		if r.direction == d && r.ipv6 == ipv6 {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+func prefixes(rules []rule) []string {
+	prefixes := make([]string, len(rules))
+	for i, r := range rules {
+		prefixes[i] = r.prefix
+	}
+	return prefixes
+}
+
+func (g *Generator) ports(sep string) string {
+	ports := make([]string, len(g.Ports))
+	for i, p := range g.Ports {
+		ports[i] = fmt.Sprint(p)
+	}
+	return strings.Join(ports, sep)
+}
+
+// Generate writes the rules of the snapshot.
+func (g *Generator) Generate(w io.Writer, s *Snapshot) error {
+	if len(g.Ports) == 0 {
+		return errors.New("no ports to allow")
+	}
+	rules := g.rules(s)
+	switch g.Format {
+	case FormatIPTables:
+		return g.iptables(w, s, rules)
+	case FormatNFTables:
+		return g.nftables(w, s, rules)
+	case FormatAWSSecurityGroup:
+		return g.awsSecurityGroup(w, rules)
+	case FormatGCPFirewall:
+		return g.gcpFirewall(w, s, rules)
+	case FormatNetworkPolicy:
+		return g.networkPolicy(w, rules)
+	case FormatNginx:
+		return g.nginx(w, s, rules)
+	default:
+		return fmt.Errorf("unsupported format %q", g.Format)
+	}
+}
+
+func (g *Generator) iptables(w io.Writer, s *Snapshot, rules []rule) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n# Datadog IP ranges modified %s.\nset -e\n", s.Modified)
+	ports := "--dport " + g.ports(",")
+	if len(g.Ports) > 1 {
+		ports = "-m multiport --dports " + g.ports(",")
+	}
+	for _, d := range []Direction{DirectionEgress, DirectionIngress} {
+		chain := strings.ToUpper(g.Name + "-" + string(d))
+		address := "-d"
+		if d == DirectionIngress {
+			address = "-s"
+		}
+		for _, ipv6 := range []bool{false, true} {
+			selected := selectRules(rules, d, ipv6)
+			if len(selected) == 0 {
+				continue
+			}
+			command := "iptables"
+			if ipv6 {
+				command = "ip6tables"
+			}
+			fmt.Fprintf(&b, "%s -N %s 2>/dev/null || %s -F %s\n", command, chain, command, chain)
+			for _, r := range selected {
+				fmt.Fprintf(&b, "%s -A %s %s %s -p tcp %s -m comment --comment %q -j ACCEPT\n", command, chain, address, r.prefix, ports, r.description(g.Name))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g *Generator) nftables(w io.Writer, s *Snapshot, rules []rule) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Datadog IP ranges modified %s.\ntable inet %s\ndelete table inet %s\n\ntable inet %s {\n", s.Modified, g.Name, g.Name, g.Name)
+	var chains []string
+	for _, d := range []Direction{DirectionEgress, DirectionIngress} {
+		var chain []string
+		for _, ipv6 := range []bool{false, true} {
+			selected := selectRules(rules, d, ipv6)
+			if len(selected) == 0 {
+				continue
+			}
+			family, kind, address := "ip", "ipv4", "daddr"
+			if ipv6 {
+				family, kind = "ip6", "ipv6"
+			}
+			if d == DirectionIngress {
+				address = "saddr"
+			}
+			set := fmt.Sprintf("%s_%s", d, kind)
+			fmt.Fprintf(&b, "\tset %s {\n\t\ttype %s_addr\n\t\tflags interval\n\t\telements = {\n", set, kind)
+			for _, prefix := range collapse(prefixes(selected)) {
+				fmt.Fprintf(&b, "\t\t\t%s,\n", prefix)
+			}
+			b.WriteString("\t\t}\n\t}\n\n")
+			chain = append(chain, fmt.Sprintf("\t\t%s %s @%s tcp dport { %s } accept\n", family, address, set, g.ports(", ")))
+		}
+		if len(chain) > 0 {
+			chains = append(chains, fmt.Sprintf("\tchain %s {\n%s\t}\n", d, strings.Join(chain, "")))
+		}
+	}
+	b.WriteString(strings.Join(chains, "\n"))
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// collapse drops the prefixes contained in others, as nft rejects sets of
+// overlapping intervals.
+func collapse(prefixes []string) []string {
+	parsed := make([]netip.Prefix, len(prefixes))
+	for i, p := range prefixes {
+		parsed[i], _ = netip.ParsePrefix(p)
+	}
+	var collapsed []string
+	for i, p := range parsed {
+		contained := false
+		for j, q := range parsed {
+			if i == j || !p.IsValid() || !q.IsValid() || !q.Contains(p.Addr()) {
+				continue
+			}
+			// Of two equal prefixes, the first is kept.
+			if q.Bits() < p.Bits() || (q.Bits() == p.Bits() && j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			collapsed = append(collapsed, prefixes[i])
+		}
+	}
+	return collapsed
+}
+
+type awsIPRange struct {
+	CidrIP      string `json:"CidrIp"`
+	Description string `json:"Description"`
+}
+
+type awsIPv6Range struct {
+	CidrIPv6    string `json:"CidrIpv6"`
+	Description string `json:"Description"`
+}
+
+type awsPermission struct {
+	IPProtocol string         `json:"IpProtocol"`
+	FromPort   int            `json:"FromPort"`
+	ToPort     int            `json:"ToPort"`
+	IPRanges   []awsIPRange   `json:"IpRanges,omitempty"`
+	IPv6Ranges []awsIPv6Range `json:"Ipv6Ranges,omitempty"`
+}
+
+type awsSecurityGroup struct {
+	IPPermissions       []awsPermission `json:"IpPermissions"`
+	IPPermissionsEgress []awsPermission `json:"IpPermissionsEgress"`
+}
+
+func (g *Generator) awsSecurityGroup(w io.Writer, rules []rule) error {
+	permissions := func(d Direction) []awsPermission {
+		permissions := []awsPermission{}
+		for _, port := range g.Ports {
+			p := awsPermission{IPProtocol: "tcp", FromPort: port, ToPort: port}
+			for _, r := range rules {
+				switch {
+				case r.direction != d:
+				case r.ipv6:
+					p.IPv6Ranges = append(p.IPv6Ranges, awsIPv6Range{CidrIPv6: r.prefix, Description: r.description(g.Name)})
+				default:
+					p.IPRanges = append(p.IPRanges, awsIPRange{CidrIP: r.prefix, Description: r.description(g.Name)})
+				}
+			}
+			if len(p.IPRanges) > 0 || len(p.IPv6Ranges) > 0 {
+				permissions = append(permissions, p)
+			}
+		}
+		return permissions
+	}
+	return writeJSON(w, awsSecurityGroup{
+		IPPermissions:       permissions(DirectionIngress),
+		IPPermissionsEgress: permissions(DirectionEgress),
+	})
+}
+
+type gcpAllowed struct {
+	IPProtocol string   `json:"IPProtocol"`
+	Ports      []string `json:"ports"`
+}
+
+type gcpFirewall struct {
+	Name              string       `json:"name"`
+	Description       string       `json:"description"`
+	Network           string       `json:"network"`
+	Direction         string       `json:"direction"`
+	Priority          int          `json:"priority"`
+	Allowed           []gcpAllowed `json:"allowed"`
+	SourceRanges      []string     `json:"sourceRanges,omitempty"`
+	DestinationRanges []string     `json:"destinationRanges,omitempty"`
+}
+
+// gcpFirewall writes a rule per direction and family, as GCP firewall rules
+// cannot mix IPv4 and IPv6 ranges.
+func (g *Generator) gcpFirewall(w io.Writer, s *Snapshot, rules []rule) error {
+	firewalls := []gcpFirewall{}
+	ports := strings.Split(g.ports(","), ",")
+	for _, d := range []Direction{DirectionEgress, DirectionIngress} {
+		for _, ipv6 := range []bool{false, true} {
+			selected := selectRules(rules, d, ipv6)
+			if len(selected) == 0 {
+				continue
+			}
+			family := "ipv4"
+			if ipv6 {
+				family = "ipv6"
+			}
+			f := gcpFirewall{
+				Name:        fmt.Sprintf("%s-%s-%s", g.Name, d, family),
+				Description: fmt.Sprintf("Datadog IP ranges modified %s", s.Modified),
+				Network:     g.Network,
+				Direction:   strings.ToUpper(string(d)),
+				Priority:    1000,
+				Allowed:     []gcpAllowed{{IPProtocol: "tcp", Ports: ports}},
+			}
+			if d == DirectionIngress {
+				f.SourceRanges = prefixes(selected)
+			} else {
+				f.DestinationRanges = prefixes(selected)
+			}
+			firewalls = append(firewalls, f)
+		}
+	}
+	return writeJSON(w, firewalls)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+type networkPolicy struct {
+	APIVersion string                `yaml:"apiVersion"`
+	Kind       string                `yaml:"kind"`
+	Metadata   networkPolicyMetadata `yaml:"metadata"`
+	Spec       networkPolicySpec     `yaml:"spec"`
+}
+
+type networkPolicyMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type networkPolicySpec struct {
+	PodSelector struct {
+		MatchLabels map[string]string `yaml:"matchLabels,omitempty"`
+	} `yaml:"podSelector"`
+	PolicyTypes []string            `yaml:"policyTypes"`
+	Egress      []networkPolicyRule `yaml:"egress,omitempty"`
+	Ingress     []networkPolicyRule `yaml:"ingress,omitempty"`
+}
+
+type networkPolicyRule struct {
+	To    []networkPolicyPeer `yaml:"to,omitempty"`
+	From  []networkPolicyPeer `yaml:"from,omitempty"`
+	Ports []networkPolicyPort `yaml:"ports"`
+}
+
+type networkPolicyPeer struct {
+	IPBlock struct {
+		CIDR string `yaml:"cidr"`
+	} `yaml:"ipBlock"`
+}
+
+type networkPolicyPort struct {
+	Protocol string `yaml:"protocol"`
+	Port     int    `yaml:"port"`
+}
+
+func (g *Generator) networkPolicy(w io.Writer, rules []rule) error {
+	policy := networkPolicy{
+		APIVersion: "networking.k8s.io/v1",
+		Kind:       "NetworkPolicy",
+		Metadata:   networkPolicyMetadata{Name: g.Name, Namespace: g.Namespace},
+	}
+	policy.Spec.PodSelector.MatchLabels = g.PodSelector
+	var ports []networkPolicyPort
+	for _, p := range g.Ports {
+		ports = append(ports, networkPolicyPort{Protocol: "TCP", Port: p})
+	}
+	var egress, ingress []networkPolicyPeer
+	for _, r := range rules {
+		var peer networkPolicyPeer
+		peer.IPBlock.CIDR = r.prefix
+		if r.direction == DirectionIngress {
+			ingress = append(ingress, peer)
+		} else {
+			egress = append(egress, peer)
+		}
+	}
+	if len(egress) > 0 {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, "Egress")
+		policy.Spec.Egress = []networkPolicyRule{{To: egress, Ports: ports}}
+	}
+	if len(ingress) > 0 {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, "Ingress")
+		policy.Spec.Ingress = []networkPolicyRule{{From: ingress, Ports: ports}}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(policy); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (g *Generator) nginx(w io.Writer, s *Snapshot, rules []rule) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Datadog IP ranges modified %s.\n", s.Modified)
+	ingress := false
+	for _, r := range rules {
+		if r.direction == DirectionIngress {
+			ingress = true
+			fmt.Fprintf(&b, "allow %s; # %s\n", r.prefix, r.description(g.Name))
+		}
+	}
+	if !ingress {
+		return errors.New("nginx allows incoming traffic only, and no ingress product has IP ranges")
+	}
+	b.WriteString("deny all;\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package audit exports the Audit Trail of an org to a SIEM.
+//
+// An Exporter follows the audit events with a tail.Tail searching the audit
+// logs, formats them as CEF, LEEF, OCSF JSON or RFC 5424 syslog messages
+// with an Encoder and sends them to a Sink, such as a file, the standard
+// output or a syslog collector over TCP or UDP.
+//
+// Every SaveInterval, and when it stops, the exporter saves a Checkpoint, the
+// timestamp of the last event exported and the IDs of the events exported at
+// that timestamp, to a state file. A restarted exporter resumes from the
+// checkpoint, without exporting the same events twice, except for the events
+// exported since the last save of a crashed exporter.
+package audit
+
+import (
+	_context "context"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/statefile"
+	"github.com/DataDog/datadog-api-client-go/v2/tail"
+)
+
+const defaultSaveInterval = 10 * time.Second
+
+// Checkpoint is the position of an exporter in the audit trail.
+type Checkpoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	// IDs are the IDs of the events exported at Timestamp.
+	IDs []string `json:"ids,omitempty"`
+}
+
+// LoadCheckpoint reads a checkpoint from a state file. A missing file is an
+// empty checkpoint.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var c Checkpoint
+	err := statefile.Load(path, &c)
+	return c, err
+}
+
+// Save writes the checkpoint to a state file, atomically.
+func (c Checkpoint) Save(path string) error {
+	return statefile.Save(path, c)
+}
+
+// advance moves the checkpoint to an exported event. Events older than the
+// checkpoint leave it unchanged.
+func (c *Checkpoint) advance(id string, ts time.Time) {
+	switch {
+	case ts.After(c.Timestamp):
+		c.Timestamp = ts
+		c.IDs = []string{id}
+	case ts.Equal(c.Timestamp):
+		c.IDs = append(c.IDs, id)
+	}
+}
+
+// Exporter exports the audit events matching a query.
+type Exporter struct {
+	encoder *Encoder
+	sink    Sink
+
+	// Tail follows the audit events. Its Start is overridden by the
+	// checkpoint, if any.
+	Tail *tail.Tail
+	// StatePath is the state file of the checkpoint. The checkpoint is not
+	// saved when empty.
+	StatePath string
+	// SaveInterval is the minimum delay between two saves of the
+	// checkpoint.
+	SaveInterval time.Duration
+}
+
+// NewExporter returns an exporter of the audit events matching the query,
+// formatted by the encoder and sent to the sink.
+func NewExporter(api *datadogV2.AuditApi, query string, encoder *Encoder, sink Sink) *Exporter {
+	return &Exporter{
+		encoder:      encoder,
+		sink:         sink,
+		Tail:         tail.New(&searchSource{api: api}, query),
+		SaveInterval: defaultSaveInterval,
+	}
+}
+
+// Run exports the audit events until the context is done or an event cannot
+// be exported, saves the checkpoint and returns that error.
+func (e *Exporter) Run(ctx _context.Context) error {
+	var checkpoint Checkpoint
+	if e.StatePath != "" {
+		var err error
+		if checkpoint, err = LoadCheckpoint(e.StatePath); err != nil {
+			return fmt.Errorf("loading checkpoint: %w", err)
+		}
+	}
+	skip := map[string]bool{}
+	for _, id := range checkpoint.IDs {
+		skip[id] = true
+	}
+	if !checkpoint.Timestamp.IsZero() {
+		e.Tail.Start = checkpoint.Timestamp
+	}
+
+	dirty := false
+	lastSave := time.Now()
+	save := func() error {
+		if e.StatePath == "" || !dirty {
+			return nil
+		}
+		if err := checkpoint.Save(e.StatePath); err != nil {
+			return fmt.Errorf("saving checkpoint: %w", err)
+		}
+		dirty = false
+		lastSave = time.Now()
+		return nil
+	}
+
+	runErr := e.Tail.Run(ctx, func(item tail.Item) error {
+		if skip[item.ID] {
+			return nil
+		}
+		event, ok := item.Value.(datadogV2.AuditLogsEvent)
+		if !ok {
+			return fmt.Errorf("event %s is a %T, not an audit event", item.ID, item.Value)
+		}
+		record, err := e.encoder.Encode(event)
+		if err != nil {
+			return fmt.Errorf("encoding event %s: %w", item.ID, err)
+		}
+		if err := e.sink.Send(record); err != nil {
+			return fmt.Errorf("sending event %s: %w", item.ID, err)
+		}
+		checkpoint.advance(item.ID, item.Timestamp)
+		dirty = true
+		if time.Since(lastSave) < e.SaveInterval {
+			return nil
+		}
+		return save()
+	})
+	if err := save(); err != nil && (runErr == nil || runErr == ctx.Err()) {
+		return err
+	}
+	return runErr
+}
@@ -0,0 +1,424 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Format is the format of exported events.
+type Format string
+
+// List of Format.
+const (
+	// FormatCEF is the ArcSight Common Event Format.
+	FormatCEF Format = "cef"
+	// FormatLEEF is the QRadar Log Event Extended Format 1.0.
+	FormatLEEF Format = "leef"
+	// FormatOCSF is the Open Cybersecurity Schema Framework API Activity
+	// class, as JSON.
+	FormatOCSF Format = "ocsf"
+	// FormatSyslog is an RFC 5424 syslog message with the event as JSON.
+	FormatSyslog Format = "syslog"
+)
+
+const (
+	vendor        = "Datadog"
+	product       = "Audit Trail"
+	version       = "1.0"
+	ocsfVersion   = "1.1.0"
+	appName       = "datadog-audit"
+	facilityAudit = 13
+)
+
+// severity is the severity of a status in every format.
+type severity struct {
+	cef, syslog, ocsf int
+	name              string
+}
+
+var severities = map[string]severity{
+	"debug":     {1, 7, 1, "Informational"},
+	"info":      {3, 6, 1, "Informational"},
+	"ok":        {3, 6, 1, "Informational"},
+	"notice":    {4, 5, 2, "Low"},
+	"warn":      {6, 4, 3, "Medium"},
+	"warning":   {6, 4, 3, "Medium"},
+	"error":     {8, 3, 4, "High"},
+	"critical":  {9, 2, 5, "Critical"},
+	"alert":     {10, 1, 5, "Critical"},
+	"emergency": {10, 0, 5, "Critical"},
+}
+
+// activities are the OCSF activities of audit actions.
+var activities = map[string]struct {
+	id   int
+	name string
+}{
+	"created":  {1, "Create"},
+	"accessed": {2, "Read"},
+	"modified": {3, "Update"},
+	"updated":  {3, "Update"},
+	"deleted":  {4, "Delete"},
+}
+
+// Encoder formats audit events.
+type Encoder struct {
+	Format Format
+	// Hostname is the HOSTNAME of syslog messages.
+	Hostname string
+	// Facility is the facility of syslog messages.
+	Facility int
+}
+
+// NewEncoder returns an encoder to the format, with the host name and the
+// log audit facility for syslog messages.
+func NewEncoder(format Format) *Encoder {
+	hostname, _ := os.Hostname()
+	return &Encoder{Format: format, Hostname: hostname, Facility: facilityAudit}
+}
+
+// Encode formats an audit event, without a trailing newline.
+func (e *Encoder) Encode(event datadogV2.AuditLogsEvent) ([]byte, error) {
+	ev := newEvent(event)
+	switch e.Format {
+	case FormatCEF:
+		return []byte(ev.cef()), nil
+	case FormatLEEF:
+		return []byte(ev.leef()), nil
+	case FormatOCSF:
+		return json.Marshal(ev.ocsf())
+	case FormatSyslog:
+		return ev.syslog(e.Hostname, e.Facility)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", e.Format)
+	}
+}
+
+// event is an audit event with its nested attributes.
+type event struct {
+	id        string
+	timestamp time.Time
+	service   string
+	tags      []string
+	attrs     map[string]interface{}
+}
+
+func newEvent(e datadogV2.AuditLogsEvent) event {
+	attributes := e.GetAttributes()
+	return event{
+		id:        e.GetId(),
+		timestamp: attributes.GetTimestamp().UTC(),
+		service:   attributes.GetService(),
+		tags:      attributes.Tags,
+		attrs:     attributes.Attributes,
+	}
+}
+
+// get returns the attribute at a dotted path, nested or flattened, as a
+// string.
+func (e event) get(path string) string {
+	v := lookup(e.attrs, path)
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func lookup(m map[string]interface{}, path string) interface{} {
+	if v, ok := m[path]; ok {
+		return v
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if sub, ok := m[path[:i]].(map[string]interface{}); ok {
+			if v := lookup(sub, path[i+1:]); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func (e event) severity() severity {
+	if s, ok := severities[strings.ToLower(e.get("status"))]; ok {
+		return s
+	}
+	return severities["info"]
+}
+
+// name is a human readable name of the event.
+func (e event) name() string {
+	if message := e.get("message"); message != "" {
+		return message
+	}
+	return strings.TrimSpace(e.get("evt.name") + " " + e.get("action"))
+}
+
+// fields are the key values of CEF and LEEF extensions.
+type fields [][2]string
+
+func (f *fields) add(key, value string) {
+	if value != "" {
+		*f = append(*f, [2]string{key, value})
+	}
+}
+
+func (e event) cef() string {
+	var ext fields
+	ext.add("rt", strconv.FormatInt(e.timestamp.UnixMilli(), 10))
+	ext.add("externalId", e.id)
+	ext.add("cat", e.get("evt.name"))
+	ext.add("act", e.get("action"))
+	ext.add("suser", e.get("usr.email"))
+	ext.add("suid", e.get("usr.id"))
+	ext.add("src", e.get("network.client.ip"))
+	ext.add("requestMethod", e.get("http.method"))
+	ext.add("request", e.get("http.url_details.path"))
+	ext.add("outcome", e.get("http.status_code"))
+	if assetType := e.get("asset.type"); assetType != "" {
+		ext.add("cs1Label", "assetType")
+		ext.add("cs1", assetType)
+	}
+	if assetName := e.get("asset.name"); assetName != "" {
+		ext.add("cs2Label", "assetName")
+		ext.add("cs2", assetName)
+	}
+	if assetID := e.get("asset.id"); assetID != "" {
+		ext.add("cs3Label", "assetId")
+		ext.add("cs3", assetID)
+	}
+
+	signature := e.get("evt.name")
+	if signature == "" {
+		signature = e.service
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|", vendor, product, version, cefHeader(signature), cefHeader(e.name()), e.severity().cef)
+	for i, f := range ext {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f[0] + "=" + cefValue(f[1]))
+	}
+	return b.String()
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefEscaper      = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ", `|`, `\|`)
+)
+
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+
+func cefValue(s string) string { return cefValueEscaper.Replace(s) }
+
+// leefTimeFormat is the devTimeFormat of LEEF events.
+const leefTimeFormat = "MMM dd yyyy HH:mm:ss.SSS"
+
+func (e event) leef() string {
+	var attrs fields
+	attrs.add("devTime", e.timestamp.Format("Jan 02 2006 15:04:05.000"))
+	attrs.add("devTimeFormat", leefTimeFormat)
+	attrs.add("cat", e.get("evt.name"))
+	attrs.add("sev", strconv.Itoa(e.severity().cef))
+	attrs.add("usrName", e.get("usr.email"))
+	attrs.add("src", e.get("network.client.ip"))
+	attrs.add("url", e.get("http.url_details.path"))
+	attrs.add("eventId", e.id)
+	attrs.add("action", e.get("action"))
+	attrs.add("httpMethod", e.get("http.method"))
+	attrs.add("httpStatus", e.get("http.status_code"))
+	attrs.add("assetType", e.get("asset.type"))
+	attrs.add("assetName", e.get("asset.name"))
+	attrs.add("assetId", e.get("asset.id"))
+	attrs.add("service", e.service)
+
+	eventID := e.get("action")
+	if eventID == "" {
+		eventID = e.get("evt.name")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|%s|", vendor, product, version, leefEscaper.Replace(eventID))
+	for i, f := range attrs {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(f[0] + "=" + leefEscaper.Replace(f[1]))
+	}
+	return b.String()
+}
+
+type ocsfEvent struct {
+	ActivityID   int                    `json:"activity_id"`
+	ActivityName string                 `json:"activity_name"`
+	CategoryUID  int                    `json:"category_uid"`
+	CategoryName string                 `json:"category_name"`
+	ClassUID     int                    `json:"class_uid"`
+	ClassName    string                 `json:"class_name"`
+	TypeUID      int                    `json:"type_uid"`
+	SeverityID   int                    `json:"severity_id"`
+	Severity     string                 `json:"severity"`
+	Time         int64                  `json:"time"`
+	Message      string                 `json:"message,omitempty"`
+	Metadata     ocsfMetadata           `json:"metadata"`
+	Actor        *ocsfActor             `json:"actor,omitempty"`
+	API          ocsfAPI                `json:"api"`
+	HTTPRequest  *ocsfHTTPRequest       `json:"http_request,omitempty"`
+	SrcEndpoint  *ocsfEndpoint          `json:"src_endpoint,omitempty"`
+	Resources    []ocsfResource         `json:"resources,omitempty"`
+	Unmapped     map[string]interface{} `json:"unmapped,omitempty"`
+}
+
+type ocsfMetadata struct {
+	Version string      `json:"version"`
+	UID     string      `json:"uid,omitempty"`
+	Labels  []string    `json:"labels,omitempty"`
+	Product ocsfProduct `json:"product"`
+}
+
+type ocsfProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+}
+
+type ocsfActor struct {
+	User ocsfUser `json:"user"`
+}
+
+type ocsfUser struct {
+	UID       string `json:"uid,omitempty"`
+	EmailAddr string `json:"email_addr,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+type ocsfAPI struct {
+	Operation string       `json:"operation"`
+	Service   *ocsfService `json:"service,omitempty"`
+}
+
+type ocsfService struct {
+	Name string `json:"name"`
+}
+
+type ocsfHTTPRequest struct {
+	HTTPMethod string  `json:"http_method,omitempty"`
+	URL        ocsfURL `json:"url"`
+}
+
+type ocsfURL struct {
+	Path string `json:"path,omitempty"`
+}
+
+type ocsfEndpoint struct {
+	IP string `json:"ip"`
+}
+
+type ocsfResource struct {
+	Type string `json:"type,omitempty"`
+	Name string `json:"name,omitempty"`
+	UID  string `json:"uid,omitempty"`
+}
+
+// ocsf maps the event to the API Activity class of the Application Activity
+// category.
+func (e event) ocsf() ocsfEvent {
+	const classUID = 6003
+	activity, ok := activities[strings.ToLower(e.get("action"))]
+	if !ok {
+		activity.id, activity.name = 99, "Other"
+	}
+	s := e.severity()
+	o := ocsfEvent{
+		ActivityID:   activity.id,
+		ActivityName: activity.name,
+		CategoryUID:  6,
+		CategoryName: "Application Activity",
+		ClassUID:     classUID,
+		ClassName:    "API Activity",
+		TypeUID:      classUID*100 + activity.id,
+		SeverityID:   s.ocsf,
+		Severity:     s.name,
+		Time:         e.timestamp.UnixMilli(),
+		Message:      e.name(),
+		Metadata: ocsfMetadata{
+			Version: ocsfVersion,
+			UID:     e.id,
+			Labels:  e.tags,
+			Product: ocsfProduct{Name: product, VendorName: vendor},
+		},
+		API:      ocsfAPI{Operation: e.get("action")},
+		Unmapped: e.attrs,
+	}
+	if e.service != "" {
+		o.API.Service = &ocsfService{Name: e.service}
+	}
+	if user := (ocsfUser{UID: e.get("usr.id"), EmailAddr: e.get("usr.email"), Name: e.get("usr.name")}); user != (ocsfUser{}) {
+		o.Actor = &ocsfActor{User: user}
+	}
+	if method, path := e.get("http.method"), e.get("http.url_details.path"); method != "" || path != "" {
+		o.HTTPRequest = &ocsfHTTPRequest{HTTPMethod: method, URL: ocsfURL{Path: path}}
+	}
+	if ip := e.get("network.client.ip"); ip != "" {
+		o.SrcEndpoint = &ocsfEndpoint{IP: ip}
+	}
+	if resource := (ocsfResource{Type: e.get("asset.type"), Name: e.get("asset.name"), UID: e.get("asset.id")}); resource != (ocsfResource{}) {
+		o.Resources = []ocsfResource{resource}
+	}
+	return o
+}
+
+// syslog formats the event as an RFC 5424 message, with the event as JSON
+// as MSG.
+func (e event) syslog(hostname string, facility int) ([]byte, error) {
+	msg, err := json.Marshal(map[string]interface{}{
+		"id":         e.id,
+		"service":    e.service,
+		"tags":       e.tags,
+		"attributes": e.attrs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s - %s - ",
+		facility*8+e.severity().syslog,
+		e.timestamp.Format("2006-01-02T15:04:05.000Z07:00"),
+		syslogName(hostname, 255),
+		appName,
+		syslogName(e.get("evt.name"), 32),
+	)
+	return append([]byte(header), msg...), nil
+}
+
+// syslogName returns a header field of printable ASCII characters without
+// spaces, or the nil value.
+func syslogName(s string, max int) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > ' ' && r < 127 && b.Len() < max {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Sink receives the formatted events, one per call.
+type Sink interface {
+	Send(record []byte) error
+}
+
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// WriterSink returns a sink writing a record per line to a writer, such as a
+// file or the standard output.
+func WriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Send(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(append(record, '\n'))
+	return err
+}
+
+// SyslogSink sends records to a syslog collector, a datagram per record over
+// UDP and with octet counting framing over TCP, as of RFC 6587.
+type SyslogSink struct {
+	network string
+	address string
+	// Timeout is the timeout of connections and writes.
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialSyslog connects to a syslog collector over "tcp" or "udp".
+func DialSyslog(network, address string) (*SyslogSink, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+	s := &SyslogSink{network: network, address: address, Timeout: 10 * time.Second}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) connect() error {
+	conn, err := net.DialTimeout(s.network, s.address, s.Timeout)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", s.address, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// Send sends a record, reconnecting once when the connection was lost.
+func (s *SyslogSink) Send(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frame := record
+	if s.network[:3] == "tcp" {
+		frame = append([]byte(fmt.Sprintf("%d ", len(record))), record...)
+	}
+	if s.conn != nil {
+		if err := s.write(frame); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.write(frame)
+}
+
+// write writes a frame. A write timing out after part of the frame resumes
+// from the bytes already written, since writing the frame again would
+// corrupt the framing of the stream. Otherwise the error is returned, and
+// the frame sent again whole on a new connection.
+func (s *SyslogSink) write(frame []byte) error {
+	for {
+		if s.Timeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+		}
+		n, err := s.conn.Write(frame)
+		frame = frame[n:]
+		if err == nil {
+			return nil
+		}
+		var netErr net.Error
+		if n == 0 || len(frame) == 0 || !errors.As(err, &netErr) || !netErr.Timeout() {
+			return err
+		}
+	}
+}
+
+// Close closes the connection.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package audit
+
+import (
+	_context "context"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/tail"
+)
+
+// timeFormat is the format of the time filters of audit log searches.
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// searchSource is a tail source of the audit events matching a search.
+type searchSource struct {
+	api *datadogV2.AuditApi
+}
+
+func (s *searchSource) List(ctx _context.Context, query string, from, to time.Time, cursor string, limit int32) ([]tail.Item, string, error) {
+	filter := datadogV2.AuditLogsQueryFilter{
+		From: datadog.PtrString(from.UTC().Format(timeFormat)),
+		To:   datadog.PtrString(to.UTC().Format(timeFormat)),
+	}
+	if query != "" {
+		filter.Query = &query
+	}
+	page := datadogV2.AuditLogsQueryPageOptions{Limit: &limit}
+	if cursor != "" {
+		page.Cursor = &cursor
+	}
+	body := datadogV2.AuditLogsSearchEventsRequest{Filter: &filter, Page: &page, Sort: datadogV2.AUDITLOGSSORT_TIMESTAMP_ASCENDING.Ptr()}
+	resp, _, err := s.api.SearchAuditLogs(ctx, *datadogV2.NewSearchAuditLogsOptionalParameters().WithBody(body))
+	if err != nil {
+		return nil, "", fmt.Errorf("searching audit logs: %w", err)
+	}
+	items := make([]tail.Item, len(resp.Data))
+	for i, e := range resp.Data {
+		attributes := e.GetAttributes()
+		items[i] = tail.Item{ID: e.GetId(), Timestamp: attributes.GetTimestamp(), Value: e}
+	}
+	meta := resp.GetMeta()
+	return items, meta.Page.GetAfter(), nil
+}
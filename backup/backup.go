@@ -0,0 +1,517 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package backup exports the configuration of an org to a directory tree and
+// restores it in another org.
+//
+// Every object is written to its own file, <kind>/<id>.json or .yaml, with
+// sorted keys and the fields generated by the server, such as creation dates
+// or authors, stripped, so that exports of an unchanged org are identical
+// and diff well in git. The order of log pipelines and indexes is written to
+// a file per kind at the root of the tree.
+//
+// Restoring recreates the objects in dependency order and rewrites the
+// references between them, such as the monitors of SLOs and composite
+// monitors or the dashboards of dashboard lists, to the IDs of the objects
+// created. Objects that already exist in the org, matched by name or by the
+// fields that identify them, are updated instead, so that restoring a backup
+// twice does not duplicate them.
+package backup
+
+import (
+	"bytes"
+	_context "context"
+	"encoding/json"
+	"fmt"
+	_nethttp "net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/jsonwalk"
+)
+
+// Format is the format of the files of a backup.
+type Format string
+
+// List of Format.
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// Backup exports and restores the configuration of orgs.
+type Backup struct {
+	client *datadog.APIClient
+	// Kinds are the kinds of objects exported or restored, all kinds when
+	// empty. See Kinds.
+	Kinds []string
+	// Webhooks are the names of the webhooks exported, as the API does not
+	// list them.
+	Webhooks []string
+	// Format is the format of exported files, FormatJSON by default.
+	Format Format
+}
+
+// New returns a backup using the client. The keys and site of the org are
+// taken from the context of each call.
+func New(client *datadog.APIClient) *Backup {
+	return &Backup{client: client, Format: FormatJSON}
+}
+
+// Report is the outcome of an export or restore.
+type Report struct {
+	// Counts are the number of objects exported or restored by kind.
+	Counts map[string]int
+	// IDs are the IDs of the restored objects in the org by kind and ID in
+	// the backup.
+	IDs map[string]map[string]string
+	// Warnings are the objects, or parts of objects, that could not be
+	// exported or restored faithfully.
+	Warnings []string
+}
+
+func newReport() *Report {
+	return &Report{Counts: map[string]int{}, IDs: map[string]map[string]string{}}
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// object is an object of a kind, stripped of the fields generated by the
+// server.
+type object struct {
+	id   string
+	body map[string]interface{}
+}
+
+// Kinds returns the names of the kinds of objects, in restore order.
+func Kinds() []string {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = k.name
+	}
+	return names
+}
+
+func (b *Backup) selected() ([]*kind, error) {
+	if len(b.Kinds) == 0 {
+		return kinds, nil
+	}
+	var selected []*kind
+	for _, k := range kinds {
+		for _, name := range b.Kinds {
+			if k.name == name {
+				selected = append(selected, k)
+			}
+		}
+	}
+	for _, name := range b.Kinds {
+		if kindByName(name) == nil {
+			return nil, fmt.Errorf("unknown kind %q", name)
+		}
+	}
+	return selected, nil
+}
+
+// Export writes the configuration of the org to the directory. Files of
+// objects that no longer exist are removed.
+func (b *Backup) Export(ctx _context.Context, dir string) (*Report, error) {
+	selected, err := b.selected()
+	if err != nil {
+		return nil, err
+	}
+	report := newReport()
+	for _, k := range selected {
+		objects, err := k.export(ctx, b, report)
+		if err != nil {
+			return report, fmt.Errorf("exporting %s: %w", k.name, err)
+		}
+		if k.single {
+			if len(objects) == 0 {
+				continue
+			}
+			if err := b.writeFile(filepath.Join(dir, k.name), objects[0].body); err != nil {
+				return report, err
+			}
+			report.Counts[k.name] = 1
+			continue
+		}
+		kindDir := filepath.Join(dir, k.name)
+		if err := removeFiles(kindDir); err != nil {
+			return report, err
+		}
+		for _, o := range objects {
+			if err := b.writeFile(filepath.Join(kindDir, url.PathEscape(o.id)), o.body); err != nil {
+				return report, err
+			}
+		}
+		report.Counts[k.name] = len(objects)
+	}
+	return report, nil
+}
+
+// Restore recreates the configuration of the directory in the org, updating
+// the objects that exist in the org. Restoring stops at the first object that
+// fails.
+func (b *Backup) Restore(ctx _context.Context, dir string) (*Report, error) {
+	selected, err := b.selected()
+	if err != nil {
+		return nil, err
+	}
+	r := &restorer{b: b, report: newReport()}
+	for _, k := range selected {
+		objects, err := readObjects(dir, k)
+		if err != nil {
+			return r.report, err
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		r.kind = k
+		if err := r.index(ctx); err != nil {
+			return r.report, fmt.Errorf("listing %s: %w", k.name, err)
+		}
+		if err := k.restore(ctx, r, objects); err != nil {
+			return r.report, fmt.Errorf("restoring %s: %w", k.name, err)
+		}
+		r.report.Counts[k.name] = len(objects)
+	}
+	for _, fn := range r.pending {
+		if err := fn(ctx); err != nil {
+			return r.report, err
+		}
+	}
+	return r.report, nil
+}
+
+// restorer holds the state of a restore.
+type restorer struct {
+	b      *Backup
+	report *Report
+	kind   *kind
+	// existing are the IDs of the objects of the kind in the org by key.
+	existing map[string][]string
+	// pending are the updates run once every kind is restored.
+	pending []func(ctx _context.Context) error
+}
+
+// index lists the objects of the kind in the org, as they are exported, so
+// that match finds the objects of the backup that exist in the org.
+func (r *restorer) index(ctx _context.Context) error {
+	r.existing = nil
+	if r.kind.key == nil {
+		return nil
+	}
+	objects, err := r.kind.export(ctx, r.b, newReport())
+	if err != nil {
+		return err
+	}
+	r.existing = map[string][]string{}
+	for _, o := range objects {
+		key := r.kind.key(o)
+		r.existing[key] = append(r.existing[key], o.id)
+	}
+	return nil
+}
+
+// match returns the ID of the object of the org with the key of an object of
+// the backup, whose references must be remapped already. An object of the
+// org is matched once, so that duplicates of the backup stay duplicates.
+func (r *restorer) match(o object) (string, bool) {
+	key := r.kind.key(o)
+	ids := r.existing[key]
+	if len(ids) == 0 {
+		return "", false
+	}
+	r.existing[key] = ids[1:]
+	return ids[0], true
+}
+
+// created records the ID of a restored object.
+func (r *restorer) created(oldID, newID string) {
+	ids := r.report.IDs[r.kind.name]
+	if ids == nil {
+		ids = map[string]string{}
+		r.report.IDs[r.kind.name] = ids
+	}
+	ids[oldID] = newID
+}
+
+// lookup returns the ID in the org of an object of a kind restored before.
+func (r *restorer) lookup(kind, oldID string) (string, bool) {
+	newID, ok := r.report.IDs[kind][oldID]
+	return newID, ok
+}
+
+// remap returns the ID in the org of an object of a kind. Objects not
+// restored keep their ID, with a warning when the kind is restored.
+func (r *restorer) remap(kind, oldID, context string) string {
+	if newID, ok := r.lookup(kind, oldID); ok {
+		return newID
+	}
+	if r.restores(kind) {
+		r.report.warn("%s: %s %s is not in the backup", context, kind, oldID)
+	}
+	return oldID
+}
+
+func (r *restorer) restores(kind string) bool {
+	if len(r.b.Kinds) == 0 {
+		return true
+	}
+	for _, k := range r.b.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// remapNumber remaps a numeric ID.
+func (r *restorer) remapNumber(kind string, v interface{}, context string) interface{} {
+	newID := r.remap(kind, fmt.Sprint(v), context)
+	if n, err := strconv.ParseInt(newID, 10, 64); err == nil {
+		return n
+	}
+	return v
+}
+
+func (b *Backup) writeFile(path string, body map[string]interface{}) error {
+	var buf bytes.Buffer
+	var err error
+	switch b.Format {
+	case FormatYAML:
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err = enc.Encode(normalize(body)); err == nil {
+			err = enc.Close()
+		}
+		path += ".yaml"
+	case FormatJSON, "":
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(body)
+		path += ".json"
+	default:
+		return fmt.Errorf("unsupported format %q", b.Format)
+	}
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// removeFiles removes the JSON and YAML files of a directory.
+func removeFiles(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".json" || ext == ".yaml") {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readObjects reads the objects of a kind, sorted by file name.
+func readObjects(dir string, k *kind) ([]object, error) {
+	if k.single {
+		for _, ext := range []string{".json", ".yaml"} {
+			body, err := readFile(filepath.Join(dir, k.name+ext))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return []object{{body: body}}, nil
+		}
+		return nil, nil
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, k.name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".yaml") {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(e.Name(), ext))
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", k.name, e.Name(), err)
+		}
+		body, err := readFile(filepath.Join(dir, k.name, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object{id: id, body: body})
+	}
+	return objects, nil
+}
+
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if filepath.Ext(path) == ".yaml" {
+		err = yaml.Unmarshal(data, &body)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return body, nil
+}
+
+// toMap returns the JSON representation of a model as a map.
+func toMap(model interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// convert decodes the JSON representation of a body into a model.
+func convert(body interface{}, model interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, model)
+}
+
+// normalize converts JSON numbers to integers or floats, for YAML.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// strip deletes the fields at the dotted paths, descending into lists.
+func strip(v interface{}, paths ...string) {
+	for _, path := range paths {
+		head, rest, nested := strings.Cut(path, ".")
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if !nested {
+				delete(v, head)
+			} else if child, ok := v[head]; ok {
+				strip(child, rest)
+			}
+		case []interface{}:
+			for _, e := range v {
+				strip(e, path)
+			}
+		}
+	}
+}
+
+// stripAll deletes the fields with the name at any depth.
+func stripAll(v interface{}, name string) {
+	jsonwalk.Maps(v, func(m map[string]interface{}) { delete(m, name) })
+}
+
+// list returns the list of a field of a map.
+func list(m map[string]interface{}, key string) []interface{} {
+	l, _ := m[key].([]interface{})
+	return l
+}
+
+// field returns the map of a field of a map, nil when unset.
+func field(m map[string]interface{}, key string) map[string]interface{} {
+	f, _ := m[key].(map[string]interface{})
+	return f
+}
+
+// str returns a field of a map as a string.
+func str(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// exists tells whether a GET found an object, from its response.
+func exists(resp *_nethttp.Response, err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if resp != nil && resp.StatusCode == _nethttp.StatusNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+// mergeOrder orders the objects of an org by the order of a backup: the
+// objects of the backup present in the org come first, in order of the
+// backup, followed by the others in their current order.
+func mergeOrder(backup, current []string, remap func(string) string) []string {
+	present := map[string]bool{}
+	for _, id := range current {
+		present[id] = true
+	}
+	var order []string
+	seen := map[string]bool{}
+	for _, id := range backup {
+		if id = remap(id); present[id] && !seen[id] {
+			seen[id] = true
+			order = append(order, id)
+		}
+	}
+	for _, id := range current {
+		if !seen[id] {
+			order = append(order, id)
+		}
+	}
+	return order
+}
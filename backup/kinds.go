@@ -0,0 +1,1188 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package backup
+
+import (
+	_context "context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/jsonwalk"
+)
+
+// kind is a kind of object of the configuration of an org.
+type kind struct {
+	name string
+	// single kinds have one object, written to <name>.<format>.
+	single  bool
+	export  func(ctx _context.Context, b *Backup, report *Report) ([]object, error)
+	restore func(ctx _context.Context, r *restorer, objects []object) error
+	// key identifies an exported object across orgs, such as its name. Kinds
+	// without key are restored by ID or name already.
+	key func(o object) string
+}
+
+// kinds are the kinds of objects, in restore order: objects come after the
+// objects they reference. Synthetic tests come before the global variables
+// parsed from them, and are updated with the global variables they use once
+// these are restored.
+var kinds = []*kind{
+	{name: "roles", export: exportRoles, restore: restoreRoles},
+	{name: "logs_indexes", export: exportLogsIndexes, restore: restoreLogsIndexes},
+	{name: "logs_index_order", single: true, export: exportLogsIndexOrder, restore: restoreLogsIndexOrder},
+	{name: "logs_pipelines", export: exportLogsPipelines, restore: restoreLogsPipelines, key: byName},
+	{name: "logs_pipeline_order", single: true, export: exportLogsPipelineOrder, restore: restoreLogsPipelineOrder},
+	{name: "logs_archives", export: exportLogsArchives, restore: restoreLogsArchives, key: byName},
+	{name: "logs_metrics", export: exportLogsMetrics, restore: restoreLogsMetrics, key: byID},
+	{name: "monitors", export: exportMonitors, restore: restoreMonitors, key: byFields("type", "name")},
+	{name: "slos", export: exportSLOs, restore: restoreSLOs, key: byName},
+	{name: "slo_corrections", export: exportSLOCorrections, restore: restoreSLOCorrections, key: byFields("slo_id", "category", "start", "description")},
+	{name: "downtimes", export: exportDowntimes, restore: restoreDowntimes, key: byFields("monitor_id", "scope", "start", "message")},
+	{name: "dashboards", export: exportDashboards, restore: restoreDashboards, key: byFields("title")},
+	{name: "dashboard_lists", export: exportDashboardLists, restore: restoreDashboardLists, key: byName},
+	{name: "synthetics_tests", export: exportSyntheticsTests, restore: restoreSyntheticsTests, key: byFields("type", "name")},
+	{name: "synthetics_global_variables", export: exportGlobalVariables, restore: restoreGlobalVariables, key: byName},
+	{name: "security_filters", export: exportSecurityFilters, restore: restoreSecurityFilters, key: byName},
+	{name: "security_rules", export: exportSecurityRules, restore: restoreSecurityRules, key: byName},
+	{name: "webhooks", export: exportWebhooks, restore: restoreWebhooks},
+	{name: "service_definitions", export: exportServiceDefinitions, restore: restoreServiceDefinitions},
+}
+
+func byID(o object) string {
+	return o.id
+}
+
+func byName(o object) string {
+	return str(o.body, "name")
+}
+
+// byFields returns a key made of the values of fields.
+func byFields(names ...string) func(o object) string {
+	return func(o object) string {
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = fmt.Sprint(normalize(o.body[name]))
+		}
+		return strings.Join(values, "\x00")
+	}
+}
+
+func kindByName(name string) *kind {
+	for _, k := range kinds {
+		if k.name == name {
+			return k
+		}
+	}
+	return nil
+}
+
+// newObject returns the object of a model stripped of the fields at the
+// paths.
+func newObject(id string, model interface{}, paths ...string) (object, error) {
+	body, err := toMap(model)
+	if err != nil {
+		return object{}, err
+	}
+	strip(body, paths...)
+	return object{id: id, body: body}, nil
+}
+
+// attributesObject returns the object of the attributes of a JSON:API
+// resource, stripped of the fields at the paths.
+func attributesObject(id string, model interface{}, paths ...string) (object, error) {
+	o, err := newObject(id, model)
+	if err != nil {
+		return object{}, err
+	}
+	o.body = field(o.body, "attributes")
+	if o.body == nil {
+		o.body = map[string]interface{}{}
+	}
+	strip(o.body, paths...)
+	return o, nil
+}
+
+// resource returns the body of a JSON:API request for the attributes.
+func resource(resourceType string, attributes map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"data": map[string]interface{}{"type": resourceType, "attributes": attributes}}
+}
+
+func exportRoles(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV2.NewRolesApi(b.client)
+	permissions, _, err := api.ListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for _, p := range permissions.Data {
+		names[p.GetId()] = p.Attributes.GetName()
+	}
+	var objects []object
+	for page := int64(0); ; page++ {
+		resp, _, err := api.ListRoles(ctx, *datadogV2.NewListRolesOptionalParameters().WithPageSize(100).WithPageNumber(page))
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range resp.Data {
+			body, err := toMap(role)
+			if err != nil {
+				return nil, err
+			}
+			granted := []string{}
+			for _, p := range list(field(field(body, "relationships"), "permissions"), "data") {
+				id := str(p.(map[string]interface{}), "id")
+				if name, ok := names[id]; ok {
+					granted = append(granted, name)
+				} else {
+					report.warn("roles/%s: unknown permission %s", role.GetId(), id)
+				}
+			}
+			sort.Strings(granted)
+			objects = append(objects, object{id: role.GetId(), body: map[string]interface{}{
+				"name":        role.Attributes.GetName(),
+				"permissions": granted,
+			}})
+		}
+		if len(resp.Data) < 100 {
+			return objects, nil
+		}
+	}
+}
+
+// restoreRoles creates the roles missing in the org, by name, with their
+// permissions. Roles existing in the org are kept as is.
+func restoreRoles(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV2.NewRolesApi(r.b.client)
+	permissions, _, err := api.ListPermissions(ctx)
+	if err != nil {
+		return err
+	}
+	ids := map[string]string{}
+	for _, p := range permissions.Data {
+		ids[p.Attributes.GetName()] = p.GetId()
+	}
+	for _, o := range objects {
+		name := str(o.body, "name")
+		existing, _, err := api.ListRoles(ctx, *datadogV2.NewListRolesOptionalParameters().WithFilter(name))
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		found := false
+		for _, role := range existing.Data {
+			if role.Attributes.GetName() == name {
+				r.created(o.id, role.GetId())
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		var data []interface{}
+		for _, p := range list(o.body, "permissions") {
+			id, ok := ids[fmt.Sprint(p)]
+			if !ok {
+				r.report.warn("roles/%s: unknown permission %s", o.id, p)
+				continue
+			}
+			data = append(data, map[string]interface{}{"id": id, "type": "permissions"})
+		}
+		body := resource("roles", map[string]interface{}{"name": name})
+		field(body, "data")["relationships"] = map[string]interface{}{"permissions": map[string]interface{}{"data": data}}
+		var req datadogV2.RoleCreateRequest
+		if err := convert(body, &req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		created, _, err := api.CreateRole(ctx, req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, created.Data.GetId())
+	}
+	return nil
+}
+
+func exportLogsIndexes(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	resp, _, err := datadogV1.NewLogsIndexesApi(b.client).ListLogIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, index := range resp.Indexes {
+		o, err := newObject(index.GetName(), index, "is_rate_limited")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// restoreLogsIndexes creates the indexes missing in the org and updates the
+// others.
+func restoreLogsIndexes(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewLogsIndexesApi(r.b.client)
+	for _, o := range objects {
+		_, resp, err := api.GetLogsIndex(ctx, o.id)
+		found, err := exists(resp, err)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		if found {
+			delete(o.body, "name")
+			var req datadogV1.LogsIndexUpdateRequest
+			if err := convert(o.body, &req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateLogsIndex(ctx, o.id, req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+		} else {
+			var index datadogV1.LogsIndex
+			if err := convert(o.body, &index); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.CreateLogsIndex(ctx, index); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+		}
+		r.created(o.id, o.id)
+	}
+	return nil
+}
+
+func exportLogsIndexOrder(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	order, _, err := datadogV1.NewLogsIndexesApi(b.client).GetLogsIndexOrder(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := newObject("", order)
+	return []object{o}, err
+}
+
+func restoreLogsIndexOrder(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewLogsIndexesApi(r.b.client)
+	current, _, err := api.GetLogsIndexOrder(ctx)
+	if err != nil {
+		return err
+	}
+	order := mergeOrder(stringList(list(objects[0].body, "index_names")), current.IndexNames, func(name string) string { return name })
+	_, _, err = api.UpdateLogsIndexOrder(ctx, *datadogV1.NewLogsIndexesOrder(order))
+	return err
+}
+
+func exportLogsPipelines(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	pipelines, _, err := datadogV1.NewLogsPipelinesApi(b.client).ListLogsPipelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, p := range pipelines {
+		// Integration pipelines are installed with their integration.
+		if p.GetIsReadOnly() {
+			continue
+		}
+		o, err := newObject(p.GetId(), p, "id", "is_read_only", "type")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func restoreLogsPipelines(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewLogsPipelinesApi(r.b.client)
+	for _, o := range objects {
+		var p datadogV1.LogsPipeline
+		if err := convert(o.body, &p); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		var saved datadogV1.LogsPipeline
+		var err error
+		if id, ok := r.match(o); ok {
+			saved, _, err = api.UpdateLogsPipeline(ctx, id, p)
+		} else {
+			saved, _, err = api.CreateLogsPipeline(ctx, p)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, saved.GetId())
+	}
+	return nil
+}
+
+func exportLogsPipelineOrder(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	order, _, err := datadogV1.NewLogsPipelinesApi(b.client).GetLogsPipelineOrder(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := newObject("", order)
+	return []object{o}, err
+}
+
+func restoreLogsPipelineOrder(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewLogsPipelinesApi(r.b.client)
+	current, _, err := api.GetLogsPipelineOrder(ctx)
+	if err != nil {
+		return err
+	}
+	order := mergeOrder(stringList(list(objects[0].body, "pipeline_ids")), current.PipelineIds, func(id string) string {
+		if newID, ok := r.lookup("logs_pipelines", id); ok {
+			return newID
+		}
+		return id
+	})
+	_, _, err = api.UpdateLogsPipelineOrder(ctx, *datadogV1.NewLogsPipelinesOrder(order))
+	return err
+}
+
+func exportLogsArchives(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	resp, _, err := datadogV2.NewLogsArchivesApi(b.client).ListLogsArchives(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, a := range resp.Data {
+		o, err := attributesObject(a.GetId(), a, "state")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func restoreLogsArchives(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV2.NewLogsArchivesApi(r.b.client)
+	for _, o := range objects {
+		var req datadogV2.LogsArchiveCreateRequest
+		if err := convert(resource("archives", o.body), &req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		var saved datadogV2.LogsArchive
+		var err error
+		if id, ok := r.match(o); ok {
+			saved, _, err = api.UpdateLogsArchive(ctx, id, req)
+		} else {
+			saved, _, err = api.CreateLogsArchive(ctx, req)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, saved.Data.GetId())
+	}
+	return nil
+}
+
+func exportLogsMetrics(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	resp, _, err := datadogV2.NewLogsMetricsApi(b.client).ListLogsMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, m := range resp.Data {
+		o, err := attributesObject(m.GetId(), m)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func restoreLogsMetrics(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV2.NewLogsMetricsApi(r.b.client)
+	for _, o := range objects {
+		body := resource("logs_metrics", o.body)
+		if id, ok := r.match(o); ok {
+			var req datadogV2.LogsMetricUpdateRequest
+			if err := convert(body, &req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateLogsMetric(ctx, id, req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			r.created(o.id, id)
+			continue
+		}
+		field(body, "data")["id"] = o.id
+		var req datadogV2.LogsMetricCreateRequest
+		if err := convert(body, &req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		created, _, err := api.CreateLogsMetric(ctx, req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, created.Data.GetId())
+	}
+	return nil
+}
+
+func exportMonitors(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV1.NewMonitorsApi(b.client)
+	var objects []object
+	for page := int64(0); ; page++ {
+		monitors, _, err := api.ListMonitors(ctx, *datadogV1.NewListMonitorsOptionalParameters().WithPage(page).WithPageSize(1000))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range monitors {
+			// Synthetic monitors are created with their test.
+			if m.GetType() == datadogV1.MONITORTYPE_SYNTHETICS_ALERT {
+				continue
+			}
+			o, err := newObject(strconv.FormatInt(m.GetId(), 10), m,
+				"id", "created", "modified", "creator", "deleted", "overall_state", "overall_state_modified",
+				"matching_downtimes", "org_id", "multi", "state")
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, o)
+		}
+		if len(monitors) < 1000 {
+			return objects, nil
+		}
+	}
+}
+
+// monitorIDs matches the monitor IDs of the queries of composite monitors.
+var monitorIDs = regexp.MustCompile(`\b\d+\b`)
+
+// restoreMonitors creates composite monitors last, with the IDs of the
+// monitors they compose.
+func restoreMonitors(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewMonitorsApi(r.b.client)
+	objects, err := sortMonitors(objects)
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		context := "monitors/" + o.id
+		r.remapRoles(o.body, context)
+		if isComposite(o) {
+			o.body["query"] = monitorIDs.ReplaceAllStringFunc(str(o.body, "query"), func(id string) string {
+				return r.remap("monitors", id, context)
+			})
+		}
+		if id, ok := r.match(o); ok {
+			monitorID, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			var req datadogV1.MonitorUpdateRequest
+			if err := convert(o.body, &req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateMonitor(ctx, monitorID, req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			r.created(o.id, id)
+			continue
+		}
+		var m datadogV1.Monitor
+		if err := convert(o.body, &m); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		created, _, err := api.CreateMonitor(ctx, m)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, strconv.FormatInt(created.GetId(), 10))
+	}
+	return nil
+}
+
+func isComposite(o object) bool {
+	return str(o.body, "type") == string(datadogV1.MONITORTYPE_COMPOSITE)
+}
+
+// sortMonitors orders composite monitors after the monitors they compose,
+// which can be composite monitors too. The order of the files is kept
+// otherwise.
+func sortMonitors(objects []object) ([]object, error) {
+	composites := map[string]object{}
+	sorted := make([]object, 0, len(objects))
+	for _, o := range objects {
+		if isComposite(o) {
+			composites[o.id] = o
+		} else {
+			sorted = append(sorted, o)
+		}
+	}
+	const visiting, done = 1, 2
+	state := map[string]int{}
+	var visit func(o object) error
+	visit = func(o object) error {
+		switch state[o.id] {
+		case visiting:
+			return fmt.Errorf("%s: composite monitors compose each other", o.id)
+		case done:
+			return nil
+		}
+		state[o.id] = visiting
+		for _, id := range monitorIDs.FindAllString(str(o.body, "query"), -1) {
+			if c, ok := composites[id]; ok {
+				if err := visit(c); err != nil {
+					return err
+				}
+			}
+		}
+		state[o.id] = done
+		sorted = append(sorted, o)
+		return nil
+	}
+	for _, o := range objects {
+		if isComposite(o) {
+			if err := visit(o); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sorted, nil
+}
+
+// remapRoles remaps the restricted roles of an object.
+func (r *restorer) remapRoles(body map[string]interface{}, context string) {
+	for i, id := range list(body, "restricted_roles") {
+		list(body, "restricted_roles")[i] = r.remap("roles", fmt.Sprint(id), context)
+	}
+}
+
+func exportSLOs(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV1.NewServiceLevelObjectivesApi(b.client)
+	var objects []object
+	for offset := int64(0); ; offset += 1000 {
+		resp, _, err := api.ListSLOs(ctx, *datadogV1.NewListSLOsOptionalParameters().WithLimit(1000).WithOffset(offset))
+		if err != nil {
+			return nil, err
+		}
+		for _, slo := range resp.Data {
+			o, err := newObject(slo.GetId(), slo, "id", "created_at", "modified_at", "creator")
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, o)
+		}
+		if len(resp.Data) < 1000 {
+			return objects, nil
+		}
+	}
+}
+
+func restoreSLOs(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewServiceLevelObjectivesApi(r.b.client)
+	for _, o := range objects {
+		context := "slos/" + o.id
+		for i, id := range list(o.body, "monitor_ids") {
+			list(o.body, "monitor_ids")[i] = r.remapNumber("monitors", id, context)
+		}
+		if id, ok := r.match(o); ok {
+			var slo datadogV1.ServiceLevelObjective
+			if err := convert(o.body, &slo); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateSLO(ctx, id, slo); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			r.created(o.id, id)
+			continue
+		}
+		var req datadogV1.ServiceLevelObjectiveRequest
+		if err := convert(o.body, &req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		created, _, err := api.CreateSLO(ctx, req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		if len(created.Data) == 0 {
+			return fmt.Errorf("%s: no SLO created", o.id)
+		}
+		r.created(o.id, created.Data[0].GetId())
+	}
+	return nil
+}
+
+func exportSLOCorrections(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV1.NewServiceLevelObjectiveCorrectionsApi(b.client)
+	var objects []object
+	for offset := int64(0); ; offset += 1000 {
+		resp, _, err := api.ListSLOCorrection(ctx, *datadogV1.NewListSLOCorrectionOptionalParameters().WithLimit(1000).WithOffset(offset))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range resp.Data {
+			o, err := attributesObject(c.GetId(), c, "creator", "modifier", "created_at", "modified_at")
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, o)
+		}
+		if len(resp.Data) < 1000 {
+			return objects, nil
+		}
+	}
+}
+
+func restoreSLOCorrections(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewServiceLevelObjectiveCorrectionsApi(r.b.client)
+	for _, o := range objects {
+		o.body["slo_id"] = r.remap("slos", str(o.body, "slo_id"), "slo_corrections/"+o.id)
+		if id, ok := r.match(o); ok {
+			var req datadogV1.SLOCorrectionUpdateRequest
+			if err := convert(resource("correction", o.body), &req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateSLOCorrection(ctx, id, req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			r.created(o.id, id)
+			continue
+		}
+		var req datadogV1.SLOCorrectionCreateRequest
+		if err := convert(resource("correction", o.body), &req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		created, _, err := api.CreateSLOCorrection(ctx, req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, created.Data.GetId())
+	}
+	return nil
+}
+
+func exportDowntimes(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	downtimes, _, err := datadogV1.NewDowntimesApi(b.client).ListDowntimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, d := range downtimes {
+		if d.Canceled.Get() != nil {
+			continue
+		}
+		o, err := newObject(strconv.FormatInt(d.GetId(), 10), d,
+			"id", "active", "active_child", "canceled", "creator_id", "updater_id", "created", "modified",
+			"downtime_type", "parent_id")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func restoreDowntimes(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewDowntimesApi(r.b.client)
+	for _, o := range objects {
+		if id, ok := o.body["monitor_id"]; ok && id != nil {
+			o.body["monitor_id"] = r.remapNumber("monitors", id, "downtimes/"+o.id)
+		}
+		var d datadogV1.Downtime
+		if err := convert(o.body, &d); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		if id, ok := r.match(o); ok {
+			downtimeID, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateDowntime(ctx, downtimeID, d); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			r.created(o.id, id)
+			continue
+		}
+		created, _, err := api.CreateDowntime(ctx, d)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, strconv.FormatInt(created.GetId(), 10))
+	}
+	return nil
+}
+
+func exportDashboards(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV1.NewDashboardsApi(b.client)
+	summary, _, err := api.ListDashboards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, s := range summary.Dashboards {
+		dashboard, _, err := api.GetDashboard(ctx, s.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.GetId(), err)
+		}
+		o, err := newObject(s.GetId(), dashboard, "id", "author_handle", "author_name", "created_at", "modified_at", "url")
+		if err != nil {
+			return nil, err
+		}
+		stripAll(o.body["widgets"], "id")
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// restoreDashboards remaps the SLOs and monitors of the widgets.
+func restoreDashboards(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewDashboardsApi(r.b.client)
+	for _, o := range objects {
+		context := "dashboards/" + o.id
+		jsonwalk.Maps(o.body["widgets"], func(m map[string]interface{}) {
+			if id, ok := m["slo_id"].(string); ok {
+				m["slo_id"] = r.remap("slos", id, context)
+			}
+			if id, ok := m["alert_id"].(string); ok {
+				m["alert_id"] = r.remap("monitors", id, context)
+			}
+		})
+		var d datadogV1.Dashboard
+		if err := convert(o.body, &d); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		var saved datadogV1.Dashboard
+		var err error
+		if id, ok := r.match(o); ok {
+			saved, _, err = api.UpdateDashboard(ctx, id, d)
+		} else {
+			saved, _, err = api.CreateDashboard(ctx, d)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, saved.GetId())
+	}
+	return nil
+}
+
+func exportDashboardLists(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	resp, _, err := datadogV1.NewDashboardListsApi(b.client).ListDashboardLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := datadogV2.NewDashboardListsApi(b.client)
+	var objects []object
+	for _, l := range resp.DashboardLists {
+		// Automatic lists, such as the list of integration dashboards, are
+		// maintained by Datadog.
+		if l.GetType() != "manual_dashboard_list" {
+			continue
+		}
+		listItems, _, err := items.GetDashboardListItems(ctx, l.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", l.GetId(), err)
+		}
+		dashboards := []interface{}{}
+		for _, item := range listItems.Dashboards {
+			dashboards = append(dashboards, map[string]interface{}{"id": item.GetId(), "type": string(item.GetType())})
+		}
+		objects = append(objects, object{id: strconv.FormatInt(l.GetId(), 10), body: map[string]interface{}{
+			"name":       l.GetName(),
+			"dashboards": dashboards,
+		}})
+	}
+	return objects, nil
+}
+
+// restoreDashboardLists remaps the custom dashboards of the lists, which
+// replace the dashboards of the lists that exist.
+func restoreDashboardLists(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewDashboardListsApi(r.b.client)
+	items := datadogV2.NewDashboardListsApi(r.b.client)
+	for _, o := range objects {
+		var listID int64
+		if id, ok := r.match(o); ok {
+			n, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			listID = n
+		} else {
+			created, _, err := api.CreateDashboardList(ctx, *datadogV1.NewDashboardList(str(o.body, "name")))
+			if err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			listID = created.GetId()
+		}
+		r.created(o.id, strconv.FormatInt(listID, 10))
+
+		var dashboards []datadogV2.DashboardListItemRequest
+		for _, item := range list(o.body, "dashboards") {
+			item := item.(map[string]interface{})
+			id, dashboardType := str(item, "id"), datadogV2.DashboardType(str(item, "type"))
+			if dashboardType == datadogV2.DASHBOARDTYPE_CUSTOM_TIMEBOARD || dashboardType == datadogV2.DASHBOARDTYPE_CUSTOM_SCREENBOARD {
+				id = r.remap("dashboards", id, "dashboard_lists/"+o.id)
+			}
+			dashboards = append(dashboards, *datadogV2.NewDashboardListItemRequest(id, dashboardType))
+		}
+		req := datadogV2.NewDashboardListUpdateItemsRequest()
+		req.SetDashboards(dashboards)
+		if _, _, err := items.UpdateDashboardListItems(ctx, listID, *req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+	}
+	return nil
+}
+
+func exportGlobalVariables(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	resp, _, err := datadogV1.NewSyntheticsApi(b.client).ListGlobalVariables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, v := range resp.Variables {
+		if v.Value.GetSecure() && v.Value.GetValue() == "" {
+			report.warn("synthetics_global_variables/%s: the value of secure variable %s is not exported", v.GetId(), v.GetName())
+		}
+		o, err := newObject(v.GetId(), v, "id")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// restoreGlobalVariables remaps the tests variables are parsed from.
+func restoreGlobalVariables(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewSyntheticsApi(r.b.client)
+	for _, o := range objects {
+		context := "synthetics_global_variables/" + o.id
+		r.remapRoles(field(o.body, "attributes"), context)
+		if id := str(o.body, "parse_test_public_id"); id != "" {
+			o.body["parse_test_public_id"] = r.remap("synthetics_tests", id, context)
+		}
+		var v datadogV1.SyntheticsGlobalVariable
+		if err := convert(o.body, &v); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		var saved datadogV1.SyntheticsGlobalVariable
+		var err error
+		if id, ok := r.match(o); ok {
+			saved, _, err = api.EditGlobalVariable(ctx, id, v)
+		} else {
+			saved, _, err = api.CreateGlobalVariable(ctx, v)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, saved.GetId())
+	}
+	return nil
+}
+
+func exportSyntheticsTests(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV1.NewSyntheticsApi(b.client)
+	var objects []object
+	for page := 0; ; page++ {
+		resp, _, err := api.ListTests(ctx, *datadogV1.NewListTestsOptionalParameters().WithPageSize("100").WithPageNumber(strconv.Itoa(page)))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range resp.Tests {
+			var test interface{}
+			if t.GetType() == datadogV1.SYNTHETICSTESTDETAILSTYPE_BROWSER {
+				test, _, err = api.GetBrowserTest(ctx, t.GetPublicId())
+			} else {
+				test, _, err = api.GetAPITest(ctx, t.GetPublicId())
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", t.GetPublicId(), err)
+			}
+			o, err := newObject(t.GetPublicId(), test, "public_id", "monitor_id", "creator", "created_at", "modified_at")
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, o)
+		}
+		if len(resp.Tests) < 100 {
+			return objects, nil
+		}
+	}
+}
+
+// restoreSyntheticsTests restores the tests before the global variables
+// parsed from them. When global variables are restored too, the tests are
+// saved without the global variables they use, and updated with them once
+// the variables are restored.
+func restoreSyntheticsTests(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewSyntheticsApi(r.b.client)
+	deferred := r.restores("synthetics_global_variables")
+	for _, o := range objects {
+		o := o
+		context := "synthetics_tests/" + o.id
+		config := field(o.body, "config")
+		var variables, globals []interface{}
+		for _, v := range list(config, "configVariables") {
+			m := v.(map[string]interface{})
+			switch {
+			case str(m, "type") != "global" || str(m, "id") == "":
+				variables = append(variables, v)
+			case deferred:
+				globals = append(globals, v)
+			default:
+				m["id"] = r.remap("synthetics_global_variables", str(m, "id"), context)
+				variables = append(variables, v)
+			}
+		}
+		if len(globals) > 0 {
+			config["configVariables"] = variables
+		}
+		id, _ := r.match(o)
+		publicID, err := saveSyntheticsTest(ctx, api, id, o.body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, publicID)
+		if len(globals) == 0 {
+			continue
+		}
+		r.pending = append(r.pending, func(ctx _context.Context) error {
+			for _, v := range globals {
+				m := v.(map[string]interface{})
+				m["id"] = r.remap("synthetics_global_variables", str(m, "id"), context)
+			}
+			config["configVariables"] = append(variables, globals...)
+			if _, err := saveSyntheticsTest(ctx, api, publicID, o.body); err != nil {
+				return fmt.Errorf("restoring the global variables of synthetics_tests/%s: %w", o.id, err)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// saveSyntheticsTest creates a test, or updates the test with the public ID
+// when set, and returns its public ID.
+func saveSyntheticsTest(ctx _context.Context, api *datadogV1.SyntheticsApi, publicID string, body map[string]interface{}) (string, error) {
+	if str(body, "type") == string(datadogV1.SYNTHETICSTESTDETAILSTYPE_BROWSER) {
+		var test datadogV1.SyntheticsBrowserTest
+		if err := convert(body, &test); err != nil {
+			return "", err
+		}
+		if publicID != "" {
+			_, _, err := api.UpdateBrowserTest(ctx, publicID, test)
+			return publicID, err
+		}
+		created, _, err := api.CreateSyntheticsBrowserTest(ctx, test)
+		return created.GetPublicId(), err
+	}
+	var test datadogV1.SyntheticsAPITest
+	if err := convert(body, &test); err != nil {
+		return "", err
+	}
+	if publicID != "" {
+		_, _, err := api.UpdateAPITest(ctx, publicID, test)
+		return publicID, err
+	}
+	created, _, err := api.CreateSyntheticsAPITest(ctx, test)
+	return created.GetPublicId(), err
+}
+
+func exportSecurityFilters(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	resp, _, err := datadogV2.NewSecurityMonitoringApi(b.client).ListSecurityFilters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, f := range resp.Data {
+		if f.Attributes.GetIsBuiltin() {
+			continue
+		}
+		o, err := attributesObject(f.GetId(), f, "is_builtin", "version")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func restoreSecurityFilters(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV2.NewSecurityMonitoringApi(r.b.client)
+	for _, o := range objects {
+		if id, ok := r.match(o); ok {
+			var req datadogV2.SecurityFilterUpdateRequest
+			if err := convert(resource("security_filters", o.body), &req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateSecurityFilter(ctx, id, req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			r.created(o.id, id)
+			continue
+		}
+		var req datadogV2.SecurityFilterCreateRequest
+		if err := convert(resource("security_filters", o.body), &req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		created, _, err := api.CreateSecurityFilter(ctx, req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, created.Data.GetId())
+	}
+	return nil
+}
+
+func exportSecurityRules(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV2.NewSecurityMonitoringApi(b.client)
+	var objects []object
+	for page := int64(0); ; page++ {
+		resp, _, err := api.ListSecurityMonitoringRules(ctx, *datadogV2.NewListSecurityMonitoringRulesOptionalParameters().WithPageSize(100).WithPageNumber(page))
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range resp.Data {
+			body, err := toMap(rule)
+			if err != nil {
+				return nil, err
+			}
+			// Default rules are maintained by Datadog.
+			if body["isDefault"] == true {
+				continue
+			}
+			id := str(body, "id")
+			strip(body, "id", "createdAt", "creationAuthorId", "updateAuthorId", "version", "isDefault", "isDeleted", "isDeprecated", "defaultTags")
+			objects = append(objects, object{id: id, body: body})
+		}
+		if len(resp.Data) < 100 {
+			return objects, nil
+		}
+	}
+}
+
+func restoreSecurityRules(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV2.NewSecurityMonitoringApi(r.b.client)
+	for _, o := range objects {
+		if id, ok := r.match(o); ok {
+			var payload datadogV2.SecurityMonitoringRuleUpdatePayload
+			if err := convert(o.body, &payload); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			if _, _, err := api.UpdateSecurityMonitoringRule(ctx, id, payload); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			r.created(o.id, id)
+			continue
+		}
+		var payload datadogV2.SecurityMonitoringRuleCreatePayload
+		if err := convert(o.body, &payload); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		created, _, err := api.CreateSecurityMonitoringRule(ctx, payload)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		body, err := toMap(created)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, str(body, "id"))
+	}
+	return nil
+}
+
+func exportWebhooks(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	api := datadogV1.NewWebhooksIntegrationApi(b.client)
+	var objects []object
+	for _, name := range b.Webhooks {
+		webhook, _, err := api.GetWebhooksIntegration(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		o, err := newObject(name, webhook)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// restoreWebhooks creates the webhooks missing in the org and updates the
+// others.
+func restoreWebhooks(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV1.NewWebhooksIntegrationApi(r.b.client)
+	for _, o := range objects {
+		_, resp, err := api.GetWebhooksIntegration(ctx, o.id)
+		found, err := exists(resp, err)
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		if found {
+			var req datadogV1.WebhooksIntegrationUpdateRequest
+			if err := convert(o.body, &req); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			_, _, err = api.UpdateWebhooksIntegration(ctx, o.id, req)
+		} else {
+			var webhook datadogV1.WebhooksIntegration
+			if err := convert(o.body, &webhook); err != nil {
+				return fmt.Errorf("%s: %w", o.id, err)
+			}
+			_, _, err = api.CreateWebhooksIntegration(ctx, webhook)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, o.id)
+	}
+	return nil
+}
+
+func exportServiceDefinitions(ctx _context.Context, b *Backup, report *Report) ([]object, error) {
+	resp, _, err := datadogV2.NewServiceDefinitionApi(b.client).ListServiceDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []object
+	for _, d := range resp.Data {
+		o, err := attributesObject("", d)
+		if err != nil {
+			return nil, err
+		}
+		schema := field(o.body, "schema")
+		if schema == nil {
+			report.warn("service_definitions: a service definition of type %s has no schema", d.GetType())
+			continue
+		}
+		objects = append(objects, object{id: str(schema, "dd-service"), body: schema})
+	}
+	return objects, nil
+}
+
+func restoreServiceDefinitions(ctx _context.Context, r *restorer, objects []object) error {
+	api := datadogV2.NewServiceDefinitionApi(r.b.client)
+	for _, o := range objects {
+		var req datadogV2.ServiceDefinitionsCreateRequest
+		if err := convert(o.body, &req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		if _, _, err := api.CreateOrUpdateServiceDefinitions(ctx, req); err != nil {
+			return fmt.Errorf("%s: %w", o.id, err)
+		}
+		r.created(o.id, o.id)
+	}
+	return nil
+}
+
+// stringList returns the values of a list as strings.
+func stringList(values []interface{}) []string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return s
+}
@@ -0,0 +1,357 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package cardinality advises on the tags of custom metrics.
+//
+// An Advisor ranks metrics by ingested and indexed volume, and finds the tag
+// keys driving their cardinality that no query uses. The queries are those of
+// dashboard widgets and metric monitors, and the tags Datadog reports as
+// actively queried. For each metric with unused tags, it proposes a tag
+// configuration allowing the used tags only, with the output series estimated
+// by Datadog, and can apply the proposals. Metrics queried without any tag
+// get no proposal unless ProposeUntagged is set, nor do metrics of a type tag
+// configurations do not support.
+package cardinality
+
+import (
+	_context "context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+const pageSize = 100
+
+// Tag is a tag key of a metric.
+type Tag struct {
+	Key string
+	// Values is the number of values of the key.
+	Values int
+	Used   bool
+}
+
+// Metric is the advice on a metric.
+type Metric struct {
+	Name string
+	Type datadogV2.MetricTagConfigurationMetricTypes
+	// Ingested and Indexed are the volumes of the metric, in series.
+	Ingested int64
+	Indexed  int64
+	// Configured is true when the metric has a tag configuration, of
+	// ConfiguredTags.
+	Configured     bool
+	ConfiguredTags []string
+	// Queried is true when a query uses the metric.
+	Queried bool
+	// Tags are the tag keys of the metric, by decreasing number of values.
+	Tags []Tag
+	// Allowlist is the tag configuration proposed, nil when there is no
+	// proposal.
+	Allowlist []string
+	// Estimated is the estimated output series of the proposal, and
+	// Savings the indexed series it saves.
+	Estimated int64
+	Savings   int64
+}
+
+// Unused returns the keys of the tags no query uses.
+func (m Metric) Unused() []string {
+	var keys []string
+	for _, t := range m.Tags {
+		if !t.Used {
+			keys = append(keys, t.Key)
+		}
+	}
+	return keys
+}
+
+// Report is the advice on the metrics of an org, by decreasing indexed
+// volume.
+type Report struct {
+	Metrics []Metric
+}
+
+// Proposals returns the metrics with a proposal, by decreasing savings.
+func (r *Report) Proposals() []Metric {
+	var proposals []Metric
+	for _, m := range r.Metrics {
+		if m.Allowlist != nil {
+			proposals = append(proposals, m)
+		}
+	}
+	sort.SliceStable(proposals, func(i, j int) bool {
+		return proposals[i].Savings > proposals[j].Savings
+	})
+	return proposals
+}
+
+// WriteText writes the report as a table, followed by the proposals.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tINGESTED\tINDEXED\tRATIO\tUNUSED TAGS")
+	for _, m := range r.Metrics {
+		ratio := "-"
+		if m.Indexed > 0 {
+			ratio = fmt.Sprintf("%.1f", float64(m.Ingested)/float64(m.Indexed))
+		}
+		unused := strings.Join(m.Unused(), ", ")
+		switch {
+		case !m.Queried:
+			unused = "(not queried)"
+		case unused == "":
+			unused = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", m.Name, m.Ingested, m.Indexed, ratio, unused)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	proposals := r.Proposals()
+	if len(proposals) == 0 {
+		_, err := fmt.Fprintln(w, "\nNo proposals.")
+		return err
+	}
+	fmt.Fprintln(w, "\nProposals:")
+	var total int64
+	for _, m := range proposals {
+		total += m.Savings
+		fmt.Fprintf(w, "  %s: allow %s, %d -> %d series (-%d)\n", m.Name, allowlist(m.Allowlist), m.Indexed, m.Estimated, m.Savings)
+	}
+	_, err := fmt.Fprintf(w, "\n%d proposal(s) saving %d indexed series.\n", len(proposals), total)
+	return err
+}
+
+func allowlist(tags []string) string {
+	if len(tags) == 0 {
+		return "no tags"
+	}
+	return strings.Join(tags, ", ")
+}
+
+// Advisor advises on the tags of metrics.
+type Advisor struct {
+	metrics    *datadogV2.MetricsApi
+	metadata   *datadogV1.MetricsApi
+	dashboards *datadogV1.DashboardsApi
+	monitors   *datadogV1.MonitorsApi
+
+	// WindowSeconds is the window the tags actively queried are looked up
+	// in, 30 days by default.
+	WindowSeconds int64
+	// Metrics filters the metrics advised on, all when nil.
+	Metrics func(name string) bool
+	// ProposeUntagged proposes to allow no tags for the metrics no query
+	// groups or filters by a tag.
+	ProposeUntagged bool
+}
+
+// NewAdvisor returns an advisor looking up the tags actively queried in the
+// last 30 days.
+func NewAdvisor(client *datadog.APIClient) *Advisor {
+	return &Advisor{
+		metrics:       datadogV2.NewMetricsApi(client),
+		metadata:      datadogV1.NewMetricsApi(client),
+		dashboards:    datadogV1.NewDashboardsApi(client),
+		monitors:      datadogV1.NewMonitorsApi(client),
+		WindowSeconds: 30 * 24 * 60 * 60,
+	}
+}
+
+// Advise returns the advice on the metrics of the org, given the usage of
+// metrics by queries. Metrics no query uses get no proposal.
+func (a *Advisor) Advise(ctx _context.Context, usage *Usage) (*Report, error) {
+	resp, _, err := a.metrics.ListTagConfigurations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing metrics: %w", err)
+	}
+	report := &Report{}
+	for _, item := range resp.Data {
+		m := Metric{}
+		switch {
+		case item.MetricTagConfiguration != nil:
+			m.Name = item.MetricTagConfiguration.GetId()
+			m.Configured = true
+			m.Type = item.MetricTagConfiguration.Attributes.GetMetricType()
+			m.ConfiguredTags = item.MetricTagConfiguration.Attributes.GetTags()
+		case item.Metric != nil:
+			m.Name = item.Metric.GetId()
+		default:
+			continue
+		}
+		if a.Metrics != nil && !a.Metrics(m.Name) {
+			continue
+		}
+		if err := a.advise(ctx, &m, usage); err != nil {
+			return nil, fmt.Errorf("metric %s: %w", m.Name, err)
+		}
+		report.Metrics = append(report.Metrics, m)
+	}
+	sort.SliceStable(report.Metrics, func(i, j int) bool {
+		if report.Metrics[i].Indexed != report.Metrics[j].Indexed {
+			return report.Metrics[i].Indexed > report.Metrics[j].Indexed
+		}
+		return report.Metrics[i].Name < report.Metrics[j].Name
+	})
+	return report, nil
+}
+
+func (a *Advisor) advise(ctx _context.Context, m *Metric, usage *Usage) error {
+	volumes, _, err := a.metrics.ListVolumesByMetricName(ctx, m.Name)
+	if err != nil {
+		return fmt.Errorf("getting volumes: %w", err)
+	}
+	if v := volumes.Data; v != nil {
+		switch {
+		case v.MetricIngestedIndexedVolume != nil:
+			m.Ingested = v.MetricIngestedIndexedVolume.Attributes.GetIngestedVolume()
+			m.Indexed = v.MetricIngestedIndexedVolume.Attributes.GetIndexedVolume()
+		case v.MetricDistinctVolume != nil:
+			m.Ingested = v.MetricDistinctVolume.Attributes.GetDistinctVolume()
+			m.Indexed = m.Ingested
+		}
+	}
+
+	tags, _, err := a.metrics.ListTagsByMetricName(ctx, m.Name)
+	if err != nil {
+		return fmt.Errorf("listing tags: %w", err)
+	}
+	values := map[string]int{}
+	if tags.Data != nil {
+		for _, t := range tags.Data.Attributes.GetTags() {
+			key, _, _ := strings.Cut(t, ":")
+			values[key]++
+		}
+	}
+
+	used := map[string]bool{}
+	for _, k := range usage.Keys(m.Name) {
+		used[k] = true
+	}
+	m.Queried = usage.Queried(m.Name)
+	active, _, err := a.metrics.ListActiveMetricConfigurations(ctx, m.Name, *datadogV2.NewListActiveMetricConfigurationsOptionalParameters().WithWindowSeconds(a.WindowSeconds))
+	if err != nil {
+		return fmt.Errorf("listing active tags: %w", err)
+	}
+	if active.Data != nil {
+		for _, t := range active.Data.Attributes.GetActiveTags() {
+			key, _, _ := strings.Cut(t, ":")
+			used[key] = true
+			m.Queried = true
+		}
+	}
+
+	for key, n := range values {
+		m.Tags = append(m.Tags, Tag{Key: key, Values: n, Used: used[key]})
+	}
+	sort.Slice(m.Tags, func(i, j int) bool {
+		if m.Tags[i].Values != m.Tags[j].Values {
+			return m.Tags[i].Values > m.Tags[j].Values
+		}
+		return m.Tags[i].Key < m.Tags[j].Key
+	})
+	if !m.Queried || len(m.Unused()) == 0 {
+		return nil
+	}
+
+	allow := []string{}
+	for _, t := range m.Tags {
+		if t.Used {
+			allow = append(allow, t.Key)
+		}
+	}
+	sort.Strings(allow)
+	if len(allow) == 0 && !a.ProposeUntagged || m.Configured && equal(allow, m.ConfiguredTags) {
+		return nil
+	}
+	if !m.Configured {
+		metadata, _, err := a.metadata.GetMetricMetadata(ctx, m.Name)
+		if err != nil {
+			return fmt.Errorf("getting metadata: %w", err)
+		}
+		m.Type = datadogV2.MetricTagConfigurationMetricTypes(metadata.GetType())
+		if !m.Type.IsValid() {
+			return nil
+		}
+	}
+	estimate, _, err := a.metrics.EstimateMetricsOutputSeries(ctx, m.Name, *datadogV2.NewEstimateMetricsOutputSeriesOptionalParameters().WithFilterGroups(strings.Join(allow, ",")))
+	if err != nil {
+		return fmt.Errorf("estimating output series: %w", err)
+	}
+	m.Allowlist = allow
+	if estimate.Data != nil {
+		m.Estimated = estimate.Data.Attributes.GetEstimatedOutputSeries()
+	}
+	if m.Savings = m.Indexed - m.Estimated; m.Savings < 0 {
+		m.Savings = 0
+	}
+	return nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	b = append([]string(nil), b...)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply applies the proposals of a report, creating the tag configurations
+// of metrics without one and updating the others. Proposals failing do not
+// stop the others; the error lists them.
+func (a *Advisor) Apply(ctx _context.Context, report *Report) error {
+	proposals := report.Proposals()
+	var failed []string
+	for _, m := range proposals {
+		var err error
+		if m.Configured {
+			_, _, err = a.metrics.UpdateTagConfiguration(ctx, m.Name, datadogV2.MetricTagConfigurationUpdateRequest{
+				Data: datadogV2.MetricTagConfigurationUpdateData{
+					Attributes: &datadogV2.MetricTagConfigurationUpdateAttributes{Tags: m.Allowlist},
+					Id:         m.Name,
+					Type:       datadogV2.METRICTAGCONFIGURATIONTYPE_MANAGE_TAGS,
+				},
+			})
+		} else {
+			_, _, err = a.metrics.CreateTagConfiguration(ctx, m.Name, datadogV2.MetricTagConfigurationCreateRequest{
+				Data: datadogV2.MetricTagConfigurationCreateData{
+					Attributes: &datadogV2.MetricTagConfigurationCreateAttributes{MetricType: m.Type, Tags: m.Allowlist},
+					Id:         m.Name,
+					Type:       datadogV2.METRICTAGCONFIGURATIONTYPE_MANAGE_TAGS,
+				},
+			})
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", m.Name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d proposal(s) failed: %s", len(failed), len(proposals), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// ApplyPrefix configures all the metrics of a prefix, such as "app.http.",
+// to allow tags, in the background. Datadog emails the outcome to emails.
+func (a *Advisor) ApplyPrefix(ctx _context.Context, prefix string, tags []string, emails ...string) error {
+	_, _, err := a.metrics.CreateBulkTagsMetricsConfiguration(ctx, datadogV2.MetricBulkTagConfigCreateRequest{
+		Data: datadogV2.MetricBulkTagConfigCreate{
+			Attributes: &datadogV2.MetricBulkTagConfigCreateAttributes{Tags: tags, Emails: emails},
+			Id:         prefix,
+			Type:       datadogV2.METRICBULKCONFIGURETAGSTYPE_BULK_MANAGE_TAGS,
+		},
+	})
+	return err
+}
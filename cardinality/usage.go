@@ -0,0 +1,148 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package cardinality
+
+import (
+	_context "context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/jsonwalk"
+)
+
+// Usage holds the tag keys queries filter or group metrics by.
+type Usage struct {
+	metrics map[string]map[string]bool
+}
+
+// NewUsage returns an empty usage.
+func NewUsage() *Usage {
+	return &Usage{metrics: map[string]map[string]bool{}}
+}
+
+// Add records a metric as queried with tag keys.
+func (u *Usage) Add(metric string, keys ...string) {
+	tags, ok := u.metrics[metric]
+	if !ok {
+		tags = map[string]bool{}
+		u.metrics[metric] = tags
+	}
+	for _, k := range keys {
+		tags[k] = true
+	}
+}
+
+// Queried returns whether a metric is queried.
+func (u *Usage) Queried(metric string) bool {
+	_, ok := u.metrics[metric]
+	return ok
+}
+
+// Keys returns the tag keys a metric is queried with, sorted.
+func (u *Usage) Keys(metric string) []string {
+	var keys []string
+	for k := range u.metrics[metric] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// metricQuery matches the metrics of queries, with their filter and the
+// groups of their "by" clause.
+var metricQuery = regexp.MustCompile(`([A-Za-z][\w.]*)\s*\{([^}]*)\}(?:\s*by\s*\{([^}]*)\})?`)
+
+// filterToken splits tag filters into tags, variables and keywords.
+var filterToken = regexp.MustCompile(`[^\s,()]+`)
+
+// AddQuery records the metrics of a metric query, such as
+// "avg:system.cpu.user{env:prod} by {host}" or the query of a metric monitor.
+// Template variables, such as "$env", resolve to the tag keys of variables;
+// the others are ignored.
+func (u *Usage) AddQuery(query string, variables map[string]string) {
+	for _, m := range metricQuery.FindAllStringSubmatch(query, -1) {
+		var keys []string
+		tokens := filterToken.FindAllString(m[2], -1)
+		for i, t := range tokens {
+			switch upper := strings.ToUpper(t); {
+			case upper == "IN" && i > 0:
+				keys = append(keys, strings.TrimLeft(tokens[i-1], "!-"))
+			case upper == "AND" || upper == "OR" || upper == "NOT" || t == "*":
+			case strings.HasPrefix(t, "$"):
+				name := strings.TrimSuffix(strings.TrimPrefix(t, "$"), ".value")
+				if key, ok := variables[name]; ok {
+					keys = append(keys, key)
+				}
+			default:
+				if key, _, ok := strings.Cut(strings.TrimLeft(t, "!-"), ":"); ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+		for _, g := range strings.Split(m[3], ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				keys = append(keys, g)
+			}
+		}
+		u.Add(m[1], keys...)
+	}
+}
+
+// Usage returns the usage of metrics by the widgets of dashboards and by
+// monitors.
+func (a *Advisor) Usage(ctx _context.Context) (*Usage, error) {
+	usage := NewUsage()
+	summary, _, err := a.dashboards.ListDashboards(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing dashboards: %w", err)
+	}
+	for _, s := range summary.Dashboards {
+		dashboard, _, err := a.dashboards.GetDashboard(ctx, s.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("getting dashboard %s: %w", s.GetId(), err)
+		}
+		variables := map[string]string{}
+		for _, v := range dashboard.TemplateVariables {
+			if prefix := v.Prefix.Get(); prefix != nil {
+				variables[v.Name] = *prefix
+			}
+		}
+		data, err := json.Marshal(dashboard.Widgets)
+		if err != nil {
+			return nil, err
+		}
+		var widgets interface{}
+		if err := json.Unmarshal(data, &widgets); err != nil {
+			return nil, err
+		}
+		jsonwalk.Maps(widgets, func(m map[string]interface{}) {
+			for _, field := range []string{"q", "query"} {
+				if q, ok := m[field].(string); ok {
+					usage.AddQuery(q, variables)
+				}
+			}
+		})
+	}
+
+	for page := int64(0); ; page++ {
+		monitors, _, err := a.monitors.ListMonitors(ctx, *datadogV1.NewListMonitorsOptionalParameters().WithPage(page).WithPageSize(pageSize))
+		if err != nil {
+			return nil, fmt.Errorf("listing monitors: %w", err)
+		}
+		for _, m := range monitors {
+			switch m.GetType() {
+			case datadogV1.MONITORTYPE_METRIC_ALERT, datadogV1.MONITORTYPE_QUERY_ALERT:
+				usage.AddQuery(m.GetQuery(), nil)
+			}
+		}
+		if len(monitors) < pageSize {
+			return usage, nil
+		}
+	}
+}
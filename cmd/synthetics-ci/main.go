@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Command synthetics-ci triggers Synthetic tests from a CI pipeline and waits
+// for their results.
+//
+// Usage:
+//
+//	DD_API_KEY=... DD_APP_KEY=... synthetics-ci -public-id abc-def-ghi -tag env:staging -junit report.xml
+//
+// The command exits with status 1 when the run fails and 2 on usage or API errors.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/synthetics/ci"
+)
+
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func main() {
+	var publicIDs, tags, locations, variables listFlag
+	flag.Var(&publicIDs, "public-id", "public ID of a test to run (repeatable)")
+	flag.Var(&tags, "tag", "run every test having this tag (repeatable, all tags must match)")
+	flag.Var(&locations, "location", "override the locations of the tests (repeatable)")
+	flag.Var(&variables, "variable", "override a variable as KEY=VALUE (repeatable)")
+	startURL := flag.String("start-url", "", "override the start URL of browser tests")
+	junit := flag.String("junit", "", "write a JUnit XML report to this file")
+	timeout := flag.Duration("timeout", 30*time.Minute, "maximum time to wait for the results")
+	pollInterval := flag.Duration("poll-interval", 5*time.Second, "initial delay between two polls")
+	failOnCritical := flag.Bool("fail-on-critical", false, "fail when tests cannot run or their results cannot be fetched")
+	failOnTimeout := flag.Bool("fail-on-timeout", true, "fail when results do not finish before the timeout")
+	failOnMissing := flag.Bool("fail-on-missing", false, "fail when requested tests are not found")
+	flag.Parse()
+
+	overrides := &datadogV1.SyntheticsCITest{Locations: locations}
+	if *startURL != "" {
+		overrides.StartUrl = datadog.PtrString(*startURL)
+	}
+	for _, v := range variables {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "invalid variable %q, expected KEY=VALUE\n", v)
+			os.Exit(2)
+		}
+		if overrides.Variables == nil {
+			overrides.Variables = map[string]string{}
+		}
+		overrides.Variables[key] = value
+	}
+
+	ctx := datadog.NewDefaultContext(context.Background())
+	configuration := datadog.NewConfiguration()
+	apiClient := datadog.NewAPIClient(configuration)
+	runner := ci.NewRunner(datadogV1.NewSyntheticsApi(apiClient), ci.Config{
+		PublicIDs:            publicIDs,
+		Tags:                 tags,
+		Overrides:            overrides,
+		PollInterval:         *pollInterval,
+		Timeout:              *timeout,
+		FailOnCriticalErrors: *failOnCritical,
+		FailOnTimeout:        *failOnTimeout,
+		FailOnMissingTests:   *failOnMissing,
+	})
+	summary, err := runner.Run(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running Synthetic tests: %v\n", err)
+		os.Exit(2)
+	}
+	if err := summary.WriteText(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing summary: %v\n", err)
+		os.Exit(2)
+	}
+	if *junit != "" {
+		f, err := os.Create(*junit)
+		if err == nil {
+			err = summary.WriteJUnit(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JUnit report: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if !summary.Success {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,328 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package drift
+
+import (
+	_context "context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/syncplan"
+)
+
+// AWSAccount is a declared AWS account, identified by its account ID, or by
+// its access key ID for GovCloud and China accounts.
+type AWSAccount struct {
+	AccountID       string   `yaml:"account_id,omitempty"`
+	RoleName        string   `yaml:"role_name,omitempty"`
+	AccessKeyID     string   `yaml:"access_key_id,omitempty"`
+	SecretAccessKey string   `yaml:"secret_access_key,omitempty"`
+	HostTags        []string `yaml:"host_tags,omitempty"`
+	FilterTags      []string `yaml:"filter_tags,omitempty"`
+	ExcludedRegions []string `yaml:"excluded_regions,omitempty"`
+	// NamespaceRules enables or disables the metrics of namespaces, such as
+	// auto_scaling. The namespaces not declared are left alone.
+	NamespaceRules         map[string]bool `yaml:"namespace_rules,omitempty"`
+	MetricsCollection      *bool           `yaml:"metrics_collection,omitempty"`
+	ResourceCollection     *bool           `yaml:"resource_collection,omitempty"`
+	CSPMResourceCollection *bool           `yaml:"cspm_resource_collection,omitempty"`
+	// TagFilters are the tag filters of namespaces, such as "elb", which
+	// need an account ID. The log services logs are collected from and the
+	// ARNs of the log forwarder lambdas need one as well.
+	TagFilters  map[string]string `yaml:"tag_filters,omitempty"`
+	LogServices []string          `yaml:"log_services,omitempty"`
+	Lambdas     []string          `yaml:"lambdas,omitempty"`
+}
+
+func (a AWSAccount) id() string {
+	if a.AccountID != "" {
+		return a.AccountID
+	}
+	return a.AccessKeyID
+}
+
+func (a AWSAccount) validate() error {
+	switch {
+	case a.AccountID == "" && a.AccessKeyID == "":
+		return errors.New("no account ID or access key ID")
+	case a.AccountID != "" && a.RoleName == "":
+		return fmt.Errorf("account %s has no role name", a.AccountID)
+	case a.AccountID == "" && (a.TagFilters != nil || a.LogServices != nil || a.Lambdas != nil):
+		return fmt.Errorf("account %s has tag filters, log services or lambdas but no account ID", a.AccessKeyID)
+	}
+	for ns := range a.TagFilters {
+		if _, err := datadogV1.NewAWSNamespaceFromValue(ns); err != nil {
+			return fmt.Errorf("account %s: %w", a.id(), err)
+		}
+	}
+	return nil
+}
+
+// diff returns the differences of the managed fields of an account.
+func (a AWSAccount) diff(current datadogV1.AWSAccount) fields {
+	var f fields
+	if a.RoleName != "" {
+		f.compare("role_name", current.GetRoleName(), a.RoleName)
+	}
+	if a.HostTags != nil {
+		f.compare("host_tags", current.HostTags, a.HostTags)
+	}
+	if a.FilterTags != nil {
+		f.compare("filter_tags", current.FilterTags, a.FilterTags)
+	}
+	if a.ExcludedRegions != nil {
+		f.compare("excluded_regions", current.ExcludedRegions, a.ExcludedRegions)
+	}
+	if a.NamespaceRules != nil {
+		rules := map[string]bool{}
+		for ns := range a.NamespaceRules {
+			if enabled, ok := current.AccountSpecificNamespaceRules[ns]; ok {
+				rules[ns] = enabled
+			}
+		}
+		f.compare("namespace_rules", rules, a.NamespaceRules)
+	}
+	if a.MetricsCollection != nil {
+		f.compare("metrics_collection", current.MetricsCollectionEnabled, a.MetricsCollection)
+	}
+	if a.ResourceCollection != nil {
+		f.compare("resource_collection", current.ResourceCollectionEnabled, a.ResourceCollection)
+	}
+	if a.CSPMResourceCollection != nil {
+		f.compare("cspm_resource_collection", current.CspmResourceCollectionEnabled, a.CSPMResourceCollection)
+	}
+	return f
+}
+
+// merge returns an account with the managed fields of the declaration, and
+// the other fields of the given account.
+func (a AWSAccount) merge(current datadogV1.AWSAccount) datadogV1.AWSAccount {
+	body := current
+	if a.AccountID != "" {
+		body.AccountId = &a.AccountID
+		body.RoleName = &a.RoleName
+	} else {
+		body.AccessKeyId = &a.AccessKeyID
+	}
+	if a.SecretAccessKey != "" {
+		body.SecretAccessKey = &a.SecretAccessKey
+	}
+	if a.HostTags != nil {
+		body.HostTags = a.HostTags
+	}
+	if a.FilterTags != nil {
+		body.FilterTags = a.FilterTags
+	}
+	if a.ExcludedRegions != nil {
+		body.ExcludedRegions = a.ExcludedRegions
+	}
+	if a.NamespaceRules != nil {
+		rules := map[string]bool{}
+		for ns, enabled := range current.AccountSpecificNamespaceRules {
+			rules[ns] = enabled
+		}
+		for ns, enabled := range a.NamespaceRules {
+			rules[ns] = enabled
+		}
+		body.AccountSpecificNamespaceRules = rules
+	}
+	if a.MetricsCollection != nil {
+		body.MetricsCollectionEnabled = a.MetricsCollection
+	}
+	if a.ResourceCollection != nil {
+		body.ResourceCollectionEnabled = a.ResourceCollection
+	}
+	if a.CSPMResourceCollection != nil {
+		body.CspmResourceCollectionEnabled = a.CSPMResourceCollection
+	}
+	return body
+}
+
+func awsID(a datadogV1.AWSAccount) string {
+	if a.GetAccountId() != "" {
+		return a.GetAccountId()
+	}
+	return a.GetAccessKeyId()
+}
+
+func (s *Syncer) diffAWS(ctx _context.Context, plan *Plan, accounts []AWSAccount) error {
+	resp, _, err := s.aws.ListAWSAccounts(ctx)
+	if err != nil {
+		return fmt.Errorf("listing AWS accounts: %w", err)
+	}
+	current := map[string]datadogV1.AWSAccount{}
+	for _, a := range resp.Accounts {
+		current[awsID(a)] = a
+	}
+
+	withLogs := false
+	for _, a := range accounts {
+		withLogs = withLogs || a.LogServices != nil || a.Lambdas != nil
+	}
+	logs := map[string]datadogV1.AWSLogsListResponse{}
+	if withLogs {
+		list, _, err := s.awsLogs.ListAWSLogsIntegrations(ctx)
+		if err != nil {
+			return fmt.Errorf("listing AWS logs integrations: %w", err)
+		}
+		for _, l := range list {
+			logs[l.GetAccountId()] = l
+		}
+	}
+
+	for _, a := range accounts {
+		a := a
+		existing, exists := current[a.id()]
+		delete(current, a.id())
+		if !exists {
+			f := a.diff(datadogV1.AWSAccount{})
+			f.secret("secret_access_key", a.SecretAccessKey)
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionCreate, Provider: ProviderAWS, Account: a.id(), Fields: created(f),
+				apply: func(ctx _context.Context) error {
+					_, _, err := s.aws.CreateAWSAccount(ctx, a.merge(datadogV1.AWSAccount{}))
+					return err
+				},
+			})
+		} else if f := a.diff(existing); len(f) > 0 {
+			params := datadogV1.NewUpdateAWSAccountOptionalParameters()
+			if a.AccountID != "" {
+				params.WithAccountId(a.AccountID).WithRoleName(existing.GetRoleName())
+			} else {
+				params.WithAccessKeyId(a.AccessKeyID)
+			}
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionUpdate, Provider: ProviderAWS, Account: a.id(), Fields: f,
+				apply: func(ctx _context.Context) error {
+					_, _, err := s.aws.UpdateAWSAccount(ctx, a.merge(existing), *params)
+					return err
+				},
+			})
+		}
+
+		if a.TagFilters != nil {
+			filters := map[string]string{}
+			if exists {
+				resp, _, err := s.aws.ListAWSTagFilters(ctx, a.AccountID)
+				if err != nil {
+					return fmt.Errorf("listing tag filters of AWS account %s: %w", a.AccountID, err)
+				}
+				for _, tf := range resp.Filters {
+					filters[string(tf.GetNamespace())] = tf.GetTagFilterStr()
+				}
+			}
+			s.diffAWSTagFilters(plan, a.AccountID, filters, a.TagFilters)
+		}
+		l := logs[a.AccountID]
+		if a.LogServices != nil {
+			var f fields
+			f.compare("services", l.Services, a.LogServices)
+			if len(f) > 0 {
+				request := datadogV1.AWSLogsServicesRequest{AccountId: a.AccountID, Services: a.LogServices}
+				plan.Changes = append(plan.Changes, Change{
+					Action: ActionUpdate, Provider: ProviderAWS, Account: a.id(), Resource: "log services", Fields: f,
+					apply: func(ctx _context.Context) error {
+						_, _, err := s.awsLogs.EnableAWSLogServices(ctx, request)
+						return err
+					},
+				})
+			}
+		}
+		if a.Lambdas != nil {
+			var arns []string
+			for _, lambda := range l.Lambdas {
+				arns = append(arns, lambda.GetArn())
+			}
+			added, removed := syncplan.Difference(a.Lambdas, arns)
+			for _, arn := range added {
+				request := datadogV1.AWSAccountAndLambdaRequest{AccountId: a.AccountID, LambdaArn: arn}
+				plan.Changes = append(plan.Changes, Change{
+					Action: ActionCreate, Provider: ProviderAWS, Account: a.id(), Resource: "lambda " + arn,
+					apply: func(ctx _context.Context) error {
+						_, _, err := s.awsLogs.CreateAWSLambdaARN(ctx, request)
+						return err
+					},
+				})
+			}
+			for _, arn := range removed {
+				request := datadogV1.AWSAccountAndLambdaRequest{AccountId: a.AccountID, LambdaArn: arn}
+				plan.Changes = append(plan.Changes, Change{
+					Action: ActionDelete, Provider: ProviderAWS, Account: a.id(), Resource: "lambda " + arn,
+					apply: func(ctx _context.Context) error {
+						_, _, err := s.awsLogs.DeleteAWSLambdaARN(ctx, request)
+						return err
+					},
+				})
+			}
+		}
+	}
+
+	if !s.Prune {
+		return nil
+	}
+	var ids []string
+	for id := range current {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		a := current[id]
+		request := datadogV1.AWSAccountDeleteRequest{AccessKeyId: a.AccessKeyId}
+		if a.GetAccountId() != "" {
+			request = datadogV1.AWSAccountDeleteRequest{AccountId: a.AccountId, RoleName: a.RoleName}
+		}
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, Provider: ProviderAWS, Account: id,
+			apply: func(ctx _context.Context) error {
+				_, _, err := s.aws.DeleteAWSAccount(ctx, request)
+				return err
+			},
+		})
+	}
+	return nil
+}
+
+// diffAWSTagFilters plans the changes of the tag filters of an account, by
+// namespace. Empty tag filters are not set.
+func (s *Syncer) diffAWSTagFilters(plan *Plan, accountID string, current, desired map[string]string) {
+	var namespaces []string
+	for ns := range current {
+		if _, ok := desired[ns]; !ok {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	for ns := range desired {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		namespace := datadogV1.AWSNamespace(ns)
+		c, d := current[ns], desired[ns]
+		change := Change{Provider: ProviderAWS, Account: accountID, Resource: "tag filter " + ns}
+		switch {
+		case c == d:
+			continue
+		case d == "":
+			change.Action = ActionDelete
+			change.apply = func(ctx _context.Context) error {
+				_, _, err := s.aws.DeleteAWSTagFilter(ctx, datadogV1.AWSTagFilterDeleteRequest{AccountId: &accountID, Namespace: &namespace})
+				return err
+			}
+		default:
+			change.Action = ActionUpdate
+			if c == "" {
+				change.Action = ActionCreate
+			}
+			change.Fields = []Field{{Name: "tag_filter_str", Current: format(c), Desired: format(d)}}
+			change.apply = func(ctx _context.Context) error {
+				_, _, err := s.aws.CreateAWSTagFilter(ctx, datadogV1.AWSTagFilterCreateRequest{AccountId: &accountID, Namespace: &namespace, TagFilterStr: &d})
+				return err
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+}
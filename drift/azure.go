@@ -0,0 +1,134 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package drift
+
+import (
+	_context "context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// AzureTenant is a declared Azure tenant, identified by its name and the
+// client ID of its app registration.
+type AzureTenant struct {
+	TenantName string `yaml:"tenant_name"`
+	ClientID   string `yaml:"client_id"`
+	// ClientSecret is the secret of the app registration, needed to create
+	// the integration.
+	ClientSecret string  `yaml:"client_secret,omitempty"`
+	HostFilters  *string `yaml:"host_filters,omitempty"`
+	Automute     *bool   `yaml:"automute,omitempty"`
+}
+
+func (t AzureTenant) id() string {
+	return t.TenantName
+}
+
+func (t AzureTenant) key() string {
+	return t.TenantName + " " + t.ClientID
+}
+
+func (t AzureTenant) validate() error {
+	switch {
+	case t.TenantName == "":
+		return errors.New("no tenant name")
+	case t.ClientID == "":
+		return fmt.Errorf("tenant %s has no client ID", t.TenantName)
+	}
+	return nil
+}
+
+func (t AzureTenant) diff(current datadogV1.AzureAccount) fields {
+	var f fields
+	if t.HostFilters != nil {
+		f.compare("host_filters", current.HostFilters, t.HostFilters)
+	}
+	if t.Automute != nil {
+		f.compare("automute", current.Automute, t.Automute)
+	}
+	return f
+}
+
+// account returns the integration of the tenant, with the managed fields and
+// the secret when set.
+func (t AzureTenant) account() datadogV1.AzureAccount {
+	body := datadogV1.AzureAccount{
+		TenantName:  &t.TenantName,
+		ClientId:    &t.ClientID,
+		HostFilters: t.HostFilters,
+		Automute:    t.Automute,
+	}
+	if t.ClientSecret != "" {
+		body.ClientSecret = &t.ClientSecret
+	}
+	return body
+}
+
+func azureKey(a datadogV1.AzureAccount) string {
+	return a.GetTenantName() + " " + a.GetClientId()
+}
+
+func (s *Syncer) diffAzure(ctx _context.Context, plan *Plan, tenants []AzureTenant) error {
+	list, _, err := s.azure.ListAzureIntegration(ctx)
+	if err != nil {
+		return fmt.Errorf("listing Azure integrations: %w", err)
+	}
+	current := map[string]datadogV1.AzureAccount{}
+	for _, a := range list {
+		current[azureKey(a)] = a
+	}
+
+	for _, t := range tenants {
+		t := t
+		existing, exists := current[t.key()]
+		delete(current, t.key())
+		if !exists {
+			if t.ClientSecret == "" {
+				return fmt.Errorf("Azure tenant %s does not exist and has no client secret", t.TenantName)
+			}
+			f := t.diff(datadogV1.AzureAccount{})
+			f.secret("client_secret", t.ClientSecret)
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionCreate, Provider: ProviderAzure, Account: t.id(), Fields: created(f),
+				apply: func(ctx _context.Context) error {
+					_, _, err := s.azure.CreateAzureIntegration(ctx, t.account())
+					return err
+				},
+			})
+		} else if f := t.diff(existing); len(f) > 0 {
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionUpdate, Provider: ProviderAzure, Account: t.id(), Fields: f,
+				apply: func(ctx _context.Context) error {
+					_, _, err := s.azure.UpdateAzureIntegration(ctx, t.account())
+					return err
+				},
+			})
+		}
+	}
+
+	if !s.Prune {
+		return nil
+	}
+	var keys []string
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		a := current[k]
+		body := datadogV1.AzureAccount{TenantName: a.TenantName, ClientId: a.ClientId}
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, Provider: ProviderAzure, Account: a.GetTenantName(),
+			apply: func(ctx _context.Context) error {
+				_, _, err := s.azure.DeleteAzureIntegration(ctx, body)
+				return err
+			},
+		})
+	}
+	return nil
+}
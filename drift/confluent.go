@@ -0,0 +1,229 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package drift
+
+import (
+	_context "context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// ConfluentAccount is a declared Confluent Cloud account, identified by its
+// API key.
+type ConfluentAccount struct {
+	APIKey string `yaml:"api_key"`
+	// APISecret is the secret of the API key, needed to create or update the
+	// account.
+	APISecret string   `yaml:"api_secret,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
+	// Resources are the resources of the account. They are not managed when
+	// nil.
+	Resources []ConfluentResource `yaml:"resources,omitempty"`
+}
+
+// ConfluentResource is a declared resource of a Confluent Cloud account,
+// identified by its Confluent ID.
+type ConfluentResource struct {
+	ID string `yaml:"id"`
+	// ResourceType is the type of the resource, such as "kafka" or "connector".
+	ResourceType string   `yaml:"resource_type,omitempty"`
+	Tags         []string `yaml:"tags,omitempty"`
+}
+
+func (a ConfluentAccount) validate() error {
+	if a.APIKey == "" {
+		return errors.New("no API key")
+	}
+	ids := map[string]bool{}
+	for i, r := range a.Resources {
+		switch {
+		case r.ID == "":
+			return fmt.Errorf("resource %d of account %s has no ID", i, a.APIKey)
+		case ids[r.ID]:
+			return fmt.Errorf("resource %s of account %s is declared twice", r.ID, a.APIKey)
+		}
+		ids[r.ID] = true
+	}
+	return nil
+}
+
+func (r ConfluentResource) diff(current datadogV2.ConfluentResourceResponseAttributes) fields {
+	var f fields
+	if r.ResourceType != "" {
+		f.compare("resource_type", current.ResourceType, r.ResourceType)
+	}
+	if r.Tags != nil {
+		f.compare("tags", current.Tags, r.Tags)
+	}
+	return f
+}
+
+func (s *Syncer) diffConfluent(ctx _context.Context, plan *Plan, accounts []ConfluentAccount) error {
+	list, _, err := s.confluent.ListConfluentAccount(ctx)
+	if err != nil {
+		return fmt.Errorf("listing Confluent accounts: %w", err)
+	}
+	current := map[string]datadogV2.ConfluentAccountResponseData{}
+	for _, a := range list.Data {
+		current[a.Attributes.ApiKey] = a
+	}
+
+	for _, a := range accounts {
+		a := a
+		existing, exists := current[a.APIKey]
+		delete(current, a.APIKey)
+		if !exists {
+			if a.APISecret == "" {
+				return fmt.Errorf("Confluent account %s does not exist and has no API secret", a.APIKey)
+			}
+			var f fields
+			if a.Tags != nil {
+				f.compare("tags", []string(nil), a.Tags)
+			}
+			body := datadogV2.ConfluentAccountCreateRequestAttributes{ApiKey: a.APIKey, ApiSecret: a.APISecret, Tags: a.Tags}
+			var ids []string
+			for _, r := range a.Resources {
+				r := r
+				ids = append(ids, r.ID)
+				resource := datadogV2.ConfluentAccountResourceAttributes{Id: &r.ID, Tags: r.Tags}
+				if r.ResourceType != "" {
+					resource.ResourceType = &r.ResourceType
+				}
+				body.Resources = append(body.Resources, resource)
+			}
+			if a.Resources != nil {
+				f.compare("resources", []string(nil), ids)
+			}
+			f.secret("api_secret", a.APISecret)
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionCreate, Provider: ProviderConfluent, Account: a.APIKey, Fields: created(f),
+				apply: func(ctx _context.Context) error {
+					_, _, err := s.confluent.CreateConfluentAccount(ctx, datadogV2.ConfluentAccountCreateRequest{
+						Data: datadogV2.ConfluentAccountCreateRequestData{Attributes: body, Type: datadogV2.CONFLUENTACCOUNTTYPE_CONFLUENT_CLOUD_ACCOUNTS},
+					})
+					return err
+				},
+			})
+			continue
+		}
+
+		if a.Tags != nil {
+			var f fields
+			f.compare("tags", existing.Attributes.Tags, a.Tags)
+			if len(f) > 0 {
+				if a.APISecret == "" {
+					return fmt.Errorf("Confluent account %s has drifted and has no API secret", a.APIKey)
+				}
+				body := datadogV2.ConfluentAccountUpdateRequestAttributes{ApiKey: a.APIKey, ApiSecret: a.APISecret, Tags: a.Tags}
+				plan.Changes = append(plan.Changes, Change{
+					Action: ActionUpdate, Provider: ProviderConfluent, Account: a.APIKey, Fields: f,
+					apply: func(ctx _context.Context) error {
+						_, _, err := s.confluent.UpdateConfluentAccount(ctx, existing.Id, datadogV2.ConfluentAccountUpdateRequest{
+							Data: datadogV2.ConfluentAccountUpdateRequestData{Attributes: body, Type: datadogV2.CONFLUENTACCOUNTTYPE_CONFLUENT_CLOUD_ACCOUNTS},
+						})
+						return err
+					},
+				})
+			}
+		}
+		if a.Resources != nil {
+			if err := s.diffConfluentResources(ctx, plan, a, existing.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !s.Prune {
+		return nil
+	}
+	var keys []string
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		id := current[k].Id
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, Provider: ProviderConfluent, Account: k,
+			apply: func(ctx _context.Context) error {
+				_, err := s.confluent.DeleteConfluentAccount(ctx, id)
+				return err
+			},
+		})
+	}
+	return nil
+}
+
+// diffConfluentResources plans the changes of the resources of an existing
+// account.
+func (s *Syncer) diffConfluentResources(ctx _context.Context, plan *Plan, a ConfluentAccount, accountID string) error {
+	list, _, err := s.confluent.ListConfluentResource(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("listing resources of Confluent account %s: %w", a.APIKey, err)
+	}
+	current := map[string]datadogV2.ConfluentResourceResponseAttributes{}
+	for _, r := range list.Data {
+		current[r.Id] = r.Attributes
+	}
+
+	for _, r := range a.Resources {
+		r := r
+		existing, exists := current[r.ID]
+		delete(current, r.ID)
+		change := Change{Provider: ProviderConfluent, Account: a.APIKey, Resource: "resource " + r.ID}
+		if exists {
+			change.Action, change.Fields = ActionUpdate, r.diff(existing)
+			if len(change.Fields) == 0 {
+				continue
+			}
+		} else {
+			change.Action, change.Fields = ActionCreate, created(r.diff(datadogV2.ConfluentResourceResponseAttributes{}))
+		}
+		attributes := datadogV2.ConfluentResourceRequestAttributes{Tags: r.Tags}
+		if r.ResourceType != "" {
+			attributes.ResourceType = &r.ResourceType
+		} else if exists {
+			attributes.ResourceType = &existing.ResourceType
+		}
+		if r.Tags == nil {
+			attributes.Tags = existing.Tags
+		}
+		request := datadogV2.ConfluentResourceRequest{
+			Data: datadogV2.ConfluentResourceRequestData{Attributes: attributes, Id: r.ID, Type: datadogV2.CONFLUENTRESOURCETYPE_CONFLUENT_CLOUD_RESOURCES},
+		}
+		if exists {
+			change.apply = func(ctx _context.Context) error {
+				_, _, err := s.confluent.UpdateConfluentResource(ctx, accountID, r.ID, request)
+				return err
+			}
+		} else {
+			change.apply = func(ctx _context.Context) error {
+				_, _, err := s.confluent.CreateConfluentResource(ctx, accountID, request)
+				return err
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	var ids []string
+	for id := range current {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		id := id
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, Provider: ProviderConfluent, Account: a.APIKey, Resource: "resource " + id,
+			apply: func(ctx _context.Context) error {
+				_, err := s.confluent.DeleteConfluentResource(ctx, accountID, id)
+				return err
+			},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package drift detects and reconciles drift of the AWS, GCP, Azure and
+// Confluent Cloud integrations of an org.
+//
+// The desired settings of integration accounts are declared in YAML. A Syncer
+// diffs them against the accounts of an org into a Plan of field-level
+// changes, renders it for review with secrets masked, and applies it through
+// the create, update and delete endpoints of every integration. Fields left
+// out of a declaration are not managed, and secrets, which the API does not
+// return, are sent when accounts are created or updated but never compared.
+// Environment variables such as ${AZURE_CLIENT_SECRET} are expanded in
+// secrets.
+//
+// A declaration in YAML looks like:
+//
+//	aws:
+//	  - account_id: "123456789012"
+//	    role_name: DatadogIntegrationRole
+//	    host_tags: [env:prod]
+//	    namespace_rules: {auto_scaling: false}
+//	    tag_filters: {elb: "!env:dev"}
+//	    log_services: [s3, elb]
+//	    lambdas: [arn:aws:lambda:us-east-1:123456789012:function:forwarder]
+//	gcp:
+//	  - project_id: my-project
+//	    client_email: datadog@my-project.iam.gserviceaccount.com
+//	    client_id: "123456789"
+//	    private_key_id: ${GCP_PRIVATE_KEY_ID}
+//	    private_key: ${GCP_PRIVATE_KEY}
+//	    host_filters: env:prod
+//	azure:
+//	  - tenant_name: contoso
+//	    client_id: 9a1b2c3d-0000-0000-0000-000000000000
+//	    client_secret: ${AZURE_CLIENT_SECRET}
+//	    automute: true
+//	confluent:
+//	  - api_key: ABCDEFGHIJKLMNOP
+//	    api_secret: ${CONFLUENT_API_SECRET}
+//	    tags: [team:streaming]
+//	    resources:
+//	      - id: lkc-abc123
+//	        resource_type: kafka
+package drift
+
+import (
+	"bytes"
+	_context "context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/strictyaml"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/syncplan"
+)
+
+// Config is a declaration of integration accounts. Accounts of a provider
+// are only deleted by a pruning syncer when the provider is declared, even
+// with no accounts, such as "gcp: []".
+type Config struct {
+	AWS       []AWSAccount       `yaml:"aws,omitempty"`
+	GCP       []GCPProject       `yaml:"gcp,omitempty"`
+	Azure     []AzureTenant      `yaml:"azure,omitempty"`
+	Confluent []ConfluentAccount `yaml:"confluent,omitempty"`
+}
+
+// Decode reads a declaration of integration accounts in YAML.
+func Decode(r io.Reader) (*Config, error) {
+	var c Config
+	if err := strictyaml.Decode(r, &c); err != nil {
+		return nil, err
+	}
+	for i := range c.AWS {
+		c.AWS[i].SecretAccessKey = os.ExpandEnv(c.AWS[i].SecretAccessKey)
+	}
+	for i := range c.GCP {
+		c.GCP[i].PrivateKeyID = os.ExpandEnv(c.GCP[i].PrivateKeyID)
+		c.GCP[i].PrivateKey = os.ExpandEnv(c.GCP[i].PrivateKey)
+	}
+	for i := range c.Azure {
+		c.Azure[i].ClientSecret = os.ExpandEnv(c.Azure[i].ClientSecret)
+	}
+	for i := range c.Confluent {
+		c.Confluent[i].APISecret = os.ExpandEnv(c.Confluent[i].APISecret)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Unmarshal parses a declaration of integration accounts in YAML.
+func Unmarshal(data []byte) (*Config, error) {
+	return Decode(bytes.NewReader(data))
+}
+
+// Validate checks that accounts are identified, and declared once.
+func (c *Config) Validate() error {
+	seen := map[string]bool{}
+	check := func(provider Provider, i int, account string, err error) error {
+		if err != nil {
+			return fmt.Errorf("%s account %d: %w", provider, i, err)
+		}
+		key := string(provider) + " " + account
+		if seen[key] {
+			return fmt.Errorf("%s %q is declared twice", provider, account)
+		}
+		seen[key] = true
+		return nil
+	}
+	for i, a := range c.AWS {
+		if err := check(ProviderAWS, i, a.id(), a.validate()); err != nil {
+			return err
+		}
+	}
+	for i, p := range c.GCP {
+		if err := check(ProviderGCP, i, p.id(), p.validate()); err != nil {
+			return err
+		}
+	}
+	for i, t := range c.Azure {
+		if err := check(ProviderAzure, i, t.id(), t.validate()); err != nil {
+			return err
+		}
+	}
+	for i, a := range c.Confluent {
+		if err := check(ProviderConfluent, i, a.APIKey, a.validate()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Provider is the cloud provider of an integration.
+type Provider string
+
+// List of Provider.
+const (
+	ProviderAWS       Provider = "aws"
+	ProviderGCP       Provider = "gcp"
+	ProviderAzure     Provider = "azure"
+	ProviderConfluent Provider = "confluent"
+)
+
+// Action is the action of a change.
+type Action string
+
+// List of Action.
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// masked replaces the value of secrets in plans.
+const masked = "********"
+
+// Field is a field-level difference.
+type Field struct {
+	Name string
+	// Current is the formatted value of the field, empty when it is not set.
+	Current string
+	// Desired is the formatted value of the field, masked for secrets.
+	Desired string
+}
+
+func (f Field) String() string {
+	if f.Current == "" {
+		return fmt.Sprintf("%s: %s", f.Name, f.Desired)
+	}
+	return fmt.Sprintf("%s: %s -> %s", f.Name, f.Current, f.Desired)
+}
+
+// Change is a change to an integration account, or to a part of it.
+type Change struct {
+	Action   Action
+	Provider Provider
+	// Account identifies the account: the ID or access key ID of AWS
+	// accounts, the project ID of GCP projects, the tenant name of Azure
+	// tenants and the API key of Confluent accounts.
+	Account string
+	// Resource is the part of the account changed, such as "tag filter elb",
+	// empty for the account itself.
+	Resource string
+	Fields   []Field
+
+	apply func(ctx _context.Context) error
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s %s", c.Action, c.Provider, c.Account)
+	if c.Resource != "" {
+		s += " " + c.Resource
+	}
+	return s
+}
+
+// Plan is the changes making the integrations of an org match a declaration.
+type Plan struct {
+	Changes []Change
+}
+
+// Empty returns whether the integrations need no change.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// WriteText writes the changes, one per line followed by their fields.
+func (p *Plan) WriteText(w io.Writer) error {
+	lines := make([]syncplan.Line, len(p.Changes))
+	count := map[Action]int{}
+	for i, c := range p.Changes {
+		count[c.Action]++
+		lines[i] = syncplan.Line{Sign: map[Action]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}[c.Action], Change: c.String()}
+		for _, f := range c.Fields {
+			lines[i].Details = append(lines[i].Details, f.String())
+		}
+	}
+	return syncplan.Write(w, lines, fmt.Sprintf("%d to create, %d to update, %d to delete", count[ActionCreate], count[ActionUpdate], count[ActionDelete]))
+}
+
+// Syncer syncs the integration accounts of an org.
+type Syncer struct {
+	aws       *datadogV1.AWSIntegrationApi
+	awsLogs   *datadogV1.AWSLogsIntegrationApi
+	gcp       *datadogV1.GCPIntegrationApi
+	azure     *datadogV1.AzureIntegrationApi
+	confluent *datadogV2.ConfluentCloudApi
+	// Prune deletes the accounts not declared, of the providers declared.
+	Prune bool
+}
+
+// NewSyncer returns a syncer using the integration APIs of the client.
+func NewSyncer(client *datadog.APIClient) *Syncer {
+	return &Syncer{
+		aws:       datadogV1.NewAWSIntegrationApi(client),
+		awsLogs:   datadogV1.NewAWSLogsIntegrationApi(client),
+		gcp:       datadogV1.NewGCPIntegrationApi(client),
+		azure:     datadogV1.NewAzureIntegrationApi(client),
+		confluent: datadogV2.NewConfluentCloudApi(client),
+	}
+}
+
+// Diff returns the changes making the integrations of an org match the
+// declaration, provider by provider in the order of the declaration, with
+// the deletions of a provider last. Providers not declared are not fetched.
+func (s *Syncer) Diff(ctx _context.Context, config *Config) (*Plan, error) {
+	plan := &Plan{}
+	if config.AWS != nil {
+		if err := s.diffAWS(ctx, plan, config.AWS); err != nil {
+			return nil, err
+		}
+	}
+	if config.GCP != nil {
+		if err := s.diffGCP(ctx, plan, config.GCP); err != nil {
+			return nil, err
+		}
+	}
+	if config.Azure != nil {
+		if err := s.diffAzure(ctx, plan, config.Azure); err != nil {
+			return nil, err
+		}
+	}
+	if config.Confluent != nil {
+		if err := s.diffConfluent(ctx, plan, config.Confluent); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// Apply runs the changes of a plan one after the other. The first error
+// ends the run, and is returned prefixed with the failed change.
+func (s *Syncer) Apply(ctx _context.Context, plan *Plan) error {
+	for _, c := range plan.Changes {
+		if c.apply == nil {
+			return fmt.Errorf("%s: change not planned by a syncer", c)
+		}
+		if err := c.apply(ctx); err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// fields collects field-level differences.
+type fields []Field
+
+// compare adds a field when its current and desired values differ.
+func (f *fields) compare(name string, current, desired interface{}) {
+	c, d := format(current), format(desired)
+	if c != d {
+		*f = append(*f, Field{Name: name, Current: c, Desired: d})
+	}
+}
+
+// secret adds a secret field when it is set.
+func (f *fields) secret(name, value string) {
+	if value != "" {
+		*f = append(*f, Field{Name: name, Desired: masked})
+	}
+}
+
+// created returns the fields of an account created, which have no current
+// value.
+func created(f fields) []Field {
+	for i := range f {
+		f[i].Current = ""
+	}
+	return f
+}
+
+// format formats values so that equal settings are formatted the same:
+// lists and maps are sorted, and unset values are empty.
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return ""
+		}
+		return fmt.Sprintf("%q", v)
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return format(*v)
+	case *bool:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(*v)
+	case []string:
+		sorted := append([]string{}, v...)
+		sort.Strings(sorted)
+		return "[" + strings.Join(sorted, ", ") + "]"
+	case map[string]bool:
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			keys[i] = fmt.Sprintf("%s: %t", k, v[k])
+		}
+		return "{" + strings.Join(keys, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package drift
+
+import (
+	_context "context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// GCPProject is a declared GCP project, identified by its project ID and the
+// email of its service account.
+type GCPProject struct {
+	ProjectID   string `yaml:"project_id"`
+	ClientEmail string `yaml:"client_email"`
+	ClientID    string `yaml:"client_id,omitempty"`
+	// PrivateKeyID and PrivateKey are the key of the service account, needed
+	// to create the integration.
+	PrivateKeyID string  `yaml:"private_key_id,omitempty"`
+	PrivateKey   string  `yaml:"private_key,omitempty"`
+	HostFilters  *string `yaml:"host_filters,omitempty"`
+	Automute     *bool   `yaml:"automute,omitempty"`
+}
+
+func (p GCPProject) id() string {
+	return p.ProjectID
+}
+
+func (p GCPProject) key() string {
+	return p.ProjectID + " " + p.ClientEmail
+}
+
+func (p GCPProject) validate() error {
+	switch {
+	case p.ProjectID == "":
+		return errors.New("no project ID")
+	case p.ClientEmail == "":
+		return fmt.Errorf("project %s has no client email", p.ProjectID)
+	}
+	return nil
+}
+
+func (p GCPProject) diff(current datadogV1.GCPAccount) fields {
+	var f fields
+	if p.ClientID != "" {
+		f.compare("client_id", current.GetClientId(), p.ClientID)
+	}
+	if p.HostFilters != nil {
+		f.compare("host_filters", current.HostFilters, p.HostFilters)
+	}
+	if p.Automute != nil {
+		f.compare("automute", current.Automute, p.Automute)
+	}
+	return f
+}
+
+// account returns the integration of the project, with the managed fields
+// and the key when set.
+func (p GCPProject) account() datadogV1.GCPAccount {
+	body := datadogV1.GCPAccount{
+		ProjectId:   &p.ProjectID,
+		ClientEmail: &p.ClientEmail,
+		HostFilters: p.HostFilters,
+		Automute:    p.Automute,
+	}
+	if p.ClientID != "" {
+		body.ClientId = &p.ClientID
+	}
+	if p.PrivateKey != "" {
+		body.PrivateKeyId = &p.PrivateKeyID
+		body.PrivateKey = &p.PrivateKey
+	}
+	return body
+}
+
+func gcpKey(a datadogV1.GCPAccount) string {
+	return a.GetProjectId() + " " + a.GetClientEmail()
+}
+
+func (s *Syncer) diffGCP(ctx _context.Context, plan *Plan, projects []GCPProject) error {
+	list, _, err := s.gcp.ListGCPIntegration(ctx)
+	if err != nil {
+		return fmt.Errorf("listing GCP integrations: %w", err)
+	}
+	current := map[string]datadogV1.GCPAccount{}
+	for _, a := range list {
+		current[gcpKey(a)] = a
+	}
+
+	for _, p := range projects {
+		p := p
+		existing, exists := current[p.key()]
+		delete(current, p.key())
+		if !exists {
+			if p.ClientID == "" || p.PrivateKeyID == "" || p.PrivateKey == "" {
+				return fmt.Errorf("GCP project %s does not exist and has no client ID or private key", p.ProjectID)
+			}
+			f := p.diff(datadogV1.GCPAccount{})
+			f.secret("private_key_id", p.PrivateKeyID)
+			f.secret("private_key", p.PrivateKey)
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionCreate, Provider: ProviderGCP, Account: p.id(), Fields: created(f),
+				apply: func(ctx _context.Context) error {
+					body := p.account()
+					body.Type = datadog.PtrString("service_account")
+					body.AuthUri = datadog.PtrString("https://accounts.google.com/o/oauth2/auth")
+					body.TokenUri = datadog.PtrString("https://oauth2.googleapis.com/token")
+					_, _, err := s.gcp.CreateGCPIntegration(ctx, body)
+					return err
+				},
+			})
+		} else if f := p.diff(existing); len(f) > 0 {
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionUpdate, Provider: ProviderGCP, Account: p.id(), Fields: f,
+				apply: func(ctx _context.Context) error {
+					_, _, err := s.gcp.UpdateGCPIntegration(ctx, p.account())
+					return err
+				},
+			})
+		}
+	}
+
+	if !s.Prune {
+		return nil
+	}
+	var keys []string
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		a := current[k]
+		body := datadogV1.GCPAccount{ProjectId: a.ProjectId, ClientEmail: a.ClientEmail}
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, Provider: ProviderGCP, Account: a.GetProjectId(),
+			apply: func(ctx _context.Context) error {
+				_, _, err := s.gcp.DeleteGCPIntegration(ctx, body)
+				return err
+			},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package hosts tags and mutes hosts in bulk.
+//
+// A Selector picks hosts with the filter of the hosts endpoint, a local
+// predicate over their apps, sources, tags and metadata, or both. A Bulk runs
+// an Operation, such as adding the tags of a source or muting until a given
+// time, on thousands of hosts with bounded concurrency. It reports progress
+// as hosts are done, and records them in a checkpoint file so that an
+// interrupted run resumes where it stopped.
+package hosts
+
+import (
+	_context "context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+const pageSize = 1000
+
+// Selector selects hosts.
+type Selector struct {
+	// Filter is the filter of the hosts endpoint, such as "env:prod".
+	Filter string
+	// From selects the hosts active since a time when set.
+	From time.Time
+	// Match selects, among the hosts of the filter, the hosts it returns
+	// true for, when set.
+	Match func(host datadogV1.Host) bool
+}
+
+// HasApp matches the hosts running an app, such as "agent" or "nginx".
+func HasApp(app string) func(datadogV1.Host) bool {
+	return func(h datadogV1.Host) bool {
+		return contains(h.Apps, app)
+	}
+}
+
+// HasSource matches the hosts reported by a source, such as "aws".
+func HasSource(source string) func(datadogV1.Host) bool {
+	return func(h datadogV1.Host) bool {
+		return contains(h.Sources, source)
+	}
+}
+
+// HasTag matches the hosts with a tag from a source, or from any source when
+// source is empty.
+func HasTag(source, tag string) func(datadogV1.Host) bool {
+	return func(h datadogV1.Host) bool {
+		for s, tags := range h.TagsBySource {
+			if (source == "" || strings.EqualFold(s, source)) && contains(tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasPlatform matches the hosts whose metadata report a platform, such as
+// "linux" or "windows".
+func HasPlatform(platform string) func(datadogV1.Host) bool {
+	return func(h datadogV1.Host) bool {
+		return strings.EqualFold(h.Meta.GetPlatform(), platform)
+	}
+}
+
+// All matches the hosts matched by all the predicates.
+func All(predicates ...func(datadogV1.Host) bool) func(datadogV1.Host) bool {
+	return func(h datadogV1.Host) bool {
+		for _, p := range predicates {
+			if !p(h) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Bulk runs operations on hosts.
+type Bulk struct {
+	hosts *datadogV1.HostsApi
+	tags  *datadogV1.TagsApi
+
+	// Concurrency is the maximum number of hosts operated on at once, 8 by
+	// default.
+	Concurrency int
+	// CheckpointPath is the path of the checkpoint file of runs, when set.
+	CheckpointPath string
+	// Progress is called as hosts are done, one call at a time, when set.
+	Progress func(Progress)
+}
+
+// NewBulk returns a bulk runner operating on 8 hosts at once.
+func NewBulk(client *datadog.APIClient) *Bulk {
+	return &Bulk{
+		hosts:       datadogV1.NewHostsApi(client),
+		tags:        datadogV1.NewTagsApi(client),
+		Concurrency: 8,
+	}
+}
+
+// Select returns the names of the hosts selected, in the order of the hosts
+// endpoint.
+func (b *Bulk) Select(ctx _context.Context, selector Selector) ([]string, error) {
+	var names []string
+	for start := int64(0); ; start += pageSize {
+		params := datadogV1.NewListHostsOptionalParameters().WithStart(start).WithCount(pageSize).
+			WithIncludeMutedHostsData(true).WithIncludeHostsMetadata(true)
+		if selector.Filter != "" {
+			params.WithFilter(selector.Filter)
+		}
+		if !selector.From.IsZero() {
+			params.WithFrom(selector.From.Unix())
+		}
+		resp, _, err := b.hosts.ListHosts(ctx, *params)
+		if err != nil {
+			return nil, fmt.Errorf("listing hosts: %w", err)
+		}
+		for _, h := range resp.HostList {
+			if selector.Match == nil || selector.Match(h) {
+				names = append(names, h.GetName())
+			}
+		}
+		if len(resp.HostList) < pageSize {
+			return names, nil
+		}
+	}
+}
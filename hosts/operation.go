@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package hosts
+
+import (
+	_context "context"
+	"fmt"
+	_nethttp "net/http"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// Operation is an operation on a host.
+type Operation interface {
+	// Apply applies the operation to a host.
+	Apply(ctx _context.Context, b *Bulk, host string) error
+	// String describes the operation. Checkpoints only resume runs of the
+	// operation they were written by.
+	String() string
+}
+
+type addTags struct {
+	source string
+	tags   []string
+}
+
+// AddTags returns an operation adding tags from a source, such as "users",
+// to hosts.
+func AddTags(source string, tags ...string) Operation {
+	return addTags{source: source, tags: tags}
+}
+
+func (o addTags) Apply(ctx _context.Context, b *Bulk, host string) error {
+	_, _, err := b.tags.CreateHostTags(ctx, host, datadogV1.HostTags{Host: &host, Tags: o.tags},
+		*datadogV1.NewCreateHostTagsOptionalParameters().WithSource(o.source))
+	return err
+}
+
+func (o addTags) String() string {
+	return fmt.Sprintf("add tags %s from source %s", strings.Join(o.tags, ", "), o.source)
+}
+
+type removeTags struct {
+	source string
+	tags   []string
+}
+
+// RemoveTags returns an operation removing tags from a source from hosts.
+// The other tags of the source are kept.
+func RemoveTags(source string, tags ...string) Operation {
+	return removeTags{source: source, tags: tags}
+}
+
+func (o removeTags) Apply(ctx _context.Context, b *Bulk, host string) error {
+	current, resp, err := b.tags.GetHostTags(ctx, host, *datadogV1.NewGetHostTagsOptionalParameters().WithSource(o.source))
+	if err != nil {
+		if resp != nil && resp.StatusCode == _nethttp.StatusNotFound {
+			// The host has no tags from the source.
+			return nil
+		}
+		return err
+	}
+	var kept []string
+	for _, t := range current.Tags {
+		if !contains(o.tags, t) {
+			kept = append(kept, t)
+		}
+	}
+	switch {
+	case len(kept) == len(current.Tags):
+		return nil
+	case len(kept) == 0:
+		_, err = b.tags.DeleteHostTags(ctx, host, *datadogV1.NewDeleteHostTagsOptionalParameters().WithSource(o.source))
+	default:
+		_, _, err = b.tags.UpdateHostTags(ctx, host, datadogV1.HostTags{Host: &host, Tags: kept},
+			*datadogV1.NewUpdateHostTagsOptionalParameters().WithSource(o.source))
+	}
+	return err
+}
+
+func (o removeTags) String() string {
+	return fmt.Sprintf("remove tags %s from source %s", strings.Join(o.tags, ", "), o.source)
+}
+
+type mute struct {
+	end     time.Time
+	message string
+}
+
+// Mute returns an operation muting hosts until an end time, or until they
+// are unmuted when end is zero. Hosts already muted are muted again with the
+// new end time.
+func Mute(end time.Time, message string) Operation {
+	return mute{end: end, message: message}
+}
+
+func (o mute) Apply(ctx _context.Context, b *Bulk, host string) error {
+	settings := datadogV1.HostMuteSettings{Override: datadog.PtrBool(true)}
+	if !o.end.IsZero() {
+		settings.End = datadog.PtrInt64(o.end.Unix())
+	}
+	if o.message != "" {
+		settings.Message = &o.message
+	}
+	_, _, err := b.hosts.MuteHost(ctx, host, settings)
+	return err
+}
+
+func (o mute) String() string {
+	if o.end.IsZero() {
+		return "mute"
+	}
+	return "mute until " + o.end.UTC().Format(time.RFC3339)
+}
+
+type unmute struct{}
+
+// Unmute returns an operation unmuting hosts.
+func Unmute() Operation {
+	return unmute{}
+}
+
+func (unmute) Apply(ctx _context.Context, b *Bulk, host string) error {
+	_, _, err := b.hosts.UnmuteHost(ctx, host)
+	return err
+}
+
+func (unmute) String() string {
+	return "unmute"
+}
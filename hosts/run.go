@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package hosts
+
+import (
+	_context "context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-api-client-go/v2/internal/parallel"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/statefile"
+)
+
+// checkpointEvery is the number of hosts done between saves of the
+// checkpoint.
+const checkpointEvery = 50
+
+// Progress is the progress of a run after a host is done.
+type Progress struct {
+	Host string
+	// Err is the error of the host, if it failed.
+	Err error
+	// Total is the number of hosts of the run, and Skipped the number of
+	// hosts done by an earlier run.
+	Total   int
+	Skipped int
+	Done    int
+	Failed  int
+}
+
+// Checkpoint records the hosts an operation is done for.
+type Checkpoint struct {
+	Operation string   `json:"operation"`
+	Done      []string `json:"done"`
+}
+
+// LoadCheckpoint reads a checkpoint file. A missing file is an empty
+// checkpoint.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var c Checkpoint
+	err := statefile.Load(path, &c)
+	return c, err
+}
+
+// Save writes the checkpoint to a file, atomically.
+func (c Checkpoint) Save(path string) error {
+	return statefile.Save(path, c)
+}
+
+// Run applies an operation to hosts. Hosts failing do not stop the others;
+// the error lists them. With a checkpoint path, the hosts done by an earlier
+// run of the same operation are skipped, and the hosts done are saved as the
+// run goes and when it ends, including when the context is done. The
+// checkpoint of another operation is an error.
+func (b *Bulk) Run(ctx _context.Context, hosts []string, op Operation) error {
+	checkpoint := Checkpoint{Operation: op.String()}
+	if b.CheckpointPath != "" {
+		saved, err := LoadCheckpoint(b.CheckpointPath)
+		if err != nil {
+			return fmt.Errorf("loading checkpoint: %w", err)
+		}
+		if saved.Operation != "" && saved.Operation != checkpoint.Operation {
+			return fmt.Errorf("checkpoint %s is of operation %q", b.CheckpointPath, saved.Operation)
+		}
+		checkpoint.Done = saved.Done
+	}
+	done := map[string]bool{}
+	for _, h := range checkpoint.Done {
+		done[h] = true
+	}
+
+	progress := Progress{Total: len(hosts)}
+	var pending []string
+	for _, h := range hosts {
+		if done[h] {
+			progress.Skipped++
+		} else {
+			pending = append(pending, h)
+		}
+	}
+
+	var mu sync.Mutex
+	var failed []string
+	var saveErr error
+	save := func() {
+		if b.CheckpointPath == "" {
+			return
+		}
+		if err := checkpoint.Save(b.CheckpointPath); err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+	finish := func(host string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Host, progress.Err = host, err
+		if err != nil {
+			progress.Failed++
+			failed = append(failed, fmt.Sprintf("%s: %v", host, err))
+		} else {
+			progress.Done++
+			checkpoint.Done = append(checkpoint.Done, host)
+			if progress.Done%checkpointEvery == 0 {
+				save()
+			}
+		}
+		if b.Progress != nil {
+			b.Progress(progress)
+		}
+	}
+
+	parallel.Run(ctx, b.Concurrency, len(pending), func(i int) {
+		finish(pending[i], op.Apply(ctx, b, pending[i]))
+	})
+	save()
+
+	if saveErr != nil {
+		return fmt.Errorf("saving checkpoint: %w", saveErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%d of %d host(s) failed: %s", len(failed), len(pending), strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package incident
+
+import (
+	_context "context"
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Declaration describes a new incident.
+type Declaration struct {
+	Title            string
+	CustomerImpacted bool
+	Fields           []Field
+	// CommanderEmail is the email of the user to assign as commander.
+	CommanderEmail string
+	// ResponderEmails are the emails of the users to notify of the incident.
+	ResponderEmails []string
+	// Notes are Markdown cells placed at the beginning of the timeline.
+	Notes []string
+}
+
+// setDefault sets the field unless the declaration already does.
+func (d *Declaration) setDefault(name, value string) {
+	for _, f := range d.Fields {
+		if f.Name == name {
+			return
+		}
+	}
+	d.Fields = append(d.Fields, Field{Name: name, Values: []string{value}})
+}
+
+// Declare creates an incident.
+func (m *Manager) Declare(ctx _context.Context, d Declaration) (datadogV2.IncidentResponseData, error) {
+	if d.Title == "" {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("incident title is required")
+	}
+	if s := fieldValue(d.Fields, "state"); s != "" {
+		if _, ok := transitions[State(s)]; !ok {
+			return datadogV2.IncidentResponseData{}, fmt.Errorf("invalid state %q", s)
+		}
+	}
+	attrs := datadogV2.NewIncidentCreateAttributes(d.CustomerImpacted, d.Title)
+	if d.CustomerImpacted {
+		// The create attributes do not declare the customer impact start.
+		attrs.AdditionalProperties = map[string]interface{}{"customer_impact_start": m.now()}
+	}
+	if len(d.Fields) > 0 {
+		attrs.Fields = make(map[string]datadogV2.IncidentFieldAttributes, len(d.Fields))
+		for _, f := range d.Fields {
+			value, err := fieldAttributes(f, nil)
+			if err != nil {
+				return datadogV2.IncidentResponseData{}, err
+			}
+			attrs.Fields[f.Name] = value
+		}
+	}
+	for _, note := range d.Notes {
+		content := datadogV2.NewIncidentTimelineCellMarkdownCreateAttributesContent()
+		content.SetContent(note)
+		cell := datadogV2.NewIncidentTimelineCellMarkdownCreateAttributes(datadogV2.INCIDENTTIMELINECELLMARKDOWNCONTENTTYPE_MARKDOWN, *content)
+		attrs.InitialCells = append(attrs.InitialCells, datadogV2.IncidentTimelineCellMarkdownCreateAttributesAsIncidentTimelineCellCreateAttributes(cell))
+	}
+	handles, err := m.notificationHandles(ctx, d.ResponderEmails)
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, err
+	}
+	attrs.NotificationHandles = handles
+
+	data := datadogV2.NewIncidentCreateData(*attrs, datadogV2.INCIDENTTYPE_INCIDENTS)
+	if d.CommanderEmail != "" {
+		commander, err := m.lookupUser(ctx, d.CommanderEmail)
+		if err != nil {
+			return datadogV2.IncidentResponseData{}, err
+		}
+		data.Relationships = datadogV2.NewIncidentCreateRelationships(*relationshipToUser(commander))
+	}
+	resp, _, err := m.api.CreateIncident(ctx, *datadogV2.NewIncidentCreateRequest(*data))
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("creating incident %q: %w", d.Title, err)
+	}
+	return resp.Data, nil
+}
+
+// DeclareFromSignal creates an incident from a security signal and links the
+// signal to it. The title, severity and first timeline cell are taken from
+// the signal unless set in the declaration.
+func (m *Manager) DeclareFromSignal(ctx _context.Context, signalID string, d Declaration) (datadogV2.IncidentResponseData, error) {
+	if m.Signals == nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("declaring incidents from signals requires the security monitoring API")
+	}
+	signal, _, err := m.Signals.GetSecurityMonitoringSignal(ctx, signalID)
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("getting signal %s: %w", signalID, err)
+	}
+	if d.Title == "" {
+		d.Title = signalFromModel(signal).Title
+	}
+	if d.Title == "" {
+		d.Title = "Security signal " + signalID
+	}
+	var status string
+	if signal.Attributes != nil {
+		status, _ = signal.Attributes.Attributes["status"].(string)
+	}
+	d.setDefault("severity", signalSeverity(status))
+	d.setDefault("detection_method", "monitor")
+	if signal.Attributes != nil && signal.Attributes.GetMessage() != "" {
+		d.Notes = append([]string{fmt.Sprintf("Declared from security signal %s.\n\n%s", signalID, signal.Attributes.GetMessage())}, d.Notes...)
+	}
+
+	incident, err := m.Declare(ctx, d)
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, err
+	}
+	ids := append(signalIncidentIDs(signal), incident.Attributes.GetPublicId())
+	body := datadogV2.NewSecurityMonitoringSignalIncidentsUpdateRequest(
+		*datadogV2.NewSecurityMonitoringSignalIncidentsUpdateData(*datadogV2.NewSecurityMonitoringSignalIncidentsUpdateAttributes(ids)))
+	if _, _, err := m.Signals.EditSecurityMonitoringSignalIncidents(ctx, signalID, *body); err != nil {
+		return incident, fmt.Errorf("linking signal %s to incident %s: %w", signalID, incident.Id, err)
+	}
+	return incident, nil
+}
+
+// DeclareFromMonitor creates an incident from a monitor. The title, severity
+// and first timeline cell are taken from the monitor unless set in the
+// declaration.
+func (m *Manager) DeclareFromMonitor(ctx _context.Context, monitorID int64, d Declaration) (datadogV2.IncidentResponseData, error) {
+	if m.Monitors == nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("declaring incidents from monitors requires the monitors API")
+	}
+	monitor, _, err := m.Monitors.GetMonitor(ctx, monitorID)
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("getting monitor %d: %w", monitorID, err)
+	}
+	if d.Title == "" {
+		d.Title = monitor.GetName()
+	}
+	d.setDefault("severity", monitorSeverity(monitor.Priority.Get()))
+	d.setDefault("detection_method", "monitor")
+	note := fmt.Sprintf("Declared from monitor %d **%s**", monitorID, monitor.GetName())
+	if state, ok := monitor.GetOverallStateOk(); ok {
+		note += fmt.Sprintf(" in state %s", *state)
+	}
+	note += "."
+	if message := monitor.GetMessage(); message != "" {
+		note += "\n\n" + message
+	}
+	d.Notes = append([]string{note}, d.Notes...)
+	return m.Declare(ctx, d)
+}
+
+// signalSeverity maps the status of a signal to an incident severity.
+func signalSeverity(status string) string {
+	switch strings.ToLower(status) {
+	case "critical":
+		return "SEV-1"
+	case "high":
+		return "SEV-2"
+	case "medium":
+		return "SEV-3"
+	case "low":
+		return "SEV-4"
+	case "info":
+		return "SEV-5"
+	}
+	return "UNKNOWN"
+}
+
+// monitorSeverity maps the priority of a monitor to an incident severity.
+func monitorSeverity(priority *int64) string {
+	if priority == nil || *priority < 1 || *priority > 5 {
+		return "UNKNOWN"
+	}
+	return fmt.Sprintf("SEV-%d", *priority)
+}
+
+func fieldValue(fields []Field, name string) string {
+	for _, f := range fields {
+		if f.Name == name && len(f.Values) > 0 {
+			return f.Values[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package incident
+
+import (
+	_context "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Exporter fetches incidents and publishes their postmortems.
+type Exporter struct {
+	api *datadogV2.IncidentsApi
+	// Signals, when set, is used to find the security signals triaged to the
+	// incident.
+	Signals *datadogV2.SecurityMonitoringApi
+}
+
+// NewExporter returns an exporter using the given API. The incident
+// operations are unstable and must be enabled in the configuration.
+func NewExporter(api *datadogV2.IncidentsApi) *Exporter {
+	return &Exporter{api: api}
+}
+
+// Postmortem fetches an incident, its attachments and linked signals.
+func (e *Exporter) Postmortem(ctx _context.Context, incidentID string) (*Postmortem, error) {
+	include := []datadogV2.IncidentRelatedObject{datadogV2.INCIDENTRELATEDOBJECT_USERS, datadogV2.INCIDENTRELATEDOBJECT_ATTACHMENTS}
+	incident, resp, err := e.api.GetIncident(ctx, incidentID, *datadogV2.NewGetIncidentOptionalParameters().WithInclude(include))
+	if err != nil {
+		return nil, fmt.Errorf("getting incident %s: %w", incidentID, err)
+	}
+	responders, err := responderIDs(resp)
+	if err != nil {
+		return nil, fmt.Errorf("reading responders of incident %s: %w", incidentID, err)
+	}
+	attachments, _, err := e.api.ListIncidentAttachments(ctx, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("listing attachments of incident %s: %w", incidentID, err)
+	}
+	var signals []datadogV2.SecurityMonitoringSignal
+	if e.Signals != nil && incident.Data.Attributes != nil {
+		if signals, err = e.linkedSignals(ctx, *incident.Data.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	return NewPostmortem(incident, responders, attachments.Data, signals), nil
+}
+
+// responderIDs reads the user IDs of the responders relationship from the
+// incident response body, which the client buffers after decoding it.
+func responderIDs(resp *http.Response) ([]string, error) {
+	var body struct {
+		Data struct {
+			Relationships struct {
+				Responders struct {
+					Data []struct {
+						ID string `json:"id"`
+					} `json:"data"`
+				} `json:"responders"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, r := range body.Data.Relationships.Responders.Data {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+func (e *Exporter) linkedSignals(ctx _context.Context, attrs datadogV2.IncidentResponseAttributes) ([]datadogV2.SecurityMonitoringSignal, error) {
+	publicID := attrs.GetPublicId()
+	params := datadogV2.NewListSecurityMonitoringSignalsOptionalParameters().
+		WithFilterQuery(fmt.Sprintf("@workflow.triage.incident_ids:%d", publicID)).
+		WithPageLimit(1000)
+	if attrs.Created != nil {
+		params = params.WithFilterFrom(attrs.Created.Add(-24 * time.Hour))
+	}
+	items, cancel := e.Signals.ListSecurityMonitoringSignalsWithPagination(ctx, *params)
+	defer cancel()
+	var signals []datadogV2.SecurityMonitoringSignal
+	for item := range items {
+		if item.Error != nil {
+			return nil, fmt.Errorf("listing signals of incident %d: %w", publicID, item.Error)
+		}
+		for _, id := range signalIncidentIDs(item.Item) {
+			if id == publicID {
+				signals = append(signals, item.Item)
+				break
+			}
+		}
+	}
+	return signals, nil
+}
+
+// PublishPostmortem attaches the document at the URL as the postmortem of the
+// incident, replacing the current postmortem attachment if any.
+func (e *Exporter) PublishPostmortem(ctx _context.Context, incidentID, title, documentURL string) (datadogV2.IncidentAttachmentData, error) {
+	existing, _, err := e.api.ListIncidentAttachments(ctx, incidentID, *datadogV2.NewListIncidentAttachmentsOptionalParameters().
+		WithFilterAttachmentType([]datadogV2.IncidentAttachmentAttachmentType{datadogV2.INCIDENTATTACHMENTATTACHMENTTYPE_POSTMORTEM}))
+	if err != nil {
+		return datadogV2.IncidentAttachmentData{}, fmt.Errorf("listing attachments of incident %s: %w", incidentID, err)
+	}
+	attrs := datadogV2.IncidentAttachmentPostmortemAttributesAsIncidentAttachmentUpdateAttributes(&datadogV2.IncidentAttachmentPostmortemAttributes{
+		Attachment:     datadogV2.IncidentAttachmentsPostmortemAttributesAttachmentObject{DocumentUrl: documentURL, Title: title},
+		AttachmentType: datadogV2.INCIDENTATTACHMENTPOSTMORTEMATTACHMENTTYPE_POSTMORTEM,
+	})
+	update := datadogV2.IncidentAttachmentUpdateData{Attributes: &attrs, Type: datadogV2.INCIDENTATTACHMENTTYPE_INCIDENT_ATTACHMENTS}
+	for _, a := range existing.Data {
+		if a.Attributes.IncidentAttachmentPostmortemAttributes != nil {
+			id := a.Id
+			update.Id = &id
+			break
+		}
+	}
+	resp, _, err := e.api.UpdateIncidentAttachments(ctx, incidentID, datadogV2.IncidentAttachmentUpdateRequest{
+		Data: []datadogV2.IncidentAttachmentUpdateData{update},
+	})
+	if err != nil {
+		return datadogV2.IncidentAttachmentData{}, fmt.Errorf("updating attachments of incident %s: %w", incidentID, err)
+	}
+	for _, a := range resp.Data {
+		if a.Attributes.IncidentAttachmentPostmortemAttributes != nil {
+			return a, nil
+		}
+	}
+	return datadogV2.IncidentAttachmentData{}, fmt.Errorf("incident %s has no postmortem attachment after the update", incidentID)
+}
+
+// signalIncidentIDs returns the incidents a signal was triaged to.
+func signalIncidentIDs(s datadogV2.SecurityMonitoringSignal) []int64 {
+	if s.Attributes == nil {
+		return nil
+	}
+	workflow, _ := s.Attributes.Attributes["workflow"].(map[string]interface{})
+	triage, _ := workflow["triage"].(map[string]interface{})
+	raw, _ := triage["incident_ids"].([]interface{})
+	ids := make([]int64, 0, len(raw))
+	for _, v := range raw {
+		if f, ok := v.(float64); ok {
+			ids = append(ids, int64(f))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,313 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package incident
+
+import (
+	_context "context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// State is the state of an incident, stored in its "state" field.
+type State string
+
+// List of State.
+const (
+	StateActive   State = "active"
+	StateStable   State = "stable"
+	StateResolved State = "resolved"
+)
+
+var transitions = map[State][]State{
+	StateActive:   {StateStable, StateResolved},
+	StateStable:   {StateActive, StateResolved},
+	StateResolved: {StateActive},
+}
+
+// CanTransition reports whether an incident can move from a state to
+// another. Incidents go from active to stable to resolved, may skip the
+// stable state, and may be reopened.
+func CanTransition(from, to State) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned when an update would move an incident to a
+// state it cannot reach from its current state.
+type TransitionError struct {
+	From State
+	To   State
+}
+
+// Error implements the error interface.
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid transition from %s to %s", e.From, e.To)
+}
+
+// StateOf returns the state of an incident, active when the field is unset.
+func StateOf(attrs datadogV2.IncidentResponseAttributes) State {
+	if values := fieldValues(attrs.Fields["state"]); len(values) > 0 {
+		return State(values[0])
+	}
+	return StateActive
+}
+
+// Update is a change to an incident. Zero values are left unchanged.
+type Update struct {
+	Title string
+	State State
+	// Fields are the fields to set. Fields without values are cleared.
+	Fields []Field
+	// CommanderEmail is the email of the user to assign as commander.
+	CommanderEmail string
+	// ResponderEmails are the emails of the users to notify of the update.
+	ResponderEmails []string
+	// CustomerImpacted, when set, starts or ends the customer impact.
+	CustomerImpacted    *bool
+	CustomerImpactScope string
+}
+
+// Manager updates incidents through their lifecycle, validating state
+// transitions and fields locally before sending them.
+type Manager struct {
+	api   *datadogV2.IncidentsApi
+	users *datadogV2.UsersApi
+	// Signals is used to declare incidents from security signals.
+	Signals *datadogV2.SecurityMonitoringApi
+	// Monitors is used to declare incidents from monitors.
+	Monitors *datadogV1.MonitorsApi
+	// Now returns the time used to stamp the customer impact, time.Now when
+	// nil.
+	Now func() time.Time
+}
+
+// NewManager returns a manager using the given APIs. Users are looked up by
+// email with the users API. The incident operations are unstable and must be
+// enabled in the configuration.
+func NewManager(api *datadogV2.IncidentsApi, users *datadogV2.UsersApi) *Manager {
+	return &Manager{api: api, users: users}
+}
+
+// Transition moves an incident to a state.
+func (m *Manager) Transition(ctx _context.Context, incidentID string, to State) (datadogV2.IncidentResponseData, error) {
+	return m.Update(ctx, incidentID, Update{State: to})
+}
+
+// Update applies a change to an incident. The state transition and the
+// fields are checked against the current incident first.
+//
+// Moving the incident out of the active state ends an ongoing customer
+// impact, and resolving it stamps its resolved timestamp.
+func (m *Manager) Update(ctx _context.Context, incidentID string, u Update) (datadogV2.IncidentResponseData, error) {
+	current, _, err := m.api.GetIncident(ctx, incidentID)
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("getting incident %s: %w", incidentID, err)
+	}
+	body, err := m.updateRequest(ctx, current.Data, u)
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("incident %s: %w", incidentID, err)
+	}
+	resp, _, err := m.api.UpdateIncident(ctx, incidentID, body)
+	if err != nil {
+		return datadogV2.IncidentResponseData{}, fmt.Errorf("updating incident %s: %w", incidentID, err)
+	}
+	return resp.Data, nil
+}
+
+func (m *Manager) updateRequest(ctx _context.Context, incident datadogV2.IncidentResponseData, u Update) (datadogV2.IncidentUpdateRequest, error) {
+	var attrs datadogV2.IncidentResponseAttributes
+	if incident.Attributes != nil {
+		attrs = *incident.Attributes
+	}
+	now := m.now()
+	update := datadogV2.NewIncidentUpdateAttributes()
+	if u.Title != "" {
+		update.SetTitle(u.Title)
+	}
+	if u.CustomerImpactScope != "" {
+		update.SetCustomerImpactScope(u.CustomerImpactScope)
+	}
+
+	fields := map[string]datadogV2.IncidentFieldAttributes{}
+	for _, f := range u.Fields {
+		if f.Name == "state" {
+			return datadogV2.IncidentUpdateRequest{}, fmt.Errorf("the state field is changed through the state of the update")
+		}
+		value, err := fieldAttributes(f, attrs.Fields)
+		if err != nil {
+			return datadogV2.IncidentUpdateRequest{}, err
+		}
+		fields[f.Name] = value
+	}
+
+	impacted := attrs.GetCustomerImpacted() && attrs.CustomerImpactEnd.Get() == nil
+	endImpact := func() {
+		if impacted {
+			update.SetCustomerImpactEnd(now)
+		}
+	}
+	if u.CustomerImpacted != nil {
+		update.SetCustomerImpacted(*u.CustomerImpacted)
+		if *u.CustomerImpacted && !impacted {
+			update.SetCustomerImpactStart(now)
+			update.SetCustomerImpactEndNil()
+		} else if !*u.CustomerImpacted {
+			endImpact()
+		}
+	}
+	if u.State != "" {
+		from := StateOf(attrs)
+		if !CanTransition(from, u.State) {
+			return datadogV2.IncidentUpdateRequest{}, &TransitionError{From: from, To: u.State}
+		}
+		fields["state"] = singleValue(string(u.State), datadogV2.INCIDENTFIELDATTRIBUTESSINGLEVALUETYPE_DROPDOWN)
+		if u.State != StateActive {
+			endImpact()
+		}
+		if u.State == StateResolved && from != StateResolved {
+			// The update attributes do not declare the resolved timestamp.
+			update.AdditionalProperties = map[string]interface{}{"resolved": now}
+		}
+	}
+	if len(fields) > 0 {
+		update.SetFields(fields)
+	}
+
+	handles, err := m.notificationHandles(ctx, u.ResponderEmails)
+	if err != nil {
+		return datadogV2.IncidentUpdateRequest{}, err
+	}
+	update.NotificationHandles = handles
+
+	data := datadogV2.NewIncidentUpdateData(incident.Id, datadogV2.INCIDENTTYPE_INCIDENTS)
+	data.Attributes = update
+	if u.CommanderEmail != "" {
+		commander, err := m.lookupUser(ctx, u.CommanderEmail)
+		if err != nil {
+			return datadogV2.IncidentUpdateRequest{}, err
+		}
+		data.Relationships = datadogV2.NewIncidentUpdateRelationships()
+		data.Relationships.CommanderUser = relationshipToUser(commander)
+	}
+	return *datadogV2.NewIncidentUpdateRequest(*data), nil
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// lookupUser returns the enabled user with the email.
+func (m *Manager) lookupUser(ctx _context.Context, email string) (datadogV2.User, error) {
+	resp, _, err := m.users.ListUsers(ctx, *datadogV2.NewListUsersOptionalParameters().WithFilter(email))
+	if err != nil {
+		return datadogV2.User{}, fmt.Errorf("looking up user %s: %w", email, err)
+	}
+	for _, user := range resp.Data {
+		if attrs := user.Attributes; attrs != nil && strings.EqualFold(attrs.GetEmail(), email) && !attrs.GetDisabled() {
+			return user, nil
+		}
+	}
+	return datadogV2.User{}, fmt.Errorf("no enabled user with email %s", email)
+}
+
+func (m *Manager) notificationHandles(ctx _context.Context, emails []string) ([]datadogV2.IncidentNotificationHandle, error) {
+	var handles []datadogV2.IncidentNotificationHandle
+	for _, email := range emails {
+		user, err := m.lookupUser(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		handle := datadogV2.NewIncidentNotificationHandle()
+		handle.SetHandle(user.Attributes.GetEmail())
+		if name := user.Attributes.GetName(); name != "" {
+			handle.SetDisplayName(name)
+		}
+		handles = append(handles, *handle)
+	}
+	return handles, nil
+}
+
+func relationshipToUser(user datadogV2.User) *datadogV2.NullableRelationshipToUser {
+	data := datadogV2.NewNullableRelationshipToUserData(user.GetId(), datadogV2.USERSTYPE_USERS)
+	return datadogV2.NewNullableRelationshipToUser(*datadogV2.NewNullableNullableRelationshipToUserData(data))
+}
+
+// allowedValues are the values of the default fields with fixed choices.
+var allowedValues = map[string][]string{
+	"severity":         {"UNKNOWN", "SEV-1", "SEV-2", "SEV-3", "SEV-4", "SEV-5"},
+	"detection_method": {"customer", "employee", "monitor", "other", "unknown"},
+}
+
+// fieldAttributes builds the value of a field. The type of the field is the
+// one of the current fields of the incident; when creating an incident,
+// fields with one value are assumed to be dropdowns.
+func fieldAttributes(f Field, current map[string]datadogV2.IncidentFieldAttributes) (datadogV2.IncidentFieldAttributes, error) {
+	if allowed, ok := allowedValues[f.Name]; ok {
+		for _, v := range f.Values {
+			if !contains(allowed, v) {
+				return datadogV2.IncidentFieldAttributes{}, fmt.Errorf("invalid %s %q, expected one of %s", f.Name, v, strings.Join(allowed, ", "))
+			}
+		}
+	}
+	existing, ok := current[f.Name]
+	switch {
+	case current != nil && !ok:
+		return datadogV2.IncidentFieldAttributes{}, fmt.Errorf("unknown field %q", f.Name)
+	case existing.IncidentFieldAttributesMultipleValue != nil:
+		return multipleValue(f.Values, existing.IncidentFieldAttributesMultipleValue.GetType()), nil
+	case existing.IncidentFieldAttributesSingleValue != nil || (!ok && len(f.Values) <= 1):
+		kind := datadogV2.INCIDENTFIELDATTRIBUTESSINGLEVALUETYPE_DROPDOWN
+		if existing.IncidentFieldAttributesSingleValue != nil {
+			kind = existing.IncidentFieldAttributesSingleValue.GetType()
+		}
+		if len(f.Values) > 1 {
+			return datadogV2.IncidentFieldAttributes{}, fmt.Errorf("field %q takes a single value, got %d", f.Name, len(f.Values))
+		}
+		value := ""
+		if len(f.Values) == 1 {
+			value = f.Values[0]
+		}
+		return singleValue(value, kind), nil
+	}
+	return multipleValue(f.Values, datadogV2.INCIDENTFIELDATTRIBUTESVALUETYPE_MULTISELECT), nil
+}
+
+func singleValue(value string, kind datadogV2.IncidentFieldAttributesSingleValueType) datadogV2.IncidentFieldAttributes {
+	field := datadogV2.NewIncidentFieldAttributesSingleValue()
+	field.SetType(kind)
+	if value == "" {
+		field.SetValueNil()
+	} else {
+		field.SetValue(value)
+	}
+	return datadogV2.IncidentFieldAttributesSingleValueAsIncidentFieldAttributes(field)
+}
+
+func multipleValue(values []string, kind datadogV2.IncidentFieldAttributesValueType) datadogV2.IncidentFieldAttributes {
+	field := datadogV2.NewIncidentFieldAttributesMultipleValue()
+	field.SetType(kind)
+	field.Value = append([]string{}, values...)
+	return datadogV2.IncidentFieldAttributesMultipleValueAsIncidentFieldAttributes(field)
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package incident builds documents from incidents and helps running them.
+//
+// An Exporter assembles the fields, people, attachments and linked security
+// signals of an incident into a Postmortem, with a timeline of its key
+// moments, which renders as Markdown, HTML or JSON. Once published, the
+// document is attached back to the incident as its postmortem.
+//
+// A Manager declares incidents, from scratch, a security signal or a monitor,
+// and updates them through their states, assigning commanders and notifying
+// responders by email, with transitions and fields validated locally.
+package incident
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Postmortem is the document of an incident.
+type Postmortem struct {
+	ID                  string       `json:"id"`
+	PublicID            int64        `json:"public_id"`
+	Title               string       `json:"title"`
+	Fields              []Field      `json:"fields,omitempty"`
+	Commander           *Person      `json:"commander,omitempty"`
+	CreatedBy           *Person      `json:"created_by,omitempty"`
+	Responders          []Person     `json:"responders,omitempty"`
+	Created             *time.Time   `json:"created,omitempty"`
+	Detected            *time.Time   `json:"detected,omitempty"`
+	Resolved            *time.Time   `json:"resolved,omitempty"`
+	CustomerImpacted    bool         `json:"customer_impacted"`
+	CustomerImpactScope string       `json:"customer_impact_scope,omitempty"`
+	CustomerImpactStart *time.Time   `json:"customer_impact_start,omitempty"`
+	CustomerImpactEnd   *time.Time   `json:"customer_impact_end,omitempty"`
+	TimeToDetect        *Duration    `json:"time_to_detect,omitempty"`
+	TimeToRepair        *Duration    `json:"time_to_repair,omitempty"`
+	TimeToResolve       *Duration    `json:"time_to_resolve,omitempty"`
+	Attachments         []Attachment `json:"attachments,omitempty"`
+	Signals             []Signal     `json:"signals,omitempty"`
+	Timeline            []Event      `json:"timeline"`
+}
+
+// Field is an incident field, such as severity or state.
+type Field struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Person is a user involved in an incident.
+type Person struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Handle string `json:"handle,omitempty"`
+}
+
+// String returns the name and the email of the user.
+func (p Person) String() string {
+	switch {
+	case p.Name != "" && p.Email != "":
+		return fmt.Sprintf("%s <%s>", p.Name, p.Email)
+	case p.Name != "":
+		return p.Name
+	case p.Email != "":
+		return p.Email
+	case p.Handle != "":
+		return p.Handle
+	}
+	return p.ID
+}
+
+// Attachment is a document attached to an incident.
+type Attachment struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Signal is a security signal linked to an incident.
+type Signal struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Timestamp time.Time `json:"timestamp"`
+	Tags      []string  `json:"tags,omitempty"`
+}
+
+// Event is a moment of the timeline of an incident.
+type Event struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	Text string    `json:"text"`
+}
+
+// Duration is a duration in seconds.
+type Duration int64
+
+// String formats the duration, e.g. "1h30m0s".
+func (d Duration) String() string {
+	return (time.Duration(d) * time.Second).String()
+}
+
+// List of Event kinds.
+const (
+	EventCreated             = "created"
+	EventDetected            = "detected"
+	EventCustomerImpactStart = "customer_impact_start"
+	EventCustomerImpactEnd   = "customer_impact_end"
+	EventSignal              = "signal"
+	EventResolved            = "resolved"
+)
+
+// NewPostmortem assembles the postmortem of an incident fetched with its
+// users and attachments included. Responders are the users of the responders
+// relationship, given by ID since the incident model does not decode it; the
+// creator and last editor of the incident are not responders. Attachments
+// listed separately and linked signals are merged into the document.
+func NewPostmortem(incident datadogV2.IncidentResponse, responders []string, attachments []datadogV2.IncidentAttachmentData, signals []datadogV2.SecurityMonitoringSignal) *Postmortem {
+	data := incident.Data
+	p := &Postmortem{ID: data.Id}
+	users := map[string]Person{}
+	for _, item := range incident.Included {
+		switch {
+		case item.User != nil:
+			person := personFromUser(*item.User)
+			users[person.ID] = person
+		case item.IncidentAttachmentData != nil:
+			attachments = append(attachments, *item.IncidentAttachmentData)
+		}
+	}
+	if attrs := data.Attributes; attrs != nil {
+		p.Title = attrs.Title
+		p.PublicID = attrs.GetPublicId()
+		p.Created = attrs.Created
+		p.Detected = attrs.Detected.Get()
+		p.Resolved = attrs.Resolved.Get()
+		p.CustomerImpacted = attrs.GetCustomerImpacted()
+		p.CustomerImpactScope = attrs.GetCustomerImpactScope()
+		p.CustomerImpactStart = attrs.CustomerImpactStart.Get()
+		p.CustomerImpactEnd = attrs.CustomerImpactEnd.Get()
+		p.TimeToDetect = duration(attrs.TimeToDetect)
+		p.TimeToRepair = duration(attrs.TimeToRepair)
+		p.TimeToResolve = duration(attrs.TimeToResolve)
+		names := make([]string, 0, len(attrs.Fields))
+		for name := range attrs.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if values := fieldValues(attrs.Fields[name]); len(values) > 0 {
+				p.Fields = append(p.Fields, Field{Name: name, Values: values})
+			}
+		}
+	}
+	lookup := func(id string) *Person {
+		if id == "" {
+			return nil
+		}
+		person, ok := users[id]
+		if !ok {
+			person = Person{ID: id}
+		}
+		return &person
+	}
+	if rel := data.Relationships; rel != nil {
+		if rel.CommanderUser != nil {
+			if commander := rel.CommanderUser.Data.Get(); commander != nil {
+				p.Commander = lookup(commander.Id)
+			}
+		}
+		if rel.CreatedByUser != nil {
+			p.CreatedBy = lookup(rel.CreatedByUser.Data.Id)
+		}
+	}
+	for _, id := range responders {
+		if p.Commander != nil && id == p.Commander.ID {
+			continue
+		}
+		if person := lookup(id); person != nil {
+			p.Responders = append(p.Responders, *person)
+		}
+	}
+	sort.Slice(p.Responders, func(i, j int) bool { return p.Responders[i].String() < p.Responders[j].String() })
+
+	seen := map[string]bool{}
+	for _, a := range attachments {
+		if seen[a.Id] {
+			continue
+		}
+		seen[a.Id] = true
+		if attachment, ok := attachmentFromData(a); ok {
+			p.Attachments = append(p.Attachments, attachment)
+		}
+	}
+	for _, s := range signals {
+		p.Signals = append(p.Signals, signalFromModel(s))
+	}
+	sort.SliceStable(p.Signals, func(i, j int) bool { return p.Signals[i].Timestamp.Before(p.Signals[j].Timestamp) })
+	p.Timeline = p.timeline()
+	return p
+}
+
+// Postmortem returns the postmortem attachment, if any.
+func (p *Postmortem) Postmortem() (Attachment, bool) {
+	for _, a := range p.Attachments {
+		if a.Type == string(datadogV2.INCIDENTATTACHMENTPOSTMORTEMATTACHMENTTYPE_POSTMORTEM) {
+			return a, true
+		}
+	}
+	return Attachment{}, false
+}
+
+func (p *Postmortem) timeline() []Event {
+	events := []Event{}
+	add := func(t *time.Time, kind, text string) {
+		if t != nil && !t.IsZero() {
+			events = append(events, Event{Time: t.UTC(), Kind: kind, Text: text})
+		}
+	}
+	created := "Incident declared"
+	if p.CreatedBy != nil {
+		created += " by " + p.CreatedBy.String()
+	}
+	add(p.Created, EventCreated, created)
+	add(p.Detected, EventDetected, "Incident detected")
+	impact := "Customer impact started"
+	if p.CustomerImpactScope != "" {
+		impact += ": " + p.CustomerImpactScope
+	}
+	add(p.CustomerImpactStart, EventCustomerImpactStart, impact)
+	add(p.CustomerImpactEnd, EventCustomerImpactEnd, "Customer impact ended")
+	for i := range p.Signals {
+		s := p.Signals[i]
+		add(&s.Timestamp, EventSignal, "Security signal: "+s.Title)
+	}
+	add(p.Resolved, EventResolved, "Incident resolved")
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
+
+func personFromUser(u datadogV2.User) Person {
+	person := Person{ID: u.GetId()}
+	if attrs := u.Attributes; attrs != nil {
+		person.Name = attrs.GetName()
+		person.Email = attrs.GetEmail()
+		person.Handle = attrs.GetHandle()
+	}
+	return person
+}
+
+func fieldValues(f datadogV2.IncidentFieldAttributes) []string {
+	switch {
+	case f.IncidentFieldAttributesSingleValue != nil:
+		if v := f.IncidentFieldAttributesSingleValue.Value.Get(); v != nil && *v != "" {
+			return []string{*v}
+		}
+	case f.IncidentFieldAttributesMultipleValue != nil:
+		return f.IncidentFieldAttributesMultipleValue.Value
+	}
+	return nil
+}
+
+func attachmentFromData(a datadogV2.IncidentAttachmentData) (Attachment, bool) {
+	switch attrs := a.Attributes; {
+	case attrs.IncidentAttachmentPostmortemAttributes != nil:
+		pm := attrs.IncidentAttachmentPostmortemAttributes
+		return Attachment{ID: a.Id, Type: string(pm.AttachmentType), Title: pm.Attachment.Title, URL: pm.Attachment.DocumentUrl}, true
+	case attrs.IncidentAttachmentLinkAttributes != nil:
+		link := attrs.IncidentAttachmentLinkAttributes
+		return Attachment{ID: a.Id, Type: string(link.AttachmentType), Title: link.Attachment.Title, URL: link.Attachment.DocumentUrl}, true
+	}
+	return Attachment{}, false
+}
+
+func signalFromModel(s datadogV2.SecurityMonitoringSignal) Signal {
+	signal := Signal{ID: s.GetId()}
+	if attrs := s.Attributes; attrs != nil {
+		signal.Timestamp = attrs.GetTimestamp().UTC()
+		signal.Tags = attrs.Tags
+		title, _, _ := strings.Cut(attrs.GetMessage(), "\n")
+		signal.Title = strings.TrimSpace(strings.TrimLeft(title, "#"))
+		if rule, ok := attrs.Attributes["title"].(string); ok && rule != "" {
+			signal.Title = rule
+		}
+	}
+	if signal.Title == "" {
+		signal.Title = signal.ID
+	}
+	return signal
+}
+
+func duration(seconds *int64) *Duration {
+	if seconds == nil {
+		return nil
+	}
+	d := Duration(*seconds)
+	return &d
+}
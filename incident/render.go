@@ -0,0 +1,185 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package incident
+
+import (
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var templateFuncs = map[string]interface{}{
+	"time": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04:05 UTC")
+	},
+	"join": strings.Join,
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	},
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(templateFuncs).Parse(`# Incident #{{ .PublicID }}: {{ .Title }}
+
+| | |
+|---|---|
+{{- with .Commander }}
+| Commander | {{ cell .String }} |
+{{- end }}
+{{- range .Fields }}
+| {{ cell .Name }} | {{ cell (join .Values ", ") }} |
+{{- end }}
+{{- with .Created }}
+| Declared | {{ time . }} |
+{{- end }}
+{{- with .Resolved }}
+| Resolved | {{ time . }} |
+{{- end }}
+{{- with .TimeToDetect }}
+| Time to detect | {{ . }} |
+{{- end }}
+{{- with .TimeToRepair }}
+| Time to repair | {{ . }} |
+{{- end }}
+{{- with .TimeToResolve }}
+| Time to resolve | {{ . }} |
+{{- end }}
+{{ if .CustomerImpacted }}
+## Customer impact
+{{ with .CustomerImpactScope }}
+{{ . }}
+{{ end }}
+{{- with .CustomerImpactStart }}
+- Started: {{ time . }}
+{{- end }}
+{{- with .CustomerImpactEnd }}
+- Ended: {{ time . }}
+{{- end }}
+{{ end }}
+## Timeline
+
+| Time | Event |
+|---|---|
+{{- range .Timeline }}
+| {{ time .Time }} | {{ cell .Text }} |
+{{- end }}
+{{ with .Responders }}
+## Responders
+{{ range . }}
+- {{ .String }}
+{{- end }}
+{{ end }}
+{{- with .Attachments }}
+## Attachments
+{{ range . }}
+- [{{ .Title }}]({{ .URL }}) ({{ .Type }})
+{{- end }}
+{{ end }}
+{{- with .Signals }}
+## Security signals
+{{ range . }}
+- {{ time .Timestamp }}: {{ .Title }} ({{ .ID }})
+{{- end }}
+{{ end -}}
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Incident #{{ .PublicID }}: {{ .Title }}</title>
+</head>
+<body>
+<h1>Incident #{{ .PublicID }}: {{ .Title }}</h1>
+<table>
+{{- with .Commander }}
+<tr><th>Commander</th><td>{{ .String }}</td></tr>
+{{- end }}
+{{- range .Fields }}
+<tr><th>{{ .Name }}</th><td>{{ join .Values ", " }}</td></tr>
+{{- end }}
+{{- with .Created }}
+<tr><th>Declared</th><td>{{ time . }}</td></tr>
+{{- end }}
+{{- with .Resolved }}
+<tr><th>Resolved</th><td>{{ time . }}</td></tr>
+{{- end }}
+{{- with .TimeToDetect }}
+<tr><th>Time to detect</th><td>{{ . }}</td></tr>
+{{- end }}
+{{- with .TimeToRepair }}
+<tr><th>Time to repair</th><td>{{ . }}</td></tr>
+{{- end }}
+{{- with .TimeToResolve }}
+<tr><th>Time to resolve</th><td>{{ . }}</td></tr>
+{{- end }}
+</table>
+{{- if .CustomerImpacted }}
+<h2>Customer impact</h2>
+{{- with .CustomerImpactScope }}
+<p>{{ . }}</p>
+{{- end }}
+<ul>
+{{- with .CustomerImpactStart }}
+<li>Started: {{ time . }}</li>
+{{- end }}
+{{- with .CustomerImpactEnd }}
+<li>Ended: {{ time . }}</li>
+{{- end }}
+</ul>
+{{- end }}
+<h2>Timeline</h2>
+<table>
+<tr><th>Time</th><th>Event</th></tr>
+{{- range .Timeline }}
+<tr class="{{ .Kind }}"><td>{{ time .Time }}</td><td>{{ .Text }}</td></tr>
+{{- end }}
+</table>
+{{- with .Responders }}
+<h2>Responders</h2>
+<ul>
+{{- range . }}
+<li>{{ .String }}</li>
+{{- end }}
+</ul>
+{{- end }}
+{{- with .Attachments }}
+<h2>Attachments</h2>
+<ul>
+{{- range . }}
+<li><a href="{{ .URL }}">{{ .Title }}</a> ({{ .Type }})</li>
+{{- end }}
+</ul>
+{{- end }}
+{{- with .Signals }}
+<h2>Security signals</h2>
+<ul>
+{{- range . }}
+<li>{{ time .Timestamp }}: {{ .Title }} ({{ .ID }})</li>
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`))
+
+// WriteMarkdown writes the postmortem as a Markdown document.
+func (p *Postmortem) WriteMarkdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, p)
+}
+
+// WriteHTML writes the postmortem as an HTML page.
+func (p *Postmortem) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, p)
+}
+
+// WriteJSON writes the postmortem as indented JSON.
+func (p *Postmortem) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
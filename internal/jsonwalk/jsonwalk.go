@@ -0,0 +1,21 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package jsonwalk walks JSON values decoded into interface{}.
+package jsonwalk
+
+// Maps calls fn for every map at any depth of a value.
+func Maps(v interface{}, fn func(map[string]interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		fn(v)
+		for _, e := range v {
+			Maps(e, fn)
+		}
+	case []interface{}:
+		for _, e := range v {
+			Maps(e, fn)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package parallel runs calls with a bounded concurrency.
+package parallel
+
+import (
+	_context "context"
+	"sync"
+)
+
+// Run calls fn for the indexes from 0 to n-1, in order, with at most
+// concurrency calls running at once, and at least one. No call is started
+// once the context is done. Run returns when the calls started are done,
+// with their number: the indexes from that number on had no call.
+func Run(ctx _context.Context, concurrency, n int, fn func(i int)) int {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	started := 0
+	for started < n && acquire(ctx, slots) {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(started)
+		started++
+	}
+	wg.Wait()
+	return started
+}
+
+// acquire takes a slot, unless the context is done first.
+func acquire(ctx _context.Context, slots chan struct{}) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,25 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package strictyaml decodes YAML configurations, rejecting unknown fields.
+package strictyaml
+
+import (
+	"errors"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decode reads a YAML document into v. A field v has no place for is an
+// error, so that a misspelled key is not silently ignored. An empty
+// document leaves v unchanged.
+func Decode(r io.Reader, v interface{}) error {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
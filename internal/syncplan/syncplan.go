@@ -0,0 +1,70 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package syncplan holds what the syncers of declarations, such as those of
+// roles and integration accounts, have in common: diffing declared values
+// against current ones, and writing plans for review.
+package syncplan
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Difference returns the sorted values wanted but not current, and current
+// but not wanted.
+func Difference(wanted, current []string) ([]string, []string) {
+	want := map[string]bool{}
+	for _, v := range wanted {
+		want[v] = true
+	}
+	have := map[string]bool{}
+	for _, v := range current {
+		have[v] = true
+	}
+	var added, removed []string
+	for v := range want {
+		if !have[v] {
+			added = append(added, v)
+		}
+	}
+	for v := range have {
+		if !want[v] {
+			removed = append(removed, v)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// Line is a change as written in a plan.
+type Line struct {
+	// Sign is "+" for additions, "-" for removals and "~" for updates.
+	Sign   string
+	Change string
+	// Details are written under the change, indented.
+	Details []string
+}
+
+// Write writes the changes of a plan, one per line followed by their
+// details, and a summary, or "No changes." when there are none.
+func Write(w io.Writer, lines []Line, summary string) error {
+	if len(lines) == 0 {
+		_, err := io.WriteString(w, "No changes.\n")
+		return err
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		fmt.Fprintf(&b, "%s %s\n", l.Sign, l.Change)
+		for _, d := range l.Details {
+			fmt.Fprintf(&b, "    %s\n", d)
+		}
+	}
+	fmt.Fprintf(&b, "\nPlan: %s.\n", summary)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,296 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package keys keeps the API and application keys of an org in check.
+//
+// A Manager inventories the API keys, the application keys and the
+// application keys of service accounts with their owners, creation dates and,
+// where a lookup provides it, the date they were last used. Audit flags the keys
+// that are stale, idle, owned by disabled users or over-privileged.
+//
+// Rotate replaces a key without downtime: the new key is created, handed to a
+// Sink such as a file, an env file or a Vault-compatible secret store,
+// verified, and only then is the old key deleted.
+package keys
+
+import (
+	_context "context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+const pageSize = 100
+
+// Kind is the kind of a key.
+type Kind string
+
+// List of Kind.
+const (
+	KindAPIKey            Kind = "api_key"
+	KindApplicationKey    Kind = "application_key"
+	KindServiceAccountKey Kind = "service_account_application_key"
+)
+
+// Key is a key of the org. The secret of keys is never listed.
+type Key struct {
+	Kind  Kind
+	ID    string
+	Name  string
+	Last4 string
+	// OwnerID is the ID of the user owning application keys, or of the user
+	// who created API keys.
+	OwnerID string
+	// Owner is the email of the owner, or its handle.
+	Owner         string
+	OwnerDisabled bool
+	CreatedAt     time.Time
+	// LastUsedAt is the last time the key was used, or zero when unknown.
+	LastUsedAt time.Time
+	// Scopes are the scopes of application keys, or nil when they are
+	// unscoped and have all the permissions of their owner.
+	Scopes []string
+}
+
+func (k Key) String() string {
+	kind := strings.ReplaceAll(string(k.Kind), "_", " ")
+	if k.Owner != "" {
+		return fmt.Sprintf("%s %q (...%s) of %s", kind, k.Name, k.Last4, k.Owner)
+	}
+	return fmt.Sprintf("%s %q (...%s)", kind, k.Name, k.Last4)
+}
+
+// Issue is a hygiene issue of a key.
+type Issue string
+
+// List of Issue.
+const (
+	IssueStale         Issue = "stale"
+	IssueIdle          Issue = "idle"
+	IssueDisabledOwner Issue = "disabled_owner"
+	IssueUnscoped      Issue = "unscoped"
+	IssuePrivileged    Issue = "privileged"
+)
+
+// Finding is an issue found on a key.
+type Finding struct {
+	Key    Key
+	Issue  Issue
+	Detail string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Key, f.Detail)
+}
+
+// Manager inventories, audits and rotates the keys of an org.
+type Manager struct {
+	keys            *datadogV2.KeyManagementApi
+	serviceAccounts *datadogV2.ServiceAccountsApi
+	users           *datadogV2.UsersApi
+	auth            *datadogV1.AuthenticationApi
+
+	// MaxAge is the age past which keys are stale.
+	MaxAge time.Duration
+	// MaxIdle is the time without use past which keys are idle.
+	MaxIdle time.Duration
+	// PrivilegedScopes are the scopes making application keys
+	// over-privileged.
+	PrivilegedScopes []string
+	// LastUsed returns the last time a key was used, or zero when unknown.
+	// The API does not return it; inventories leave it unknown unless set,
+	// for instance to a lookup of the audit trail.
+	LastUsed func(ctx _context.Context, key Key) (time.Time, error)
+	// Grace is the time rotations wait between storing the new key and
+	// deleting the old one, for the consumers of the key to reload it.
+	Grace time.Duration
+	// Verify verifies the new key of a rotation. When nil, API keys are
+	// verified with the validate endpoint, and application keys by getting
+	// them with themselves.
+	Verify func(ctx _context.Context, key Key, secret string) error
+	Now    func() time.Time
+}
+
+// NewManager returns a manager flagging keys older than 90 days or unused for
+// 30 days.
+func NewManager(client *datadog.APIClient) *Manager {
+	return &Manager{
+		keys:             datadogV2.NewKeyManagementApi(client),
+		serviceAccounts:  datadogV2.NewServiceAccountsApi(client),
+		users:            datadogV2.NewUsersApi(client),
+		auth:             datadogV1.NewAuthenticationApi(client),
+		MaxAge:           90 * 24 * time.Hour,
+		MaxIdle:          30 * 24 * time.Hour,
+		PrivilegedScopes: []string{"api_keys_write", "org_management", "user_access_manage"},
+		Now:              time.Now,
+	}
+}
+
+// Inventory lists the keys of the org, API keys first, by name.
+func (m *Manager) Inventory(ctx _context.Context) ([]Key, error) {
+	owners, err := m.listUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []Key
+	for page := int64(0); ; page++ {
+		resp, _, err := m.keys.ListAPIKeys(ctx, *datadogV2.NewListAPIKeysOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, fmt.Errorf("listing API keys: %w", err)
+		}
+		for _, k := range resp.Data {
+			key := Key{
+				Kind:      KindAPIKey,
+				ID:        k.GetId(),
+				Name:      k.Attributes.GetName(),
+				Last4:     k.Attributes.GetLast4(),
+				CreatedAt: parseTime(k.Attributes.GetCreatedAt()),
+			}
+			if k.Relationships != nil && k.Relationships.CreatedBy != nil {
+				key.setOwner(k.Relationships.CreatedBy.Data.Id, owners)
+			}
+			keys = append(keys, key)
+		}
+		if len(resp.Data) < pageSize {
+			break
+		}
+	}
+
+	var appKeys []Key
+	for page := int64(0); ; page++ {
+		resp, _, err := m.keys.ListApplicationKeys(ctx, *datadogV2.NewListApplicationKeysOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, fmt.Errorf("listing application keys: %w", err)
+		}
+		for _, k := range resp.Data {
+			key := Key{
+				Kind:      KindApplicationKey,
+				ID:        k.GetId(),
+				Name:      k.Attributes.GetName(),
+				Last4:     k.Attributes.GetLast4(),
+				CreatedAt: parseTime(k.Attributes.GetCreatedAt()),
+			}
+			if k.Attributes != nil {
+				key.Scopes = k.Attributes.Scopes
+			}
+			if k.Relationships != nil && k.Relationships.OwnedBy != nil {
+				id := k.Relationships.OwnedBy.Data.Id
+				key.setOwner(id, owners)
+				if owner, ok := owners[id]; ok && owner.Attributes.GetServiceAccount() {
+					key.Kind = KindServiceAccountKey
+				}
+			}
+			appKeys = append(appKeys, key)
+		}
+		if len(resp.Data) < pageSize {
+			break
+		}
+	}
+	sortKeys(keys)
+	sortKeys(appKeys)
+	keys = append(keys, appKeys...)
+	if m.LastUsed != nil {
+		for i := range keys {
+			if keys[i].LastUsedAt, err = m.LastUsed(ctx, keys[i]); err != nil {
+				return nil, fmt.Errorf("getting last use of %s: %w", keys[i], err)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// listUsers returns the users and service accounts of the org, by ID.
+func (m *Manager) listUsers(ctx _context.Context) (map[string]datadogV2.User, error) {
+	users := map[string]datadogV2.User{}
+	for page := int64(0); ; page++ {
+		resp, _, err := m.users.ListUsers(ctx, *datadogV2.NewListUsersOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, fmt.Errorf("listing users: %w", err)
+		}
+		for _, u := range resp.Data {
+			users[u.GetId()] = u
+		}
+		if len(resp.Data) < pageSize {
+			return users, nil
+		}
+	}
+}
+
+func (k *Key) setOwner(id string, owners map[string]datadogV2.User) {
+	k.OwnerID = id
+	owner, ok := owners[id]
+	if !ok {
+		return
+	}
+	k.Owner = owner.Attributes.GetEmail()
+	if k.Owner == "" {
+		k.Owner = owner.Attributes.GetHandle()
+	}
+	k.OwnerDisabled = owner.Attributes.GetDisabled()
+}
+
+func sortKeys(keys []Key) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].Name != keys[j].Name {
+			return keys[i].Name < keys[j].Name
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t.UTC()
+}
+
+// Audit returns the hygiene issues of keys.
+func (m *Manager) Audit(keys []Key) []Finding {
+	now := m.Now()
+	privileged := map[string]bool{}
+	for _, s := range m.PrivilegedScopes {
+		privileged[s] = true
+	}
+	var findings []Finding
+	for _, k := range keys {
+		if !k.CreatedAt.IsZero() && m.MaxAge > 0 && now.Sub(k.CreatedAt) > m.MaxAge {
+			findings = append(findings, Finding{Key: k, Issue: IssueStale,
+				Detail: fmt.Sprintf("created %d days ago", days(now.Sub(k.CreatedAt)))})
+		}
+		if !k.LastUsedAt.IsZero() && m.MaxIdle > 0 && now.Sub(k.LastUsedAt) > m.MaxIdle {
+			findings = append(findings, Finding{Key: k, Issue: IssueIdle,
+				Detail: fmt.Sprintf("last used %d days ago", days(now.Sub(k.LastUsedAt)))})
+		}
+		if k.OwnerDisabled {
+			findings = append(findings, Finding{Key: k, Issue: IssueDisabledOwner, Detail: "owner is disabled"})
+		}
+		if k.Kind == KindAPIKey {
+			continue
+		}
+		if len(k.Scopes) == 0 {
+			findings = append(findings, Finding{Key: k, Issue: IssueUnscoped, Detail: "unscoped, with all the permissions of its owner"})
+			continue
+		}
+		var scopes []string
+		for _, s := range k.Scopes {
+			if privileged[s] {
+				scopes = append(scopes, s)
+			}
+		}
+		if len(scopes) > 0 {
+			findings = append(findings, Finding{Key: k, Issue: IssuePrivileged,
+				Detail: fmt.Sprintf("privileged scopes %s", strings.Join(scopes, ", "))})
+		}
+	}
+	return findings
+}
+
+func days(d time.Duration) int {
+	return int(d / (24 * time.Hour))
+}
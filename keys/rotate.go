@@ -0,0 +1,141 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package keys
+
+import (
+	_context "context"
+	"errors"
+	"fmt"
+	_nethttp "net/http"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Rotate replaces a key with a new key of the same name and scopes, and
+// returns the new key. The new key is stored in the sink and verified before
+// the old key is deleted, so that a failed rotation leaves the old key valid.
+// The new key is deleted when it cannot be stored, and kept along with the old
+// one when it cannot be verified.
+//
+// The new key of a user application key is owned by the user of the client.
+func (m *Manager) Rotate(ctx _context.Context, key Key, sink Sink) (Key, error) {
+	created, secret, err := m.create(ctx, key)
+	if err != nil {
+		return Key{}, fmt.Errorf("creating new key: %w", err)
+	}
+	if err := sink.Store(ctx, created, secret); err != nil {
+		if _, derr := m.delete(ctx, created); derr != nil {
+			return Key{}, fmt.Errorf("storing new key: %w (deleting it: %v)", err, derr)
+		}
+		return Key{}, fmt.Errorf("storing new key: %w", err)
+	}
+	verify := m.Verify
+	if verify == nil {
+		verify = m.validate
+	}
+	if err := verify(ctx, created, secret); err != nil {
+		return created, fmt.Errorf("verifying new key %s: %w; the old key is kept", created.ID, err)
+	}
+	if m.Grace > 0 {
+		timer := time.NewTimer(m.Grace)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return created, fmt.Errorf("waiting to delete old key: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+	resp, err := m.delete(ctx, key)
+	if err != nil && !(resp != nil && resp.StatusCode == _nethttp.StatusNotFound) {
+		return created, fmt.Errorf("deleting old key: %w", err)
+	}
+	return created, nil
+}
+
+// create creates a key like another, and returns it with its secret.
+func (m *Manager) create(ctx _context.Context, like Key) (Key, string, error) {
+	key := Key{Kind: like.Kind, Name: like.Name, OwnerID: like.OwnerID, Owner: like.Owner, Scopes: like.Scopes}
+	if like.Kind == KindAPIKey {
+		resp, _, err := m.keys.CreateAPIKey(ctx, datadogV2.APIKeyCreateRequest{Data: datadogV2.APIKeyCreateData{
+			Attributes: datadogV2.APIKeyCreateAttributes{Name: like.Name},
+			Type:       datadogV2.APIKEYSTYPE_API_KEYS,
+		}})
+		if err != nil {
+			return Key{}, "", err
+		}
+		data := resp.GetData()
+		key.ID = data.GetId()
+		key.Last4 = data.Attributes.GetLast4()
+		key.CreatedAt = parseTime(data.Attributes.GetCreatedAt())
+		return key, data.Attributes.GetKey(), nil
+	}
+
+	body := datadogV2.ApplicationKeyCreateRequest{Data: datadogV2.ApplicationKeyCreateData{
+		Attributes: datadogV2.ApplicationKeyCreateAttributes{Name: like.Name, Scopes: like.Scopes},
+		Type:       datadogV2.APPLICATIONKEYSTYPE_APPLICATION_KEYS,
+	}}
+	var resp datadogV2.ApplicationKeyResponse
+	var err error
+	switch like.Kind {
+	case KindApplicationKey:
+		resp, _, err = m.keys.CreateCurrentUserApplicationKey(ctx, body)
+		key.OwnerID, key.Owner = "", ""
+	case KindServiceAccountKey:
+		resp, _, err = m.serviceAccounts.CreateServiceAccountApplicationKey(ctx, like.OwnerID, body)
+	default:
+		return Key{}, "", fmt.Errorf("unknown kind %q", like.Kind)
+	}
+	if err != nil {
+		return Key{}, "", err
+	}
+	data := resp.GetData()
+	key.ID = data.GetId()
+	key.Last4 = data.Attributes.GetLast4()
+	key.CreatedAt = parseTime(data.Attributes.GetCreatedAt())
+	if data.Relationships != nil && data.Relationships.OwnedBy != nil {
+		key.OwnerID = data.Relationships.OwnedBy.Data.Id
+	}
+	return key, data.Attributes.GetKey(), nil
+}
+
+func (m *Manager) delete(ctx _context.Context, key Key) (*_nethttp.Response, error) {
+	switch key.Kind {
+	case KindAPIKey:
+		return m.keys.DeleteAPIKey(ctx, key.ID)
+	case KindApplicationKey:
+		return m.keys.DeleteApplicationKey(ctx, key.ID)
+	case KindServiceAccountKey:
+		return m.serviceAccounts.DeleteServiceAccountApplicationKey(ctx, key.OwnerID, key.ID)
+	}
+	return nil, fmt.Errorf("unknown kind %q", key.Kind)
+}
+
+// validate verifies a new API key with the validate endpoint, which only
+// checks API keys, and a new application key by getting it with a request
+// authenticated with it.
+func (m *Manager) validate(ctx _context.Context, key Key, secret string) error {
+	auth := map[string]datadog.APIKey{}
+	if current, ok := ctx.Value(datadog.ContextAPIKeys).(map[string]datadog.APIKey); ok {
+		for name, k := range current {
+			auth[name] = k
+		}
+	}
+	if key.Kind != KindAPIKey {
+		auth["appKeyAuth"] = datadog.APIKey{Key: secret}
+		_, _, err := m.keys.GetCurrentUserApplicationKey(_context.WithValue(ctx, datadog.ContextAPIKeys, auth), key.ID)
+		return err
+	}
+	auth["apiKeyAuth"] = datadog.APIKey{Key: secret}
+	resp, _, err := m.auth.Validate(_context.WithValue(ctx, datadog.ContextAPIKeys, auth))
+	if err != nil {
+		return err
+	}
+	if !resp.GetValid() {
+		return errors.New("key is not valid")
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package keys
+
+import (
+	"bufio"
+	"bytes"
+	_context "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	_nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sink stores the secret of new keys where their consumers read them.
+type Sink interface {
+	Store(ctx _context.Context, key Key, secret string) error
+}
+
+type fileSink struct {
+	path string
+}
+
+// FileSink returns a sink writing secrets alone to a file, readable by its
+// owner only.
+func FileSink(path string) Sink {
+	return fileSink{path: path}
+}
+
+func (s fileSink) Store(ctx _context.Context, key Key, secret string) error {
+	return writeFile(s.path, []byte(secret+"\n"))
+}
+
+type envFileSink struct {
+	path     string
+	variable string
+}
+
+// EnvFileSink returns a sink setting a variable of an env file to secrets.
+// The other lines of the file are kept.
+func EnvFileSink(path, variable string) Sink {
+	return envFileSink{path: path, variable: variable}
+}
+
+func (s envFileSink) Store(ctx _context.Context, key Key, secret string) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	line := s.variable + "=" + secret
+	var out bytes.Buffer
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		text := scanner.Text()
+		name, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(text), "export "), "=")
+		if ok && strings.TrimSpace(name) == s.variable {
+			if strings.HasPrefix(strings.TrimSpace(text), "export ") {
+				text = "export " + line
+			} else {
+				text = line
+			}
+			found = true
+		}
+		out.WriteString(text + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !found {
+		out.WriteString(line + "\n")
+	}
+	return writeFile(s.path, out.Bytes())
+}
+
+// writeFile writes a file readable by its owner only, atomically.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// VaultSink writes secrets to a field of a secret of a key/value version 2
+// engine of Vault, or of a store with the same API.
+type VaultSink struct {
+	address string
+	token   string
+	path    string
+	field   string
+	// Client is the HTTP client of requests to the store.
+	Client *_nethttp.Client
+}
+
+// NewVaultSink returns a sink writing secrets to a field of the secret at a
+// path, such as "secret/data/datadog", of the store at an address.
+func NewVaultSink(address, token, path, field string) *VaultSink {
+	return &VaultSink{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		path:    strings.Trim(path, "/"),
+		field:   field,
+		Client:  _nethttp.DefaultClient,
+	}
+}
+
+// Store writes a new version of the secret with the field set. The other
+// fields of the secret are not kept, as writes replace secrets whole.
+func (s *VaultSink) Store(ctx _context.Context, key Key, secret string) error {
+	body, err := json.Marshal(map[string]interface{}{"data": map[string]string{s.field: secret}})
+	if err != nil {
+		return err
+	}
+	req, err := _nethttp.NewRequestWithContext(ctx, _nethttp.MethodPost, s.address+"/v1/"+s.path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", s.token)
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("writing secret %s: %s: %s", s.path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package extract
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// URL splits a URL into the scheme, host, port, path and query string
+// details produced by the URL parser.
+func URL(raw string, normalizeSlashes bool) (map[string]interface{}, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	path := u.Path
+	if normalizeSlashes && len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	details := map[string]interface{}{
+		"scheme": u.Scheme,
+		"host":   u.Hostname(),
+		"path":   path,
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err == nil {
+			details["port"] = float64(n)
+		}
+	}
+	if values := u.Query(); len(values) > 0 {
+		qs := map[string]interface{}{}
+		for k, v := range values {
+			if len(v) == 1 {
+				qs[k] = v[0]
+			} else {
+				list := make([]interface{}, len(v))
+				for i := range v {
+					list[i] = v[i]
+				}
+				qs[k] = list
+			}
+		}
+		details["queryString"] = qs
+	}
+	return details, nil
+}
@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package extract implements the attribute extractors shared by the log
+// pipeline processors and the grok filters.
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+type uaRule struct {
+	family  string
+	pattern *regexp.Regexp
+}
+
+// Order matters: browsers embedding another browser token are checked first.
+var browserRules = []uaRule{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Opera", regexp.MustCompile(`OPR/(\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+)(?:\.(\d+))?(?:\.(\d+))?.*Safari/`)},
+	{"IE", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)(\d+)(?:\.(\d+))?`)},
+	{"curl", regexp.MustCompile(`curl/(\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Python Requests", regexp.MustCompile(`python-requests/(\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Go-http-client", regexp.MustCompile(`Go-http-client/(\d+)(?:\.(\d+))?`)},
+}
+
+var osRules = []uaRule{
+	{"iOS", regexp.MustCompile(`(?:iPhone|CPU) OS (\d+)(?:_(\d+))?(?:_(\d+))?`)},
+	{"Android", regexp.MustCompile(`Android (\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Windows", regexp.MustCompile(`Windows NT (\d+)(?:\.(\d+))?`)},
+	{"Mac OS X", regexp.MustCompile(`Mac OS X (\d+)(?:[_.](\d+))?(?:[_.](\d+))?`)},
+	{"Chrome OS", regexp.MustCompile(`CrOS \S+ (\d+)(?:\.(\d+))?(?:\.(\d+))?`)},
+	{"Linux", regexp.MustCompile(`Linux()`)},
+}
+
+var botPattern = regexp.MustCompile(`(?i)bot|crawler|spider|slurp|synthetics`)
+
+// UserAgent extracts browser, operating system and device details from a
+// User-Agent header, in the shape produced by the user agent parser.
+func UserAgent(ua string) map[string]interface{} {
+	browser := map[string]interface{}{"family": "Other"}
+	for _, rule := range browserRules {
+		if m := rule.pattern.FindStringSubmatch(ua); m != nil {
+			browser = versioned(rule.family, m, []string{"major", "minor", "patch"})
+			break
+		}
+	}
+	os := map[string]interface{}{"family": "Other"}
+	for _, rule := range osRules {
+		if m := rule.pattern.FindStringSubmatch(ua); m != nil {
+			os = versioned(rule.family, m, []string{"major", "minor", "patch"})
+			break
+		}
+	}
+	device := map[string]interface{}{"family": "Other", "category": "Desktop"}
+	switch {
+	case botPattern.MatchString(ua):
+		device = map[string]interface{}{"family": "Spider", "category": "Bot"}
+	case strings.Contains(ua, "iPad"):
+		device = map[string]interface{}{"family": "iPad", "category": "Tablet"}
+	case strings.Contains(ua, "iPhone"):
+		device = map[string]interface{}{"family": "iPhone", "category": "Mobile"}
+	case strings.Contains(ua, "Android") && strings.Contains(ua, "Mobile"):
+		device["category"] = "Mobile"
+	case strings.Contains(ua, "Android"):
+		device["category"] = "Tablet"
+	case browser["family"] == "curl" || browser["family"] == "Python Requests" || browser["family"] == "Go-http-client":
+		device["category"] = "Other"
+	}
+	return map[string]interface{}{"browser": browser, "os": os, "device": device}
+}
+
+func versioned(family string, match []string, keys []string) map[string]interface{} {
+	out := map[string]interface{}{"family": family}
+	for i, key := range keys {
+		if i+1 < len(match) && match[i+1] != "" {
+			out[key] = match[i+1]
+		}
+	}
+	return out
+}
@@ -0,0 +1,362 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type dateField int
+
+const (
+	fieldIgnored dateField = iota
+	fieldYear
+	fieldYear2
+	fieldMonth
+	fieldMonthName
+	fieldDay
+	fieldHour24
+	fieldHour12
+	fieldMinute
+	fieldSecond
+	fieldFraction
+	fieldAmPm
+	fieldOffset
+	fieldZoneID
+	fieldZoneName
+)
+
+// dateFormat is a compiled Java style date pattern, as used by the date matcher.
+type dateFormat struct {
+	pattern  string
+	expr     string
+	re       *regexp.Regexp
+	fields   []dateField
+	location *time.Location
+}
+
+var monthNames = map[string]time.Month{}
+
+// zoneNames are the offsets of the time zone abbreviations parsed by the z
+// pattern letter. An abbreviation names either the standard or the daylight
+// saving time of a zone, so it has a fixed offset.
+var zoneNames = map[string]int{
+	"GMT": 0, "UTC": 0, "UT": 0, "Z": 0,
+	"WET": 0, "WEST": 1 * 3600, "BST": 1 * 3600,
+	"CET": 1 * 3600, "CEST": 2 * 3600, "MET": 1 * 3600, "MEST": 2 * 3600,
+	"EET": 2 * 3600, "EEST": 3 * 3600, "MSK": 3 * 3600,
+	"IST": 5*3600 + 1800, "SGT": 8 * 3600, "HKT": 8 * 3600, "AWST": 8 * 3600,
+	"JST": 9 * 3600, "KST": 9 * 3600, "ACST": 9*3600 + 1800, "ACDT": 10*3600 + 1800,
+	"AEST": 10 * 3600, "AEDT": 11 * 3600, "NZST": 12 * 3600, "NZDT": 13 * 3600,
+	"NST": -3*3600 - 1800, "NDT": -2*3600 - 1800, "AST": -4 * 3600, "ADT": -3 * 3600,
+	"EST": -5 * 3600, "EDT": -4 * 3600, "CST": -6 * 3600, "CDT": -5 * 3600,
+	"MST": -7 * 3600, "MDT": -6 * 3600, "PST": -8 * 3600, "PDT": -7 * 3600,
+	"AKST": -9 * 3600, "AKDT": -8 * 3600, "HST": -10 * 3600,
+}
+
+func init() {
+	for m := time.January; m <= time.December; m++ {
+		monthNames[strings.ToLower(m.String())] = m
+		monthNames[strings.ToLower(m.String()[:3])] = m
+	}
+}
+
+func newDateFormat(pattern, timezone, locale string) (*dateFormat, error) {
+	if locale != "" && !isEnglish(locale) {
+		return nil, fmt.Errorf("matcher date: locale %q is not supported, only English month and day names are", locale)
+	}
+	f := &dateFormat{pattern: pattern, location: time.UTC}
+	if timezone != "" {
+		loc, err := loadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("matcher date: %w", err)
+		}
+		f.location = loc
+	}
+	var embedded, capturing strings.Builder
+	add := func(expr string, field dateField) {
+		embedded.WriteString("(?:" + expr + ")")
+		if field == fieldIgnored {
+			capturing.WriteString("(?:" + expr + ")")
+			return
+		}
+		capturing.WriteString("(" + expr + ")")
+		f.fields = append(f.fields, field)
+	}
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if r == '\'' {
+			end := i + 1
+			var literal strings.Builder
+			for end < len(runes) {
+				if runes[end] == '\'' {
+					if end+1 < len(runes) && runes[end+1] == '\'' {
+						literal.WriteRune('\'')
+						end += 2
+						continue
+					}
+					break
+				}
+				literal.WriteRune(runes[end])
+				end++
+			}
+			if i+1 == end {
+				literal.WriteRune('\'')
+			}
+			add(regexp.QuoteMeta(literal.String()), fieldIgnored)
+			i = end + 1
+			continue
+		}
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			add(regexp.QuoteMeta(string(r)), fieldIgnored)
+			i++
+			continue
+		}
+		count := 1
+		for i+count < len(runes) && runes[i+count] == r {
+			count++
+		}
+		i += count
+		digits := func(n int) string {
+			if n == 1 {
+				return `\d{1,2}`
+			}
+			return fmt.Sprintf(`\d{%d}`, n)
+		}
+		switch r {
+		case 'y', 'u', 'Y':
+			if count == 2 {
+				add(`\d{2}`, fieldYear2)
+			} else {
+				add(`\d{4}`, fieldYear)
+			}
+		case 'M':
+			switch {
+			case count <= 2:
+				add(digits(count), fieldMonth)
+			case count == 3:
+				add(`[A-Za-z]{3}`, fieldMonthName)
+			default:
+				add(`[A-Za-z]+`, fieldMonthName)
+			}
+		case 'd':
+			add(digits(count), fieldDay)
+		case 'H', 'k':
+			add(digits(count), fieldHour24)
+		case 'h', 'K':
+			add(digits(count), fieldHour12)
+		case 'm':
+			add(digits(count), fieldMinute)
+		case 's':
+			add(digits(count), fieldSecond)
+		case 'S':
+			add(fmt.Sprintf(`\d{%d}`, count), fieldFraction)
+		case 'a':
+			add(`[AaPp][Mm]`, fieldAmPm)
+		case 'E':
+			add(`[A-Za-z]+`, fieldIgnored)
+		case 'Z':
+			switch count {
+			case 1:
+				add(`Z|[+-]\d{4}`, fieldOffset)
+			case 2:
+				add(`Z|[+-]\d{2}:\d{2}`, fieldOffset)
+			default:
+				add(`[A-Za-z_]+(?:/[A-Za-z_+-]+)*`, fieldZoneID)
+			}
+		case 'X', 'x':
+			add(`Z|[+-]\d{2}(?::?\d{2})?`, fieldOffset)
+		case 'z':
+			add(`[A-Za-z]{1,5}|[A-Za-z_]+/[A-Za-z_]+`, fieldZoneName)
+		default:
+			return nil, fmt.Errorf("matcher date: unsupported pattern letter %q in %q", r, pattern)
+		}
+	}
+	f.expr = "(?:" + embedded.String() + ")"
+	re, err := regexp.Compile("^" + capturing.String() + "$")
+	if err != nil {
+		return nil, fmt.Errorf("matcher date: %w", err)
+	}
+	f.re = re
+	return f, nil
+}
+
+// parse converts a string matched by the format into a time.
+func (f *dateFormat) parse(s string) (time.Time, error) {
+	m := f.re.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("date %q does not match %q", s, f.pattern)
+	}
+	year, month, day := time.Now().In(f.location).Year(), time.January, 1
+	hour, minute, second, nanos := 0, 0, 0, 0
+	pm, hasAmPm, hour12 := false, false, false
+	loc := f.location
+	for i, field := range f.fields {
+		v := m[i+1]
+		n, _ := strconv.Atoi(v)
+		switch field {
+		case fieldYear:
+			year = n
+		case fieldYear2:
+			year = 2000 + n
+		case fieldMonth:
+			month = time.Month(n)
+		case fieldMonthName:
+			mn, ok := monthNames[strings.ToLower(v)]
+			if !ok {
+				return time.Time{}, fmt.Errorf("unknown month %q", v)
+			}
+			month = mn
+		case fieldDay:
+			day = n
+		case fieldHour24:
+			hour = n
+		case fieldHour12:
+			hour, hour12 = n, true
+		case fieldMinute:
+			minute = n
+		case fieldSecond:
+			second = n
+		case fieldFraction:
+			for len(v) < 9 {
+				v += "0"
+			}
+			nanos, _ = strconv.Atoi(v[:9])
+		case fieldAmPm:
+			hasAmPm = true
+			pm = strings.EqualFold(v, "pm")
+		case fieldOffset:
+			offset, err := parseOffset(v)
+			if err != nil {
+				return time.Time{}, err
+			}
+			loc = offset
+		case fieldZoneID:
+			zone, err := loadLocation(v)
+			if err != nil {
+				return time.Time{}, err
+			}
+			loc = zone
+		case fieldZoneName:
+			zone, err := loadZoneName(v)
+			if err != nil {
+				return time.Time{}, err
+			}
+			loc = zone
+		}
+	}
+	if err := checkRanges(year, month, day, hour, minute, second, hour12); err != nil {
+		return time.Time{}, fmt.Errorf("date %q: %w", s, err)
+	}
+	if hasAmPm {
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	}
+	return time.Date(year, month, day, hour, minute, second, nanos, loc), nil
+}
+
+// checkRanges rejects the values of date fields out of their range, which
+// time.Date would silently carry into the next field, turning the 30th of
+// February into the 1st or 2nd of March.
+func checkRanges(year int, month time.Month, day, hour, minute, second int, hour12 bool) error {
+	maxHour := 23
+	if hour12 {
+		maxHour = 12
+	}
+	switch {
+	case month < time.January || month > time.December:
+		return fmt.Errorf("month %d out of range", month)
+	case day < 1 || day > daysIn(year, month):
+		return fmt.Errorf("day %d out of range for %s %d", day, month, year)
+	case hour > maxHour:
+		return fmt.Errorf("hour %d out of range", hour)
+	case minute > 59:
+		return fmt.Errorf("minute %d out of range", minute)
+	case second > 59:
+		return fmt.Errorf("second %d out of range", second)
+	}
+	return nil
+}
+
+// daysIn returns the number of days of a month.
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseOffset parses a UTC offset of the form Z, ±h, ±hh, ±hhmm or ±hh:mm.
+func parseOffset(v string) (*time.Location, error) {
+	if v == "Z" {
+		return time.UTC, nil
+	}
+	if len(v) < 2 || (v[0] != '+' && v[0] != '-') {
+		return nil, fmt.Errorf("invalid offset %q", v)
+	}
+	digits := v[1:]
+	if len(digits) == 5 && digits[2] == ':' {
+		digits = digits[:2] + digits[3:]
+	}
+	if len(digits) == 3 || len(digits) > 4 {
+		return nil, fmt.Errorf("invalid offset %q", v)
+	}
+	var hours, minutes int
+	for i, c := range digits {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid offset %q", v)
+		}
+		if len(digits) == 4 && i >= 2 {
+			minutes = minutes*10 + int(c-'0')
+		} else {
+			hours = hours*10 + int(c-'0')
+		}
+	}
+	if hours > 18 || minutes > 59 {
+		return nil, fmt.Errorf("offset %q out of range", v)
+	}
+	sign := 1
+	if v[0] == '-' {
+		sign = -1
+	}
+	return time.FixedZone(v, sign*(hours*3600+minutes*60)), nil
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	switch strings.ToUpper(name) {
+	case "UTC", "GMT", "Z":
+		return time.UTC, nil
+	}
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		return parseOffset(name)
+	}
+	return time.LoadLocation(name)
+}
+
+// loadZoneName returns the zone of a time zone abbreviation such as PST, or
+// of a zone ID.
+func loadZoneName(name string) (*time.Location, error) {
+	if offset, ok := zoneNames[strings.ToUpper(name)]; ok {
+		return time.FixedZone(strings.ToUpper(name), offset), nil
+	}
+	if !strings.Contains(name, "/") {
+		return nil, fmt.Errorf("unknown time zone abbreviation %q", name)
+	}
+	return loadLocation(name)
+}
+
+// isEnglish returns whether a locale, such as en, en_US or en-GB, is an
+// English one, whose month and day names the date matcher parses.
+func isEnglish(locale string) bool {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_-"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang == "en"
+}
@@ -0,0 +1,300 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package grok
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/logs/extract"
+)
+
+// filter is a post-processing step applied to an extracted value.
+type filter struct {
+	name  string
+	args  []string
+	apply func(value interface{}) (interface{}, error)
+}
+
+var rubyHashArrow = regexp.MustCompile(`\s*=>\s*`)
+
+func newFilter(spec string) (*filter, error) {
+	name, args, err := parseCall(spec)
+	if err != nil {
+		return nil, err
+	}
+	f := &filter{name: name, args: args}
+	switch name {
+	case "number":
+		f.apply = func(v interface{}) (interface{}, error) {
+			n, err := toNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+	case "integer":
+		f.apply = func(v interface{}) (interface{}, error) {
+			n, err := toNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			return math.Trunc(n), nil
+		}
+	case "boolean":
+		f.apply = func(v interface{}) (interface{}, error) {
+			return strings.EqualFold(toString(v), "true"), nil
+		}
+	case "nullIf":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("filter nullIf expects one argument")
+		}
+		f.apply = func(v interface{}) (interface{}, error) {
+			if toString(v) == args[0] {
+				return nil, nil
+			}
+			return v, nil
+		}
+	case "json":
+		f.apply = func(v interface{}) (interface{}, error) {
+			var out interface{}
+			if err := json.Unmarshal([]byte(toString(v)), &out); err != nil {
+				return nil, err
+			}
+			return out, nil
+		}
+	case "rubyhash":
+		f.apply = func(v interface{}) (interface{}, error) {
+			var out interface{}
+			converted := rubyHashArrow.ReplaceAllString(toString(v), ":")
+			if err := json.Unmarshal([]byte(converted), &out); err != nil {
+				return nil, err
+			}
+			return out, nil
+		}
+	case "lowercase":
+		f.apply = func(v interface{}) (interface{}, error) { return strings.ToLower(toString(v)), nil }
+	case "uppercase":
+		f.apply = func(v interface{}) (interface{}, error) { return strings.ToUpper(toString(v)), nil }
+	case "decodeuricomponent":
+		f.apply = func(v interface{}) (interface{}, error) { return url.QueryUnescape(toString(v)) }
+	case "url":
+		f.apply = func(v interface{}) (interface{}, error) { return extract.URL(toString(v), false) }
+	case "useragent":
+		decode := len(args) > 0 && args[0] == "decodeuricomponent"
+		f.apply = func(v interface{}) (interface{}, error) {
+			ua := toString(v)
+			if decode {
+				if decoded, err := url.QueryUnescape(ua); err == nil {
+					ua = decoded
+				}
+			}
+			return extract.UserAgent(ua), nil
+		}
+	case "querystring":
+		f.apply = func(v interface{}) (interface{}, error) {
+			values, err := url.ParseQuery(strings.TrimPrefix(toString(v), "?"))
+			if err != nil {
+				return nil, err
+			}
+			out := map[string]interface{}{}
+			for k, vs := range values {
+				out[k] = vs[len(vs)-1]
+			}
+			return out, nil
+		}
+	case "scale":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("filter scale expects one argument")
+		}
+		factor, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter scale: %w", err)
+		}
+		f.apply = func(v interface{}) (interface{}, error) {
+			n, err := toNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			return n * factor, nil
+		}
+	case "keyvalue":
+		return newKeyValueFilter(f)
+	case "csv":
+		return newCSVFilter(f)
+	case "array":
+		return newArrayFilter(f)
+	default:
+		return nil, fmt.Errorf("unknown filter %q", name)
+	}
+	return f, nil
+}
+
+// newKeyValueFilter implements keyvalue([separatorStr[, characterAllowList[, quotingStr[, delimiter]]]]).
+func newKeyValueFilter(f *filter) (*filter, error) {
+	separator, allowList, quoting, delimiter := "=", "", "", ""
+	args := f.args
+	if len(args) > 0 {
+		separator = args[0]
+	}
+	if len(args) > 1 {
+		allowList = args[1]
+	}
+	if len(args) > 2 {
+		quoting = args[2]
+	}
+	if len(args) > 3 {
+		delimiter = args[3]
+	}
+	valueChars := `\w.\-_@` + regexp.QuoteMeta(allowList)
+	quoted := `"([^"]*)"|'([^']*)'|<([^>]*)>`
+	if quoting != "" {
+		var alternatives []string
+		for i := 0; i+1 < len(quoting); i += 2 {
+			opening, closing := regexp.QuoteMeta(quoting[i:i+1]), regexp.QuoteMeta(quoting[i+1:i+2])
+			alternatives = append(alternatives, opening+`([^`+closing+`]*)`+closing)
+		}
+		quoted = strings.Join(alternatives, "|")
+	}
+	re, err := regexp.Compile(`([` + valueChars + `]+)` + regexp.QuoteMeta(separator) + `(?:` + quoted + `|([` + valueChars + `]*))`)
+	if err != nil {
+		return nil, fmt.Errorf("filter keyvalue: %w", err)
+	}
+	f.apply = func(v interface{}) (interface{}, error) {
+		text := toString(v)
+		segments := []string{text}
+		if delimiter != "" {
+			segments = strings.Split(text, delimiter)
+		}
+		out := map[string]interface{}{}
+		for _, segment := range segments {
+			for _, m := range re.FindAllStringSubmatch(segment, -1) {
+				value := ""
+				for _, group := range m[2:] {
+					if group != "" {
+						value = group
+						break
+					}
+				}
+				if value != "" {
+					out[m[1]] = value
+				}
+			}
+		}
+		return out, nil
+	}
+	return f, nil
+}
+
+// newCSVFilter implements csv(headers[, separator[, quotingcharacter]]).
+func newCSVFilter(f *filter) (*filter, error) {
+	if len(f.args) < 1 {
+		return nil, fmt.Errorf("filter csv expects at least one argument")
+	}
+	headers := strings.Split(f.args[0], ",")
+	separator := ','
+	if len(f.args) > 1 && f.args[1] != "" {
+		separator = []rune(f.args[1])[0]
+	}
+	f.apply = func(v interface{}) (interface{}, error) {
+		reader := csv.NewReader(strings.NewReader(toString(v)))
+		reader.Comma = separator
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		record, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		out := map[string]interface{}{}
+		for i, header := range headers {
+			if i < len(record) && record[i] != "" {
+				out[strings.TrimSpace(header)] = record[i]
+			}
+		}
+		return out, nil
+	}
+	return f, nil
+}
+
+// newArrayFilter implements array([[openCloseStr, ] separator][, subFilter]).
+func newArrayFilter(f *filter) (*filter, error) {
+	openClose, separator := "", ","
+	var sub *filter
+	args := f.args
+	if n := len(args); n > 0 && isFilterName(args[n-1]) {
+		s, err := newFilter(args[n-1])
+		if err != nil {
+			return nil, err
+		}
+		sub = s
+		args = args[:n-1]
+	}
+	switch len(args) {
+	case 1:
+		separator = args[0]
+	case 2:
+		openClose, separator = args[0], args[1]
+	}
+	f.apply = func(v interface{}) (interface{}, error) {
+		text := strings.TrimSpace(toString(v))
+		if len(openClose) == 2 {
+			text = strings.TrimSuffix(strings.TrimPrefix(text, openClose[:1]), openClose[1:])
+		}
+		out := []interface{}{}
+		if text == "" {
+			return out, nil
+		}
+		for _, item := range strings.Split(text, separator) {
+			var value interface{} = strings.TrimSpace(item)
+			if sub != nil {
+				converted, err := sub.apply(value)
+				if err != nil {
+					return nil, err
+				}
+				value = converted
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	}
+	return f, nil
+}
+
+func isFilterName(s string) bool {
+	name, _, err := parseCall(s)
+	if err != nil {
+		return false
+	}
+	switch name {
+	case "number", "integer", "boolean", "lowercase", "uppercase", "decodeuricomponent", "json", "nullIf", "scale":
+		return true
+	}
+	return false
+}
+
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func toNumber(v interface{}) (float64, error) {
+	if f, ok := v.(float64); ok {
+		return f, nil
+	}
+	return strconv.ParseFloat(strings.TrimSpace(toString(v)), 64)
+}
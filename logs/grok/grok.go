@@ -0,0 +1,403 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package grok evaluates Datadog grok parsing rules offline.
+//
+// Rules use the Datadog grok syntax: every line of the match and support
+// rules is a rule name followed by a pattern, and patterns reference matchers
+// and other rules with %{MATCHER:attribute:filter}. Rules are compiled to
+// regular expressions, so the parts of a pattern outside %{} must use the
+// RE2 syntax supported by the regexp package.
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/query"
+)
+
+// Parser is a compiled set of grok match rules.
+type Parser struct {
+	rules []*rule
+}
+
+// Match is the result of a successful parse.
+type Match struct {
+	// Rule is the name of the match rule that matched.
+	Rule string `json:"rule"`
+	// Attributes are the attributes extracted by the rule.
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// SampleResult is the outcome of parsing a single sample.
+type SampleResult struct {
+	Sample     string                 `json:"sample"`
+	Matched    bool                   `json:"matched"`
+	Rule       string                 `json:"rule,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type rule struct {
+	name     string
+	pattern  string
+	re       *regexp.Regexp
+	captures map[string]*capture
+}
+
+// capture describes how a named group of the compiled expression is extracted.
+type capture struct {
+	attribute string
+	matcher   *matcher
+	filters   []*filter
+}
+
+type compiler struct {
+	support   map[string]string
+	resolving map[string]bool
+	captures  map[string]*capture
+	next      int
+}
+
+// Compile compiles match rules and support rules, given in the format of
+// datadogV1.LogsGrokParserRules.
+func Compile(matchRules, supportRules string) (*Parser, error) {
+	support, _, err := parseRules(supportRules)
+	if err != nil {
+		return nil, fmt.Errorf("support rules: %w", err)
+	}
+	matches, order, err := parseRules(matchRules)
+	if err != nil {
+		return nil, fmt.Errorf("match rules: %w", err)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("match rules: no rule defined")
+	}
+	p := &Parser{}
+	for _, name := range order {
+		c := &compiler{
+			support:   support,
+			resolving: map[string]bool{},
+			captures:  map[string]*capture{},
+		}
+		expr, err := c.compile(matches[name])
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		re, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		p.rules = append(p.rules, &rule{name: name, pattern: matches[name], re: re, captures: c.captures})
+	}
+	return p, nil
+}
+
+// NewParser compiles the rules of a grok parser processor.
+func NewParser(p datadogV1.LogsGrokParser) (*Parser, error) {
+	return Compile(p.Grok.MatchRules, p.Grok.GetSupportRules())
+}
+
+// Rules returns the names of the match rules, in evaluation order.
+func (p *Parser) Rules() []string {
+	names := make([]string, len(p.rules))
+	for i, r := range p.rules {
+		names[i] = r.name
+	}
+	return names
+}
+
+// Parse runs the match rules in order and returns the first match. A rule
+// whose matchers or filters fail to convert a value does not match; if no
+// rule matches, the first such failure is returned.
+func (p *Parser) Parse(text string) (*Match, error) {
+	var firstErr error
+	for _, r := range p.rules {
+		attributes, err := r.parse(text)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("rule %s: %w", r.name, err)
+			}
+			continue
+		}
+		if attributes != nil {
+			return &Match{Rule: r.name, Attributes: attributes}, nil
+		}
+	}
+	return nil, firstErr
+}
+
+func (r *rule) parse(text string) (map[string]interface{}, error) {
+	m := r.re.FindStringSubmatch(text)
+	if m == nil {
+		return nil, nil
+	}
+	attributes := map[string]interface{}{}
+	for i, name := range r.re.SubexpNames() {
+		c, ok := r.captures[name]
+		if !ok {
+			continue
+		}
+		if err := c.extract(attributes, m[i]); err != nil {
+			return nil, err
+		}
+	}
+	return attributes, nil
+}
+
+// ValidateSamples parses every sample of a grok parser processor and reports
+// which rule matched each of them.
+func ValidateSamples(p datadogV1.LogsGrokParser) ([]SampleResult, error) {
+	parser, err := NewParser(p)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseSamples(p.Samples), nil
+}
+
+// ParseSamples parses each sample and reports the outcome.
+func (p *Parser) ParseSamples(samples []string) []SampleResult {
+	results := make([]SampleResult, len(samples))
+	for i, sample := range samples {
+		results[i].Sample = sample
+		m, err := p.Parse(sample)
+		switch {
+		case err != nil:
+			results[i].Error = err.Error()
+		case m == nil:
+			results[i].Error = "no rule matched"
+		default:
+			results[i].Matched = true
+			results[i].Rule = m.Rule
+			results[i].Attributes = m.Attributes
+		}
+	}
+	return results
+}
+
+func (c *capture) extract(attributes map[string]interface{}, raw string) error {
+	var value interface{} = raw
+	if c.matcher != nil && c.matcher.convert != nil {
+		v, err := c.matcher.convert(c.matcher, raw)
+		if err != nil {
+			return err
+		}
+		value = v
+	}
+	for _, f := range c.filters {
+		v, err := f.apply(value)
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", f.name, err)
+		}
+		value = v
+	}
+	if value == nil {
+		return nil
+	}
+	if c.attribute == "" {
+		// Filters producing objects without a target attribute are merged into the log root.
+		if object, ok := value.(map[string]interface{}); ok {
+			for k, v := range object {
+				attributes[k] = v
+			}
+		}
+		return nil
+	}
+	query.Set(attributes, c.attribute, value)
+	return nil
+}
+
+// parseRules splits a rule block into named patterns, preserving their order.
+func parseRules(block string) (map[string]string, []string, error) {
+	rules := map[string]string{}
+	var order []string
+	for i, line := range strings.Split(block, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.IndexAny(line, " \t")
+		if idx < 0 {
+			return nil, nil, fmt.Errorf("line %d: missing pattern for rule %q", i+1, line)
+		}
+		name, pattern := line[:idx], strings.TrimSpace(line[idx:])
+		if _, exists := rules[name]; exists {
+			return nil, nil, fmt.Errorf("line %d: duplicate rule %q", i+1, name)
+		}
+		rules[name] = pattern
+		order = append(order, name)
+	}
+	return rules, order, nil
+}
+
+// compile turns a grok pattern into a regular expression.
+func (c *compiler) compile(pattern string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(pattern, "%{")
+		if start < 0 {
+			b.WriteString(pattern)
+			return b.String(), nil
+		}
+		b.WriteString(pattern[:start])
+		end, err := closingBrace(pattern, start+2)
+		if err != nil {
+			return "", err
+		}
+		expr, err := c.compileReference(pattern[start+2 : end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(expr)
+		pattern = pattern[end+1:]
+	}
+}
+
+func (c *compiler) compileReference(ref string) (string, error) {
+	parts := splitTopLevel(ref, ':')
+	name, args, err := parseCall(parts[0])
+	if err != nil {
+		return "", err
+	}
+	capt := &capture{}
+	if len(parts) > 1 {
+		capt.attribute = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 {
+		// Several filters can be chained, as in %{data:attr:json:lowercase}.
+		for _, spec := range parts[2:] {
+			f, err := newFilter(spec)
+			if err != nil {
+				return "", err
+			}
+			capt.filters = append(capt.filters, f)
+		}
+	}
+
+	var expr string
+	if pattern, ok := c.support[name]; ok && args == nil {
+		if c.resolving[name] {
+			return "", fmt.Errorf("rule %s references itself", name)
+		}
+		// Support rules are compiled at every reference so that their
+		// captures get distinct group names.
+		c.resolving[name] = true
+		expr, err = c.compile(pattern)
+		c.resolving[name] = false
+		if err != nil {
+			return "", err
+		}
+		expr = "(?:" + expr + ")"
+	} else {
+		m, err := newMatcher(name, args)
+		if err != nil {
+			return "", err
+		}
+		capt.matcher = m
+		expr = m.expr
+	}
+	if capt.attribute == "" && len(capt.filters) == 0 {
+		return expr, nil
+	}
+	c.next++
+	group := fmt.Sprintf("g%d", c.next)
+	c.captures[group] = capt
+	return "(?P<" + group + ">" + expr + ")", nil
+}
+
+// closingBrace returns the index of the brace closing a reference, skipping
+// quoted strings and nested parentheses.
+func closingBrace(s string, from int) (int, error) {
+	depth := 0
+	for i := from; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"', '\'':
+			quote := s[i]
+			for i++; i < len(s) && s[i] != quote; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '}':
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated reference in %q", s)
+}
+
+// splitTopLevel splits s on sep, ignoring separators inside quotes or parentheses.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"', '\'':
+			quote := s[i]
+			for i++; i < len(s) && s[i] != quote; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// parseCall parses `name` or `name(arg, "arg", ...)`. A nil argument list
+// means the call had no parentheses.
+func parseCall(s string) (string, []string, error) {
+	s = strings.TrimSpace(s)
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		return s, nil, nil
+	}
+	if !strings.HasSuffix(s, ")") {
+		return "", nil, fmt.Errorf("invalid call %q", s)
+	}
+	args := []string{}
+	inner := strings.TrimSpace(s[open+1 : len(s)-1])
+	if inner != "" {
+		for _, arg := range splitTopLevel(inner, ',') {
+			args = append(args, unquote(strings.TrimSpace(arg)))
+		}
+	}
+	return strings.TrimSpace(s[:open]), args, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		inner := s[1 : len(s)-1]
+		var b strings.Builder
+		for i := 0; i < len(inner); i++ {
+			if inner[i] == '\\' && i+1 < len(inner) && (inner[i+1] == s[0] || inner[i+1] == '\\') {
+				i++
+			}
+			b.WriteByte(inner[i])
+		}
+		return b.String()
+	}
+	return s
+}
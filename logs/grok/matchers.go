@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matcher is a grok matcher such as word, integer or date("...").
+type matcher struct {
+	name    string
+	args    []string
+	expr    string
+	convert func(m *matcher, raw string) (interface{}, error)
+	date    *dateFormat
+	trueStr string
+}
+
+const (
+	exprInteger    = `[+-]?\d+`
+	exprIntegerExt = `[+-]?\d+(?:[eE][+-]?\d+)?`
+	exprNumber     = `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`
+	exprNumberExt  = `[+-]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][+-]?\d+)?`
+	exprIPv4       = `(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)`
+	exprIPv6       = `(?:[0-9A-Fa-f]{0,4}:){2,7}(?:[0-9A-Fa-f]{0,4}|` + exprIPv4 + `)`
+	exprHostname   = `(?:[0-9A-Za-z][0-9A-Za-z-]{0,62})(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?`
+)
+
+// simpleMatchers are the matchers taking no argument.
+var simpleMatchers = map[string]string{
+	"notSpace":           `\S+`,
+	"word":               `\w+`,
+	"data":               `(?s:.*)`,
+	"integer":            exprInteger,
+	"integerStr":         exprInteger,
+	"integerExt":         exprIntegerExt,
+	"integerExtStr":      exprIntegerExt,
+	"number":             exprNumber,
+	"numberStr":          exprNumber,
+	"numberExt":          exprNumberExt,
+	"numberExtStr":       exprNumberExt,
+	"doubleQuotedString": `"(?:[^"\\]|\\.)*"`,
+	"singleQuotedString": `'(?:[^'\\]|\\.)*'`,
+	"quotedString":       `(?:"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')`,
+	"uuid":               `[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`,
+	"mac":                `(?:[0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}`,
+	"ipv4":               exprIPv4,
+	"ipv6":               exprIPv6,
+	"ip":                 `(?:` + exprIPv4 + `|` + exprIPv6 + `)`,
+	"hostname":           exprHostname,
+	"ipOrHost":           `(?:` + exprIPv4 + `|` + exprIPv6 + `|` + exprHostname + `)`,
+	"port":               `\d{1,5}`,
+}
+
+func newMatcher(name string, args []string) (*matcher, error) {
+	m := &matcher{name: name, args: args}
+	if expr, ok := simpleMatchers[name]; ok {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("matcher %s takes no argument", name)
+		}
+		m.expr = expr
+		switch name {
+		case "integer", "integerExt", "number", "numberExt":
+			m.convert = convertNumber
+		}
+		return m, nil
+	}
+	switch name {
+	case "regex":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("matcher regex expects one argument")
+		}
+		if _, err := regexp.Compile(args[0]); err != nil {
+			return nil, fmt.Errorf("matcher regex: %w", err)
+		}
+		m.expr = `(?:` + args[0] + `)`
+	case "boolean":
+		trueStr, falseStr := "true", "false"
+		switch len(args) {
+		case 0:
+		case 2:
+			trueStr, falseStr = args[0], args[1]
+		default:
+			return nil, fmt.Errorf("matcher boolean expects zero or two arguments")
+		}
+		m.trueStr = trueStr
+		m.expr = `(?i:` + regexp.QuoteMeta(trueStr) + `|` + regexp.QuoteMeta(falseStr) + `)`
+		m.convert = func(m *matcher, raw string) (interface{}, error) {
+			return strings.EqualFold(raw, m.trueStr), nil
+		}
+	case "date":
+		if len(args) < 1 || len(args) > 3 {
+			return nil, fmt.Errorf("matcher date expects between one and three arguments")
+		}
+		timezone, locale := "", ""
+		if len(args) > 1 {
+			timezone = args[1]
+		}
+		if len(args) > 2 {
+			locale = args[2]
+		}
+		format, err := newDateFormat(args[0], timezone, locale)
+		if err != nil {
+			return nil, err
+		}
+		m.date = format
+		m.expr = format.expr
+		m.convert = func(m *matcher, raw string) (interface{}, error) {
+			t, err := m.date.parse(raw)
+			if err != nil {
+				return nil, err
+			}
+			return float64(t.UnixNano() / 1e6), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown matcher or rule %q", name)
+	}
+	return m, nil
+}
+
+func convertNumber(m *matcher, raw string) (interface{}, error) {
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("matcher %s: %w", m.name, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package index
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Volume is the expected volume of an index.
+type Volume struct {
+	Index string
+	// DailyLimit is the daily limit of the index in logs, zero when the index
+	// has no limit.
+	DailyLimit    int64
+	RetentionDays int64
+	// Matched is the number of sample logs caught by the index, Excluded the
+	// expected number of them excluded and Indexed the expected number of them
+	// indexed.
+	Matched  int
+	Excluded float64
+	Indexed  float64
+	// Daily is the expected number of logs indexed per day.
+	Daily float64
+}
+
+// OverLimit returns whether the expected daily volume exceeds the daily limit.
+func (v Volume) OverLimit() bool {
+	return v.DailyLimit > 0 && v.Daily > float64(v.DailyLimit)
+}
+
+// Report is the expected volume of indexes over a sample of logs.
+type Report struct {
+	// Logs is the number of sample logs, received over Period.
+	Logs   int
+	Period time.Duration
+	// Indexes holds the volume of every index, in index order.
+	Indexes []Volume
+	// Unmatched is the number of sample logs no index catches.
+	Unmatched int
+}
+
+// Report aggregates the results of a sample of logs received over a period
+// into the expected volume of every index. A zero period is a day.
+func (s *Simulator) Report(results []*Result, period time.Duration) *Report {
+	if period <= 0 {
+		period = 24 * time.Hour
+	}
+	report := &Report{Logs: len(results), Period: period}
+	position := map[string]int{}
+	for _, index := range s.Indexes {
+		position[index.GetName()] = len(report.Indexes)
+		report.Indexes = append(report.Indexes, Volume{
+			Index:         index.GetName(),
+			DailyLimit:    index.GetDailyLimit(),
+			RetentionDays: index.GetNumRetentionDays(),
+		})
+	}
+	for _, r := range results {
+		i, ok := position[r.Index]
+		if !ok {
+			report.Unmatched++
+			continue
+		}
+		v := &report.Indexes[i]
+		v.Matched++
+		v.Excluded += r.SampleRate
+		v.Indexed += r.Kept()
+	}
+	scale := float64(24*time.Hour) / float64(period)
+	for i := range report.Indexes {
+		report.Indexes[i].Daily = report.Indexes[i].Indexed * scale
+	}
+	return report
+}
+
+// WriteText writes the report as a table, flagging the indexes expected to
+// exceed their daily limit.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tMATCHED\tEXCLUDED\tINDEXED\tDAILY\tDAILY LIMIT\tRETENTION")
+	for _, v := range r.Indexes {
+		limit := "-"
+		if v.DailyLimit > 0 {
+			limit = fmt.Sprint(v.DailyLimit)
+			if v.OverLimit() {
+				limit += " (exceeded)"
+			}
+		}
+		retention := "-"
+		if v.RetentionDays > 0 {
+			retention = fmt.Sprintf("%dd", v.RetentionDays)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1f\t%.0f\t%s\t%s\n", v.Index, v.Matched, v.Excluded, v.Indexed, v.Daily, limit, retention)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d log(s) over %s, %d not matching any index.\n", r.Logs, r.Period, r.Unmatched)
+	return err
+}
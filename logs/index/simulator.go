@@ -0,0 +1,225 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package index predicts how Datadog log indexes route and exclude logs.
+//
+// A Simulator evaluates the filters and exclusion filters of an ordered list
+// of datadogV1.LogsIndex values against sample logs: the first index whose
+// filter matches a log catches it, and the first enabled exclusion filter of
+// that index matching the log excludes it at its sample rate. A Report
+// aggregates the results into the expected daily volume of every index.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	_context "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/query"
+)
+
+// Simulator routes logs through an ordered list of log indexes. It is safe
+// for concurrent use, as long as its indexes are not modified.
+type Simulator struct {
+	// Indexes are evaluated in order, as in the index order of an organization.
+	Indexes []datadogV1.LogsIndex
+
+	// mu guards the cache of parsed queries.
+	mu      sync.Mutex
+	queries map[string]*query.Query
+}
+
+// Result is the outcome of routing a log.
+type Result struct {
+	// Log is the routed log.
+	Log map[string]interface{} `json:"log"`
+	// Index is the name of the index catching the log, empty when no index
+	// filter matches it.
+	Index string `json:"index,omitempty"`
+	// Exclusion is the name of the exclusion filter applied to the log, and
+	// SampleRate the fraction of the matching logs it excludes.
+	Exclusion  string  `json:"exclusion,omitempty"`
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	// Errors lists the filters which could not be evaluated.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Excluded returns whether the log is always excluded.
+func (r *Result) Excluded() bool {
+	return r.Exclusion != "" && r.SampleRate >= 1
+}
+
+// Kept returns the probability of the log being indexed.
+func (r *Result) Kept() float64 {
+	if r.Index == "" {
+		return 0
+	}
+	return 1 - r.SampleRate
+}
+
+// String describes where the log lands, such as
+// `index "main", 50% excluded by "health checks"`.
+func (r *Result) String() string {
+	switch {
+	case r.Index == "":
+		return "no index"
+	case r.Exclusion == "":
+		return fmt.Sprintf("index %q", r.Index)
+	case r.Excluded():
+		return fmt.Sprintf("index %q, excluded by %q", r.Index, r.Exclusion)
+	}
+	return fmt.Sprintf("index %q, %g%% excluded by %q", r.Index, r.SampleRate*100, r.Exclusion)
+}
+
+// NewSimulator returns a simulator routing logs through the given indexes in
+// order.
+func NewSimulator(indexes ...datadogV1.LogsIndex) *Simulator {
+	return &Simulator{
+		Indexes: indexes,
+		queries: map[string]*query.Query{},
+	}
+}
+
+// NewSimulatorFromAPI returns a simulator for the indexes of an organization,
+// ordered as returned by GetLogsIndexOrder.
+func NewSimulatorFromAPI(ctx _context.Context, api *datadogV1.LogsIndexesApi) (*Simulator, error) {
+	order, _, err := api.GetLogsIndexOrder(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting index order: %w", err)
+	}
+	indexes, _, err := api.ListLogIndexes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing indexes: %w", err)
+	}
+	return NewSimulator(OrderIndexes(indexes.Indexes, order.GetIndexNames())...), nil
+}
+
+// ReadIndexes decodes indexes from JSON, either a list of indexes or a
+// ListLogIndexes response. The indexes are returned in the order of the input.
+func ReadIndexes(r io.Reader) ([]datadogV1.LogsIndex, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		var indexes []datadogV1.LogsIndex
+		if err := json.Unmarshal(data, &indexes); err != nil {
+			return nil, err
+		}
+		return indexes, nil
+	}
+	var list datadogV1.LogsIndexListResponse
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return list.Indexes, nil
+}
+
+// OrderIndexes sorts indexes following the given list of index names.
+// Indexes missing from the list are kept at the end in their original order.
+func OrderIndexes(indexes []datadogV1.LogsIndex, names []string) []datadogV1.LogsIndex {
+	position := make(map[string]int, len(names))
+	for i, name := range names {
+		position[name] = i
+	}
+	ordered := make([]datadogV1.LogsIndex, len(indexes))
+	copy(ordered, indexes)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		pi, iok := position[ordered[i].GetName()]
+		pj, jok := position[ordered[j].GetName()]
+		if iok && jok {
+			return pi < pj
+		}
+		return iok && !jok
+	})
+	return ordered
+}
+
+// Run routes a single log. Filters which cannot be evaluated do not match,
+// and are listed in the errors of the result.
+func (s *Simulator) Run(log map[string]interface{}) *Result {
+	if log == nil {
+		log = map[string]interface{}{}
+	}
+	result := &Result{Log: log}
+	for _, index := range s.Indexes {
+		matched, err := s.match(index.Filter.GetQuery(), log)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("index %s: %v", index.GetName(), err))
+		}
+		if !matched {
+			continue
+		}
+		result.Index = index.GetName()
+		for _, exclusion := range index.ExclusionFilters {
+			if !exclusion.GetIsEnabled() || exclusion.Filter == nil {
+				continue
+			}
+			matched, err := s.match(exclusion.Filter.GetQuery(), log)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("index %s: exclusion filter %s: %v", index.GetName(), exclusion.GetName(), err))
+			}
+			if matched {
+				result.Exclusion = exclusion.GetName()
+				result.SampleRate = exclusion.Filter.GetSampleRate()
+				break
+			}
+		}
+		break
+	}
+	return result
+}
+
+// RunJSON routes a single JSON encoded log.
+func (s *Simulator) RunJSON(data []byte) (*Result, error) {
+	var log map[string]interface{}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, err
+	}
+	return s.Run(log), nil
+}
+
+// RunJSONLines routes every log of a JSON Lines stream.
+func (s *Simulator) RunJSONLines(r io.Reader) ([]*Result, error) {
+	var results []*Result
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		result, err := s.RunJSON(scanner.Bytes())
+		if err != nil {
+			return results, fmt.Errorf("line %d: %w", line, err)
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+func (s *Simulator) match(q string, log map[string]interface{}) (bool, error) {
+	s.mu.Lock()
+	if s.queries == nil {
+		s.queries = map[string]*query.Query{}
+	}
+	parsed, ok := s.queries[q]
+	if !ok {
+		var err error
+		if parsed, err = query.Parse(q); err != nil {
+			s.mu.Unlock()
+			return false, err
+		}
+		s.queries[q] = parsed
+	}
+	s.mu.Unlock()
+	return parsed.Match(log), nil
+}
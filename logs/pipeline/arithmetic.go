@@ -0,0 +1,199 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+
+	"github.com/DataDog/datadog-api-client-go/v2/logs/query"
+)
+
+// evaluateArithmetic computes an arithmetic processor expression. Supported
+// operators are +, -, *, / and %, with parentheses and the abs, ceil, floor
+// and round functions. Attribute names may contain dots and underscores.
+func evaluateArithmetic(expr string, log map[string]interface{}, replaceMissing bool) (float64, error) {
+	e := &arithmetic{input: []rune(expr), log: log, replaceMissing: replaceMissing}
+	v, err := e.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	e.skipSpaces()
+	if e.pos < len(e.input) {
+		return 0, fmt.Errorf("unexpected %q in expression", string(e.input[e.pos:]))
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("expression result is not a number")
+	}
+	return v, nil
+}
+
+type arithmetic struct {
+	input          []rune
+	pos            int
+	log            map[string]interface{}
+	replaceMissing bool
+}
+
+func (e *arithmetic) skipSpaces() {
+	for e.pos < len(e.input) && unicode.IsSpace(e.input[e.pos]) {
+		e.pos++
+	}
+}
+
+func (e *arithmetic) next() rune {
+	e.skipSpaces()
+	if e.pos >= len(e.input) {
+		return 0
+	}
+	return e.input[e.pos]
+}
+
+func (e *arithmetic) parseSum() (float64, error) {
+	left, err := e.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := e.next()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		e.pos++
+		right, err := e.parseProduct()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (e *arithmetic) parseProduct() (float64, error) {
+	left, err := e.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := e.next()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		e.pos++
+		right, err := e.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left *= right
+		case '/':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		case '%':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+func (e *arithmetic) parseUnary() (float64, error) {
+	if e.next() == '-' {
+		e.pos++
+		v, err := e.parseUnary()
+		return -v, err
+	}
+	return e.parsePrimary()
+}
+
+func (e *arithmetic) parsePrimary() (float64, error) {
+	r := e.next()
+	switch {
+	case r == '(':
+		e.pos++
+		v, err := e.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if e.next() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		e.pos++
+		return v, nil
+	case unicode.IsDigit(r) || r == '.':
+		start := e.pos
+		for e.pos < len(e.input) && (unicode.IsDigit(e.input[e.pos]) || e.input[e.pos] == '.') {
+			e.pos++
+		}
+		return strconv.ParseFloat(string(e.input[start:e.pos]), 64)
+	case unicode.IsLetter(r) || r == '_' || r == '@':
+		start := e.pos
+		for e.pos < len(e.input) && isAttributeRune(e.input[e.pos]) {
+			e.pos++
+		}
+		name := string(e.input[start:e.pos])
+		if e.next() == '(' {
+			return e.parseFunction(name)
+		}
+		return e.attribute(name)
+	case r == 0:
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected %q in expression", string(r))
+}
+
+func (e *arithmetic) parseFunction(name string) (float64, error) {
+	e.pos++
+	arg, err := e.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	if e.next() != ')' {
+		return 0, fmt.Errorf("missing closing parenthesis")
+	}
+	e.pos++
+	switch name {
+	case "abs":
+		return math.Abs(arg), nil
+	case "ceil":
+		return math.Ceil(arg), nil
+	case "floor":
+		return math.Floor(arg), nil
+	case "round":
+		return math.Round(arg), nil
+	}
+	return 0, fmt.Errorf("unknown function %q", name)
+}
+
+func (e *arithmetic) attribute(name string) (float64, error) {
+	if len(name) > 0 && name[0] == '@' {
+		name = name[1:]
+	}
+	value, ok := query.Lookup(e.log, name)
+	if !ok || value == nil {
+		if e.replaceMissing {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("missing attribute %q", name)
+	}
+	f, ok := toNumber(value)
+	if !ok {
+		return 0, fmt.Errorf("attribute %q is not a number", name)
+	}
+	return f, nil
+}
+
+func isAttributeRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '@'
+}
@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package pipeline
+
+import (
+	"strings"
+	"time"
+)
+
+// dateLayouts are the textual date formats recognized by the date remapper.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05,999",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	time.UnixDate,
+	time.Stamp,
+	time.StampMicro,
+}
+
+// parseDate converts an epoch number or a textual date into the RFC 3339
+// representation stored in the reserved date attribute.
+func parseDate(value interface{}) (string, bool) {
+	if n, ok := value.(float64); ok {
+		return formatDate(epochToTime(n)), true
+	}
+	s := strings.TrimSpace(stringify(value))
+	if n, ok := toNumber(s); ok {
+		return formatDate(epochToTime(n)), true
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			if t.Year() == 0 {
+				t = t.AddDate(time.Now().Year(), 0, 0)
+			}
+			return formatDate(t), true
+		}
+	}
+	return "", false
+}
+
+// epochToTime interprets a number as seconds, milliseconds or nanoseconds
+// since the epoch depending on its magnitude.
+func epochToTime(n float64) time.Time {
+	switch {
+	case n >= 1e17:
+		return time.Unix(0, int64(n))
+	case n >= 1e11:
+		return time.UnixMilli(int64(n))
+	}
+	sec := int64(n)
+	return time.Unix(sec, int64((n-float64(sec))*1e9))
+}
+
+func formatDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
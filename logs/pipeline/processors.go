@@ -0,0 +1,508 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/extract"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/grok"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/query"
+)
+
+const (
+	reasonDisabled      = "processor disabled"
+	reasonNoSource      = "no source attribute found"
+	reasonUnsupported   = "processor type not supported by the simulator"
+	reasonFilterNoMatch = "filter did not match"
+)
+
+var templateAttribute = regexp.MustCompile(`%\{([^}]+)\}`)
+
+func enabled(v *bool) bool {
+	return v == nil || *v
+}
+
+func filterQuery(f *datadogV1.LogsFilter) string {
+	if f == nil {
+		return ""
+	}
+	return f.GetQuery()
+}
+
+// apply runs a single processor on the result output and describes what happened.
+func (s *Simulator) apply(log map[string]interface{}, p datadogV1.LogsProcessor) Step {
+	switch {
+	case p.LogsGrokParser != nil:
+		v := p.LogsGrokParser
+		step := Step{Name: v.GetName(), Type: string(v.Type)}
+		if !enabled(v.IsEnabled) {
+			step.Reason = reasonDisabled
+			return step
+		}
+		parser, err := s.grokParser(v)
+		if err != nil {
+			step.Error = err.Error()
+			return step
+		}
+		source, ok := query.Lookup(log, v.Source)
+		if !ok {
+			step.Reason = reasonNoSource
+			return step
+		}
+		match, err := parser.Parse(stringify(source))
+		if err != nil {
+			step.Error = err.Error()
+			return step
+		}
+		if match == nil {
+			step.Reason = "no rule matched"
+			return step
+		}
+		merge(log, match.Attributes)
+		step.Rule = match.Rule
+		step.Applied = true
+		return step
+	case p.LogsDateRemapper != nil:
+		v := p.LogsDateRemapper
+		return remap(log, v.GetName(), string(v.Type), v.IsEnabled, v.Sources, func(value interface{}) (bool, string) {
+			date, ok := parseDate(value)
+			if !ok {
+				return false, fmt.Sprintf("could not parse date %v", value)
+			}
+			log["date"] = date
+			return true, ""
+		})
+	case p.LogsStatusRemapper != nil:
+		v := p.LogsStatusRemapper
+		return remap(log, v.GetName(), string(v.Type), v.IsEnabled, v.Sources, func(value interface{}) (bool, string) {
+			log["status"] = NormalizeStatus(value)
+			return true, ""
+		})
+	case p.LogsServiceRemapper != nil:
+		v := p.LogsServiceRemapper
+		return remap(log, v.GetName(), string(v.Type), v.IsEnabled, v.Sources, func(value interface{}) (bool, string) {
+			log["service"] = stringify(value)
+			return true, ""
+		})
+	case p.LogsMessageRemapper != nil:
+		v := p.LogsMessageRemapper
+		return remap(log, v.GetName(), string(v.Type), v.IsEnabled, v.Sources, func(value interface{}) (bool, string) {
+			log["message"] = stringify(value)
+			return true, ""
+		})
+	case p.LogsTraceRemapper != nil:
+		v := p.LogsTraceRemapper
+		sources := v.Sources
+		if len(sources) == 0 {
+			sources = []string{"dd.trace_id"}
+		}
+		return remap(log, v.GetName(), string(v.Type), v.IsEnabled, sources, func(value interface{}) (bool, string) {
+			log["trace_id"] = stringify(value)
+			return true, ""
+		})
+	case p.LogsAttributeRemapper != nil:
+		return applyAttributeRemapper(log, p.LogsAttributeRemapper)
+	case p.LogsURLParser != nil:
+		v := p.LogsURLParser
+		return remap(log, v.GetName(), string(v.Type), v.IsEnabled, v.Sources, func(value interface{}) (bool, string) {
+			details, err := extract.URL(stringify(value), v.GetNormalizeEndingSlashes())
+			if err != nil {
+				return false, err.Error()
+			}
+			query.Set(log, v.Target, details)
+			return true, ""
+		})
+	case p.LogsUserAgentParser != nil:
+		v := p.LogsUserAgentParser
+		return remap(log, v.GetName(), string(v.Type), v.IsEnabled, v.Sources, func(value interface{}) (bool, string) {
+			ua := stringify(value)
+			if v.GetIsEncoded() {
+				if decoded, err := url.QueryUnescape(ua); err == nil {
+					ua = decoded
+				}
+			}
+			query.Set(log, v.Target, extract.UserAgent(ua))
+			return true, ""
+		})
+	case p.LogsCategoryProcessor != nil:
+		return s.applyCategory(log, p.LogsCategoryProcessor)
+	case p.LogsArithmeticProcessor != nil:
+		return applyArithmetic(log, p.LogsArithmeticProcessor)
+	case p.LogsStringBuilderProcessor != nil:
+		return applyStringBuilder(log, p.LogsStringBuilderProcessor)
+	case p.LogsLookupProcessor != nil:
+		v := p.LogsLookupProcessor
+		table := make(map[string]string, len(v.LookupTable))
+		for _, row := range v.LookupTable {
+			if key, value, ok := strings.Cut(row, ","); ok {
+				table[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		return applyLookup(log, v.GetName(), string(v.Type), v.IsEnabled, v.Source, v.Target, table, v.DefaultLookup)
+	case p.ReferenceTableLogsLookupProcessor != nil:
+		v := p.ReferenceTableLogsLookupProcessor
+		table, ok := s.ReferenceTables[v.LookupEnrichmentTable]
+		if !ok {
+			return Step{Name: v.GetName(), Type: string(v.Type), Reason: fmt.Sprintf("reference table %q not loaded", v.LookupEnrichmentTable)}
+		}
+		return applyLookup(log, v.GetName(), string(v.Type), v.IsEnabled, v.Source, v.Target, table, nil)
+	case p.LogsGeoIPParser != nil:
+		v := p.LogsGeoIPParser
+		step := Step{Name: v.GetName(), Type: string(v.Type), Reason: reasonUnsupported}
+		if !enabled(v.IsEnabled) {
+			step.Reason = reasonDisabled
+		}
+		return step
+	case p.LogsPipelineProcessor != nil:
+		v := p.LogsPipelineProcessor
+		step := Step{Name: v.GetName(), Type: string(v.Type)}
+		if !enabled(v.IsEnabled) {
+			step.Reason = reasonDisabled
+			return step
+		}
+		matched, err := s.match(filterQuery(v.Filter), log)
+		if err != nil {
+			step.Error = err.Error()
+			return step
+		}
+		if !matched {
+			step.Reason = reasonFilterNoMatch
+		}
+		step.Applied = matched
+		return step
+	}
+	return Step{Type: "unknown", Reason: reasonUnsupported}
+}
+
+func (s *Simulator) grokParser(v *datadogV1.LogsGrokParser) (*grok.Parser, error) {
+	key := v.Grok.MatchRules + "\x00" + v.Grok.GetSupportRules()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if parser, ok := s.grokParsers[key]; ok {
+		return parser, nil
+	}
+	parser, err := grok.NewParser(*v)
+	if err != nil {
+		return nil, err
+	}
+	if s.grokParsers == nil {
+		s.grokParsers = map[string]*grok.Parser{}
+	}
+	s.grokParsers[key] = parser
+	return parser, nil
+}
+
+// merge copies the extracted attributes into the log, merging nested objects.
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if srcObject, ok := v.(map[string]interface{}); ok {
+			if dstObject, ok := dst[k].(map[string]interface{}); ok {
+				merge(dstObject, srcObject)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// remap finds the first existing source attribute and hands its value to fn.
+func remap(log map[string]interface{}, name, typ string, isEnabled *bool, sources []string, fn func(interface{}) (bool, string)) Step {
+	step := Step{Name: name, Type: typ}
+	if !enabled(isEnabled) {
+		step.Reason = reasonDisabled
+		return step
+	}
+	for _, source := range sources {
+		value, ok := query.Lookup(log, source)
+		if !ok || value == nil {
+			continue
+		}
+		step.Applied, step.Reason = fn(value)
+		return step
+	}
+	step.Reason = reasonNoSource
+	return step
+}
+
+func applyAttributeRemapper(log map[string]interface{}, v *datadogV1.LogsAttributeRemapper) Step {
+	step := Step{Name: v.GetName(), Type: string(v.Type)}
+	if !enabled(v.IsEnabled) {
+		step.Reason = reasonDisabled
+		return step
+	}
+	fromTag := v.GetSourceType() == "tag"
+	toTag := v.GetTargetType() == "tag"
+	for _, source := range v.Sources {
+		var value interface{}
+		var found bool
+		if fromTag {
+			value, found = tagValue(log, source)
+		} else {
+			value, found = query.Lookup(log, source)
+		}
+		if !found {
+			continue
+		}
+		if toTag {
+			if hasTagKey(log, v.Target) && !v.GetOverrideOnConflict() {
+				step.Reason = "target tag already set"
+				return step
+			}
+			removeTagKey(log, v.Target)
+			addTag(log, v.Target+":"+stringify(value))
+		} else {
+			if _, exists := query.Lookup(log, v.Target); exists && !v.GetOverrideOnConflict() {
+				step.Reason = "target attribute already set"
+				return step
+			}
+			if v.TargetFormat != nil {
+				value = castValue(value, *v.TargetFormat)
+			}
+			query.Set(log, v.Target, value)
+		}
+		if !v.GetPreserveSource() && source != v.Target {
+			if fromTag {
+				removeTagKey(log, source)
+			} else {
+				query.Delete(log, source)
+			}
+		}
+		step.Applied = true
+		return step
+	}
+	step.Reason = reasonNoSource
+	return step
+}
+
+func (s *Simulator) applyCategory(log map[string]interface{}, v *datadogV1.LogsCategoryProcessor) Step {
+	step := Step{Name: v.GetName(), Type: string(v.Type)}
+	if !enabled(v.IsEnabled) {
+		step.Reason = reasonDisabled
+		return step
+	}
+	for _, category := range v.Categories {
+		matched, err := s.match(filterQuery(category.Filter), log)
+		if err != nil {
+			step.Error = err.Error()
+			return step
+		}
+		if matched {
+			query.Set(log, v.Target, category.GetName())
+			step.Applied = true
+			return step
+		}
+	}
+	step.Reason = "no category matched"
+	return step
+}
+
+func applyArithmetic(log map[string]interface{}, v *datadogV1.LogsArithmeticProcessor) Step {
+	step := Step{Name: v.GetName(), Type: string(v.Type)}
+	if !enabled(v.IsEnabled) {
+		step.Reason = reasonDisabled
+		return step
+	}
+	result, err := evaluateArithmetic(v.Expression, log, v.GetIsReplaceMissing())
+	if err != nil {
+		step.Reason = err.Error()
+		return step
+	}
+	query.Set(log, v.Target, result)
+	step.Applied = true
+	return step
+}
+
+func applyStringBuilder(log map[string]interface{}, v *datadogV1.LogsStringBuilderProcessor) Step {
+	step := Step{Name: v.GetName(), Type: string(v.Type)}
+	if !enabled(v.IsEnabled) {
+		step.Reason = reasonDisabled
+		return step
+	}
+	var missing string
+	out := templateAttribute.ReplaceAllStringFunc(v.Template, func(m string) string {
+		attr := strings.TrimSpace(m[2 : len(m)-1])
+		value, ok := query.Lookup(log, attr)
+		if !ok || value == nil {
+			if missing == "" {
+				missing = attr
+			}
+			return ""
+		}
+		return stringify(value)
+	})
+	if missing != "" && !v.GetIsReplaceMissing() {
+		step.Reason = fmt.Sprintf("missing attribute %q", missing)
+		return step
+	}
+	query.Set(log, v.Target, out)
+	step.Applied = true
+	return step
+}
+
+func applyLookup(log map[string]interface{}, name, typ string, isEnabled *bool, source, target string, table map[string]string, defaultValue *string) Step {
+	step := Step{Name: name, Type: typ}
+	if !enabled(isEnabled) {
+		step.Reason = reasonDisabled
+		return step
+	}
+	value, ok := query.Lookup(log, source)
+	if !ok {
+		step.Reason = reasonNoSource
+		return step
+	}
+	if mapped, found := table[stringify(value)]; found {
+		query.Set(log, target, mapped)
+	} else if defaultValue != nil {
+		query.Set(log, target, *defaultValue)
+	} else {
+		step.Reason = fmt.Sprintf("no entry for %q", stringify(value))
+		return step
+	}
+	step.Applied = true
+	return step
+}
+
+// NormalizeStatus maps a raw severity value to a Datadog log status,
+// following the rules of the status remapper.
+func NormalizeStatus(value interface{}) string {
+	if f, ok := toNumber(value); ok && f == math.Trunc(f) && f >= 0 && f <= 7 {
+		return []string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}[int(f)]
+	}
+	s := strings.ToLower(strings.TrimSpace(stringify(value)))
+	switch {
+	case strings.HasPrefix(s, "emerg"), strings.HasPrefix(s, "f"):
+		return "emergency"
+	case strings.HasPrefix(s, "a"):
+		return "alert"
+	case strings.HasPrefix(s, "c"):
+		return "critical"
+	case strings.HasPrefix(s, "e"):
+		return "error"
+	case strings.HasPrefix(s, "w"):
+		return "warning"
+	case strings.HasPrefix(s, "n"):
+		return "notice"
+	case strings.HasPrefix(s, "i"):
+		return "info"
+	case strings.HasPrefix(s, "d"), strings.HasPrefix(s, "trace"), strings.HasPrefix(s, "verbose"):
+		return "debug"
+	case strings.HasPrefix(s, "o"), strings.HasPrefix(s, "s"):
+		return "ok"
+	}
+	return "info"
+}
+
+func castValue(value interface{}, format datadogV1.TargetFormatType) interface{} {
+	switch format {
+	case datadogV1.TARGETFORMATTYPE_STRING:
+		return stringify(value)
+	case datadogV1.TARGETFORMATTYPE_INTEGER:
+		if f, ok := toNumber(value); ok {
+			return math.Trunc(f)
+		}
+	case datadogV1.TARGETFORMATTYPE_DOUBLE:
+		if f, ok := toNumber(value); ok {
+			return f
+		}
+	}
+	return value
+}
+
+func tagValue(log map[string]interface{}, key string) (interface{}, bool) {
+	for _, tag := range query.Tags(log) {
+		if k, v, ok := strings.Cut(tag, ":"); ok && k == key {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func hasTagKey(log map[string]interface{}, key string) bool {
+	_, ok := tagValue(log, key)
+	return ok
+}
+
+func addTag(log map[string]interface{}, tag string) {
+	switch v := log["ddtags"].(type) {
+	case string:
+		if v == "" {
+			log["ddtags"] = tag
+		} else {
+			log["ddtags"] = v + "," + tag
+		}
+		return
+	}
+	switch v := log["tags"].(type) {
+	case []interface{}:
+		log["tags"] = append(v, tag)
+		return
+	}
+	log["ddtags"] = tag
+}
+
+func removeTagKey(log map[string]interface{}, key string) {
+	keep := func(tag string) bool {
+		k, _, _ := strings.Cut(tag, ":")
+		return strings.TrimSpace(k) != key
+	}
+	if v, ok := log["ddtags"].(string); ok {
+		var kept []string
+		for _, tag := range strings.Split(v, ",") {
+			if tag != "" && keep(tag) {
+				kept = append(kept, tag)
+			}
+		}
+		log["ddtags"] = strings.Join(kept, ",")
+	}
+	if v, ok := log["tags"].([]interface{}); ok {
+		kept := []interface{}{}
+		for _, tag := range v {
+			if keep(fmt.Sprint(tag)) {
+				kept = append(kept, tag)
+			}
+		}
+		log["tags"] = kept
+	}
+}
+
+func stringify(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,262 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package pipeline runs Datadog log pipelines locally against sample logs.
+//
+// A Simulator applies the processors of one or more datadogV1.LogsPipeline
+// values, in order, to JSON logs and records what every processor did, so
+// pipeline changes can be checked in CI before they are shipped.
+package pipeline
+
+import (
+	"bufio"
+	_context "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/grok"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/query"
+)
+
+// Simulator applies an ordered list of log pipelines to logs. It is safe for
+// concurrent use, as long as its pipelines and reference tables are not
+// modified.
+type Simulator struct {
+	// Pipelines are applied in order, as in the pipeline order of an organization.
+	Pipelines []datadogV1.LogsPipeline
+	// ReferenceTables holds the key/value rows of the reference tables used by
+	// reference table lookup processors, indexed by table name.
+	ReferenceTables map[string]map[string]string
+
+	// mu guards the caches of parsed queries and grok parsers.
+	mu          sync.Mutex
+	queries     map[string]*query.Query
+	grokParsers map[string]*grok.Parser
+}
+
+// Result is the outcome of running a log through the simulator.
+type Result struct {
+	// Input is a copy of the original log.
+	Input map[string]interface{} `json:"input"`
+	// Output is the processed log.
+	Output map[string]interface{} `json:"output"`
+	// Trace lists the pipelines and processors in the order they were evaluated.
+	Trace []Step `json:"trace"`
+}
+
+// Step describes the evaluation of a pipeline or processor on a log.
+type Step struct {
+	// Pipeline is the path of pipeline names containing the processor.
+	Pipeline []string `json:"pipeline"`
+	// Name of the processor or pipeline.
+	Name string `json:"name,omitempty"`
+	// Type of the processor, or "pipeline" for a top level pipeline.
+	Type string `json:"type"`
+	// Applied is true when the processor ran on the log, or when the pipeline filter matched.
+	Applied bool `json:"applied"`
+	// Rule is the grok rule that matched the log, for grok parsers.
+	Rule string `json:"rule,omitempty"`
+	// Reason explains why the processor did not apply.
+	Reason string `json:"reason,omitempty"`
+	// Error is set when the processor definition could not be evaluated.
+	Error string `json:"error,omitempty"`
+	// Changes lists the attributes modified by the processor.
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Change is a modification of a single attribute.
+type Change struct {
+	Attribute string      `json:"attribute"`
+	Before    interface{} `json:"before,omitempty"`
+	After     interface{} `json:"after,omitempty"`
+}
+
+// NewSimulator returns a simulator applying the given pipelines in order.
+func NewSimulator(pipelines ...datadogV1.LogsPipeline) *Simulator {
+	return &Simulator{
+		Pipelines: pipelines,
+		queries:   map[string]*query.Query{},
+	}
+}
+
+// NewSimulatorFromAPI returns a simulator for the pipelines of an organization,
+// ordered as returned by GetLogsPipelineOrder.
+func NewSimulatorFromAPI(ctx _context.Context, api *datadogV1.LogsPipelinesApi) (*Simulator, error) {
+	order, _, err := api.GetLogsPipelineOrder(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pipelines, _, err := api.ListLogsPipelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewSimulator(OrderPipelines(pipelines, order.GetPipelineIds())...), nil
+}
+
+// OrderPipelines sorts pipelines following the given list of pipeline IDs.
+// Pipelines missing from the list are kept at the end in their original order.
+func OrderPipelines(pipelines []datadogV1.LogsPipeline, ids []string) []datadogV1.LogsPipeline {
+	position := make(map[string]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+	ordered := make([]datadogV1.LogsPipeline, len(pipelines))
+	copy(ordered, pipelines)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		pi, iok := position[ordered[i].GetId()]
+		pj, jok := position[ordered[j].GetId()]
+		if iok && jok {
+			return pi < pj
+		}
+		return iok && !jok
+	})
+	return ordered
+}
+
+// Run processes a single log. The given log is not modified.
+func (s *Simulator) Run(log map[string]interface{}) *Result {
+	if log == nil {
+		log = map[string]interface{}{}
+	}
+	result := &Result{
+		Input:  deepCopy(log).(map[string]interface{}),
+		Output: deepCopy(log).(map[string]interface{}),
+	}
+	for _, p := range s.Pipelines {
+		step := Step{Pipeline: []string{p.GetName()}, Name: p.GetName(), Type: "pipeline"}
+		switch {
+		case !enabled(p.IsEnabled):
+			step.Reason = "pipeline disabled"
+		default:
+			matched, err := s.match(filterQuery(p.Filter), result.Output)
+			if err != nil {
+				step.Error = err.Error()
+			} else if !matched {
+				step.Reason = "filter did not match"
+			}
+			step.Applied = matched
+		}
+		result.Trace = append(result.Trace, step)
+		if step.Applied {
+			s.runProcessors(result, step.Pipeline, p.Processors)
+		}
+	}
+	return result
+}
+
+// RunJSON processes a single JSON encoded log.
+func (s *Simulator) RunJSON(data []byte) (*Result, error) {
+	var log map[string]interface{}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, err
+	}
+	return s.Run(log), nil
+}
+
+// RunJSONLines processes every log of a JSON Lines stream.
+func (s *Simulator) RunJSONLines(r io.Reader) ([]*Result, error) {
+	var results []*Result
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		result, err := s.RunJSON(scanner.Bytes())
+		if err != nil {
+			return results, fmt.Errorf("line %d: %w", line, err)
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+func (s *Simulator) match(q string, log map[string]interface{}) (bool, error) {
+	s.mu.Lock()
+	if s.queries == nil {
+		s.queries = map[string]*query.Query{}
+	}
+	parsed, ok := s.queries[q]
+	if !ok {
+		var err error
+		if parsed, err = query.Parse(q); err != nil {
+			s.mu.Unlock()
+			return false, err
+		}
+		s.queries[q] = parsed
+	}
+	s.mu.Unlock()
+	return parsed.Match(log), nil
+}
+
+func (s *Simulator) runProcessors(result *Result, path []string, processors []datadogV1.LogsProcessor) {
+	for _, p := range processors {
+		before := flatten(result.Output)
+		step := s.apply(result.Output, p)
+		step.Pipeline = path
+		step.Changes = diff(before, flatten(result.Output))
+		result.Trace = append(result.Trace, step)
+		if nested := p.LogsPipelineProcessor; nested != nil && step.Applied {
+			s.runProcessors(result, append(append([]string{}, path...), nested.GetName()), nested.Processors)
+		}
+	}
+}
+
+func flatten(log map[string]interface{}) map[string]interface{} {
+	flat := map[string]interface{}{}
+	var walk func(prefix string, v map[string]interface{})
+	walk = func(prefix string, v map[string]interface{}) {
+		for k, child := range v {
+			key := prefix + k
+			if m, ok := child.(map[string]interface{}); ok && len(m) > 0 {
+				walk(key+".", m)
+				continue
+			}
+			flat[key] = child
+		}
+	}
+	walk("", log)
+	return flat
+}
+
+func diff(before, after map[string]interface{}) []Change {
+	var changes []Change
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			changes = append(changes, Change{Attribute: k, Before: before[k], After: v})
+		}
+	}
+	for k, v := range before {
+		if _, ok := after[k]; !ok {
+			changes = append(changes, Change{Attribute: k, Before: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Attribute < changes[j].Attribute })
+	return changes
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			c[k] = deepCopy(child)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, child := range t {
+			c[i] = deepCopy(child)
+		}
+		return c
+	}
+	return v
+}
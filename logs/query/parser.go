@@ -0,0 +1,278 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenNot
+	tokenFieldGroup
+	tokenRange
+)
+
+type token struct {
+	kind   tokenKind
+	field  string
+	text   string
+	quoted bool
+}
+
+func tokenize(q string) []token {
+	var tokens []token
+	runes := []rune(q)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")"})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('):
+			tokens = append(tokens, token{kind: tokenNot, text: "-"})
+			i++
+		case r == '"':
+			text, next := readQuoted(runes, i)
+			tokens = append(tokens, token{kind: tokenTerm, text: text, quoted: true})
+			i = next
+		default:
+			var tok token
+			tok, i = readWord(runes, i)
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+func readQuoted(runes []rune, i int) (string, int) {
+	var b strings.Builder
+	i++
+	for i < len(runes) && runes[i] != '"' {
+		if runes[i] == '\\' && i+1 < len(runes) {
+			i++
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	return b.String(), i + 1
+}
+
+func readWord(runes []rune, i int) (token, int) {
+	var b strings.Builder
+	for i < len(runes) {
+		r := runes[i]
+		if unicode.IsSpace(r) || r == '(' || r == ')' {
+			break
+		}
+		if r == '\\' && i+1 < len(runes) {
+			b.WriteRune(runes[i+1])
+			i += 2
+			continue
+		}
+		if r == ':' && b.Len() > 0 {
+			field := b.String()
+			i++
+			if i >= len(runes) {
+				return token{kind: tokenTerm, field: field}, i
+			}
+			switch runes[i] {
+			case '"':
+				text, next := readQuoted(runes, i)
+				return token{kind: tokenTerm, field: field, text: text, quoted: true}, next
+			case '(':
+				return token{kind: tokenFieldGroup, field: field}, i + 1
+			case '[', '{':
+				start := i
+				for i < len(runes) && runes[i] != ']' && runes[i] != '}' {
+					i++
+				}
+				if i < len(runes) {
+					i++
+				}
+				return token{kind: tokenRange, field: field, text: string(runes[start:i])}, i
+			}
+			value, next := readWord(runes, i)
+			return token{kind: tokenTerm, field: field, text: value.text}, next
+		}
+		b.WriteRune(r)
+		i++
+	}
+	return token{kind: tokenTerm, text: b.String()}, i
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) isKeyword(word string) bool {
+	if p.done() {
+		return false
+	}
+	t := p.peek()
+	return t.kind == tokenTerm && !t.quoted && t.field == "" && t.text == word
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseOrIn("")
+}
+
+func (p *parser) parseOrIn(field string) (node, error) {
+	var children orNode
+	for {
+		n, err := p.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+		if !p.isKeyword("OR") {
+			break
+		}
+		p.pos++
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return children, nil
+}
+
+func (p *parser) parseAnd(field string) (node, error) {
+	var children andNode
+	for !p.done() && p.peek().kind != tokenRParen && !p.isKeyword("OR") {
+		if p.isKeyword("AND") {
+			p.pos++
+			continue
+		}
+		n, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+	switch len(children) {
+	case 0:
+		return nil, fmt.Errorf("query: empty expression")
+	case 1:
+		return children[0], nil
+	}
+	return children, nil
+}
+
+func (p *parser) parseUnary(field string) (node, error) {
+	if p.isKeyword("NOT") || (!p.done() && p.peek().kind == tokenNot) {
+		p.pos++
+		if p.done() {
+			return nil, fmt.Errorf("query: missing operand after negation")
+		}
+		n, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parsePrimary(field)
+}
+
+func (p *parser) parsePrimary(field string) (node, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case tokenLParen, tokenFieldGroup:
+		inner := field
+		if t.kind == tokenFieldGroup {
+			inner = t.field
+		}
+		n, err := p.parseOrIn(inner)
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.peek().kind != tokenRParen {
+			return nil, fmt.Errorf("query: missing closing parenthesis")
+		}
+		p.pos++
+		return n, nil
+	case tokenRange:
+		return newRangeTerm(t.field, t.text)
+	case tokenTerm:
+		f := t.field
+		if f == "" {
+			f = field
+		}
+		if f == "" && t.text == "*" {
+			return matchAll{}, nil
+		}
+		return newTerm(f, t.text, t.quoted), nil
+	}
+	return nil, fmt.Errorf("query: unexpected token %q", t.text)
+}
+
+func newTerm(field, value string, quoted bool) *termNode {
+	n := &termNode{field: field, value: value}
+	if !quoted {
+		for _, op := range []string{">=", "<=", ">", "<"} {
+			if strings.HasPrefix(value, op) {
+				if f, err := strconv.ParseFloat(value[len(op):], 64); err == nil {
+					n.op = op
+					n.number = f
+					return n
+				}
+			}
+		}
+		if value != "*" && strings.ContainsAny(value, "*?") {
+			n.pattern = globToRegexp(value, field != "", field == "" || field == "status")
+		}
+	}
+	return n
+}
+
+func newRangeTerm(field, text string) (node, error) {
+	if len(text) < 2 {
+		return nil, fmt.Errorf("query: invalid range %q", text)
+	}
+	n := &termNode{field: field, lowInc: text[0] == '[', highInc: text[len(text)-1] == ']'}
+	parts := strings.Fields(text[1 : len(text)-1])
+	if len(parts) != 3 || parts[1] != "TO" {
+		return nil, fmt.Errorf("query: invalid range %q", text)
+	}
+	bound := func(s string) (*float64, error) {
+		if s == "*" {
+			return nil, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid range bound %q", s)
+		}
+		return &f, nil
+	}
+	var err error
+	if n.low, err = bound(parts[0]); err != nil {
+		return nil, err
+	}
+	if n.high, err = bound(parts[2]); err != nil {
+		return nil, err
+	}
+	if n.low == nil && n.high == nil {
+		n.value = "*"
+	}
+	return n, nil
+}
@@ -0,0 +1,376 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package query evaluates Datadog log search queries against JSON logs.
+//
+// The supported syntax covers what is commonly used in pipeline, category,
+// index and exclusion filters: free text, reserved attributes (host, service,
+// source, status, message), tags, @attribute facets, quoted phrases,
+// wildcards, numeric comparisons and ranges, and boolean operators.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed log search query.
+type Query struct {
+	raw  string
+	root node
+}
+
+// Parse parses a log search query. An empty query matches every log.
+func Parse(q string) (*Query, error) {
+	p := &parser{tokens: tokenize(q)}
+	if len(p.tokens) == 0 {
+		return &Query{raw: q, root: matchAll{}}, nil
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("query: unexpected token %q in %q", p.peek().text, q)
+	}
+	return &Query{raw: q, root: n}, nil
+}
+
+// MustParse is like Parse but panics if the query cannot be parsed.
+func MustParse(q string) *Query {
+	parsed, err := Parse(q)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// String returns the original query string.
+func (q *Query) String() string {
+	return q.raw
+}
+
+// Match reports whether the log matches the query.
+func (q *Query) Match(log map[string]interface{}) bool {
+	return q.root.match(log)
+}
+
+// Match parses the query and reports whether the log matches it.
+func Match(q string, log map[string]interface{}) (bool, error) {
+	parsed, err := Parse(q)
+	if err != nil {
+		return false, err
+	}
+	return parsed.Match(log), nil
+}
+
+type node interface {
+	match(log map[string]interface{}) bool
+}
+
+type matchAll struct{}
+
+func (matchAll) match(map[string]interface{}) bool { return true }
+
+type andNode []node
+
+func (n andNode) match(log map[string]interface{}) bool {
+	for _, c := range n {
+		if !c.match(log) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []node
+
+func (n orNode) match(log map[string]interface{}) bool {
+	for _, c := range n {
+		if c.match(log) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct{ child node }
+
+func (n notNode) match(log map[string]interface{}) bool { return !n.child.match(log) }
+
+// termNode matches a single field against a value, a comparison or a range.
+type termNode struct {
+	field   string
+	value   string
+	pattern *regexp.Regexp
+	op      string
+	number  float64
+	low     *float64
+	high    *float64
+	lowInc  bool
+	highInc bool
+}
+
+func (n *termNode) match(log map[string]interface{}) bool {
+	if n.field == "" {
+		msg, _ := Lookup(log, "message")
+		return n.matchText(fmt.Sprint(valueOrEmpty(msg)))
+	}
+	values, ok := fieldValues(log, n.field)
+	if !ok {
+		return false
+	}
+	for _, v := range values {
+		if n.matchValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *termNode) matchText(text string) bool {
+	if n.value == "*" {
+		return true
+	}
+	if n.pattern != nil {
+		return n.pattern.MatchString(text)
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(n.value))
+}
+
+func (n *termNode) matchValue(v interface{}) bool {
+	switch {
+	case n.op != "":
+		f, ok := toFloat(v)
+		if !ok {
+			return false
+		}
+		switch n.op {
+		case ">":
+			return f > n.number
+		case ">=":
+			return f >= n.number
+		case "<":
+			return f < n.number
+		case "<=":
+			return f <= n.number
+		}
+		return false
+	case n.low != nil || n.high != nil:
+		f, ok := toFloat(v)
+		if !ok {
+			return false
+		}
+		if n.low != nil && (f < *n.low || (!n.lowInc && f == *n.low)) {
+			return false
+		}
+		if n.high != nil && (f > *n.high || (!n.highInc && f == *n.high)) {
+			return false
+		}
+		return true
+	case n.value == "*":
+		return true
+	}
+	s := stringify(v)
+	if n.pattern != nil {
+		return n.pattern.MatchString(s)
+	}
+	if f, ok := toFloat(v); ok {
+		if want, err := strconv.ParseFloat(n.value, 64); err == nil {
+			return f == want
+		}
+	}
+	if n.field == "status" {
+		return strings.EqualFold(s, n.value)
+	}
+	return s == n.value
+}
+
+// fieldValues returns the candidate values of a query field in the log.
+// Fields prefixed with @ are attributes, reserved attributes are read from
+// the top level of the log and every other field is treated as a tag key.
+func fieldValues(log map[string]interface{}, field string) ([]interface{}, bool) {
+	switch {
+	case field == "":
+		return nil, false
+	case strings.HasPrefix(field, "@"):
+		v, ok := Lookup(log, field[1:])
+		if !ok {
+			return nil, false
+		}
+		if list, isList := v.([]interface{}); isList {
+			return list, true
+		}
+		return []interface{}{v}, true
+	case IsReserved(field):
+		v, ok := Lookup(log, field)
+		if !ok && field == "source" {
+			v, ok = Lookup(log, "ddsource")
+		}
+		if !ok {
+			return nil, false
+		}
+		return []interface{}{v}, true
+	}
+	var values []interface{}
+	prefix := field + ":"
+	for _, tag := range Tags(log) {
+		if tag == field {
+			values = append(values, "")
+		} else if strings.HasPrefix(tag, prefix) {
+			values = append(values, tag[len(prefix):])
+		}
+	}
+	return values, len(values) > 0
+}
+
+// IsReserved reports whether the attribute is one of the reserved log attributes.
+func IsReserved(name string) bool {
+	switch name {
+	case "host", "service", "source", "status", "message", "trace_id", "date":
+		return true
+	}
+	return false
+}
+
+// Tags returns the tags of the log, read from the "ddtags" string and the
+// "tags" string or array.
+func Tags(log map[string]interface{}) []string {
+	var tags []string
+	for _, key := range []string{"ddtags", "tags"} {
+		switch v := log[key].(type) {
+		case string:
+			for _, t := range strings.Split(v, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					tags = append(tags, t)
+				}
+			}
+		case []interface{}:
+			for _, t := range v {
+				tags = append(tags, fmt.Sprint(t))
+			}
+		case []string:
+			tags = append(tags, v...)
+		}
+	}
+	return tags
+}
+
+// Lookup returns the value at the dotted attribute path.
+// A key containing dots is matched before descending into nested objects.
+func Lookup(log map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := log[path]; ok {
+		return v, true
+	}
+	parts := strings.Split(path, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		head := strings.Join(parts[:i], ".")
+		child, ok := log[head].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := Lookup(child, strings.Join(parts[i:], ".")); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Set stores the value at the dotted attribute path, creating intermediate objects.
+func Set(log map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	cur := log
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := cur[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			cur[p] = next
+		}
+		cur = next
+	}
+	cur[parts[len(parts)-1]] = value
+}
+
+// Delete removes the value at the dotted attribute path.
+func Delete(log map[string]interface{}, path string) {
+	if _, ok := log[path]; ok {
+		delete(log, path)
+		return
+	}
+	parts := strings.Split(path, ".")
+	cur := log
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := cur[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	delete(cur, parts[len(parts)-1])
+}
+
+func valueOrEmpty(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+func stringify(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// globToRegexp converts a search wildcard pattern into an anchored regular expression.
+func globToRegexp(pattern string, anchored, caseInsensitive bool) *regexp.Regexp {
+	var b strings.Builder
+	if caseInsensitive {
+		b.WriteString("(?i)")
+	}
+	if anchored {
+		b.WriteString("^")
+	}
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if anchored {
+		b.WriteString("$")
+	}
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,274 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package monitor renders monitor notification messages locally.
+//
+// Messages use the template syntax of Datadog notifications: conditional
+// sections such as {{#is_alert}} or {{^is_warning}}, comparisons such as
+// {{#is_match "env.name" "prod"}}, and variables such as {{value}} or
+// {{host.name}}. A message is rendered for a simulated state and tag
+// context, listing the handles that would be notified and the variables that
+// are unknown for the type of the monitor.
+package monitor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// State is the state of a monitor.
+type State string
+
+// List of State.
+const (
+	StateOK      State = "OK"
+	StateAlert   State = "Alert"
+	StateWarning State = "Warn"
+	StateNoData  State = "No Data"
+)
+
+// Context is the simulated notification a message is rendered for.
+type Context struct {
+	// State is the state the monitor transitioned to. The monitor recovered
+	// when it is OK.
+	State State
+	// Previous is the state the monitor transitioned from, used by the
+	// recovery and is_alert_to_warning conditions.
+	Previous State
+	// Renotify is set for re-notifications.
+	Renotify bool
+	// Priority is the priority of the monitor, such as "P1".
+	Priority string
+	// Tags are the values of the tags of the group, such as "host" or "env",
+	// rendered by the {{<tag>.name}} variables.
+	Tags map[string]string
+	// Values are the values of the other variables, such as "value",
+	// "threshold" or "log.message".
+	Values map[string]string
+}
+
+// Message is a rendered notification message.
+type Message struct {
+	Text string
+	// Handles are the handles notified, without the "@" prefix.
+	Handles []string
+	// Unknown are the variables and conditions that are not known for the
+	// type of the monitor.
+	Unknown []string
+}
+
+// commonVariables are the variables of all monitor types.
+var commonVariables = map[string]bool{
+	"value":                    true,
+	"threshold":                true,
+	"warn_threshold":           true,
+	"ok_threshold":             true,
+	"comparator":               true,
+	"priority":                 true,
+	"last_triggered_at":        true,
+	"last_triggered_at_epoch":  true,
+	"first_triggered_at":       true,
+	"first_triggered_at_epoch": true,
+	"triggered_duration_sec":   true,
+}
+
+// typeVariables are the variables, or prefixes of variables when ending
+// with a dot, specific to monitor types.
+var typeVariables = map[datadogV1.MonitorType][]string{
+	datadogV1.MONITORTYPE_LOG_ALERT:             {"log."},
+	datadogV1.MONITORTYPE_EVENT_ALERT:           {"event."},
+	datadogV1.MONITORTYPE_EVENT_V2_ALERT:        {"event."},
+	datadogV1.MONITORTYPE_SERVICE_CHECK:         {"check_message"},
+	datadogV1.MONITORTYPE_PROCESS_ALERT:         {"process."},
+	datadogV1.MONITORTYPE_RUM_ALERT:             {"rum."},
+	datadogV1.MONITORTYPE_SYNTHETICS_ALERT:      {"synthetics."},
+	datadogV1.MONITORTYPE_TRACE_ANALYTICS_ALERT: {"span."},
+	datadogV1.MONITORTYPE_SLO_ALERT:             {"slo."},
+	datadogV1.MONITORTYPE_AUDIT_ALERT:           {"audit."},
+	datadogV1.MONITORTYPE_CI_PIPELINES_ALERT:    {"cipipeline."},
+	datadogV1.MONITORTYPE_CI_TESTS_ALERT:        {"citest."},
+	datadogV1.MONITORTYPE_ERROR_TRACKING_ALERT:  {"issue."},
+}
+
+// knownVariable reports whether the variable is known for the type. Tag
+// variables, {{<tag>.name}}, and host variables are known for all types.
+func knownVariable(monitorType datadogV1.MonitorType, name string) bool {
+	if commonVariables[name] || strings.HasSuffix(name, ".name") || strings.HasPrefix(name, "host.") {
+		return true
+	}
+	for _, v := range typeVariables[monitorType] {
+		if name == v || (strings.HasSuffix(v, ".") && strings.HasPrefix(name, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// conditions are the conditional sections and whether they hold in a
+// context.
+var conditions = map[string]func(c *Context, args []string) bool{
+	"is_alert":    func(c *Context, _ []string) bool { return c.State == StateAlert },
+	"is_warning":  func(c *Context, _ []string) bool { return c.State == StateWarning },
+	"is_no_data":  func(c *Context, _ []string) bool { return c.State == StateNoData },
+	"is_recovery": func(c *Context, _ []string) bool { return c.State == StateOK },
+	"is_alert_recovery": func(c *Context, _ []string) bool {
+		return c.State == StateOK && c.Previous == StateAlert
+	},
+	"is_warning_recovery": func(c *Context, _ []string) bool {
+		return c.State == StateOK && c.Previous == StateWarning
+	},
+	"is_alert_to_warning": func(c *Context, _ []string) bool {
+		return c.State == StateWarning && c.Previous == StateAlert
+	},
+	"is_renotify": func(c *Context, _ []string) bool { return c.Renotify },
+	"is_priority": func(c *Context, args []string) bool {
+		return len(args) > 0 && strings.EqualFold(c.Priority, args[0])
+	},
+	"is_match": func(c *Context, args []string) bool {
+		if len(args) == 0 {
+			return false
+		}
+		value := c.lookup(args[0])
+		for _, s := range args[1:] {
+			if strings.Contains(value, s) {
+				return true
+			}
+		}
+		return false
+	},
+	"is_exact_match": func(c *Context, args []string) bool {
+		if len(args) == 0 {
+			return false
+		}
+		value := c.lookup(args[0])
+		for _, s := range args[1:] {
+			if value == s {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+// comparisons are the conditions whose first argument is a variable.
+var comparisons = map[string]bool{"is_match": true, "is_exact_match": true}
+
+// lookup returns the value of a variable, empty when unset.
+func (c *Context) lookup(name string) string {
+	if v, ok := c.Values[name]; ok {
+		return v
+	}
+	if strings.HasSuffix(name, ".name") {
+		return c.Tags[strings.TrimSuffix(name, ".name")]
+	}
+	return ""
+}
+
+// Render renders the template for the context and the type of the monitor.
+func (t *Template) Render(monitorType datadogV1.MonitorType, c Context) *Message {
+	r := renderer{monitorType: monitorType, context: &c, unknown: map[string]bool{}}
+	r.check(t.nodes)
+	r.render(t.nodes)
+	m := &Message{Text: r.b.String(), Handles: Handles(r.b.String())}
+	for name := range r.unknown {
+		m.Unknown = append(m.Unknown, name)
+	}
+	sort.Strings(m.Unknown)
+	return m
+}
+
+// Render renders a notification message for the context and the type of
+// the monitor.
+func Render(message string, monitorType datadogV1.MonitorType, c Context) (*Message, error) {
+	t, err := Parse(message)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(monitorType, c), nil
+}
+
+// RenderMonitor renders the message of a monitor. The escalation message is
+// appended to re-notifications.
+func RenderMonitor(m datadogV1.Monitor, c Context) (*Message, error) {
+	message := m.GetMessage()
+	if escalation := m.Options.GetEscalationMessage(); c.Renotify && escalation != "" {
+		message += "\n\n" + escalation
+	}
+	rendered, err := Render(message, m.GetType(), c)
+	if err != nil {
+		return nil, fmt.Errorf("monitor %d: %w", m.GetId(), err)
+	}
+	return rendered, nil
+}
+
+type renderer struct {
+	monitorType datadogV1.MonitorType
+	context     *Context
+	unknown     map[string]bool
+	b           strings.Builder
+}
+
+// check collects the unknown variables and conditions of all the branches
+// of the template, rendered or not.
+func (r *renderer) check(nodes []node) {
+	for _, n := range nodes {
+		switch {
+		case n.variable != "":
+			if !knownVariable(r.monitorType, n.variable) {
+				r.unknown[n.variable] = true
+			}
+		case n.section != nil:
+			s := n.section
+			if _, ok := conditions[s.name]; !ok {
+				r.unknown["#"+s.name] = true
+			} else if comparisons[s.name] && len(s.args) > 0 && !knownVariable(r.monitorType, s.args[0]) {
+				r.unknown[s.args[0]] = true
+			}
+			r.check(s.children)
+		}
+	}
+}
+
+func (r *renderer) render(nodes []node) {
+	for _, n := range nodes {
+		switch {
+		case n.section != nil:
+			s := n.section
+			holds := false
+			if condition, ok := conditions[s.name]; ok {
+				holds = condition(r.context, s.args)
+			}
+			if holds != s.inverted {
+				r.render(s.children)
+			}
+		case n.variable != "":
+			r.b.WriteString(r.context.lookup(n.variable))
+		default:
+			r.b.WriteString(n.text)
+		}
+	}
+}
+
+// handlePattern matches notification handles such as @slack-channel,
+// @pagerduty-service or @user@example.com.
+var handlePattern = regexp.MustCompile(`(?:^|[\s(\[,;:])@([A-Za-z0-9_\-+.]+(?:@[A-Za-z0-9\-.]+)?)`)
+
+// Handles returns the handles notified by a rendered message, in order of
+// appearance and without the "@" prefix.
+func Handles(text string) []string {
+	var handles []string
+	seen := map[string]bool{}
+	for _, match := range handlePattern.FindAllStringSubmatch(text, -1) {
+		handle := strings.TrimRight(match[1], ".-")
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package monitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// node is a part of a parsed template: text, a variable or a section.
+type node struct {
+	text     string
+	variable string
+	section  *section
+}
+
+// section is a conditional block, {{#name args}}...{{/name}}, or its
+// inverse {{^name args}}...{{/name}}.
+type section struct {
+	name     string
+	args     []string
+	inverted bool
+	children []node
+}
+
+// Template is a parsed notification message template.
+type Template struct {
+	nodes []node
+}
+
+// Parse parses a notification message template.
+func Parse(message string) (*Template, error) {
+	type frame struct {
+		section *section
+		nodes   []node
+	}
+	stack := []frame{{}}
+	rest := message
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			break
+		}
+		open, close := "{{", "}}"
+		if strings.HasPrefix(rest[start:], "{{{") {
+			open, close = "{{{", "}}}"
+		}
+		end := strings.Index(rest[start+len(open):], close)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed tag at offset %d", len(message)-len(rest)+start)
+		}
+		top := &stack[len(stack)-1]
+		if start > 0 {
+			top.nodes = append(top.nodes, node{text: rest[:start]})
+		}
+		tag := strings.TrimSpace(rest[start+len(open) : start+len(open)+end])
+		rest = rest[start+len(open)+end+len(close):]
+
+		switch {
+		case tag == "":
+			return nil, fmt.Errorf("empty tag")
+		case tag[0] == '!':
+			// Comment.
+		case tag[0] == '#' || tag[0] == '^':
+			fields, err := splitArgs(tag[1:])
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("section without name")
+			}
+			s := &section{name: fields[0], args: fields[1:], inverted: tag[0] == '^'}
+			stack = append(stack, frame{section: s})
+		case tag[0] == '/':
+			name := strings.TrimSpace(tag[1:])
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected {{/%s}}", name)
+			}
+			if name != top.section.name {
+				return nil, fmt.Errorf("{{/%s}} closes {{#%s}}", name, top.section.name)
+			}
+			closed := stack[len(stack)-1]
+			closed.section.children = closed.nodes
+			stack = stack[:len(stack)-1]
+			parent := &stack[len(stack)-1]
+			parent.nodes = append(parent.nodes, node{section: closed.section})
+		default:
+			top.nodes = append(top.nodes, node{variable: tag})
+		}
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("unclosed section {{#%s}}", stack[len(stack)-1].section.name)
+	}
+	if rest != "" {
+		stack[0].nodes = append(stack[0].nodes, node{text: rest})
+	}
+	return &Template{nodes: stack[0].nodes}, nil
+}
+
+// splitArgs splits the name and arguments of a section. Arguments may be
+// quoted with double or single quotes.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if s == "" {
+			return args, nil
+		}
+		if q := s[0]; q == '"' || q == '\'' {
+			end := strings.IndexByte(s[1:], q)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", s)
+			}
+			args = append(args, s[1:end+1])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t\n")
+		if end < 0 {
+			end = len(s)
+		}
+		args = append(args, s[:end])
+		s = s[end:]
+	}
+}
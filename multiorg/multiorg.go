@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package multiorg runs operations across the parent and child orgs of an
+// account.
+//
+// A Registry holds the credentials and site of every org by public ID. Run
+// calls an operation for all or selected orgs with bounded concurrency, each
+// with a context carrying the keys and site of its org, and collects the
+// result or error of every org.
+package multiorg
+
+import (
+	"bytes"
+	_context "context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/strictyaml"
+)
+
+// Org is an org and its credentials.
+type Org struct {
+	PublicID string `yaml:"public_id"`
+	Name     string `yaml:"name,omitempty"`
+	// Site is the site of the org, such as "datadoghq.eu", the site of the
+	// context when empty.
+	Site   string `yaml:"site,omitempty"`
+	APIKey string `yaml:"api_key"`
+	AppKey string `yaml:"app_key"`
+}
+
+// Context returns a context carrying the keys and site of the org. The other
+// server variables of the context are kept.
+func (o Org) Context(ctx _context.Context) _context.Context {
+	if o.Site != "" {
+		variables := map[string]string{}
+		if current, ok := ctx.Value(datadog.ContextServerVariables).(map[string]string); ok {
+			for k, v := range current {
+				variables[k] = v
+			}
+		}
+		variables["site"] = o.Site
+		ctx = _context.WithValue(ctx, datadog.ContextServerVariables, variables)
+	}
+	return _context.WithValue(ctx, datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: o.APIKey},
+		"appKeyAuth": {Key: o.AppKey},
+	})
+}
+
+func (o Org) validate() error {
+	switch {
+	case o.PublicID == "":
+		return fmt.Errorf("org %q has no public ID", o.Name)
+	case o.APIKey == "" || o.AppKey == "":
+		return fmt.Errorf("org %s has no API or application key", o.PublicID)
+	}
+	return nil
+}
+
+// Decode reads orgs from a YAML list. Environment variables such as
+// ${DD_API_KEY} are expanded in keys.
+func Decode(r io.Reader) ([]Org, error) {
+	var orgs []Org
+	if err := strictyaml.Decode(r, &orgs); err != nil {
+		return nil, err
+	}
+	for i := range orgs {
+		orgs[i].APIKey = os.ExpandEnv(orgs[i].APIKey)
+		orgs[i].AppKey = os.ExpandEnv(orgs[i].AppKey)
+		if err := orgs[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	return orgs, nil
+}
+
+// Unmarshal reads orgs from a YAML list.
+func Unmarshal(data []byte) ([]Org, error) {
+	return Decode(bytes.NewReader(data))
+}
+
+// Registry holds the orgs operations run across.
+type Registry struct {
+	client *datadog.APIClient
+	mu     sync.RWMutex
+	orgs   map[string]Org
+	// Concurrency is the maximum number of orgs operated on at once, 4 by
+	// default.
+	Concurrency int
+}
+
+// NewRegistry returns a registry of the orgs, operated on with the client.
+func NewRegistry(client *datadog.APIClient, orgs ...Org) (*Registry, error) {
+	r := &Registry{client: client, orgs: map[string]Org{}, Concurrency: 4}
+	for _, o := range orgs {
+		if err := r.Add(o); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Client returns the client of the registry.
+func (r *Registry) Client() *datadog.APIClient {
+	return r.client
+}
+
+// Add adds or replaces an org.
+func (r *Registry) Add(o Org) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orgs[o.PublicID] = o
+	return nil
+}
+
+// Remove removes an org.
+func (r *Registry) Remove(publicID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.orgs, publicID)
+}
+
+// Get returns an org by public ID.
+func (r *Registry) Get(publicID string) (Org, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	o, ok := r.orgs[publicID]
+	return o, ok
+}
+
+// Orgs returns the orgs sorted by public ID.
+func (r *Registry) Orgs() []Org {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	orgs := make([]Org, 0, len(r.orgs))
+	for _, o := range r.orgs {
+		orgs = append(orgs, o)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].PublicID < orgs[j].PublicID })
+	return orgs
+}
+
+// Unregistered lists the orgs visible from an org, its child orgs when it
+// is a parent org, and returns the ones not in the registry.
+func (r *Registry) Unregistered(ctx _context.Context, publicID string) ([]datadogV1.Organization, error) {
+	parent, ok := r.Get(publicID)
+	if !ok {
+		return nil, fmt.Errorf("org %s is not registered", publicID)
+	}
+	resp, _, err := datadogV1.NewOrganizationsApi(r.client).ListOrgs(parent.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("listing orgs of %s: %w", publicID, err)
+	}
+	var orgs []datadogV1.Organization
+	for _, o := range resp.Orgs {
+		if _, ok := r.Get(o.GetPublicId()); !ok {
+			orgs = append(orgs, o)
+		}
+	}
+	return orgs, nil
+}
+
+// CreateChild creates a child org of a parent org and registers it with the
+// keys returned on creation, on the site of the parent. The keys are only
+// returned on creation: when the created org cannot be registered, it is
+// returned along with the error.
+func (r *Registry) CreateChild(ctx _context.Context, parentID, name string) (Org, error) {
+	parent, ok := r.Get(parentID)
+	if !ok {
+		return Org{}, fmt.Errorf("org %s is not registered", parentID)
+	}
+	resp, _, err := datadogV1.NewOrganizationsApi(r.client).CreateChildOrg(parent.Context(ctx), *datadogV1.NewOrganizationCreateBody(name))
+	if err != nil {
+		return Org{}, fmt.Errorf("creating child org %q of %s: %w", name, parentID, err)
+	}
+	o := Org{
+		PublicID: resp.Org.GetPublicId(),
+		Name:     resp.Org.GetName(),
+		Site:     parent.Site,
+		APIKey:   resp.ApiKey.GetKey(),
+		AppKey:   resp.ApplicationKey.GetHash(),
+	}
+	if err := r.Add(o); err != nil {
+		return o, fmt.Errorf("registering child org %q of %s: %w", name, parentID, err)
+	}
+	return o, nil
+}
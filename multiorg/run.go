@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package multiorg
+
+import (
+	_context "context"
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/parallel"
+)
+
+// Operation is an operation run for an org. The context carries the keys
+// and site of the org.
+type Operation func(ctx _context.Context, client *datadog.APIClient, org Org) (interface{}, error)
+
+// Result is the result of an operation for an org.
+type Result struct {
+	Org   Org
+	Value interface{}
+	Err   error
+}
+
+// Run runs the operation for the orgs with the given public IDs, or for all
+// the orgs of the registry when none are given. Results are in order of the
+// public IDs, or of the orgs of the registry. Orgs not in the registry, and
+// orgs not started when the context is done, have an error.
+func (r *Registry) Run(ctx _context.Context, op Operation, publicIDs ...string) []Result {
+	var results []Result
+	if len(publicIDs) == 0 {
+		for _, o := range r.Orgs() {
+			results = append(results, Result{Org: o})
+		}
+	} else {
+		for _, id := range publicIDs {
+			o, ok := r.Get(id)
+			if !ok {
+				results = append(results, Result{Org: Org{PublicID: id}, Err: fmt.Errorf("org %s is not registered", id)})
+				continue
+			}
+			results = append(results, Result{Org: o})
+		}
+	}
+
+	var pending []*Result
+	for i := range results {
+		if results[i].Err == nil {
+			pending = append(pending, &results[i])
+		}
+	}
+	started := parallel.Run(ctx, r.Concurrency, len(pending), func(i int) {
+		res := pending[i]
+		res.Value, res.Err = op(res.Org.Context(ctx), r.client, res.Org)
+	})
+	for _, res := range pending[started:] {
+		res.Err = ctx.Err()
+	}
+	return results
+}
+
+// Error is the error of the orgs an operation failed for.
+type Error struct {
+	// Errs are the errors by org public ID.
+	Errs  map[string]error
+	order []string
+	total int
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.order))
+	for i, id := range e.order {
+		messages[i] = fmt.Sprintf("%s: %v", id, e.Errs[id])
+	}
+	return fmt.Sprintf("%d of %d org(s) failed: %s", len(e.order), e.total, strings.Join(messages, "; "))
+}
+
+// Errors returns an *Error when the operation failed for some orgs, nil
+// otherwise.
+func Errors(results []Result) error {
+	e := &Error{Errs: map[string]error{}, total: len(results)}
+	for _, res := range results {
+		if res.Err != nil {
+			e.Errs[res.Org.PublicID] = res.Err
+			e.order = append(e.order, res.Org.PublicID)
+		}
+	}
+	if len(e.order) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,479 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package provision provisions the users of an org in bulk.
+//
+// The desired users are read from CSV, JSON or SCIM 2.0 payloads. Their
+// groups map to roles, and optionally to the AuthN mappings giving the same
+// roles to members of the groups signing in with SAML. A Provisioner diffs
+// the desired users against the users of the org into a Plan, which renders
+// as a dry-run report, and applies it: users are created and invited,
+// renamed, re-enabled, disabled and given their roles.
+//
+// Disabling is idempotent, and service accounts are never modified.
+package provision
+
+import (
+	_context "context"
+	"errors"
+	"fmt"
+	"io"
+	_nethttp "net/http"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/syncplan"
+)
+
+const pageSize = 100
+
+// Action is the action of a change.
+type Action string
+
+// List of Action.
+const (
+	ActionCreate        Action = "create"
+	ActionUpdate        Action = "update"
+	ActionEnable        Action = "enable"
+	ActionDisable       Action = "disable"
+	ActionAddRole       Action = "add_role"
+	ActionRemoveRole    Action = "remove_role"
+	ActionCreateMapping Action = "create_mapping"
+)
+
+// Change is a change to a user or an AuthN mapping.
+type Change struct {
+	Action Action
+	// Email is the email of the user.
+	Email string
+	// Name is the name of the user created or updated.
+	Name  string
+	Title string
+	// Role is the role added or removed, or of the mapping created.
+	Role string
+	// Roles are the roles of the user created.
+	Roles []string
+	// Group is the group of the mapping created.
+	Group string
+
+	userID  string
+	roleIDs []string
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case ActionCreate:
+		if len(c.Roles) > 0 {
+			return fmt.Sprintf("create user %s with roles %s", c.Email, strings.Join(c.Roles, ", "))
+		}
+		return fmt.Sprintf("create user %s", c.Email)
+	case ActionUpdate:
+		return fmt.Sprintf("rename user %s to %q", c.Email, c.Name)
+	case ActionEnable:
+		return fmt.Sprintf("enable user %s", c.Email)
+	case ActionDisable:
+		return fmt.Sprintf("disable user %s", c.Email)
+	case ActionAddRole:
+		return fmt.Sprintf("add role %q to user %s", c.Role, c.Email)
+	case ActionRemoveRole:
+		return fmt.Sprintf("remove role %q from user %s", c.Role, c.Email)
+	case ActionCreateMapping:
+		return fmt.Sprintf("map group %q to role %q", c.Group, c.Role)
+	}
+	return string(c.Action)
+}
+
+// Plan is the changes provisioning the desired users.
+type Plan struct {
+	Changes []Change
+	// Warnings are the desired changes not planned, such as changes to
+	// service accounts.
+	Warnings []string
+}
+
+// WriteText writes the changes and warnings of the plan, with counts by
+// action.
+func (p *Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+	counts := map[Action]int{}
+	for _, c := range p.Changes {
+		fmt.Fprintf(&b, "%s\n", c)
+		counts[c.Action]++
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", warning)
+	}
+	if len(p.Changes) == 0 {
+		b.WriteString("No changes.\n")
+	} else {
+		var summary []string
+		for _, a := range []Action{ActionCreate, ActionUpdate, ActionEnable, ActionDisable, ActionAddRole, ActionRemoveRole, ActionCreateMapping} {
+			if counts[a] > 0 {
+				summary = append(summary, fmt.Sprintf("%d %s", counts[a], a))
+			}
+		}
+		fmt.Fprintf(&b, "\n%d change(s): %s.\n", len(p.Changes), strings.Join(summary, ", "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Provisioner provisions the users of an org.
+type Provisioner struct {
+	users *datadogV2.UsersApi
+	roles *datadogV2.RolesApi
+	authn *datadogV2.AuthNMappingsApi
+
+	// Groups are the names of the roles of a group, by group.
+	Groups map[string][]string
+	// AuthNAttribute is the SAML attribute of the groups of users. When set,
+	// plans create the AuthN mappings of groups to roles missing.
+	AuthNAttribute string
+	// DisableMissing disables the users of the org that are not desired.
+	DisableMissing bool
+	// Invite sends invitations to the users created.
+	Invite bool
+	// Protected are the emails of users never disabled.
+	Protected []string
+}
+
+// NewProvisioner returns a provisioner inviting the users created.
+func NewProvisioner(client *datadog.APIClient) *Provisioner {
+	return &Provisioner{
+		users:  datadogV2.NewUsersApi(client),
+		roles:  datadogV2.NewRolesApi(client),
+		authn:  datadogV2.NewAuthNMappingsApi(client),
+		Groups: map[string][]string{},
+		Invite: true,
+	}
+}
+
+// Plan returns the changes provisioning the desired users. The roles of
+// desired users with neither groups nor roles are left alone.
+func (p *Provisioner) Plan(ctx _context.Context, desired []User) (*Plan, error) {
+	roleIDs, roleNames, err := p.listRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := p.listUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byEmail := map[string]datadogV2.User{}
+	for _, u := range existing {
+		byEmail[strings.ToLower(u.Attributes.GetEmail())] = u
+	}
+	protected := map[string]bool{}
+	for _, email := range p.Protected {
+		protected[strings.ToLower(email)] = true
+	}
+
+	plan := &Plan{}
+	unknown := map[string]bool{}
+	wanted := map[string]bool{}
+	for _, d := range desired {
+		email := strings.ToLower(d.Email)
+		if wanted[email] {
+			return nil, fmt.Errorf("user %s is listed twice", d.Email)
+		}
+		wanted[email] = true
+		roles, managed := p.roleNames(d, plan)
+		for _, r := range roles {
+			if _, ok := roleIDs[r]; !ok {
+				unknown[r] = true
+			}
+		}
+
+		u, exists := byEmail[email]
+		if !exists {
+			if d.Active {
+				plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Email: d.Email, Name: d.Name, Title: d.Title, Roles: roles, roleIDs: ids(roles, roleIDs)})
+			}
+			continue
+		}
+		if u.Attributes.GetServiceAccount() {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("user %s is a service account and is not provisioned", d.Email))
+			continue
+		}
+		disabled := u.Attributes.GetDisabled()
+		if !d.Active {
+			if !disabled {
+				if protected[email] {
+					plan.Warnings = append(plan.Warnings, fmt.Sprintf("user %s is protected and is not disabled", d.Email))
+				} else {
+					plan.Changes = append(plan.Changes, Change{Action: ActionDisable, Email: d.Email, userID: u.GetId()})
+				}
+			}
+			continue
+		}
+		if disabled {
+			plan.Changes = append(plan.Changes, Change{Action: ActionEnable, Email: d.Email, userID: u.GetId()})
+		}
+		if d.Name != "" && d.Name != u.Attributes.GetName() {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Email: d.Email, Name: d.Name, userID: u.GetId()})
+		}
+		if !managed {
+			continue
+		}
+		var current []string
+		relationships := u.GetRelationships()
+		userRoles := relationships.GetRoles()
+		for _, r := range userRoles.Data {
+			if name, ok := roleNames[r.GetId()]; ok {
+				current = append(current, name)
+			}
+		}
+		added, removed := syncplan.Difference(roles, current)
+		for _, r := range added {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAddRole, Email: d.Email, Role: r, userID: u.GetId(), roleIDs: []string{roleIDs[r]}})
+		}
+		for _, r := range removed {
+			plan.Changes = append(plan.Changes, Change{Action: ActionRemoveRole, Email: d.Email, Role: r, userID: u.GetId(), roleIDs: []string{roleIDs[r]}})
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown roles: %s", strings.Join(sortedKeys(unknown), ", "))
+	}
+
+	if p.DisableMissing {
+		for _, u := range existing {
+			email := strings.ToLower(u.Attributes.GetEmail())
+			if wanted[email] || u.Attributes.GetDisabled() {
+				continue
+			}
+			switch {
+			case u.Attributes.GetServiceAccount():
+			case protected[email]:
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("user %s is protected and is not disabled", u.Attributes.GetEmail()))
+			default:
+				plan.Changes = append(plan.Changes, Change{Action: ActionDisable, Email: u.Attributes.GetEmail(), userID: u.GetId()})
+			}
+		}
+	}
+
+	if p.AuthNAttribute != "" {
+		if err := p.planMappings(ctx, plan, roleIDs); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// roleNames returns the sorted roles of a desired user and whether its roles
+// are managed.
+func (p *Provisioner) roleNames(d User, plan *Plan) ([]string, bool) {
+	if d.Groups == nil && d.Roles == nil {
+		return nil, false
+	}
+	set := map[string]bool{}
+	for _, r := range d.Roles {
+		set[r] = true
+	}
+	for _, g := range d.Groups {
+		roles, ok := p.Groups[g]
+		if !ok {
+			warning := fmt.Sprintf("group %q maps to no role", g)
+			if !contains(plan.Warnings, warning) {
+				plan.Warnings = append(plan.Warnings, warning)
+			}
+		}
+		for _, r := range roles {
+			set[r] = true
+		}
+	}
+	return sortedKeys(set), true
+}
+
+// planMappings plans the AuthN mappings of groups to roles missing.
+func (p *Provisioner) planMappings(ctx _context.Context, plan *Plan, roleIDs map[string]string) error {
+	existing := map[[2]string]bool{}
+	for page := int64(0); ; page++ {
+		resp, _, err := p.authn.ListAuthNMappings(ctx, *datadogV2.NewListAuthNMappingsOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return fmt.Errorf("listing AuthN mappings: %w", err)
+		}
+		for _, m := range resp.Data {
+			attributes := m.GetAttributes()
+			relationships := m.GetRelationships()
+			role := relationships.GetRole()
+			data := role.GetData()
+			if attributes.GetAttributeKey() == p.AuthNAttribute {
+				existing[[2]string{attributes.GetAttributeValue(), data.GetId()}] = true
+			}
+		}
+		if len(resp.Data) < pageSize {
+			break
+		}
+	}
+
+	var groups []string
+	for g := range p.Groups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	var unknown []string
+	for _, group := range groups {
+		for _, role := range p.Groups[group] {
+			id, ok := roleIDs[role]
+			if !ok {
+				unknown = append(unknown, role)
+				continue
+			}
+			if !existing[[2]string{group, id}] {
+				plan.Changes = append(plan.Changes, Change{Action: ActionCreateMapping, Group: group, Role: role, roleIDs: []string{id}})
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown roles: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func (p *Provisioner) listRoles(ctx _context.Context) (map[string]string, map[string]string, error) {
+	ids, names := map[string]string{}, map[string]string{}
+	for page := int64(0); ; page++ {
+		resp, _, err := p.roles.ListRoles(ctx, *datadogV2.NewListRolesOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing roles: %w", err)
+		}
+		for _, r := range resp.Data {
+			ids[r.Attributes.GetName()] = r.GetId()
+			names[r.GetId()] = r.Attributes.GetName()
+		}
+		if len(resp.Data) < pageSize {
+			return ids, names, nil
+		}
+	}
+}
+
+func (p *Provisioner) listUsers(ctx _context.Context) ([]datadogV2.User, error) {
+	var users []datadogV2.User
+	for page := int64(0); ; page++ {
+		resp, _, err := p.users.ListUsers(ctx, *datadogV2.NewListUsersOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, fmt.Errorf("listing users: %w", err)
+		}
+		users = append(users, resp.Data...)
+		if len(resp.Data) < pageSize {
+			return users, nil
+		}
+	}
+}
+
+// Apply applies the changes of a plan, and sends invitations to the users
+// created in a single request. Changes failing do not stop the others; the
+// error lists them.
+func (p *Provisioner) Apply(ctx _context.Context, plan *Plan) error {
+	var failed []string
+	var invitations []datadogV2.UserInvitationData
+	for _, c := range plan.Changes {
+		userID, err := p.apply(ctx, c)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", c, err))
+			continue
+		}
+		if c.Action == ActionCreate && p.Invite {
+			invitations = append(invitations, datadogV2.UserInvitationData{
+				Relationships: datadogV2.UserInvitationRelationships{User: user(userID)},
+				Type:          datadogV2.USERINVITATIONSTYPE_USER_INVITATIONS,
+			})
+		}
+	}
+	if len(invitations) > 0 {
+		if _, _, err := p.users.SendInvitations(ctx, datadogV2.UserInvitationsRequest{Data: invitations}); err != nil {
+			failed = append(failed, fmt.Sprintf("sending %d invitation(s): %v", len(invitations), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d change(s) failed: %s", len(failed), len(plan.Changes), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// apply applies a change, and returns the ID of the user changed.
+func (p *Provisioner) apply(ctx _context.Context, c Change) (string, error) {
+	switch c.Action {
+	case ActionCreate:
+		attributes := datadogV2.UserCreateAttributes{Email: c.Email}
+		if c.Name != "" {
+			attributes.Name = &c.Name
+		}
+		if c.Title != "" {
+			attributes.Title = &c.Title
+		}
+		data := datadogV2.UserCreateData{Attributes: attributes, Type: datadogV2.USERSTYPE_USERS}
+		if len(c.roleIDs) > 0 {
+			roles := datadogV2.RelationshipToRoles{}
+			for _, id := range c.roleIDs {
+				roles.Data = append(roles.Data, datadogV2.RelationshipToRoleData{Id: datadog.PtrString(id), Type: datadogV2.ROLESTYPE_ROLES.Ptr()})
+			}
+			data.Relationships = &datadogV2.UserRelationships{Roles: &roles}
+		}
+		resp, _, err := p.users.CreateUser(ctx, datadogV2.UserCreateRequest{Data: data})
+		if err != nil {
+			return "", err
+		}
+		return resp.Data.GetId(), nil
+	case ActionUpdate, ActionEnable:
+		attributes := datadogV2.UserUpdateAttributes{}
+		if c.Action == ActionUpdate {
+			attributes.Name = &c.Name
+		} else {
+			attributes.Disabled = datadog.PtrBool(false)
+		}
+		_, _, err := p.users.UpdateUser(ctx, c.userID, datadogV2.UserUpdateRequest{
+			Data: datadogV2.UserUpdateData{Attributes: attributes, Id: c.userID, Type: datadogV2.USERSTYPE_USERS},
+		})
+		return c.userID, err
+	case ActionDisable:
+		resp, err := p.users.DisableUser(ctx, c.userID)
+		if err != nil && resp != nil && resp.StatusCode == _nethttp.StatusNotFound {
+			// The user is already gone.
+			err = nil
+		}
+		return c.userID, err
+	case ActionAddRole:
+		_, _, err := p.roles.AddUserToRole(ctx, c.roleIDs[0], user(c.userID))
+		return c.userID, err
+	case ActionRemoveRole:
+		_, _, err := p.roles.RemoveUserFromRole(ctx, c.roleIDs[0], user(c.userID))
+		return c.userID, err
+	case ActionCreateMapping:
+		_, _, err := p.authn.CreateAuthNMapping(ctx, datadogV2.AuthNMappingCreateRequest{Data: datadogV2.AuthNMappingCreateData{
+			Attributes: &datadogV2.AuthNMappingCreateAttributes{AttributeKey: &p.AuthNAttribute, AttributeValue: &c.Group},
+			Relationships: &datadogV2.AuthNMappingCreateRelationships{Role: &datadogV2.RelationshipToRole{
+				Data: &datadogV2.RelationshipToRoleData{Id: &c.roleIDs[0], Type: datadogV2.ROLESTYPE_ROLES.Ptr()},
+			}},
+			Type: datadogV2.AUTHNMAPPINGSTYPE_AUTHN_MAPPINGS,
+		}})
+		return "", err
+	}
+	return "", errors.New("unknown action")
+}
+
+func user(id string) datadogV2.RelationshipToUser {
+	return datadogV2.RelationshipToUser{Data: datadogV2.RelationshipToUserData{Id: id, Type: datadogV2.USERSTYPE_USERS}}
+}
+
+func ids(names []string, byName map[string]string) []string {
+	var ids []string
+	for _, n := range names {
+		if id, ok := byName[n]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
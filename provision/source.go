@@ -0,0 +1,247 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package provision
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// User is a desired user.
+type User struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+	Title string `json:"title,omitempty"`
+	// Groups are the groups of the user, mapped to roles.
+	Groups []string `json:"groups,omitempty"`
+	// Roles are the names of roles of the user, besides the roles of its
+	// groups.
+	Roles []string `json:"roles,omitempty"`
+	// Active is false for users to disable.
+	Active bool `json:"active"`
+}
+
+// ReadCSV reads users from CSV with a header. The email column is required;
+// the name, title, groups, roles and active columns are optional. Groups and
+// roles are separated by semicolons, and users are active unless the active
+// column is false.
+func ReadCSV(r io.Reader) ([]User, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("missing email column")
+	}
+
+	var users []User
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return users, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		u := User{
+			Email:  get("email"),
+			Name:   get("name"),
+			Title:  get("title"),
+			Groups: split(get("groups")),
+			Roles:  split(get("roles")),
+			Active: true,
+		}
+		if active := get("active"); active != "" {
+			if u.Active, err = parseBool(active); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if u.Email == "" {
+			return nil, fmt.Errorf("line %d: missing email", line)
+		}
+		users = append(users, u)
+	}
+}
+
+func split(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid active value %q", s)
+	}
+	return b, nil
+}
+
+// ReadJSON reads a JSON array of users. Users are active unless active is
+// false.
+func ReadJSON(r io.Reader) ([]User, error) {
+	var raw []struct {
+		User
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	users := make([]User, len(raw))
+	for i, u := range raw {
+		users[i] = u.User
+		users[i].Active = u.Active == nil || *u.Active
+		if users[i].Email == "" {
+			return nil, fmt.Errorf("user %d: missing email", i)
+		}
+	}
+	return users, nil
+}
+
+const (
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+)
+
+type scimResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	UserName    string   `json:"userName"`
+	DisplayName string   `json:"displayName"`
+	Title       string   `json:"title"`
+	Active      *bool    `json:"active"`
+	Name        struct {
+		Formatted  string `json:"formatted"`
+		GivenName  string `json:"givenName"`
+		FamilyName string `json:"familyName"`
+	} `json:"name"`
+	Emails []struct {
+		Value   string `json:"value"`
+		Primary bool   `json:"primary"`
+	} `json:"emails"`
+	Groups []struct {
+		Value   string `json:"value"`
+		Display string `json:"display"`
+	} `json:"groups"`
+	Members []struct {
+		Value string `json:"value"`
+	} `json:"members"`
+	Meta struct {
+		ResourceType string `json:"resourceType"`
+	} `json:"meta"`
+	// Resources are the resources of a ListResponse.
+	Resources []scimResource `json:"Resources"`
+}
+
+func (r *scimResource) is(schema, resourceType string) bool {
+	if r.Meta.ResourceType == resourceType {
+		return true
+	}
+	for _, s := range r.Schemas {
+		if s == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadSCIM reads users from SCIM 2.0 payloads, such as the ListResponses of
+// the Users and Groups endpoints. The groups of users are the groups listing
+// them as members and the groups listed by the users.
+func ReadSCIM(payloads ...io.Reader) ([]User, error) {
+	var resources []scimResource
+	for _, r := range payloads {
+		var payload scimResource
+		if err := json.NewDecoder(r).Decode(&payload); err != nil {
+			return nil, err
+		}
+		if payload.Resources != nil {
+			resources = append(resources, payload.Resources...)
+		} else {
+			resources = append(resources, payload)
+		}
+	}
+
+	var users []User
+	byID := map[string]int{}
+	groupNames := map[string]string{}
+	for _, r := range resources {
+		switch {
+		case r.is(scimGroupSchema, "Group"):
+			groupNames[r.ID] = r.DisplayName
+		case r.is(scimUserSchema, "User"):
+			u := User{Email: r.UserName, Name: r.DisplayName, Title: r.Title, Active: r.Active == nil || *r.Active}
+			for i, e := range r.Emails {
+				if e.Primary || i == 0 {
+					u.Email = e.Value
+				}
+			}
+			if u.Name == "" {
+				u.Name = r.Name.Formatted
+			}
+			if u.Name == "" {
+				u.Name = strings.TrimSpace(r.Name.GivenName + " " + r.Name.FamilyName)
+			}
+			for _, g := range r.Groups {
+				if g.Display != "" {
+					u.Groups = append(u.Groups, g.Display)
+				}
+			}
+			if u.Email == "" {
+				return nil, fmt.Errorf("user %s: missing email", r.ID)
+			}
+			byID[r.ID] = len(users)
+			users = append(users, u)
+		}
+	}
+	for _, r := range resources {
+		if !r.is(scimGroupSchema, "Group") {
+			continue
+		}
+		for _, m := range r.Members {
+			if i, ok := byID[m.Value]; ok && !contains(users[i].Groups, groupNames[r.ID]) {
+				users[i].Groups = append(users[i].Groups, groupNames[r.ID])
+			}
+		}
+	}
+	return users, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package rbac
+
+import (
+	_context "context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strings"
+)
+
+// UserAccess is the effective access of a user.
+type UserAccess struct {
+	Handle string
+	Roles  []string
+	// Permissions are the names of the roles granting a permission, by
+	// permission name.
+	Permissions map[string][]string
+}
+
+// Access is the effective access of the users of an org.
+type Access struct {
+	// Permissions are the names of the permissions granted to some user.
+	Permissions []string
+	Users       []UserAccess
+}
+
+// Access returns the effective access of the enabled users of an org.
+func (s *Syncer) Access(ctx _context.Context) (*Access, error) {
+	roles, err := s.listRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	grants := map[string][]string{}
+	for _, r := range roles {
+		names[r.GetId()] = r.Attributes.GetName()
+		if grants[r.GetId()], err = s.rolePermissions(ctx, r.GetId()); err != nil {
+			return nil, err
+		}
+	}
+	users, err := s.listUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &Access{}
+	granted := map[string]bool{}
+	for _, u := range users {
+		if u.Attributes.GetDisabled() {
+			continue
+		}
+		ua := UserAccess{Handle: u.Attributes.GetHandle(), Roles: []string{}, Permissions: map[string][]string{}}
+		relationships := u.GetRelationships()
+		roles := relationships.GetRoles()
+		for _, r := range roles.Data {
+			name, ok := names[r.GetId()]
+			if !ok {
+				continue
+			}
+			ua.Roles = append(ua.Roles, name)
+			for _, p := range grants[r.GetId()] {
+				ua.Permissions[p] = append(ua.Permissions[p], name)
+				granted[p] = true
+			}
+		}
+		sort.Strings(ua.Roles)
+		for _, p := range ua.Permissions {
+			sort.Strings(p)
+		}
+		access.Users = append(access.Users, ua)
+	}
+	for p := range granted {
+		access.Permissions = append(access.Permissions, p)
+	}
+	sort.Strings(access.Permissions)
+	sort.Slice(access.Users, func(i, j int) bool { return access.Users[i].Handle < access.Users[j].Handle })
+	return access, nil
+}
+
+// WriteCSV writes the access matrix, a row per user and a column per
+// permission with the roles granting it.
+func (a *Access) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"user", "roles"}, a.Permissions...)); err != nil {
+		return err
+	}
+	for _, u := range a.Users {
+		row := []string{u.Handle, strings.Join(u.Roles, "; ")}
+		for _, p := range a.Permissions {
+			row = append(row, strings.Join(u.Permissions[p], "; "))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,468 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package rbac manages roles declaratively.
+//
+// Roles are declared by name with the names of their permissions, such as
+// dashboards_write, rather than their IDs, which differ between orgs, so the
+// same declaration applies to every org of an account. A Syncer diffs the
+// declared roles against an org into a Plan, renders it for review and
+// applies it. Export writes the roles of an org as a declaration, to copy
+// them to another org.
+//
+// Access reports the effective access of the users of an org, every
+// permission of every user and the roles granting it, for access reviews.
+//
+// A declaration in YAML looks like:
+//
+//	roles:
+//	  - name: Incident Commander
+//	    clone_from: Datadog Standard Role
+//	    permissions:
+//	      - incident_read
+//	      - incident_write
+//	    users:
+//	      - jane@example.com
+package rbac
+
+import (
+	"bytes"
+	_context "context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/strictyaml"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/syncplan"
+)
+
+const pageSize = 100
+
+// Role is a declared role.
+type Role struct {
+	Name string `yaml:"name"`
+	// CloneFrom is the name of the role the role is cloned from when it does
+	// not exist yet.
+	CloneFrom   string   `yaml:"clone_from,omitempty"`
+	Permissions []string `yaml:"permissions"`
+	// Users are the handles of the members of the role. Members are not
+	// managed when nil.
+	Users []string `yaml:"users,omitempty"`
+}
+
+// MarshalYAML writes an empty list of users, rather than none, for a role
+// whose members are managed, so that it reads back the same.
+func (r Role) MarshalYAML() (interface{}, error) {
+	fields := struct {
+		Name        string    `yaml:"name"`
+		CloneFrom   string    `yaml:"clone_from,omitempty"`
+		Permissions []string  `yaml:"permissions"`
+		Users       *[]string `yaml:"users,omitempty"`
+	}{Name: r.Name, CloneFrom: r.CloneFrom, Permissions: r.Permissions}
+	if r.Users != nil {
+		fields.Users = &r.Users
+	}
+	return fields, nil
+}
+
+// Config is a declaration of roles.
+type Config struct {
+	Roles []Role `yaml:"roles"`
+}
+
+// Decode reads a declaration of roles in YAML.
+func Decode(r io.Reader) (*Config, error) {
+	var c Config
+	if err := strictyaml.Decode(r, &c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Unmarshal parses a declaration of roles in YAML.
+func Unmarshal(data []byte) (*Config, error) {
+	return Decode(bytes.NewReader(data))
+}
+
+// Validate checks that roles have a unique name.
+func (c *Config) Validate() error {
+	names := map[string]bool{}
+	for i, r := range c.Roles {
+		if r.Name == "" {
+			return fmt.Errorf("role %d has no name", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("role %q is declared twice", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// Action is the action of a change.
+type Action string
+
+// List of Action.
+const (
+	ActionCreateRole       Action = "create_role"
+	ActionAddPermission    Action = "add_permission"
+	ActionRemovePermission Action = "remove_permission"
+	ActionAddUser          Action = "add_user"
+	ActionRemoveUser       Action = "remove_user"
+)
+
+// Change is a change to a role.
+type Change struct {
+	Action    Action
+	Role      string
+	CloneFrom string
+	// Permission is the name of the permission added or removed.
+	Permission string
+	// User is the handle of the user added or removed.
+	User string
+
+	roleID, cloneFromID, permissionID, userID string
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case ActionCreateRole:
+		if c.CloneFrom != "" {
+			return fmt.Sprintf("create role %q as a clone of %q", c.Role, c.CloneFrom)
+		}
+		return fmt.Sprintf("create role %q", c.Role)
+	case ActionAddPermission:
+		return fmt.Sprintf("add permission %s to role %q", c.Permission, c.Role)
+	case ActionRemovePermission:
+		return fmt.Sprintf("remove permission %s from role %q", c.Permission, c.Role)
+	case ActionAddUser:
+		return fmt.Sprintf("add user %s to role %q", c.User, c.Role)
+	case ActionRemoveUser:
+		return fmt.Sprintf("remove user %s from role %q", c.User, c.Role)
+	}
+	return string(c.Action)
+}
+
+// Plan is the changes making an org match a declaration.
+type Plan struct {
+	Changes []Change
+}
+
+// Empty returns whether the org already matches the declaration.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// WriteText writes the changes, one per line.
+func (p *Plan) WriteText(w io.Writer) error {
+	lines := make([]syncplan.Line, len(p.Changes))
+	added, removed := 0, 0
+	for i, c := range p.Changes {
+		lines[i] = syncplan.Line{Sign: "+", Change: c.String()}
+		if c.Action == ActionRemovePermission || c.Action == ActionRemoveUser {
+			lines[i].Sign = "-"
+			removed++
+		} else {
+			added++
+		}
+	}
+	return syncplan.Write(w, lines, fmt.Sprintf("%d to add, %d to remove", added, removed))
+}
+
+// Syncer syncs the roles of an org.
+type Syncer struct {
+	roles *datadogV2.RolesApi
+	users *datadogV2.UsersApi
+}
+
+// NewSyncer returns a syncer using the roles and users APIs.
+func NewSyncer(roles *datadogV2.RolesApi, users *datadogV2.UsersApi) *Syncer {
+	return &Syncer{roles: roles, users: users}
+}
+
+// org is the state of an org.
+type org struct {
+	permissions map[string]string // ID by name
+	roles       map[string]string // ID by name
+	users       map[string]string // ID by handle
+}
+
+func (s *Syncer) org(ctx _context.Context, withUsers bool) (*org, error) {
+	o := &org{permissions: map[string]string{}, roles: map[string]string{}, users: map[string]string{}}
+	permissions, _, err := s.roles.ListPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing permissions: %w", err)
+	}
+	for _, p := range permissions.Data {
+		o.permissions[p.Attributes.GetName()] = p.GetId()
+	}
+	roles, err := s.listRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		o.roles[r.Attributes.GetName()] = r.GetId()
+	}
+	if withUsers {
+		users, err := s.listUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			o.users[u.Attributes.GetHandle()] = u.GetId()
+		}
+	}
+	return o, nil
+}
+
+func (s *Syncer) listRoles(ctx _context.Context) ([]datadogV2.Role, error) {
+	var roles []datadogV2.Role
+	for page := int64(0); ; page++ {
+		resp, _, err := s.roles.ListRoles(ctx, *datadogV2.NewListRolesOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, fmt.Errorf("listing roles: %w", err)
+		}
+		roles = append(roles, resp.Data...)
+		if len(resp.Data) < pageSize {
+			return roles, nil
+		}
+	}
+}
+
+func (s *Syncer) listUsers(ctx _context.Context) ([]datadogV2.User, error) {
+	var users []datadogV2.User
+	for page := int64(0); ; page++ {
+		resp, _, err := s.users.ListUsers(ctx, *datadogV2.NewListUsersOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, fmt.Errorf("listing users: %w", err)
+		}
+		users = append(users, resp.Data...)
+		if len(resp.Data) < pageSize {
+			return users, nil
+		}
+	}
+}
+
+func (s *Syncer) rolePermissions(ctx _context.Context, roleID string) ([]string, error) {
+	resp, _, err := s.roles.ListRolePermissions(ctx, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("listing permissions of role %s: %w", roleID, err)
+	}
+	names := make([]string, len(resp.Data))
+	for i, p := range resp.Data {
+		names[i] = p.Attributes.GetName()
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Syncer) roleUsers(ctx _context.Context, roleID string) ([]datadogV2.User, error) {
+	var users []datadogV2.User
+	for page := int64(0); ; page++ {
+		resp, _, err := s.roles.ListRoleUsers(ctx, roleID, *datadogV2.NewListRoleUsersOptionalParameters().WithPageSize(pageSize).WithPageNumber(page))
+		if err != nil {
+			return nil, fmt.Errorf("listing users of role %s: %w", roleID, err)
+		}
+		users = append(users, resp.Data...)
+		if len(resp.Data) < pageSize {
+			return users, nil
+		}
+	}
+}
+
+// Diff returns the changes making the declared roles of an org match the
+// declaration, in the order of the declaration. Roles not declared are left
+// alone.
+func (s *Syncer) Diff(ctx _context.Context, config *Config) (*Plan, error) {
+	withUsers := false
+	for _, r := range config.Roles {
+		withUsers = withUsers || r.Users != nil
+	}
+	o, err := s.org(ctx, withUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	for _, r := range config.Roles {
+		for _, p := range r.Permissions {
+			if _, ok := o.permissions[p]; !ok {
+				unknown = append(unknown, p)
+			}
+		}
+		for _, u := range r.Users {
+			if _, ok := o.users[u]; !ok {
+				unknown = append(unknown, "user "+u)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown permissions or users: %s", strings.Join(unknown, ", "))
+	}
+
+	plan := &Plan{}
+	// Roles are cloned after the changes to the roles declared before them.
+	planned := map[string][]string{}
+	for _, r := range config.Roles {
+		roleID, exists := o.roles[r.Name]
+		var current []string
+		var members []string
+		if exists {
+			if current, err = s.rolePermissions(ctx, roleID); err != nil {
+				return nil, err
+			}
+			if r.Users != nil {
+				users, err := s.roleUsers(ctx, roleID)
+				if err != nil {
+					return nil, err
+				}
+				for _, u := range users {
+					members = append(members, u.Attributes.GetHandle())
+				}
+			}
+		} else {
+			create := Change{Action: ActionCreateRole, Role: r.Name}
+			if r.CloneFrom != "" {
+				cloneFromID, ok := o.roles[r.CloneFrom]
+				if !ok {
+					return nil, fmt.Errorf("role %q is cloned from unknown role %q", r.Name, r.CloneFrom)
+				}
+				if p, ok := planned[r.CloneFrom]; ok {
+					current = p
+				} else if current, err = s.rolePermissions(ctx, cloneFromID); err != nil {
+					return nil, err
+				}
+				create.CloneFrom, create.cloneFromID = r.CloneFrom, cloneFromID
+			}
+			plan.Changes = append(plan.Changes, create)
+		}
+
+		planned[r.Name] = r.Permissions
+		added, removed := syncplan.Difference(r.Permissions, current)
+		for _, p := range added {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAddPermission, Role: r.Name, Permission: p, roleID: roleID, permissionID: o.permissions[p]})
+		}
+		for _, p := range removed {
+			plan.Changes = append(plan.Changes, Change{Action: ActionRemovePermission, Role: r.Name, Permission: p, roleID: roleID, permissionID: o.permissions[p]})
+		}
+		if r.Users == nil {
+			continue
+		}
+		added, removed = syncplan.Difference(r.Users, members)
+		for _, u := range added {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAddUser, Role: r.Name, User: u, roleID: roleID, userID: o.users[u]})
+		}
+		for _, u := range removed {
+			plan.Changes = append(plan.Changes, Change{Action: ActionRemoveUser, Role: r.Name, User: u, roleID: roleID, userID: o.users[u]})
+		}
+	}
+	return plan, nil
+}
+
+// Apply applies the changes of a plan in order, and stops at the first
+// change failing.
+func (s *Syncer) Apply(ctx _context.Context, plan *Plan) error {
+	created := map[string]string{}
+	for _, c := range plan.Changes {
+		roleID := c.roleID
+		if roleID == "" {
+			roleID = created[c.Role]
+		}
+		var err error
+		switch c.Action {
+		case ActionCreateRole:
+			created[c.Role], err = s.createRole(ctx, c)
+		case ActionAddPermission:
+			_, _, err = s.roles.AddPermissionToRole(ctx, roleID, permission(c.permissionID))
+		case ActionRemovePermission:
+			_, _, err = s.roles.RemovePermissionFromRole(ctx, roleID, permission(c.permissionID))
+		case ActionAddUser:
+			_, _, err = s.roles.AddUserToRole(ctx, roleID, user(c.userID))
+		case ActionRemoveUser:
+			_, _, err = s.roles.RemoveUserFromRole(ctx, roleID, user(c.userID))
+		default:
+			err = fmt.Errorf("unknown action %q", c.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) createRole(ctx _context.Context, c Change) (string, error) {
+	if c.cloneFromID != "" {
+		resp, _, err := s.roles.CloneRole(ctx, c.cloneFromID, datadogV2.RoleCloneRequest{
+			Data: datadogV2.RoleClone{Attributes: datadogV2.RoleCloneAttributes{Name: c.Role}, Type: datadogV2.ROLESTYPE_ROLES},
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.Data.GetId(), nil
+	}
+	resp, _, err := s.roles.CreateRole(ctx, datadogV2.RoleCreateRequest{
+		Data: datadogV2.RoleCreateData{Attributes: datadogV2.RoleCreateAttributes{Name: c.Role}, Type: datadogV2.ROLESTYPE_ROLES.Ptr()},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Data.GetId(), nil
+}
+
+func permission(id string) datadogV2.RelationshipToPermission {
+	return datadogV2.RelationshipToPermission{Data: &datadogV2.RelationshipToPermissionData{Id: &id, Type: datadogV2.PERMISSIONSTYPE_PERMISSIONS.Ptr()}}
+}
+
+func user(id string) datadogV2.RelationshipToUser {
+	return datadogV2.RelationshipToUser{Data: datadogV2.RelationshipToUserData{Id: id, Type: datadogV2.USERSTYPE_USERS}}
+}
+
+// Export returns the declaration of the roles of an org with the given
+// names, or of all its roles when none are given.
+func (s *Syncer) Export(ctx _context.Context, names ...string) (*Config, error) {
+	roles, err := s.listRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, n := range names {
+		wanted[n] = true
+	}
+	config := &Config{}
+	for _, r := range roles {
+		name := r.Attributes.GetName()
+		if len(names) > 0 && !wanted[name] {
+			continue
+		}
+		delete(wanted, name)
+		role := Role{Name: name, Users: []string{}}
+		if role.Permissions, err = s.rolePermissions(ctx, r.GetId()); err != nil {
+			return nil, err
+		}
+		users, err := s.roleUsers(ctx, r.GetId())
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			role.Users = append(role.Users, u.Attributes.GetHandle())
+		}
+		sort.Strings(role.Users)
+		config.Roles = append(config.Roles, role)
+	}
+	if len(wanted) > 0 {
+		var missing []string
+		for n := range wanted {
+			missing = append(missing, n)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("unknown roles: %s", strings.Join(missing, ", "))
+	}
+	sort.Slice(config.Roles, func(i, j int) bool { return config.Roles[i].Name < config.Roles[j].Name })
+	return config, nil
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// CorrectionIntervals returns the intervals an SLO correction applies to which
+// overlap the range. Occurrences of a recurring correction last its duration,
+// or the time between its start and end when it has no duration.
+func CorrectionIntervals(c datadogV1.SLOCorrectionResponseAttributes, within Interval) ([]Interval, error) {
+	loc, err := location(c.GetTimezone())
+	if err != nil {
+		return nil, err
+	}
+	start, end := unix(c.Start, loc), unix(c.End, loc)
+	rrule := c.Rrule.Get()
+	if rrule == nil || *rrule == "" {
+		if iv := (Interval{Start: start, End: end}); iv.Overlaps(within) {
+			return []Interval{iv}, nil
+		}
+		return nil, nil
+	}
+	if start.IsZero() {
+		return nil, fmt.Errorf("recurring correction has no start")
+	}
+	rule, err := ParseRule(*rrule)
+	if err != nil {
+		return nil, err
+	}
+	duration := end.Sub(start)
+	if v := c.Duration.Get(); v != nil {
+		duration = time.Duration(*v) * time.Second
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("recurring correction has no duration")
+	}
+	return expand(rule, start, duration, within)
+}
+
+// CorrectionWindow is an occurrence of an SLO correction.
+type CorrectionWindow struct {
+	Interval
+	Correction datadogV1.SLOCorrection
+}
+
+// CorrectionWindows returns the occurrences of the corrections overlapping the
+// range, e.g. the range of an SLO history, sorted by start.
+func CorrectionWindows(corrections []datadogV1.SLOCorrection, within Interval) ([]CorrectionWindow, error) {
+	var windows []CorrectionWindow
+	for _, c := range corrections {
+		if c.Attributes == nil {
+			continue
+		}
+		intervals, err := CorrectionIntervals(*c.Attributes, within)
+		if err != nil {
+			return nil, fmt.Errorf("correction %s: %w", c.GetId(), err)
+		}
+		for _, iv := range intervals {
+			windows = append(windows, CorrectionWindow{Interval: iv, Correction: c})
+		}
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	return windows, nil
+}
@@ -0,0 +1,207 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+var downtimeFrequencies = map[string]Frequency{
+	"days":   Daily,
+	"weeks":  Weekly,
+	"months": Monthly,
+	"years":  Yearly,
+}
+
+var downtimeWeekDays = map[string]time.Weekday{
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+	"Sun": time.Sunday,
+}
+
+// RecurrenceRule converts the recurrence of a downtime to a rule. The
+// `days`, `weeks`, `months` and `years` types repeat every period of the
+// type, on the given week days for `weeks`.
+func RecurrenceRule(rec datadogV1.DowntimeRecurrence) (*Rule, error) {
+	var rule *Rule
+	switch typ := rec.GetType(); typ {
+	case "rrule":
+		var err error
+		if rule, err = ParseRule(rec.GetRrule()); err != nil {
+			return nil, err
+		}
+	case "days", "weeks", "months", "years":
+		rule = &Rule{Freq: downtimeFrequencies[typ], Interval: int(rec.GetPeriod()), WeekStart: time.Monday}
+		if typ == "weeks" {
+			for _, name := range rec.WeekDays {
+				day, ok := downtimeWeekDays[name]
+				if !ok {
+					return nil, fmt.Errorf("invalid week day %q", name)
+				}
+				rule.ByDay = append(rule.ByDay, Weekday{Day: day})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported recurrence type %q", typ)
+	}
+	if v := rec.UntilDate.Get(); v != nil {
+		rule.Until, rule.untilFloating, rule.Count = time.Unix(*v, 0), false, 0
+	}
+	if v := rec.UntilOccurrences.Get(); v != nil {
+		rule.Count, rule.Until = int(*v), time.Time{}
+	}
+	return rule, nil
+}
+
+// DowntimeIntervals returns the intervals a downtime is in effect which
+// overlap the range. A downtime without end lasts indefinitely, and its
+// recurrence is ignored. Disabled downtimes are never in effect, and canceled
+// ones stop at their cancellation.
+func DowntimeIntervals(d datadogV1.Downtime, within Interval) ([]Interval, error) {
+	if d.GetDisabled() {
+		return nil, nil
+	}
+	loc, err := location(d.GetTimezone())
+	if err != nil {
+		return nil, err
+	}
+	start, end := unix(d.Start, loc), unix(d.End.Get(), loc)
+	var intervals []Interval
+	if rec := d.Recurrence.Get(); rec != nil && !start.IsZero() && !end.IsZero() {
+		rule, err := RecurrenceRule(*rec)
+		if err != nil {
+			return nil, err
+		}
+		if intervals, err = expand(rule, start, end.Sub(start), within); err != nil {
+			return nil, err
+		}
+	} else if iv := (Interval{Start: start, End: end}); iv.Overlaps(within) {
+		intervals = []Interval{iv}
+	}
+	canceled := unix(d.Canceled.Get(), loc)
+	if canceled.IsZero() {
+		return intervals, nil
+	}
+	kept := intervals[:0]
+	for _, iv := range intervals {
+		if !iv.Start.Before(canceled) {
+			break
+		}
+		if iv.End.IsZero() || iv.End.After(canceled) {
+			iv.End = canceled
+		}
+		if iv.Overlaps(within) {
+			kept = append(kept, iv)
+		}
+	}
+	return kept, nil
+}
+
+// DowntimeWindow is an occurrence of a downtime.
+type DowntimeWindow struct {
+	Interval
+	Downtime datadogV1.Downtime
+}
+
+// DowntimeWindows returns the occurrences of the downtimes overlapping the
+// range, sorted by start. The children ListDowntimes returns for recurring
+// downtimes are skipped when their parent is listed, since the parent
+// occurrences already cover them.
+func DowntimeWindows(downtimes []datadogV1.Downtime, within Interval) ([]DowntimeWindow, error) {
+	recurring := map[int64]bool{}
+	for _, d := range downtimes {
+		if d.Recurrence.Get() != nil {
+			recurring[d.GetId()] = true
+		}
+	}
+	var windows []DowntimeWindow
+	for _, d := range downtimes {
+		if parent := d.ParentId.Get(); parent != nil && recurring[*parent] {
+			continue
+		}
+		intervals, err := DowntimeIntervals(d, within)
+		if err != nil {
+			return nil, fmt.Errorf("downtime %d: %w", d.GetId(), err)
+		}
+		for _, iv := range intervals {
+			windows = append(windows, DowntimeWindow{Interval: iv, Downtime: d})
+		}
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	return windows, nil
+}
+
+// Target is a source of alerts downtimes can mute.
+type Target struct {
+	// Tags of the source, e.g. "host:web-1" and the tags of the host.
+	Tags []string
+	// MonitorID and MonitorTags identify the alerting monitor. When both are
+	// empty, downtimes restricted to some monitors match too.
+	MonitorID   int64
+	MonitorTags []string
+}
+
+// MutedBy returns the downtimes muting the target at the given time.
+func MutedBy(downtimes []datadogV1.Downtime, target Target, at time.Time) ([]datadogV1.Downtime, error) {
+	var matching []datadogV1.Downtime
+	for _, d := range downtimes {
+		if target.matches(d) {
+			matching = append(matching, d)
+		}
+	}
+	windows, err := DowntimeWindows(matching, Interval{Start: at, End: at.Add(time.Second)})
+	if err != nil {
+		return nil, err
+	}
+	var muting []datadogV1.Downtime
+	seen := map[int64]bool{}
+	for _, w := range windows {
+		if w.Contains(at) && !seen[w.Downtime.GetId()] {
+			seen[w.Downtime.GetId()] = true
+			muting = append(muting, w.Downtime)
+		}
+	}
+	return muting, nil
+}
+
+func (t Target) matches(d datadogV1.Downtime) bool {
+	if t.MonitorID != 0 || len(t.MonitorTags) > 0 {
+		if id := d.MonitorId.Get(); id != nil && *id != t.MonitorID {
+			return false
+		}
+		for _, tag := range d.MonitorTags {
+			if tag != "*" && !containsString(t.MonitorTags, tag) {
+				return false
+			}
+		}
+	}
+	for _, scope := range d.Scope {
+		for _, tag := range strings.Split(scope, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "*" && !containsString(t.Tags, tag) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsString(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
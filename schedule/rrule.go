@@ -0,0 +1,611 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a recurrence rule.
+type Frequency int
+
+// List of Frequency.
+const (
+	Yearly Frequency = iota
+	Monthly
+	Weekly
+	Daily
+	Hourly
+	Minutely
+)
+
+var frequencyNames = map[Frequency]string{
+	Yearly:   "YEARLY",
+	Monthly:  "MONTHLY",
+	Weekly:   "WEEKLY",
+	Daily:    "DAILY",
+	Hourly:   "HOURLY",
+	Minutely: "MINUTELY",
+}
+
+// String returns the RFC 5545 name of the frequency.
+func (f Frequency) String() string {
+	return frequencyNames[f]
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Weekday is a BYDAY value. N selects the nth occurrence of the day in the
+// month or the year, counting from the end when negative, or every occurrence
+// when zero.
+type Weekday struct {
+	N   int
+	Day time.Weekday
+}
+
+// String returns the RFC 5545 form of the weekday, e.g. "-1FR".
+func (w Weekday) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Day]
+	}
+	return strconv.Itoa(w.N) + weekdayNames[w.Day]
+}
+
+// ErrUnbounded is returned when expanding a rule without COUNT or UNTIL over a
+// range without an end.
+var ErrUnbounded = errors.New("cannot expand an unbounded recurrence without an end")
+
+// maxEmptyPeriods stops the expansion of rules which cannot produce any
+// occurrence, e.g. the 30th of February.
+const maxEmptyPeriods = 1000
+
+// Rule is an RFC 5545 recurrence rule.
+//
+// FREQ (YEARLY to MINUTELY), INTERVAL, COUNT, UNTIL, BYMONTH, BYMONTHDAY,
+// BYDAY, BYHOUR, BYMINUTE, BYSECOND, BYSETPOS and WKST are supported.
+type Rule struct {
+	Freq Frequency
+	// Interval is the number of periods between occurrences, 1 when zero.
+	Interval int
+	// Count limits the number of occurrences when positive.
+	Count int
+	// Until is the last time an occurrence can start, when not zero.
+	Until      time.Time
+	ByMonth    []int
+	ByMonthDay []int
+	ByDay      []Weekday
+	ByHour     []int
+	ByMinute   []int
+	BySecond   []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+
+	// untilFloating is set when UNTIL has no time zone. Until then holds the
+	// wall clock in UTC and is interpreted in the time zone of the start.
+	untilFloating bool
+}
+
+// ParseRule parses a recurrence rule such as "FREQ=WEEKLY;BYDAY=MO,FR". An
+// "RRULE:" prefix is accepted.
+//
+// An UNTIL without time zone is interpreted in the time zone of the start of
+// the recurrence, and an UNTIL date includes the whole day.
+func ParseRule(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+	r := &Rule{WeekStart: time.Monday}
+	hasFreq := false
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		var err error
+		switch key {
+		case "FREQ":
+			hasFreq = true
+			r.Freq, err = parseFrequency(value)
+		case "INTERVAL":
+			r.Interval, err = parsePositive(value)
+		case "COUNT":
+			r.Count, err = parsePositive(value)
+		case "UNTIL":
+			r.Until, r.untilFloating, err = parseUntil(value)
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, 1, 31, true)
+		case "BYDAY":
+			r.ByDay, err = parseWeekdays(value)
+		case "BYHOUR":
+			r.ByHour, err = parseInts(value, 0, 23, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseInts(value, 0, 59, false)
+		case "BYSECOND":
+			r.BySecond, err = parseInts(value, 0, 59, false)
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(value, 1, 366, true)
+		case "WKST":
+			r.WeekStart, err = parseDay(value)
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	if !hasFreq {
+		return nil, fmt.Errorf("recurrence rule %q has no FREQ", s)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL are mutually exclusive")
+	}
+	if r.Freq != Monthly && r.Freq != Yearly {
+		for _, d := range r.ByDay {
+			if d.N != 0 {
+				return nil, fmt.Errorf("BYDAY %s needs a MONTHLY or YEARLY frequency", d)
+			}
+		}
+	}
+	return r, nil
+}
+
+// String returns the RFC 5545 form of the rule, without "RRULE:" prefix.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.untilFloating {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	ints := func(key string, values []int) {
+		if len(values) == 0 {
+			return
+		}
+		s := make([]string, len(values))
+		for i, v := range values {
+			s[i] = strconv.Itoa(v)
+		}
+		parts = append(parts, key+"="+strings.Join(s, ","))
+	}
+	ints("BYMONTH", r.ByMonth)
+	ints("BYMONTHDAY", r.ByMonthDay)
+	if len(r.ByDay) > 0 {
+		s := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			s[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(s, ","))
+	}
+	ints("BYHOUR", r.ByHour)
+	ints("BYMINUTE", r.ByMinute)
+	ints("BYSECOND", r.BySecond)
+	ints("BYSETPOS", r.BySetPos)
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the occurrences of the rule starting at dtstart which fall
+// in the range. Wall clock times are computed in the location of dtstart, so
+// a daily occurrence at 09:00 stays at 09:00 across DST changes, and times
+// which do not exist on a given day are skipped, as RFC 5545 requires.
+func (r *Rule) Between(dtstart time.Time, within Interval) ([]time.Time, error) {
+	if within.End.IsZero() && r.unbounded() {
+		return nil, ErrUnbounded
+	}
+	var out []time.Time
+	r.iterate(dtstart, within.Start, within.End, func(t time.Time) bool {
+		if within.Contains(t) {
+			out = append(out, t)
+		}
+		return true
+	})
+	return out, nil
+}
+
+func (r *Rule) unbounded() bool {
+	return r.Count == 0 && r.Until.IsZero()
+}
+
+func (r *Rule) interval() int {
+	if r.Interval < 1 {
+		return 1
+	}
+	return r.Interval
+}
+
+// until returns the UNTIL of the rule in the given location.
+func (r *Rule) until(loc *time.Location) time.Time {
+	if r.Until.IsZero() || !r.untilFloating {
+		return r.Until
+	}
+	u := r.Until
+	return time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), 0, loc)
+}
+
+// iterate calls fn for every occurrence in order until fn returns false.
+// Periods which cannot contain occurrences after the after time are skipped
+// when the rule has no COUNT, and the iteration stops at the first period
+// starting at or after before, when not zero.
+func (r *Rule) iterate(dtstart, after, before time.Time, fn func(time.Time) bool) {
+	interval := r.interval()
+	until := r.until(dtstart.Location())
+	k := 0
+	if r.Count == 0 && after.After(dtstart) {
+		k = r.periodsBetween(dtstart, after)/interval*interval - interval
+		if k < 0 {
+			k = 0
+		}
+	}
+	count, empty := 0, 0
+	for ; ; k += interval {
+		start, candidates := r.period(dtstart, k)
+		if !before.IsZero() && !start.Before(before) {
+			return
+		}
+		if !until.IsZero() && start.After(until) {
+			return
+		}
+		if len(candidates) == 0 {
+			empty++
+			if empty > maxEmptyPeriods {
+				return
+			}
+			continue
+		}
+		empty = 0
+		for _, t := range candidates {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return
+			}
+			if !fn(t) {
+				return
+			}
+		}
+	}
+}
+
+// periodsBetween returns the number of periods from the one of dtstart to the
+// one of t.
+func (r *Rule) periodsBetween(dtstart, t time.Time) int {
+	t = t.In(dtstart.Location())
+	switch r.Freq {
+	case Yearly:
+		return t.Year() - dtstart.Year()
+	case Monthly:
+		return (t.Year()-dtstart.Year())*12 + int(t.Month()) - int(dtstart.Month())
+	case Weekly:
+		return daysBetween(weekStart(civil(dtstart), r.WeekStart), weekStart(civil(t), r.WeekStart)) / 7
+	case Daily:
+		return daysBetween(civil(dtstart), civil(t))
+	case Hourly:
+		return int(t.Sub(dtstart) / time.Hour)
+	default:
+		return int(t.Sub(dtstart) / time.Minute)
+	}
+}
+
+// period returns the start of the kth period of the rule and the sorted
+// occurrences it contains.
+func (r *Rule) period(dtstart time.Time, k int) (time.Time, []time.Time) {
+	loc := dtstart.Location()
+	var start time.Time
+	var times []time.Time
+	switch r.Freq {
+	case Yearly, Monthly, Weekly, Daily:
+		var days []time.Time
+		switch r.Freq {
+		case Yearly:
+			year := time.Date(dtstart.Year()+k, 1, 1, 0, 0, 0, 0, time.UTC)
+			start = year
+			months := r.ByMonth
+			if len(months) == 0 {
+				if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+					months = []int{int(dtstart.Month())}
+				} else {
+					months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+				}
+			}
+			for _, m := range months {
+				month := time.Date(year.Year(), time.Month(m), 1, 0, 0, 0, 0, time.UTC)
+				spanFirst, spanLast := month, month.AddDate(0, 1, -1)
+				if len(r.ByMonth) == 0 {
+					spanFirst, spanLast = year, year.AddDate(1, 0, -1)
+				}
+				for d := month; d.Month() == month.Month(); d = d.AddDate(0, 0, 1) {
+					if r.matchDay(d, dtstart, spanFirst, spanLast) {
+						days = append(days, d)
+					}
+				}
+			}
+		case Monthly:
+			month := time.Date(dtstart.Year(), dtstart.Month()+time.Month(k), 1, 0, 0, 0, 0, time.UTC)
+			start = month
+			last := month.AddDate(0, 1, -1)
+			for d := month; !d.After(last); d = d.AddDate(0, 0, 1) {
+				if r.matchDay(d, dtstart, month, last) {
+					days = append(days, d)
+				}
+			}
+		case Weekly:
+			start = weekStart(civil(dtstart), r.WeekStart).AddDate(0, 0, 7*k)
+			for i := 0; i < 7; i++ {
+				d := start.AddDate(0, 0, i)
+				if r.matchDay(d, dtstart, d, d) {
+					days = append(days, d)
+				}
+			}
+		case Daily:
+			start = civil(dtstart).AddDate(0, 0, k)
+			if r.matchDay(start, dtstart, start, start) {
+				days = append(days, start)
+			}
+		}
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		hours := orDefault(r.ByHour, dtstart.Hour())
+		minutes := orDefault(r.ByMinute, dtstart.Minute())
+		seconds := orDefault(r.BySecond, dtstart.Second())
+		for _, d := range days {
+			for _, h := range hours {
+				for _, m := range minutes {
+					for _, s := range seconds {
+						t := time.Date(d.Year(), d.Month(), d.Day(), h, m, s, 0, loc)
+						if t.Day() != d.Day() || t.Hour() != h || t.Minute() != m {
+							// The wall clock time does not exist on that day.
+							continue
+						}
+						times = append(times, t)
+					}
+				}
+			}
+		}
+	case Hourly, Minutely:
+		unit, seconds := time.Hour, orDefault(r.BySecond, dtstart.Second())
+		var offsets []time.Duration
+		if r.Freq == Hourly {
+			start = time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), dtstart.Hour(), 0, 0, 0, loc)
+			for _, m := range orDefault(r.ByMinute, dtstart.Minute()) {
+				for _, s := range seconds {
+					offsets = append(offsets, time.Duration(m)*time.Minute+time.Duration(s)*time.Second)
+				}
+			}
+		} else {
+			unit = time.Minute
+			start = time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), dtstart.Hour(), dtstart.Minute(), 0, 0, loc)
+			for _, s := range seconds {
+				offsets = append(offsets, time.Duration(s)*time.Second)
+			}
+		}
+		start = start.Add(time.Duration(k) * unit)
+		for _, o := range offsets {
+			t := start.Add(o)
+			d := civil(t)
+			month := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+			if !r.matchDay(d, dtstart, month, month.AddDate(0, 1, -1)) ||
+				(len(r.ByHour) > 0 && !containsInt(r.ByHour, t.Hour())) ||
+				(r.Freq == Minutely && len(r.ByMinute) > 0 && !containsInt(r.ByMinute, t.Minute())) {
+				continue
+			}
+			times = append(times, t)
+		}
+	}
+	return start, r.setPos(times)
+}
+
+// matchDay reports whether the day is selected by the BYMONTH, BYMONTHDAY
+// and BYDAY parts of the rule. BYDAY ordinals count within the span of days.
+func (r *Rule) matchDay(d, dtstart, spanFirst, spanLast time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(d.Month())) {
+		return false
+	}
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		switch r.Freq {
+		case Yearly, Monthly:
+			return d.Day() == dtstart.Day()
+		case Weekly:
+			return d.Weekday() == dtstart.Weekday()
+		}
+		return true
+	}
+	if len(r.ByMonthDay) > 0 {
+		daysInMonth := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		matched := false
+		for _, md := range r.ByMonthDay {
+			if md == d.Day() || (md < 0 && daysInMonth+md+1 == d.Day()) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.ByDay) > 0 {
+		matched := false
+		for _, wd := range r.ByDay {
+			if wd.Day != d.Weekday() {
+				continue
+			}
+			if wd.N == 0 ||
+				(wd.N > 0 && daysBetween(spanFirst, d)/7+1 == wd.N) ||
+				(wd.N < 0 && -(daysBetween(d, spanLast)/7+1) == wd.N) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// setPos applies BYSETPOS to the sorted occurrences of a period.
+func (r *Rule) setPos(times []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(times) == 0 {
+		return times
+	}
+	var out []time.Time
+	for _, p := range r.BySetPos {
+		i := p - 1
+		if p < 0 {
+			i = len(times) + p
+		}
+		if i >= 0 && i < len(times) {
+			out = append(out, times[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	deduped := out[:0]
+	for i, t := range out {
+		if i == 0 || !t.Equal(out[i-1]) {
+			deduped = append(deduped, t)
+		}
+	}
+	return deduped
+}
+
+// civil returns the date of t as midnight UTC, for DST-free date arithmetic.
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func weekStart(d time.Time, wkst time.Weekday) time.Time {
+	return d.AddDate(0, 0, -((int(d.Weekday()) - int(wkst) + 7) % 7))
+}
+
+func orDefault(values []int, def int) []int {
+	if len(values) > 0 {
+		return values
+	}
+	return []int{def}
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFrequency(s string) (Frequency, error) {
+	for f, name := range frequencyNames {
+		if name == s {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported frequency %q", s)
+}
+
+func parsePositive(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%q is not a positive integer", s)
+	}
+	return n, nil
+}
+
+func parseUntil(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", s); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%q is not a date or a date-time", s)
+}
+
+// parseInts parses a sorted list of integers between min and max, or between
+// -max and -min when negative values are allowed.
+func parseInts(s string, min, max int, negative bool) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", part)
+		}
+		abs := n
+		if negative && n < 0 {
+			abs = -n
+		}
+		if abs < min || abs > max {
+			return nil, fmt.Errorf("%d is out of range", n)
+		}
+		if !containsInt(values, n) {
+			values = append(values, n)
+		}
+	}
+	sort.Ints(values)
+	return values, nil
+}
+
+func parseWeekdays(s string) ([]Weekday, error) {
+	var days []Weekday
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", part)
+		}
+		day, err := parseDay(part[len(part)-2:])
+		if err != nil {
+			return nil, err
+		}
+		wd := Weekday{Day: day}
+		if prefix := part[:len(part)-2]; prefix != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+			if err != nil || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("invalid weekday %q", part)
+			}
+			wd.N = n
+		}
+		days = append(days, wd)
+	}
+	return days, nil
+}
+
+func parseDay(s string) (time.Weekday, error) {
+	for i, name := range weekdayNames {
+		if name == s {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
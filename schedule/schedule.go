@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package schedule expands recurring downtimes and SLO corrections into the
+// time intervals they are in effect.
+//
+// Recurrences are expanded in the time zone of the downtime or correction, so
+// that a window starting at 22:00 in Europe/Paris keeps starting at 22:00 when
+// DST begins or ends. DowntimeWindows and CorrectionWindows list the
+// occurrences overlapping a range, e.g. to build a change-freeze calendar, and
+// MutedBy tells which downtimes mute a host or a monitor at a given time.
+package schedule
+
+import (
+	"sort"
+	"time"
+)
+
+// Interval is a half-open time interval [Start, End). A zero Start or End
+// leaves the interval unbounded on that side.
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains reports whether t is in the interval.
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && (i.End.IsZero() || t.Before(i.End))
+}
+
+// Overlaps reports whether the intervals have a time in common.
+func (i Interval) Overlaps(o Interval) bool {
+	return (o.End.IsZero() || i.Start.Before(o.End)) && (i.End.IsZero() || o.Start.Before(i.End))
+}
+
+// Merge returns the union of the intervals as sorted, disjoint intervals.
+func Merge(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+	sorted := make([]Interval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	out := []Interval{sorted[0]}
+	for _, iv := range sorted[1:] {
+		last := &out[len(out)-1]
+		if last.End.IsZero() {
+			break
+		}
+		if iv.Start.After(last.End) {
+			out = append(out, iv)
+			continue
+		}
+		if iv.End.IsZero() || iv.End.After(last.End) {
+			last.End = iv.End
+		}
+	}
+	return out
+}
+
+// expand returns the occurrences of the rule lasting the given duration which
+// overlap the range.
+func expand(rule *Rule, start time.Time, duration time.Duration, within Interval) ([]Interval, error) {
+	if within.End.IsZero() && rule.unbounded() {
+		return nil, ErrUnbounded
+	}
+	after := within.Start
+	if !after.IsZero() {
+		after = after.Add(-duration)
+	}
+	var out []Interval
+	rule.iterate(start, after, within.End, func(t time.Time) bool {
+		iv := Interval{Start: t, End: t.Add(duration)}
+		if iv.Overlaps(within) {
+			out = append(out, iv)
+		}
+		return true
+	})
+	return out, nil
+}
+
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+func unix(sec *int64, loc *time.Location) time.Time {
+	if sec == nil {
+		return time.Time{}
+	}
+	return time.Unix(*sec, 0).In(loc)
+}
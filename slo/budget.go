@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package slo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/schedule"
+)
+
+// Timeframe returns the duration of a rolling SLO timeframe.
+func Timeframe(tf datadogV1.SLOTimeframe) (time.Duration, error) {
+	switch tf {
+	case datadogV1.SLOTIMEFRAME_SEVEN_DAYS:
+		return 7 * 24 * time.Hour, nil
+	case datadogV1.SLOTIMEFRAME_THIRTY_DAYS:
+		return 30 * 24 * time.Hour, nil
+	case datadogV1.SLOTIMEFRAME_NINETY_DAYS:
+		return 90 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("unsupported timeframe %q", tf)
+}
+
+// Budget is the error budget of an SLO over a range.
+type Budget struct {
+	Window schedule.Interval
+	// Target is the SLO target as a percentage, e.g. 99.9.
+	Target float64
+	// SLI is the percentage of good events, 100 when there are no events.
+	SLI         float64
+	Good, Total float64
+	// Allowed is the number of bad events the target allows, and Consumed the
+	// number of bad events.
+	Allowed, Consumed float64
+	// Remaining is the percentage of the budget left, negative once the
+	// budget is overspent.
+	Remaining float64
+}
+
+// ComputeBudget computes the error budget of the series over the range.
+func ComputeBudget(s Series, target float64, within schedule.Interval) Budget {
+	good, total := s.Totals(within)
+	b := Budget{
+		Window:    within,
+		Target:    target,
+		SLI:       100,
+		Good:      good,
+		Total:     total,
+		Allowed:   total * (100 - target) / 100,
+		Consumed:  total - good,
+		Remaining: 100,
+	}
+	if total > 0 {
+		b.SLI = good / total * 100
+	}
+	switch {
+	case b.Allowed > 0:
+		b.Remaining = (b.Allowed - b.Consumed) / b.Allowed * 100
+	case b.Consumed > 0:
+		b.Remaining = -100
+	}
+	return b
+}
+
+// Spend is the part of an error budget consumed during a range.
+type Spend struct {
+	schedule.Interval
+	Consumed float64
+	// Percent is the percentage of the budget consumed during the range.
+	Percent float64
+}
+
+// Spending splits the range of the budget in steps and returns the budget
+// consumed in each of them, e.g. per day.
+func Spending(s Series, b Budget, step time.Duration) []Spend {
+	if step <= 0 || b.Window.Start.IsZero() || b.Window.End.IsZero() {
+		return nil
+	}
+	var out []Spend
+	for start := b.Window.Start; start.Before(b.Window.End); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(b.Window.End) {
+			end = b.Window.End
+		}
+		iv := schedule.Interval{Start: start, End: end}
+		good, total := s.Totals(iv)
+		spend := Spend{Interval: iv, Consumed: total - good}
+		if b.Allowed > 0 {
+			spend.Percent = spend.Consumed / b.Allowed * 100
+		}
+		out = append(out, spend)
+	}
+	return out
+}
+
+// BurnRate returns how fast the budget is spent over the range: 1 spends
+// exactly the budget over the timeframe, 2 spends it in half the timeframe.
+func BurnRate(s Series, target float64, within schedule.Interval) float64 {
+	good, total := s.Totals(within)
+	if total == 0 || target >= 100 {
+		return 0
+	}
+	return (total - good) / total / ((100 - target) / 100)
+}
+
+// BurnRateAlert is a multi-window burn rate alert condition. It fires when
+// both the long and the short window burn the budget fast enough to consume
+// BudgetConsumed percent of it within the long window.
+type BurnRateAlert struct {
+	Name           string
+	BudgetConsumed float64
+	Long, Short    time.Duration
+}
+
+// DefaultAlerts are the multi-window, multi-burn-rate alerts recommended by the
+// Google SRE workbook, which page at burn rates 14.4 and 6 and open tickets at
+// burn rates 3 and 1 for a 30 day timeframe.
+var DefaultAlerts = []BurnRateAlert{
+	{Name: "page", BudgetConsumed: 2, Long: time.Hour, Short: 5 * time.Minute},
+	{Name: "page", BudgetConsumed: 5, Long: 6 * time.Hour, Short: 30 * time.Minute},
+	{Name: "ticket", BudgetConsumed: 10, Long: 24 * time.Hour, Short: 2 * time.Hour},
+	{Name: "ticket", BudgetConsumed: 10, Long: 72 * time.Hour, Short: 6 * time.Hour},
+}
+
+// Threshold returns the burn rate firing the alert for an SLO timeframe.
+func (a BurnRateAlert) Threshold(timeframe time.Duration) float64 {
+	return a.BudgetConsumed / 100 * float64(timeframe) / float64(a.Long)
+}
+
+// AlertStatus is the state of a burn rate alert at a given time.
+type AlertStatus struct {
+	Alert                       BurnRateAlert
+	Threshold                   float64
+	LongBurnRate, ShortBurnRate float64
+	Firing                      bool
+}
+
+// EvaluateAlerts evaluates the alerts on the windows ending at the given time.
+func EvaluateAlerts(s Series, target float64, timeframe time.Duration, alerts []BurnRateAlert, at time.Time) []AlertStatus {
+	statuses := make([]AlertStatus, 0, len(alerts))
+	for _, a := range alerts {
+		status := AlertStatus{
+			Alert:         a,
+			Threshold:     a.Threshold(timeframe),
+			LongBurnRate:  BurnRate(s, target, schedule.Interval{Start: at.Add(-a.Long), End: at}),
+			ShortBurnRate: BurnRate(s, target, schedule.Interval{Start: at.Add(-a.Short), End: at}),
+		}
+		status.Firing = status.LongBurnRate >= status.Threshold && status.ShortBurnRate >= status.Threshold
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Projection tells when the remaining budget runs out at a given burn rate.
+type Projection struct {
+	BurnRate float64
+	// Exhausts is set when the budget runs out at that burn rate, after
+	// TimeToExhaustion.
+	Exhausts         bool
+	TimeToExhaustion time.Duration
+}
+
+// Project projects the remaining budget at the burn rate over the timeframe.
+func Project(b Budget, timeframe time.Duration, burnRate float64) Projection {
+	p := Projection{BurnRate: burnRate}
+	switch {
+	case b.Remaining <= 0:
+		p.Exhausts = true
+	case burnRate > 0:
+		p.Exhausts = true
+		p.TimeToExhaustion = time.Duration(b.Remaining / 100 * float64(timeframe) / burnRate)
+	}
+	return p
+}
@@ -0,0 +1,164 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package slo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/schedule"
+)
+
+// Sample holds the good and total events of a period. For monitor based SLOs,
+// events are seconds of uptime and seconds of the period.
+type Sample struct {
+	Start    time.Time
+	Duration time.Duration
+	Good     float64
+	Total    float64
+}
+
+// End returns the end of the period of the sample.
+func (s Sample) End() time.Time {
+	return s.Start.Add(s.Duration)
+}
+
+// Series is a time ordered list of samples. Events are assumed to be spread
+// evenly over the period of a sample.
+type Series []Sample
+
+// MetricSeries returns the series of a metric based SLO history.
+func MetricSeries(m datadogV1.SLOHistoryMetrics) (Series, error) {
+	if len(m.Numerator.Values) != len(m.Times) || len(m.Denominator.Values) != len(m.Times) {
+		return nil, fmt.Errorf("history has %d times, %d numerator values and %d denominator values", len(m.Times), len(m.Numerator.Values), len(m.Denominator.Values))
+	}
+	interval := time.Duration(m.Interval) * time.Second
+	series := make(Series, 0, len(m.Times))
+	for i, ts := range m.Times {
+		duration := interval
+		if duration <= 0 {
+			if i+1 >= len(m.Times) {
+				return nil, fmt.Errorf("history has no interval")
+			}
+			duration = time.Duration(m.Times[i+1]-ts) * time.Millisecond
+		}
+		series = append(series, Sample{
+			Start:    time.UnixMilli(int64(ts)),
+			Duration: duration,
+			Good:     m.Numerator.Values[i],
+			Total:    m.Denominator.Values[i],
+		})
+	}
+	return series, nil
+}
+
+// MonitorSeries returns the series of a monitor based SLO history, made of
+// [timestamp, state] pairs where state 0 is OK, up to the end of the history.
+func MonitorSeries(history [][]float64, to time.Time) (Series, error) {
+	series := make(Series, 0, len(history))
+	for i, point := range history {
+		if len(point) < 2 {
+			return nil, fmt.Errorf("invalid history point %v", point)
+		}
+		start := time.Unix(int64(point[0]), 0)
+		end := to
+		if i+1 < len(history) && len(history[i+1]) > 0 {
+			end = time.Unix(int64(history[i+1][0]), 0)
+		}
+		if !end.After(start) {
+			continue
+		}
+		seconds := end.Sub(start).Seconds()
+		sample := Sample{Start: start, Duration: end.Sub(start), Total: seconds}
+		if point[1] == 0 {
+			sample.Good = seconds
+		}
+		series = append(series, sample)
+	}
+	return series, nil
+}
+
+// HistorySeries returns the overall series of an SLO history.
+func HistorySeries(data datadogV1.SLOHistoryResponseData) (Series, error) {
+	if data.Series != nil {
+		return MetricSeries(*data.Series)
+	}
+	if data.Overall != nil {
+		return MonitorSeries(data.Overall.History, time.Unix(data.GetToTs(), 0))
+	}
+	return nil, fmt.Errorf("history has no data")
+}
+
+// GroupSeries returns the series of the groups of a grouped SLO, or of the
+// monitors of a multi-monitor SLO, by group or monitor name.
+func GroupSeries(data datadogV1.SLOHistoryResponseData) (map[string]Series, error) {
+	to := time.Unix(data.GetToTs(), 0)
+	groups := map[string]Series{}
+	for _, list := range [][]datadogV1.SLOHistoryMonitor{data.Groups, data.Monitors} {
+		for _, m := range list {
+			name := m.GetGroup()
+			if name == "" {
+				name = m.GetName()
+			}
+			series, err := MonitorSeries(m.History, to)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			groups[name] = series
+		}
+	}
+	return groups, nil
+}
+
+// Exclude removes the events of the periods covered by the intervals, e.g.
+// the occurrences of SLO corrections.
+func (s Series) Exclude(intervals []schedule.Interval) Series {
+	merged := schedule.Merge(intervals)
+	out := make(Series, 0, len(s))
+	for _, sample := range s {
+		var excluded time.Duration
+		for _, iv := range merged {
+			excluded += overlap(sample, iv)
+		}
+		if excluded > 0 && sample.Duration > 0 {
+			kept := 1 - float64(excluded)/float64(sample.Duration)
+			sample.Good *= kept
+			sample.Total *= kept
+		}
+		out = append(out, sample)
+	}
+	return out
+}
+
+// Totals returns the good and total events in the range.
+func (s Series) Totals(within schedule.Interval) (good, total float64) {
+	for _, sample := range s {
+		f := 1.0
+		if sample.Duration > 0 {
+			f = float64(overlap(sample, within)) / float64(sample.Duration)
+		} else if !within.Contains(sample.Start) {
+			f = 0
+		}
+		good += sample.Good * f
+		total += sample.Total * f
+	}
+	return good, total
+}
+
+// overlap returns how much of the period of the sample is in the interval.
+func overlap(sample Sample, iv schedule.Interval) time.Duration {
+	start, end := sample.Start, sample.End()
+	if iv.Start.After(start) {
+		start = iv.Start
+	}
+	if !iv.End.IsZero() && iv.End.Before(end) {
+		end = iv.End
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
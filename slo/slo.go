@@ -0,0 +1,146 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package slo computes error budgets and burn rates from SLO histories.
+//
+// Histories are converted to series of good and total events, seconds of
+// uptime for monitor based SLOs, from which the remaining error budget, the
+// budget consumed per period, multi-window burn rate alerts and budget
+// exhaustion projections are computed. SLO corrections are excluded from the
+// series using the occurrences expanded by the schedule package.
+package slo
+
+import (
+	_context "context"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/schedule"
+)
+
+// Report is the error budget analysis of an SLO at a given time.
+type Report struct {
+	SLO       datadogV1.ServiceLevelObjective
+	Timeframe datadogV1.SLOTimeframe
+	At        time.Time
+	Budget    Budget
+	// Groups holds the budgets of the groups or monitors of the SLO.
+	Groups      map[string]Budget
+	Alerts      []AlertStatus
+	Projection  Projection
+	Corrections []schedule.CorrectionWindow
+}
+
+// Analyzer fetches SLO histories and corrections and analyzes them.
+type Analyzer struct {
+	api *datadogV1.ServiceLevelObjectivesApi
+	// Alerts are the burn rate alerts to evaluate, DefaultAlerts when nil.
+	Alerts []BurnRateAlert
+	// Lookback is the range of the burn rate used to project the budget, 24
+	// hours when zero.
+	Lookback time.Duration
+}
+
+// NewAnalyzer returns an analyzer using the given API.
+func NewAnalyzer(api *datadogV1.ServiceLevelObjectivesApi) *Analyzer {
+	return &Analyzer{api: api}
+}
+
+// Analyze analyzes the SLO over the timeframe ending at the given time.
+// Corrections are fetched and applied by the analyzer rather than by the
+// history endpoint.
+func (a *Analyzer) Analyze(ctx _context.Context, sloID string, timeframe datadogV1.SLOTimeframe, at time.Time) (*Report, error) {
+	duration, err := Timeframe(timeframe)
+	if err != nil {
+		return nil, err
+	}
+	sloResp, _, err := a.api.GetSLO(ctx, sloID)
+	if err != nil {
+		return nil, fmt.Errorf("getting SLO %s: %w", sloID, err)
+	}
+	data := sloResp.GetData()
+	slo := datadogV1.ServiceLevelObjective{
+		Id:         data.Id,
+		Name:       data.GetName(),
+		Tags:       data.Tags,
+		Thresholds: data.Thresholds,
+		Type:       data.GetType(),
+	}
+	historyResp, _, err := a.api.GetSLOHistory(ctx, sloID, at.Add(-duration).Unix(), at.Unix(),
+		*datadogV1.NewGetSLOHistoryOptionalParameters().WithApplyCorrection(false))
+	if err != nil {
+		return nil, fmt.Errorf("getting history of SLO %s: %w", sloID, err)
+	}
+	correctionsResp, _, err := a.api.GetSLOCorrections(ctx, sloID)
+	if err != nil {
+		return nil, fmt.Errorf("getting corrections of SLO %s: %w", sloID, err)
+	}
+	return a.Report(slo, historyResp.GetData(), correctionsResp.Data, timeframe, at)
+}
+
+// Report analyzes a history of the SLO, excluding the corrections.
+func (a *Analyzer) Report(slo datadogV1.ServiceLevelObjective, history datadogV1.SLOHistoryResponseData, corrections []datadogV1.SLOCorrection, timeframe datadogV1.SLOTimeframe, at time.Time) (*Report, error) {
+	duration, err := Timeframe(timeframe)
+	if err != nil {
+		return nil, err
+	}
+	target, err := Target(slo.Thresholds, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	window := schedule.Interval{Start: at.Add(-duration), End: at}
+	windows, err := schedule.CorrectionWindows(corrections, window)
+	if err != nil {
+		return nil, err
+	}
+	excluded := make([]schedule.Interval, 0, len(windows))
+	for _, w := range windows {
+		excluded = append(excluded, w.Interval)
+	}
+	series, err := HistorySeries(history)
+	if err != nil {
+		return nil, err
+	}
+	series = series.Exclude(excluded)
+	groups, err := GroupSeries(history)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := a.Alerts
+	if alerts == nil {
+		alerts = DefaultAlerts
+	}
+	lookback := a.Lookback
+	if lookback <= 0 {
+		lookback = 24 * time.Hour
+	}
+	report := &Report{
+		SLO:         slo,
+		Timeframe:   timeframe,
+		At:          at,
+		Budget:      ComputeBudget(series, target, window),
+		Alerts:      EvaluateAlerts(series, target, duration, alerts, at),
+		Corrections: windows,
+	}
+	report.Projection = Project(report.Budget, duration, BurnRate(series, target, schedule.Interval{Start: at.Add(-lookback), End: at}))
+	if len(groups) > 0 {
+		report.Groups = make(map[string]Budget, len(groups))
+		for name, s := range groups {
+			report.Groups[name] = ComputeBudget(s.Exclude(excluded), target, window)
+		}
+	}
+	return report, nil
+}
+
+// Target returns the target of the threshold of the timeframe.
+func Target(thresholds []datadogV1.SLOThreshold, timeframe datadogV1.SLOTimeframe) (float64, error) {
+	for _, t := range thresholds {
+		if t.Timeframe == timeframe {
+			return t.Target, nil
+		}
+	}
+	return 0, fmt.Errorf("SLO has no %s threshold", timeframe)
+}
@@ -0,0 +1,364 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package ci runs Synthetic tests from CI pipelines.
+//
+// A Runner selects tests by public ID or tags, triggers them as a CI batch,
+// polls the batch until every result is finished, fetches the result details
+// and applies the failure policies. The Summary it returns renders as text
+// or as JUnit XML.
+package ci
+
+import (
+	_context "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+const (
+	defaultPollInterval    = 5 * time.Second
+	defaultMaxPollInterval = 30 * time.Second
+	defaultTimeout         = 30 * time.Minute
+	listTestsPageSize      = 100
+)
+
+// Config describes which tests to run and how to evaluate the batch.
+type Config struct {
+	// PublicIDs of the tests to run.
+	PublicIDs []string
+	// Tags selects every test having all of the given tags, such as "env:prod".
+	Tags []string
+	// Overrides is applied to every triggered test. Its PublicId is ignored.
+	Overrides *datadogV1.SyntheticsCITest
+	// Metadata attached to the batch. MetadataFromEnv is used when nil.
+	Metadata *datadogV1.SyntheticsCIBatchMetadata
+
+	// PollInterval is the initial delay between two batch polls. It grows
+	// by half at every poll, up to MaxPollInterval.
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+	// Timeout bounds the time spent waiting for the batch to finish.
+	Timeout time.Duration
+
+	// FailOnCriticalErrors fails the run when results cannot be fetched or
+	// when tests could not be executed.
+	FailOnCriticalErrors bool
+	// FailOnTimeout fails the run when results are not finished before the timeout.
+	FailOnTimeout bool
+	// FailOnMissingTests fails the run when requested tests were not triggered.
+	FailOnMissingTests bool
+}
+
+// Runner triggers Synthetic tests and waits for their results.
+type Runner struct {
+	api    *datadogV1.SyntheticsApi
+	config Config
+}
+
+// NewRunner returns a runner using the given Synthetics API.
+func NewRunner(api *datadogV1.SyntheticsApi, config Config) *Runner {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.MaxPollInterval < config.PollInterval {
+		config.MaxPollInterval = defaultMaxPollInterval
+		if config.MaxPollInterval < config.PollInterval {
+			config.MaxPollInterval = config.PollInterval
+		}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Runner{api: api, config: config}
+}
+
+// batchResult mirrors datadogV1.SyntheticsBatchResult with a free-form status,
+// since in progress results use a status the generated enum does not know.
+type batchResult struct {
+	ResultID      string  `json:"result_id"`
+	Status        string  `json:"status"`
+	TestPublicID  string  `json:"test_public_id"`
+	TestName      string  `json:"test_name"`
+	TestType      string  `json:"test_type"`
+	Location      string  `json:"location"`
+	Device        string  `json:"device"`
+	Duration      float64 `json:"duration"`
+	Retries       float64 `json:"retries"`
+	ExecutionRule string  `json:"execution_rule"`
+}
+
+type batchDetails struct {
+	Data struct {
+		Status  string        `json:"status"`
+		Results []batchResult `json:"results"`
+	} `json:"data"`
+}
+
+// Run triggers the selected tests and waits for the batch to finish.
+// The returned error reports failures to talk to the API; test failures are
+// reported by the summary.
+func (r *Runner) Run(ctx _context.Context) (*Summary, error) {
+	summary := &Summary{StartedAt: time.Now()}
+	ids, err := r.selectTests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no test matches the given public IDs and tags")
+	}
+
+	metadata := r.config.Metadata
+	if metadata == nil {
+		metadata = MetadataFromEnv()
+	}
+	body := datadogV1.SyntheticsCITestBody{}
+	for _, id := range ids {
+		test := datadogV1.SyntheticsCITest{}
+		if r.config.Overrides != nil {
+			test = *r.config.Overrides
+		}
+		test.PublicId = id
+		test.Metadata = metadata
+		body.Tests = append(body.Tests, test)
+	}
+	triggered, _, err := r.api.TriggerCITests(ctx, body)
+	if err != nil {
+		return nil, fmt.Errorf("triggering tests: %w", err)
+	}
+	summary.BatchID = triggered.GetBatchId()
+	summary.Missing = missingTests(ids, triggered.TriggeredCheckIds)
+	if summary.BatchID == "" {
+		return nil, fmt.Errorf("no batch was created for tests %v", ids)
+	}
+
+	results, timedOut, err := r.poll(ctx, summary.BatchID)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		summary.Results = append(summary.Results, r.describe(ctx, res, timedOut))
+	}
+	sort.SliceStable(summary.Results, func(i, j int) bool {
+		return summary.Results[i].TestPublicID < summary.Results[j].TestPublicID
+	})
+	summary.FinishedAt = time.Now()
+	summary.apply(r.config)
+	return summary, nil
+}
+
+// selectTests resolves tag selectors into public IDs and adds the explicit IDs.
+func (r *Runner) selectTests(ctx _context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var ids []string
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range r.config.PublicIDs {
+		add(id)
+	}
+	if len(r.config.Tags) == 0 {
+		return ids, nil
+	}
+	for page := 0; ; page++ {
+		resp, _, err := r.api.ListTests(ctx, *datadogV1.NewListTestsOptionalParameters().
+			WithPageSize(strconv.Itoa(listTestsPageSize)).
+			WithPageNumber(strconv.Itoa(page)))
+		if err != nil {
+			return nil, fmt.Errorf("listing tests: %w", err)
+		}
+		for _, test := range resp.Tests {
+			if hasTags(test.Tags, r.config.Tags) {
+				add(test.GetPublicId())
+			}
+		}
+		if len(resp.Tests) < listTestsPageSize {
+			return ids, nil
+		}
+	}
+}
+
+// poll waits for every result of the batch to finish, with exponential backoff.
+func (r *Runner) poll(ctx _context.Context, batchID string) ([]batchResult, bool, error) {
+	deadline := time.Now().Add(r.config.Timeout)
+	interval := r.config.PollInterval
+	for {
+		resp, _, err := r.api.GetSyntheticsCIBatch(ctx, batchID)
+		if err != nil {
+			return nil, false, fmt.Errorf("polling batch %s: %w", batchID, err)
+		}
+		details, err := decodeBatch(resp)
+		if err != nil {
+			return nil, false, err
+		}
+		if finished(details) {
+			return details.Data.Results, false, nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return details.Data.Results, true, nil
+		}
+		// The last fetch is at the deadline, not one interval before it.
+		wait := interval
+		if wait > remaining {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(wait):
+		}
+		interval = interval * 3 / 2
+		if interval > r.config.MaxPollInterval {
+			interval = r.config.MaxPollInterval
+		}
+	}
+}
+
+// describe fetches the details of a finished result.
+func (r *Runner) describe(ctx _context.Context, res batchResult, timedOut bool) Result {
+	out := Result{
+		TestPublicID:  res.TestPublicID,
+		TestName:      res.TestName,
+		TestType:      res.TestType,
+		ResultID:      res.ResultID,
+		Location:      res.Location,
+		Device:        res.Device,
+		Duration:      time.Duration(res.Duration * float64(time.Millisecond)),
+		Retries:       int(res.Retries),
+		Blocking:      res.ExecutionRule != string(datadogV1.SYNTHETICSTESTEXECUTIONRULE_NON_BLOCKING),
+		Status:        Status(res.Status),
+		ExecutionRule: res.ExecutionRule,
+	}
+	if !isFinal(res.Status) {
+		if timedOut {
+			out.Status = StatusTimedOut
+		}
+		return out
+	}
+	if res.ResultID == "" || out.Status == StatusSkipped {
+		return out
+	}
+	var err error
+	switch res.TestType {
+	case string(datadogV1.SYNTHETICSTESTDETAILSTYPE_BROWSER):
+		var full datadogV1.SyntheticsBrowserTestResultFull
+		var httpResp *http.Response
+		full, httpResp, err = r.api.GetBrowserTestResult(ctx, res.TestPublicID, res.ResultID)
+		if err == nil {
+			data := full.GetResult()
+			failure := data.GetFailure()
+			out.Failure = failure.GetMessage()
+			if out.Failure == "" {
+				out.Failure = data.GetError()
+			}
+			for _, step := range data.StepDetails {
+				if step.GetError() != "" {
+					out.StepErrors = append(out.StepErrors, fmt.Sprintf("%s: %s", step.GetDescription(), step.GetError()))
+				}
+			}
+			if browserEventType(httpResp) == datadogV1.SYNTHETICSTESTPROCESSSTATUS_FINISHED_WITH_ERROR {
+				out.CriticalError = fmt.Sprintf("test finished with error: %s", out.Failure)
+			}
+		}
+	default:
+		var full datadogV1.SyntheticsAPITestResultFull
+		full, _, err = r.api.GetAPITestResult(ctx, res.TestPublicID, res.ResultID)
+		if err == nil {
+			data := full.GetResult()
+			failure := data.GetFailure()
+			out.Failure = failure.GetMessage()
+			if code, ok := failure.GetCodeOk(); ok {
+				out.FailureCode = string(*code)
+			}
+			out.HTTPStatusCode = int(data.GetHttpStatusCode())
+			if data.GetEventType() == datadogV1.SYNTHETICSTESTPROCESSSTATUS_FINISHED_WITH_ERROR {
+				out.CriticalError = fmt.Sprintf("test finished with error: %s", out.Failure)
+			}
+		}
+	}
+	if err != nil {
+		out.CriticalError = fmt.Sprintf("fetching result details: %v", err)
+	}
+	return out
+}
+
+// browserEventType reads the event type of a browser test result from the
+// body of its response, since the browser result model does not declare it.
+func browserEventType(resp *http.Response) datadogV1.SyntheticsTestProcessStatus {
+	var body struct {
+		Result struct {
+			EventType datadogV1.SyntheticsTestProcessStatus `json:"eventType"`
+		} `json:"result"`
+	}
+	if resp != nil {
+		json.NewDecoder(resp.Body).Decode(&body)
+	}
+	return body.Result.EventType
+}
+
+func decodeBatch(resp datadogV1.SyntheticsBatchDetails) (batchDetails, error) {
+	var details batchDetails
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return details, err
+	}
+	err = json.Unmarshal(raw, &details)
+	return details, err
+}
+
+func finished(details batchDetails) bool {
+	if len(details.Data.Results) == 0 {
+		return isFinal(details.Data.Status)
+	}
+	for _, res := range details.Data.Results {
+		if !isFinal(res.Status) {
+			return false
+		}
+	}
+	return true
+}
+
+func isFinal(status string) bool {
+	switch Status(status) {
+	case StatusPassed, StatusFailed, StatusSkipped:
+		return true
+	}
+	return false
+}
+
+func hasTags(tags, wanted []string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		set[t] = true
+	}
+	for _, t := range wanted {
+		if !set[t] {
+			return false
+		}
+	}
+	return true
+}
+
+func missingTests(requested, triggered []string) []string {
+	set := make(map[string]bool, len(triggered))
+	for _, id := range triggered {
+		set[id] = true
+	}
+	var missing []string
+	for _, id := range requested {
+		if !set[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
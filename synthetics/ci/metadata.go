@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package ci
+
+import (
+	"os"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// ciProvider describes how to read the metadata of a CI provider from the environment.
+type ciProvider struct {
+	name     string
+	detect   string
+	branch   []string
+	commit   []string
+	pipeline func() string
+}
+
+var ciProviders = []ciProvider{
+	{
+		name:   "github",
+		detect: "GITHUB_ACTIONS",
+		branch: []string{"GITHUB_HEAD_REF", "GITHUB_REF"},
+		commit: []string{"GITHUB_SHA"},
+		pipeline: func() string {
+			if os.Getenv("GITHUB_SERVER_URL") == "" {
+				return ""
+			}
+			return os.Getenv("GITHUB_SERVER_URL") + "/" + os.Getenv("GITHUB_REPOSITORY") + "/actions/runs/" + os.Getenv("GITHUB_RUN_ID")
+		},
+	},
+	{
+		name:     "gitlab",
+		detect:   "GITLAB_CI",
+		branch:   []string{"CI_COMMIT_REF_NAME"},
+		commit:   []string{"CI_COMMIT_SHA"},
+		pipeline: func() string { return os.Getenv("CI_PIPELINE_URL") },
+	},
+	{
+		name:     "circleci",
+		detect:   "CIRCLECI",
+		branch:   []string{"CIRCLE_BRANCH"},
+		commit:   []string{"CIRCLE_SHA1"},
+		pipeline: func() string { return os.Getenv("CIRCLE_BUILD_URL") },
+	},
+	{
+		name:     "jenkins",
+		detect:   "JENKINS_URL",
+		branch:   []string{"GIT_BRANCH", "BRANCH_NAME"},
+		commit:   []string{"GIT_COMMIT"},
+		pipeline: func() string { return os.Getenv("BUILD_URL") },
+	},
+	{
+		name:   "azurepipelines",
+		detect: "TF_BUILD",
+		branch: []string{"BUILD_SOURCEBRANCH"},
+		commit: []string{"BUILD_SOURCEVERSION"},
+		pipeline: func() string {
+			return os.Getenv("SYSTEM_TEAMFOUNDATIONSERVERURI") + os.Getenv("SYSTEM_TEAMPROJECTID") + "/_build/results?buildId=" + os.Getenv("BUILD_BUILDID")
+		},
+	},
+	{
+		name:     "buildkite",
+		detect:   "BUILDKITE",
+		branch:   []string{"BUILDKITE_BRANCH"},
+		commit:   []string{"BUILDKITE_COMMIT"},
+		pipeline: func() string { return os.Getenv("BUILDKITE_BUILD_URL") },
+	},
+}
+
+// MetadataFromEnv returns the git and CI provider metadata found in the
+// environment of the common CI providers. The DD_GIT_BRANCH, DD_GIT_COMMIT_SHA,
+// DD_CI_PROVIDER_NAME and DD_CI_PIPELINE_URL variables take precedence.
+func MetadataFromEnv() *datadogV1.SyntheticsCIBatchMetadata {
+	var provider, branch, commit, pipelineURL string
+	for _, p := range ciProviders {
+		if os.Getenv(p.detect) == "" {
+			continue
+		}
+		provider = p.name
+		branch = firstEnv(p.branch...)
+		commit = firstEnv(p.commit...)
+		pipelineURL = p.pipeline()
+		break
+	}
+	provider = firstNonEmpty(os.Getenv("DD_CI_PROVIDER_NAME"), provider)
+	branch = firstNonEmpty(os.Getenv("DD_GIT_BRANCH"), branch)
+	commit = firstNonEmpty(os.Getenv("DD_GIT_COMMIT_SHA"), commit)
+	pipelineURL = firstNonEmpty(os.Getenv("DD_CI_PIPELINE_URL"), pipelineURL)
+
+	branch = strings.TrimPrefix(strings.TrimPrefix(branch, "refs/heads/"), "origin/")
+	metadata := &datadogV1.SyntheticsCIBatchMetadata{}
+	if branch != "" || commit != "" {
+		metadata.Git = &datadogV1.SyntheticsCIBatchMetadataGit{}
+		if branch != "" {
+			metadata.Git.Branch = datadog.PtrString(branch)
+		}
+		if commit != "" {
+			metadata.Git.CommitSha = datadog.PtrString(commit)
+		}
+	}
+	if provider != "" || pipelineURL != "" {
+		metadata.Ci = &datadogV1.SyntheticsCIBatchMetadataCI{}
+		if provider != "" {
+			metadata.Ci.Provider = &datadogV1.SyntheticsCIBatchMetadataProvider{Name: datadog.PtrString(provider)}
+		}
+		if pipelineURL != "" {
+			metadata.Ci.Pipeline = &datadogV1.SyntheticsCIBatchMetadataPipeline{Url: datadog.PtrString(pipelineURL)}
+		}
+	}
+	return metadata
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,266 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package ci
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a single result.
+type Status string
+
+// List of Status.
+const (
+	StatusPassed   Status = "passed"
+	StatusFailed   Status = "failed"
+	StatusSkipped  Status = "skipped"
+	StatusTimedOut Status = "timed_out"
+)
+
+// Result is the outcome of a test on one location and device.
+type Result struct {
+	TestPublicID   string        `json:"test_public_id"`
+	TestName       string        `json:"test_name"`
+	TestType       string        `json:"test_type"`
+	ResultID       string        `json:"result_id,omitempty"`
+	Location       string        `json:"location,omitempty"`
+	Device         string        `json:"device,omitempty"`
+	Status         Status        `json:"status"`
+	ExecutionRule  string        `json:"execution_rule,omitempty"`
+	Blocking       bool          `json:"blocking"`
+	Duration       time.Duration `json:"duration"`
+	Retries        int           `json:"retries"`
+	HTTPStatusCode int           `json:"http_status_code,omitempty"`
+	Failure        string        `json:"failure,omitempty"`
+	FailureCode    string        `json:"failure_code,omitempty"`
+	StepErrors     []string      `json:"step_errors,omitempty"`
+	// CriticalError is set when the test could not run or its details could not be fetched.
+	CriticalError string `json:"critical_error,omitempty"`
+}
+
+// Summary is the outcome of a CI batch.
+type Summary struct {
+	BatchID    string    `json:"batch_id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Results    []Result  `json:"results"`
+	// Missing lists the requested tests that were not triggered.
+	Missing []string `json:"missing,omitempty"`
+
+	Passed            int `json:"passed"`
+	Failed            int `json:"failed"`
+	FailedNonBlocking int `json:"failed_non_blocking"`
+	Skipped           int `json:"skipped"`
+	TimedOut          int `json:"timed_out"`
+	CriticalErrors    int `json:"critical_errors"`
+
+	// Success is false when a blocking test failed or a failure policy was triggered.
+	Success bool `json:"success"`
+	// Reasons explains why the run failed.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// apply counts the results and evaluates the failure policies.
+func (s *Summary) apply(config Config) {
+	for _, res := range s.Results {
+		switch res.Status {
+		case StatusPassed:
+			s.Passed++
+		case StatusFailed:
+			if res.Blocking {
+				s.Failed++
+			} else {
+				s.FailedNonBlocking++
+			}
+		case StatusSkipped:
+			s.Skipped++
+		case StatusTimedOut:
+			s.TimedOut++
+		}
+		if res.CriticalError != "" {
+			s.CriticalErrors++
+		}
+	}
+	if s.Failed > 0 {
+		s.Reasons = append(s.Reasons, fmt.Sprintf("%d blocking result(s) failed", s.Failed))
+	}
+	if config.FailOnTimeout && s.TimedOut > 0 {
+		s.Reasons = append(s.Reasons, fmt.Sprintf("%d result(s) timed out", s.TimedOut))
+	}
+	if config.FailOnCriticalErrors && s.CriticalErrors > 0 {
+		s.Reasons = append(s.Reasons, fmt.Sprintf("%d critical error(s)", s.CriticalErrors))
+	}
+	if config.FailOnMissingTests && len(s.Missing) > 0 {
+		s.Reasons = append(s.Reasons, fmt.Sprintf("test(s) not found: %s", strings.Join(s.Missing, ", ")))
+	}
+	s.Success = len(s.Reasons) == 0
+}
+
+// WriteText writes a human readable summary.
+func (s *Summary) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, res := range s.Results {
+		fmt.Fprintf(&b, "[%s] %s (%s)", strings.ToUpper(string(res.Status)), res.TestName, res.TestPublicID)
+		if res.Location != "" {
+			fmt.Fprintf(&b, " on %s", res.Location)
+		}
+		if res.Device != "" {
+			fmt.Fprintf(&b, " with %s", res.Device)
+		}
+		fmt.Fprintf(&b, " in %s", res.Duration.Round(time.Millisecond))
+		if !res.Blocking {
+			b.WriteString(" (non blocking)")
+		}
+		b.WriteString("\n")
+		if res.Failure != "" {
+			fmt.Fprintf(&b, "    failure: %s\n", res.Failure)
+		}
+		for _, step := range res.StepErrors {
+			fmt.Fprintf(&b, "    step: %s\n", step)
+		}
+		if res.CriticalError != "" {
+			fmt.Fprintf(&b, "    critical error: %s\n", res.CriticalError)
+		}
+	}
+	for _, id := range s.Missing {
+		fmt.Fprintf(&b, "[NOT FOUND] %s\n", id)
+	}
+	fmt.Fprintf(&b, "\nBatch %s: %d passed, %d failed, %d failed (non blocking), %d skipped, %d timed out, %d critical error(s), %d not found\n",
+		s.BatchID, s.Passed, s.Failed, s.FailedNonBlocking, s.Skipped, s.TimedOut, s.CriticalErrors, len(s.Missing))
+	if s.Success {
+		b.WriteString("Result: success\n")
+	} else {
+		fmt.Fprintf(&b, "Result: failure (%s)\n", strings.Join(s.Reasons, "; "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the summary as a JUnit XML report, with one test suite
+// per Synthetic test and one test case per result.
+func (s *Summary) WriteJUnit(w io.Writer) error {
+	report := junitTestSuites{Name: "Synthetics " + s.BatchID}
+	index := map[string]int{}
+	for _, res := range s.Results {
+		i, ok := index[res.TestPublicID]
+		if !ok {
+			i = len(report.Suites)
+			index[res.TestPublicID] = i
+			report.Suites = append(report.Suites, junitTestSuite{
+				Name: fmt.Sprintf("%s (%s)", res.TestName, res.TestPublicID),
+				Properties: []junitProperty{
+					{Name: "batch_id", Value: s.BatchID},
+					{Name: "public_id", Value: res.TestPublicID},
+					{Name: "test_type", Value: res.TestType},
+					{Name: "execution_rule", Value: res.ExecutionRule},
+				},
+			})
+		}
+		suite := &report.Suites[i]
+		name := res.Location
+		if res.Device != "" {
+			name = strings.TrimSpace(name + " " + res.Device)
+		}
+		if name == "" {
+			name = res.ResultID
+		}
+		tc := junitTestCase{Name: name, Classname: res.TestPublicID, Time: res.Duration.Seconds()}
+		switch {
+		case res.CriticalError != "":
+			tc.Error = &junitMessage{Message: res.CriticalError, Type: "critical"}
+			suite.Errors++
+		case res.Status == StatusFailed && res.Blocking:
+			tc.Failure = &junitMessage{Message: res.Failure, Type: res.FailureCode, Text: strings.Join(res.StepErrors, "\n")}
+			suite.Failures++
+		case res.Status == StatusFailed:
+			tc.Skipped = &junitMessage{Message: "non blocking failure: " + res.Failure}
+			suite.Skipped++
+		case res.Status == StatusTimedOut:
+			tc.Error = &junitMessage{Message: "result did not finish before the timeout", Type: "timeout"}
+			suite.Errors++
+		case res.Status == StatusSkipped:
+			tc.Skipped = &junitMessage{}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		suite.Time += tc.Time
+	}
+	for _, id := range s.Missing {
+		report.Suites = append(report.Suites, junitTestSuite{
+			Name:   id,
+			Tests:  1,
+			Errors: 1,
+			Cases: []junitTestCase{{
+				Name:      id,
+				Classname: id,
+				Error:     &junitMessage{Message: "test not found", Type: "missing"},
+			}},
+		})
+	}
+	for _, suite := range report.Suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Time += suite.Time
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,301 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package local
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/synthetics/spec"
+)
+
+// Response is the part of an HTTP response assertions apply to.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+	TLS        *tls.ConnectionState
+}
+
+// AssertionResult is the outcome of one assertion.
+type AssertionResult struct {
+	Assertion spec.Assertion
+	Passed    bool
+	// Skipped is set for assertions that cannot be evaluated locally.
+	Skipped bool
+	// Actual holds the values the assertion was evaluated against.
+	Actual []interface{}
+	// Message explains a failure or a skip.
+	Message string
+}
+
+// String describes the result, e.g. "PASS statusCode is 200".
+func (r AssertionResult) String() string {
+	status := "FAIL"
+	switch {
+	case r.Skipped:
+		status = "SKIP"
+	case r.Passed:
+		status = "PASS"
+	}
+	s := status + " " + r.Assertion.String()
+	if r.Message != "" {
+		s += ": " + r.Message
+	}
+	return s
+}
+
+// Evaluate evaluates every assertion against the response.
+func Evaluate(assertions []datadogV1.SyntheticsAssertion, resp *Response) []AssertionResult {
+	results := make([]AssertionResult, 0, len(assertions))
+	for _, a := range assertions {
+		results = append(results, EvaluateAssertion(a, resp))
+	}
+	return results
+}
+
+// EvaluateAssertion evaluates an assertion against the response.
+func EvaluateAssertion(assertion datadogV1.SyntheticsAssertion, resp *Response) AssertionResult {
+	a, err := spec.AssertionFromModel(assertion)
+	if err != nil {
+		return AssertionResult{Skipped: true, Message: err.Error()}
+	}
+	res := AssertionResult{Assertion: a}
+	values, err := actualValues(a, resp)
+	if err != nil {
+		_, res.Skipped = err.(unsupportedError)
+		res.Message = err.Error()
+		return res
+	}
+	if values != nil && (a.JSONPath != "" || a.XPath != "") {
+		if values, err = selectValues(a, values); err != nil {
+			res.Message = err.Error()
+			return res
+		}
+	}
+	res.Actual = values
+	if len(values) == 0 && a.Operator == string(datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_EXIST) {
+		res.Passed = true
+		return res
+	}
+	if len(values) == 0 {
+		res.Message = "no value found"
+		return res
+	}
+	negated := isNegated(a.Operator)
+	res.Passed = negated
+	for _, v := range values {
+		ok, err := compare(a.Operator, v, a.Target)
+		if err != nil {
+			if _, unsupported := err.(unsupportedError); unsupported {
+				res.Skipped = true
+			}
+			res.Passed = false
+			res.Message = err.Error()
+			return res
+		}
+		if ok != negated {
+			res.Passed = ok
+			break
+		}
+	}
+	if !res.Passed {
+		res.Message = fmt.Sprintf("got %s", describe(values))
+	}
+	return res
+}
+
+type unsupportedError string
+
+func (e unsupportedError) Error() string {
+	return string(e)
+}
+
+// actualValues returns the values of the response the assertion targets, or
+// nil when the targeted header does not exist.
+func actualValues(a spec.Assertion, resp *Response) ([]interface{}, error) {
+	switch datadogV1.SyntheticsAssertionType(a.Type) {
+	case datadogV1.SYNTHETICSASSERTIONTYPE_STATUS_CODE:
+		return []interface{}{float64(resp.StatusCode)}, nil
+	case datadogV1.SYNTHETICSASSERTIONTYPE_RESPONSE_TIME:
+		if a.Property != "" && a.Property != "total" {
+			return nil, unsupportedError(fmt.Sprintf("timing %q is not measured locally", a.Property))
+		}
+		return []interface{}{float64(resp.Duration) / float64(time.Millisecond)}, nil
+	case datadogV1.SYNTHETICSASSERTIONTYPE_HEADER:
+		headers := resp.Header.Values(a.Property)
+		if len(headers) == 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, 0, len(headers))
+		for _, h := range headers {
+			values = append(values, h)
+		}
+		return values, nil
+	case datadogV1.SYNTHETICSASSERTIONTYPE_BODY:
+		return []interface{}{string(resp.Body)}, nil
+	case datadogV1.SYNTHETICSASSERTIONTYPE_CERTIFICATE:
+		if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+			return nil, fmt.Errorf("the response has no certificate")
+		}
+		days := time.Until(resp.TLS.PeerCertificates[0].NotAfter).Hours() / 24
+		return []interface{}{days}, nil
+	}
+	return nil, unsupportedError(fmt.Sprintf("%s assertions cannot be evaluated locally", a.Type))
+}
+
+// selectValues applies the JSON path or XPath of the assertion.
+func selectValues(a spec.Assertion, values []interface{}) ([]interface{}, error) {
+	var out []interface{}
+	for _, v := range values {
+		text := fmt.Sprint(v)
+		if a.JSONPath != "" {
+			segments, err := parseJSONPath(a.JSONPath)
+			if err != nil {
+				return nil, err
+			}
+			var doc interface{}
+			if err := json.Unmarshal([]byte(text), &doc); err != nil {
+				return nil, fmt.Errorf("invalid JSON: %w", err)
+			}
+			out = append(out, evalJSONPath(segments, doc)...)
+			continue
+		}
+		steps, err := parseXPath(a.XPath)
+		if err != nil {
+			return nil, err
+		}
+		root, err := parseXML([]byte(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+		found, err := evalXPath(steps, root)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range found {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func isNegated(operator string) bool {
+	switch datadogV1.SyntheticsAssertionOperator(operator) {
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_IS_NOT,
+		datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_CONTAIN,
+		datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_MATCH:
+		return true
+	}
+	return false
+}
+
+// compare applies the operator to an actual value and the target.
+func compare(operator string, actual, target interface{}) (bool, error) {
+	switch datadogV1.SyntheticsAssertionOperator(operator) {
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_IS:
+		return equal(actual, target), nil
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_IS_NOT:
+		return !equal(actual, target), nil
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_CONTAINS:
+		return strings.Contains(toString(actual), toString(target)), nil
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_CONTAIN:
+		return !strings.Contains(toString(actual), toString(target)), nil
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_MATCHES, datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_MATCH:
+		re, err := regexp.Compile(toString(target))
+		if err != nil {
+			return false, err
+		}
+		matched := re.MatchString(toString(actual))
+		if operator == string(datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_MATCH) {
+			return !matched, nil
+		}
+		return matched, nil
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_LESS_THAN, datadogV1.SYNTHETICSASSERTIONOPERATOR_IS_IN_LESS_DAYS_THAN:
+		return compareNumbers(actual, target, func(a, b float64) bool { return a < b })
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_LESS_THAN_OR_EQUAL:
+		return compareNumbers(actual, target, func(a, b float64) bool { return a <= b })
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_MORE_THAN, datadogV1.SYNTHETICSASSERTIONOPERATOR_IS_IN_MORE_DAYS_THAN:
+		return compareNumbers(actual, target, func(a, b float64) bool { return a > b })
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_MORE_THAN_OR_EQUAL:
+		return compareNumbers(actual, target, func(a, b float64) bool { return a >= b })
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_EXIST:
+		return false, nil
+	case datadogV1.SYNTHETICSASSERTIONOPERATOR_VALIDATES:
+		return false, unsupportedError("JSON schema validation is not supported locally")
+	}
+	return false, unsupportedError(fmt.Sprintf("operator %q is not supported locally", operator))
+}
+
+func equal(actual, target interface{}) bool {
+	a, aErr := toNumber(actual)
+	b, bErr := toNumber(target)
+	if aErr == nil && bErr == nil {
+		return a == b
+	}
+	return toString(actual) == toString(target)
+}
+
+func compareNumbers(actual, target interface{}, cmp func(a, b float64) bool) (bool, error) {
+	a, err := toNumber(actual)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a number", describe([]interface{}{actual}))
+	}
+	b, err := toNumber(target)
+	if err != nil {
+		return false, fmt.Errorf("target %v is not a number", target)
+	}
+	return cmp(a, b), nil
+}
+
+func toNumber(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case int64:
+		return float64(t), nil
+	case int:
+		return float64(t), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(t), 64)
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(t)
+		return string(raw)
+	}
+	return fmt.Sprint(v)
+}
+
+func describe(values []interface{}) string {
+	const maxLength = 200
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		s := toString(v)
+		if len(s) > maxLength {
+			s = s[:maxLength] + "..."
+		}
+		parts = append(parts, strconv.Quote(s))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,221 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package local
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment selects children of the current values.
+type jsonPathSegment struct {
+	recursive bool
+	wildcard  bool
+	name      string
+	index     *int
+	slice     *[2]*int
+}
+
+// parseJSONPath supports the dot and bracket notations, wildcards, recursive
+// descent, indexes and slices. Filter expressions are not supported.
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSON path %q must start with $", path)
+	}
+	var segments []jsonPathSegment
+	rest := path[1:]
+	for rest != "" {
+		var seg jsonPathSegment
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			seg.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				var err error
+				if seg, rest, err = parseBracket(rest, seg); err != nil {
+					return nil, fmt.Errorf("JSON path %q: %w", path, err)
+				}
+				segments = append(segments, seg)
+				continue
+			}
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "["):
+			var err error
+			if seg, rest, err = parseBracket(rest, seg); err != nil {
+				return nil, fmt.Errorf("JSON path %q: %w", path, err)
+			}
+			segments = append(segments, seg)
+			continue
+		default:
+			return nil, fmt.Errorf("JSON path %q: unexpected %q", path, rest)
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		name := rest[:end]
+		rest = rest[end:]
+		if name == "" {
+			return nil, fmt.Errorf("JSON path %q: empty name", path)
+		}
+		if name == "*" {
+			seg.wildcard = true
+		} else {
+			seg.name = name
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+func parseBracket(rest string, seg jsonPathSegment) (jsonPathSegment, string, error) {
+	if len(rest) > 1 && (rest[1] == '\'' || rest[1] == '"') {
+		quote := rest[1]
+		end := strings.IndexByte(rest[2:], quote)
+		if end < 0 || !strings.HasPrefix(rest[2+end+1:], "]") {
+			return seg, "", fmt.Errorf("unterminated name in %q", rest)
+		}
+		seg.name = rest[2 : 2+end]
+		return seg, rest[2+end+2:], nil
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return seg, "", fmt.Errorf("unterminated bracket in %q", rest)
+	}
+	inner := strings.TrimSpace(rest[1:end])
+	rest = rest[end+1:]
+	switch {
+	case inner == "*":
+		seg.wildcard = true
+	case strings.HasPrefix(inner, "?"):
+		return seg, "", fmt.Errorf("filter expressions are not supported")
+	case strings.Contains(inner, ":"):
+		bounds := strings.SplitN(inner, ":", 2)
+		var slice [2]*int
+		for i, b := range bounds {
+			b = strings.TrimSpace(b)
+			if b == "" {
+				continue
+			}
+			n, err := strconv.Atoi(b)
+			if err != nil {
+				return seg, "", fmt.Errorf("invalid slice %q", inner)
+			}
+			slice[i] = &n
+		}
+		seg.slice = &slice
+	default:
+		n, err := strconv.Atoi(inner)
+		if err != nil {
+			return seg, "", fmt.Errorf("invalid index %q", inner)
+		}
+		seg.index = &n
+	}
+	return seg, rest, nil
+}
+
+// evalJSONPath returns the values found at the path.
+func evalJSONPath(segments []jsonPathSegment, doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, seg := range segments {
+		var next []interface{}
+		for _, v := range current {
+			if seg.recursive {
+				for _, d := range descendants(v) {
+					next = append(next, seg.children(d)...)
+				}
+				continue
+			}
+			next = append(next, seg.children(v)...)
+		}
+		current = next
+	}
+	return current
+}
+
+func (seg jsonPathSegment) children(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if seg.wildcard {
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			out := make([]interface{}, 0, len(keys))
+			for _, k := range keys {
+				out = append(out, t[k])
+			}
+			return out
+		}
+		if seg.index == nil && seg.slice == nil {
+			if child, ok := t[seg.name]; ok {
+				return []interface{}{child}
+			}
+		}
+	case []interface{}:
+		switch {
+		case seg.wildcard:
+			return t
+		case seg.index != nil:
+			i := *seg.index
+			if i < 0 {
+				i += len(t)
+			}
+			if i >= 0 && i < len(t) {
+				return []interface{}{t[i]}
+			}
+		case seg.slice != nil:
+			start, end := 0, len(t)
+			if seg.slice[0] != nil {
+				start = clampIndex(*seg.slice[0], len(t))
+			}
+			if seg.slice[1] != nil {
+				end = clampIndex(*seg.slice[1], len(t))
+			}
+			if start < end {
+				return t[start:end]
+			}
+		}
+	}
+	return nil
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// descendants returns the value and all the values nested in it.
+func descendants(v interface{}) []interface{} {
+	out := []interface{}{v}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, descendants(t[k])...)
+		}
+	case []interface{}:
+		for _, item := range t {
+			out = append(out, descendants(item)...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,327 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package local runs Synthetic API tests against local services.
+//
+// A Runner sends the HTTP requests of a SyntheticsAPITest with net/http, for
+// example to an httptest.Server or to a local build of a service, and evaluates
+// every assertion of the test on the response. Multistep tests run their steps
+// in order and pass extracted values to the following steps.
+//
+// Status code, response time, header, body and certificate assertions are
+// evaluated, including JSON path and XPath targets. Other assertions are
+// reported as skipped.
+package local
+
+import (
+	"bytes"
+	_context "context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/synthetics/spec"
+)
+
+var variablePattern = regexp.MustCompile(`{{\s*([A-Za-z0-9_]+)\s*}}`)
+
+// Runner sends the requests of API tests and evaluates their assertions.
+type Runner struct {
+	// Client sends the requests. http.DefaultClient is used when nil.
+	Client *http.Client
+	// BaseURL, when set, replaces the scheme and host of the request URLs.
+	BaseURL string
+	// Variables are substituted for {{ NAME }} in the requests. They take
+	// precedence over the examples of the test variables.
+	Variables map[string]string
+}
+
+// Result is the outcome of a test.
+type Result struct {
+	Name   string
+	Steps  []StepResult
+	Passed bool
+}
+
+// StepResult is the outcome of a request and of its assertions.
+type StepResult struct {
+	Name     string
+	Method   string
+	URL      string
+	Response *Response
+	// Error is set when the request could not be sent or a value could not
+	// be extracted from the response.
+	Error      string
+	Assertions []AssertionResult
+	// Extracted holds the variables extracted from the response.
+	Extracted    map[string]string
+	AllowFailure bool
+	IsCritical   bool
+	Passed       bool
+}
+
+// Run runs an HTTP or multistep API test.
+func (r *Runner) Run(ctx _context.Context, test datadogV1.SyntheticsAPITest) (*Result, error) {
+	subtype := test.GetSubtype()
+	if subtype != "" && subtype != datadogV1.SYNTHETICSTESTDETAILSSUBTYPE_HTTP && subtype != datadogV1.SYNTHETICSTESTDETAILSSUBTYPE_MULTI {
+		return nil, fmt.Errorf("%s tests cannot be run locally, only http and multi tests can", subtype)
+	}
+	variables := map[string]string{}
+	for _, v := range test.Config.ConfigVariables {
+		if v.Type == datadogV1.SYNTHETICSCONFIGVARIABLETYPE_TEXT && v.Example != nil {
+			variables[v.Name] = v.GetExample()
+		}
+	}
+	for k, v := range r.Variables {
+		variables[k] = v
+	}
+	result := &Result{Name: test.Name, Passed: true}
+	if subtype != datadogV1.SYNTHETICSTESTDETAILSSUBTYPE_MULTI {
+		if test.Config.Request == nil {
+			return nil, fmt.Errorf("test %q has no request", test.Name)
+		}
+		step := r.runStep(ctx, test.Name, *test.Config.Request, test.Config.Assertions, nil, test.Options, variables)
+		result.Steps = append(result.Steps, step)
+		result.Passed = step.Passed
+		return result, nil
+	}
+	for _, s := range test.Config.Steps {
+		step := r.runStep(ctx, s.Name, s.Request, s.Assertions, s.ExtractedValues, test.Options, variables)
+		step.AllowFailure = s.GetAllowFailure()
+		step.IsCritical = s.IsCritical == nil || s.GetIsCritical()
+		result.Steps = append(result.Steps, step)
+		for k, v := range step.Extracted {
+			variables[k] = v
+		}
+		if step.Passed {
+			continue
+		}
+		if !step.AllowFailure || step.IsCritical {
+			result.Passed = false
+		}
+		if !step.AllowFailure {
+			break
+		}
+	}
+	return result, nil
+}
+
+// RunRequest sends a request and evaluates the assertions on its response.
+func (r *Runner) RunRequest(ctx _context.Context, request datadogV1.SyntheticsTestRequest, assertions []datadogV1.SyntheticsAssertion) StepResult {
+	return r.runStep(ctx, "", request, assertions, nil, datadogV1.SyntheticsTestOptions{}, r.Variables)
+}
+
+func (r *Runner) runStep(ctx _context.Context, name string, request datadogV1.SyntheticsTestRequest, assertions []datadogV1.SyntheticsAssertion, extract []datadogV1.SyntheticsParsingOptions, options datadogV1.SyntheticsTestOptions, variables map[string]string) StepResult {
+	step := StepResult{Name: name, Method: request.GetMethod(), Extracted: map[string]string{}}
+	if request.Timeout != nil {
+		var cancel _context.CancelFunc
+		ctx, cancel = _context.WithTimeout(ctx, time.Duration(request.GetTimeout()*float64(time.Second)))
+		defer cancel()
+	}
+	req, err := r.newRequest(ctx, request, variables)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	step.URL = req.URL.String()
+	client, err := r.client(request, options)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	start := time.Now()
+	httpResp, err := client.Do(req)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	body, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	duration := time.Since(start)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	resp := &Response{
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		Body:       body,
+		Duration:   duration,
+		TLS:        httpResp.TLS,
+	}
+	step.Response = resp
+	step.Assertions = Evaluate(assertions, resp)
+	step.Passed = true
+	for _, a := range step.Assertions {
+		if !a.Passed && !a.Skipped {
+			step.Passed = false
+		}
+	}
+	for _, e := range extract {
+		value, err := extractValue(e, resp)
+		if err != nil {
+			step.Error = fmt.Sprintf("extracting %s: %v", e.GetName(), err)
+			step.Passed = false
+			continue
+		}
+		step.Extracted[e.GetName()] = value
+	}
+	return step
+}
+
+// NewRequest builds the HTTP request of a test request.
+func (r *Runner) NewRequest(ctx _context.Context, request datadogV1.SyntheticsTestRequest) (*http.Request, error) {
+	return r.newRequest(ctx, request, r.Variables)
+}
+
+func (r *Runner) newRequest(ctx _context.Context, request datadogV1.SyntheticsTestRequest, variables map[string]string) (*http.Request, error) {
+	expand := func(s string) string {
+		return variablePattern.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := variables[variablePattern.FindStringSubmatch(m)[1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+	u, err := url.Parse(expand(request.GetUrl()))
+	if err != nil {
+		return nil, err
+	}
+	if r.BaseURL != "" {
+		base, err := url.Parse(r.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL: %w", err)
+		}
+		u.Scheme, u.Host, u.User = base.Scheme, base.Host, base.User
+		u.Path = strings.TrimSuffix(base.Path, "/") + u.Path
+		u.RawPath = ""
+	}
+	if query, ok := request.Query.(map[string]interface{}); ok {
+		values := u.Query()
+		keys := make([]string, 0, len(query))
+		for k := range query {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			values.Set(k, expand(fmt.Sprint(query[k])))
+		}
+		u.RawQuery = values.Encode()
+	}
+	method := request.GetMethod()
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if request.Body != nil {
+		body = bytes.NewBufferString(expand(request.GetBody()))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range request.Headers {
+		req.Header.Set(k, expand(v))
+	}
+	if request.BodyType != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", string(request.GetBodyType()))
+	}
+	if request.BasicAuth != nil {
+		web := request.BasicAuth.SyntheticsBasicAuthWeb
+		if web == nil {
+			return nil, fmt.Errorf("only web basic authentication is supported locally")
+		}
+		req.SetBasicAuth(expand(web.Username), expand(web.Password))
+	}
+	return req, nil
+}
+
+// client applies the redirect and certificate settings of the request.
+func (r *Runner) client(request datadogV1.SyntheticsTestRequest, options datadogV1.SyntheticsTestOptions) (*http.Client, error) {
+	base := r.Client
+	if base == nil {
+		base = http.DefaultClient
+	}
+	client := *base
+	follow := true
+	if v, ok := options.GetFollowRedirectsOk(); ok {
+		follow = *v
+	}
+	if v, ok := request.GetFollowRedirectsOk(); ok {
+		follow = *v
+	}
+	if !follow {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	}
+	if request.GetAllowInsecure() || options.GetAllowInsecure() || options.GetAcceptSelfSigned() {
+		transport, ok := client.Transport.(*http.Transport)
+		if client.Transport == nil {
+			transport, ok = http.DefaultTransport.(*http.Transport)
+		}
+		if !ok {
+			return nil, fmt.Errorf("insecure requests need an *http.Transport")
+		}
+		transport = transport.Clone()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		client.Transport = transport
+	}
+	return &client, nil
+}
+
+// extractValue applies a parsing option to the response.
+func extractValue(e datadogV1.SyntheticsParsingOptions, resp *Response) (string, error) {
+	var source string
+	switch e.GetType() {
+	case datadogV1.SYNTHETICSGLOBALVARIABLEPARSETESTOPTIONSTYPE_HTTP_HEADER:
+		source = resp.Header.Get(e.GetField())
+	case datadogV1.SYNTHETICSGLOBALVARIABLEPARSETESTOPTIONSTYPE_HTTP_BODY, "":
+		source = string(resp.Body)
+	default:
+		return "", fmt.Errorf("%s values cannot be extracted locally", e.GetType())
+	}
+	parser := e.GetParser()
+	switch parser.Type {
+	case datadogV1.SYNTHETICSGLOBALVARIABLEPARSERTYPE_RAW, "":
+		return source, nil
+	case datadogV1.SYNTHETICSGLOBALVARIABLEPARSERTYPE_REGEX:
+		re, err := regexp.Compile(parser.GetValue())
+		if err != nil {
+			return "", err
+		}
+		m := re.FindStringSubmatch(source)
+		if m == nil {
+			return "", fmt.Errorf("%q does not match", parser.GetValue())
+		}
+		if len(m) > 1 {
+			return m[1], nil
+		}
+		return m[0], nil
+	case datadogV1.SYNTHETICSGLOBALVARIABLEPARSERTYPE_JSON_PATH, datadogV1.SYNTHETICSGLOBALVARIABLEPARSERTYPE_X_PATH:
+		var a spec.Assertion
+		if parser.Type == datadogV1.SYNTHETICSGLOBALVARIABLEPARSERTYPE_JSON_PATH {
+			a.JSONPath = parser.GetValue()
+		} else {
+			a.XPath = parser.GetValue()
+		}
+		values, err := selectValues(a, []interface{}{source})
+		if err != nil {
+			return "", err
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("%s not found", parser.GetValue())
+		}
+		return toString(values[0]), nil
+	}
+	return "", fmt.Errorf("unknown parser %q", parser.Type)
+}
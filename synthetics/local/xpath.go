@@ -0,0 +1,315 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package local
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is an element or a text node of a parsed XML or HTML document.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	isText   bool
+	parent   *xmlNode
+	children []*xmlNode
+}
+
+// parseXML parses XML, and HTML leniently.
+func parseXML(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	root := &xmlNode{}
+	current := root
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: map[string]string{}, parent: current}
+			for _, a := range t.Attr {
+				node.attrs[a.Name.Local] = a.Value
+			}
+			current.children = append(current.children, node)
+			current = node
+		case xml.EndElement:
+			for n := current; n != root; n = n.parent {
+				if strings.EqualFold(n.name, t.Name.Local) {
+					current = n.parent
+					break
+				}
+			}
+		case xml.CharData:
+			current.children = append(current.children, &xmlNode{text: string(t), isText: true, parent: current})
+		}
+	}
+	return root, nil
+}
+
+// stringValue is the concatenation of the text nodes below the node.
+func (n *xmlNode) stringValue() string {
+	if n.isText {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.stringValue())
+	}
+	return b.String()
+}
+
+func (n *xmlNode) elements() []*xmlNode {
+	var out []*xmlNode
+	for _, c := range n.children {
+		if !c.isText {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (n *xmlNode) descendantsOrSelf() []*xmlNode {
+	out := []*xmlNode{n}
+	for _, c := range n.elements() {
+		out = append(out, c.descendantsOrSelf()...)
+	}
+	return out
+}
+
+type xpathStep struct {
+	descendant bool
+	axis       string // "child", "attribute", "text", "self" or "parent"
+	name       string
+	predicates []string
+}
+
+// parseXPath supports location paths made of element names, "*", ".", "..",
+// "@attr" and "text()" steps, with positional, last(), attribute and equality
+// predicates.
+func parseXPath(path string) ([]xpathStep, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("XPath %q must be absolute", path)
+	}
+	var steps []xpathStep
+	rest := path
+	for rest != "" {
+		var step xpathStep
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			step.descendant = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		default:
+			return nil, fmt.Errorf("XPath %q: unexpected %q", path, rest)
+		}
+		end, depth := 0, 0
+		var quote byte
+		for ; end < len(rest); end++ {
+			c := rest[end]
+			if quote == 0 && depth == 0 && c == '/' {
+				break
+			}
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"':
+				quote = c
+			case c == '[':
+				depth++
+			case c == ']':
+				depth--
+			}
+		}
+		raw := rest[:end]
+		rest = rest[end:]
+		name := raw
+		if i := strings.IndexByte(raw, '['); i >= 0 {
+			name = raw[:i]
+			preds := raw[i:]
+			for preds != "" {
+				end := matchingBracket(preds)
+				if end < 0 {
+					return nil, fmt.Errorf("XPath %q: unterminated predicate", path)
+				}
+				step.predicates = append(step.predicates, strings.TrimSpace(preds[1:end]))
+				preds = preds[end+1:]
+			}
+		}
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("XPath %q: empty step", path)
+		case name == "text()":
+			step.axis = "text"
+		case name == ".":
+			step.axis = "self"
+		case name == "..":
+			step.axis = "parent"
+		case strings.HasPrefix(name, "@"):
+			step.axis, step.name = "attribute", name[1:]
+		default:
+			step.axis, step.name = "child", name
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func matchingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// evalXPath returns the string values selected by the path.
+func evalXPath(steps []xpathStep, root *xmlNode) ([]string, error) {
+	nodes := []*xmlNode{root}
+	for i, step := range steps {
+		if step.descendant {
+			var all []*xmlNode
+			for _, n := range nodes {
+				all = append(all, n.descendantsOrSelf()...)
+			}
+			nodes = all
+		}
+		last := i == len(steps)-1
+		switch step.axis {
+		case "attribute", "text":
+			if !last {
+				return nil, fmt.Errorf("%s() must be the last step", step.axis)
+			}
+			var values []string
+			for _, n := range nodes {
+				if step.axis == "text" {
+					for _, c := range n.children {
+						if c.isText {
+							values = append(values, c.text)
+						}
+					}
+					continue
+				}
+				for name, v := range n.attrs {
+					if step.name == "*" || name == step.name {
+						values = append(values, v)
+					}
+				}
+			}
+			return values, nil
+		case "self":
+		case "parent":
+			var parents []*xmlNode
+			for _, n := range nodes {
+				if n.parent != nil {
+					parents = append(parents, n.parent)
+				}
+			}
+			nodes = parents
+		default:
+			var next []*xmlNode
+			for _, n := range nodes {
+				var matched []*xmlNode
+				for _, c := range n.elements() {
+					if step.name == "*" || strings.EqualFold(c.name, step.name) {
+						matched = append(matched, c)
+					}
+				}
+				for _, pred := range step.predicates {
+					var err error
+					if matched, err = filterXPath(matched, pred); err != nil {
+						return nil, err
+					}
+				}
+				next = append(next, matched...)
+			}
+			nodes = next
+		}
+	}
+	values := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		values = append(values, n.stringValue())
+	}
+	return values, nil
+}
+
+func filterXPath(nodes []*xmlNode, pred string) ([]*xmlNode, error) {
+	if pred == "last()" {
+		if len(nodes) == 0 {
+			return nil, nil
+		}
+		return nodes[len(nodes)-1:], nil
+	}
+	if n, err := strconv.Atoi(pred); err == nil {
+		if n < 1 || n > len(nodes) {
+			return nil, nil
+		}
+		return nodes[n-1 : n], nil
+	}
+	left, right, hasValue := strings.Cut(pred, "=")
+	left = strings.TrimSpace(left)
+	var want string
+	if hasValue {
+		right = strings.TrimSpace(right)
+		if len(right) < 2 || (right[0] != '\'' && right[0] != '"') || right[len(right)-1] != right[0] {
+			return nil, fmt.Errorf("unsupported predicate [%s]", pred)
+		}
+		want = right[1 : len(right)-1]
+	}
+	var out []*xmlNode
+	for _, n := range nodes {
+		var got string
+		var ok bool
+		switch {
+		case strings.HasPrefix(left, "@"):
+			got, ok = n.attrs[left[1:]]
+		case left == "text()" || left == ".":
+			got, ok = n.stringValue(), true
+		default:
+			for _, c := range n.elements() {
+				if strings.EqualFold(c.name, left) {
+					got, ok = c.stringValue(), true
+					break
+				}
+			}
+		}
+		if ok && (!hasValue || got == want) {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
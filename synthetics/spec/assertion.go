@@ -0,0 +1,310 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package spec
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// Assertion is a test assertion. It is written either as a string such as
+// "header content-type contains json" or as a mapping.
+type Assertion struct {
+	Type     string      `yaml:"type"`
+	Property string      `yaml:"property,omitempty"`
+	Operator string      `yaml:"operator"`
+	Target   interface{} `yaml:"target,omitempty"`
+	// JSONPath makes the assertion a validatesJSONPath assertion; Operator
+	// and Target then apply to the value found at the path.
+	JSONPath string `yaml:"jsonPath,omitempty"`
+	// XPath makes the assertion a validatesXPath assertion.
+	XPath string `yaml:"xPath,omitempty"`
+}
+
+// assertionFields avoids the recursion of the custom (un)marshalers.
+type assertionFields Assertion
+
+// UnmarshalYAML accepts both the string and the mapping forms.
+func (a *Assertion) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		parsed, err := ParseAssertion(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		*a = parsed
+		return nil
+	}
+	var fields assertionFields
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	fields.Target = normalize(fields.Target)
+	*a = Assertion(fields)
+	return nil
+}
+
+// MarshalYAML uses the string form when it reads back unambiguously.
+func (a Assertion) MarshalYAML() (interface{}, error) {
+	if s, ok := a.compact(); ok {
+		return s, nil
+	}
+	return assertionFields(a), nil
+}
+
+// ParseAssertion parses the string form of an assertion.
+func ParseAssertion(s string) (Assertion, error) {
+	var a Assertion
+	var tok string
+	tok, rest := nextToken(s)
+	if tok == "" {
+		return a, fmt.Errorf("empty assertion")
+	}
+	a.Type = tok
+	tok, rest = nextToken(rest)
+	switch {
+	case strings.HasPrefix(tok, "$"):
+		a.JSONPath = tok
+		tok, rest = nextToken(rest)
+	case strings.HasPrefix(tok, "/"):
+		a.XPath = tok
+		tok, rest = nextToken(rest)
+	case !isOperator(tok):
+		a.Property = tok
+		tok, rest = nextToken(rest)
+	}
+	if tok == "" {
+		return a, fmt.Errorf("assertion %q has no operator", s)
+	}
+	a.Operator = tok
+	target, err := parseTarget(strings.TrimSpace(rest))
+	if err != nil {
+		return a, fmt.Errorf("assertion %q: %w", s, err)
+	}
+	a.Target = target
+	return a, nil
+}
+
+// String returns the string form of the assertion.
+func (a Assertion) String() string {
+	if s, ok := a.compact(); ok {
+		return s
+	}
+	return fmt.Sprintf("%s %s %s %v", a.Type, a.Property, a.Operator, a.Target)
+}
+
+func (a Assertion) compact() (string, bool) {
+	parts := []string{a.Type}
+	switch {
+	case a.JSONPath != "" && a.XPath != "", (a.JSONPath != "" || a.XPath != "") && a.Property != "":
+		return "", false
+	case a.JSONPath != "":
+		if !strings.HasPrefix(a.JSONPath, "$") || hasSpace(a.JSONPath) {
+			return "", false
+		}
+		parts = append(parts, a.JSONPath)
+	case a.XPath != "":
+		if !strings.HasPrefix(a.XPath, "/") || hasSpace(a.XPath) {
+			return "", false
+		}
+		parts = append(parts, a.XPath)
+	case a.Property != "":
+		if hasSpace(a.Property) || isOperator(a.Property) || strings.HasPrefix(a.Property, "$") || strings.HasPrefix(a.Property, "/") {
+			return "", false
+		}
+		parts = append(parts, a.Property)
+	}
+	if a.Type == "" || hasSpace(a.Type) || a.Operator == "" || hasSpace(a.Operator) {
+		return "", false
+	}
+	parts = append(parts, a.Operator)
+	switch t := a.Target.(type) {
+	case nil:
+	case string:
+		if numberPattern.MatchString(t) || t == "" || strings.TrimSpace(t) != t || strings.HasPrefix(t, `"`) {
+			t = strconv.Quote(t)
+		}
+		parts = append(parts, t)
+	case float64:
+		parts = append(parts, strconv.FormatFloat(t, 'f', -1, 64))
+	case int:
+		parts = append(parts, strconv.Itoa(t))
+	case int64:
+		parts = append(parts, strconv.FormatInt(t, 10))
+	default:
+		return "", false
+	}
+	return strings.Join(parts, " "), true
+}
+
+// model converts the assertion to the API union type.
+func (a Assertion) model() (datadogV1.SyntheticsAssertion, error) {
+	typ, err := datadogV1.NewSyntheticsAssertionTypeFromValue(a.Type)
+	if err != nil {
+		return datadogV1.SyntheticsAssertion{}, err
+	}
+	var property *string
+	if a.Property != "" {
+		property = datadog.PtrString(a.Property)
+	}
+	switch {
+	case a.JSONPath != "":
+		return datadogV1.SyntheticsAssertionJSONPathTargetAsSyntheticsAssertion(&datadogV1.SyntheticsAssertionJSONPathTarget{
+			Operator: datadogV1.SYNTHETICSASSERTIONJSONPATHOPERATOR_VALIDATES_JSON_PATH,
+			Property: property,
+			Target: &datadogV1.SyntheticsAssertionJSONPathTargetTarget{
+				JsonPath:    datadog.PtrString(a.JSONPath),
+				Operator:    datadog.PtrString(a.Operator),
+				TargetValue: a.Target,
+			},
+			Type: *typ,
+		}), nil
+	case a.XPath != "":
+		return datadogV1.SyntheticsAssertionXPathTargetAsSyntheticsAssertion(&datadogV1.SyntheticsAssertionXPathTarget{
+			Operator: datadogV1.SYNTHETICSASSERTIONXPATHOPERATOR_VALIDATES_X_PATH,
+			Property: property,
+			Target: &datadogV1.SyntheticsAssertionXPathTargetTarget{
+				XPath:       datadog.PtrString(a.XPath),
+				Operator:    datadog.PtrString(a.Operator),
+				TargetValue: a.Target,
+			},
+			Type: *typ,
+		}), nil
+	}
+	operator, err := datadogV1.NewSyntheticsAssertionOperatorFromValue(a.Operator)
+	if err != nil {
+		return datadogV1.SyntheticsAssertion{}, err
+	}
+	return datadogV1.SyntheticsAssertionTargetAsSyntheticsAssertion(&datadogV1.SyntheticsAssertionTarget{
+		Operator: *operator,
+		Property: property,
+		Target:   a.Target,
+		Type:     *typ,
+	}), nil
+}
+
+// AssertionFromModel converts an API assertion to its spec.
+func AssertionFromModel(m datadogV1.SyntheticsAssertion) (Assertion, error) {
+	switch {
+	case m.SyntheticsAssertionTarget != nil:
+		t := m.SyntheticsAssertionTarget
+		return Assertion{
+			Type:     string(t.Type),
+			Property: t.GetProperty(),
+			Operator: string(t.Operator),
+			Target:   normalize(t.Target),
+		}, nil
+	case m.SyntheticsAssertionJSONPathTarget != nil:
+		t := m.SyntheticsAssertionJSONPathTarget
+		target := t.GetTarget()
+		return Assertion{
+			Type:     string(t.Type),
+			Property: t.GetProperty(),
+			JSONPath: target.GetJsonPath(),
+			Operator: target.GetOperator(),
+			Target:   normalize(target.TargetValue),
+		}, nil
+	case m.SyntheticsAssertionXPathTarget != nil:
+		t := m.SyntheticsAssertionXPathTarget
+		target := t.GetTarget()
+		return Assertion{
+			Type:     string(t.Type),
+			Property: t.GetProperty(),
+			XPath:    target.GetXPath(),
+			Operator: target.GetOperator(),
+			Target:   normalize(target.TargetValue),
+		}, nil
+	}
+	return Assertion{}, fmt.Errorf("unsupported assertion %v", m.UnparsedObject)
+}
+
+func assertionsToModel(in []Assertion) ([]datadogV1.SyntheticsAssertion, error) {
+	out := make([]datadogV1.SyntheticsAssertion, 0, len(in))
+	for _, a := range in {
+		m, err := a.model()
+		if err != nil {
+			return nil, fmt.Errorf("assertion %q: %w", a.String(), err)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func assertionsFromModel(in []datadogV1.SyntheticsAssertion) ([]Assertion, error) {
+	var out []Assertion
+	for _, m := range in {
+		a, err := AssertionFromModel(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+var (
+	operators     = map[string]bool{}
+	numberPattern = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d+)?$`)
+)
+
+func init() {
+	for _, v := range []string{
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_CONTAINS),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_CONTAIN),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_IS),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_IS_NOT),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_LESS_THAN),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_LESS_THAN_OR_EQUAL),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_MORE_THAN),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_MORE_THAN_OR_EQUAL),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_MATCHES),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_MATCH),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_VALIDATES),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_IS_IN_MORE_DAYS_THAN),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_IS_IN_LESS_DAYS_THAN),
+		string(datadogV1.SYNTHETICSASSERTIONOPERATOR_DOES_NOT_EXIST),
+	} {
+		operators[v] = true
+	}
+}
+
+func isOperator(s string) bool {
+	return operators[s]
+}
+
+func nextToken(s string) (string, string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// parseTarget reads numbers as numbers and double-quoted strings as strings.
+func parseTarget(s string) (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		return strconv.Unquote(s)
+	}
+	if numberPattern.MatchString(s) {
+		f, err := strconv.ParseFloat(s, 64)
+		return normalize(f), err
+	}
+	return s, nil
+}
+
+func hasSpace(s string) bool {
+	return strings.IndexFunc(s, unicode.IsSpace) >= 0
+}
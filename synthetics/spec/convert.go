@@ -0,0 +1,534 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// APITest converts the spec to an API test.
+func (t *Test) APITest(r *Resolver) (datadogV1.SyntheticsAPITest, error) {
+	var test datadogV1.SyntheticsAPITest
+	if err := t.Validate(); err != nil {
+		return test, err
+	}
+	if t.Type != TypeAPI {
+		return test, fmt.Errorf("test %q is not an API test", t.Name)
+	}
+	test.Name = t.Name
+	test.Message = t.Message
+	test.Tags = t.Tags
+	test.Type = datadogV1.SYNTHETICSAPITESTTYPE_API
+	if t.PublicID != "" {
+		test.PublicId = datadog.PtrString(t.PublicID)
+	}
+	if t.Subtype != "" {
+		subtype, err := datadogV1.NewSyntheticsTestDetailsSubTypeFromValue(t.Subtype)
+		if err != nil {
+			return test, fmt.Errorf("test %q: %w", t.Name, err)
+		}
+		test.Subtype = subtype
+	}
+	if err := t.common(r, &test.Locations, &test.Status, &test.Options); err != nil {
+		return test, err
+	}
+	if t.Request != nil {
+		test.Config.Request = &datadogV1.SyntheticsTestRequest{}
+		if err := fromMap(t.Request, test.Config.Request); err != nil {
+			return test, fmt.Errorf("test %q: request: %w", t.Name, err)
+		}
+	}
+	var err error
+	if len(t.Assertions) > 0 {
+		if test.Config.Assertions, err = assertionsToModel(t.Assertions); err != nil {
+			return test, fmt.Errorf("test %q: %w", t.Name, err)
+		}
+	}
+	if test.Config.ConfigVariables, err = configVariablesToModel(t.Variables, r); err != nil {
+		return test, fmt.Errorf("test %q: %w", t.Name, err)
+	}
+	for _, s := range t.Steps {
+		step, err := s.apiStep()
+		if err != nil {
+			return test, fmt.Errorf("test %q: step %q: %w", t.Name, s.Name, err)
+		}
+		test.Config.Steps = append(test.Config.Steps, step)
+	}
+	return test, nil
+}
+
+// BrowserTest converts the spec to a browser test.
+func (t *Test) BrowserTest(r *Resolver) (datadogV1.SyntheticsBrowserTest, error) {
+	var test datadogV1.SyntheticsBrowserTest
+	if err := t.Validate(); err != nil {
+		return test, err
+	}
+	if t.Type != TypeBrowser {
+		return test, fmt.Errorf("test %q is not a browser test", t.Name)
+	}
+	test.Name = t.Name
+	test.Message = t.Message
+	test.Tags = t.Tags
+	test.Type = datadogV1.SYNTHETICSBROWSERTESTTYPE_BROWSER
+	if t.PublicID != "" {
+		test.PublicId = datadog.PtrString(t.PublicID)
+	}
+	if err := t.common(r, &test.Locations, &test.Status, &test.Options); err != nil {
+		return test, err
+	}
+	if err := fromMap(t.Request, &test.Config.Request); err != nil {
+		return test, fmt.Errorf("test %q: request: %w", t.Name, err)
+	}
+	if t.SetCookie != "" {
+		test.Config.SetCookie = datadog.PtrString(t.SetCookie)
+	}
+	var err error
+	if test.Config.Assertions, err = assertionsToModel(t.Assertions); err != nil {
+		return test, fmt.Errorf("test %q: %w", t.Name, err)
+	}
+	if test.Config.ConfigVariables, err = configVariablesToModel(t.Variables, r); err != nil {
+		return test, fmt.Errorf("test %q: %w", t.Name, err)
+	}
+	for _, v := range t.BrowserVariables {
+		variable, err := browserVariableToModel(v, r)
+		if err != nil {
+			return test, fmt.Errorf("test %q: %w", t.Name, err)
+		}
+		test.Config.Variables = append(test.Config.Variables, variable)
+	}
+	for _, s := range t.Steps {
+		step, err := s.browserStep()
+		if err != nil {
+			return test, fmt.Errorf("test %q: step %q: %w", t.Name, s.Name, err)
+		}
+		test.Steps = append(test.Steps, step)
+	}
+	return test, nil
+}
+
+// common converts the fields shared by API and browser tests.
+func (t *Test) common(r *Resolver, locations *[]string, status **datadogV1.SyntheticsTestPauseStatus, options *datadogV1.SyntheticsTestOptions) error {
+	*locations = []string{}
+	for _, l := range t.Locations {
+		id, err := r.LocationID(l)
+		if err != nil {
+			return fmt.Errorf("test %q: %w", t.Name, err)
+		}
+		*locations = append(*locations, id)
+	}
+	if t.Status != "" {
+		s, err := datadogV1.NewSyntheticsTestPauseStatusFromValue(t.Status)
+		if err != nil {
+			return fmt.Errorf("test %q: %w", t.Name, err)
+		}
+		*status = s
+	}
+	if err := fromMap(t.Options, options); err != nil {
+		return fmt.Errorf("test %q: options: %w", t.Name, err)
+	}
+	return nil
+}
+
+// FromAPITest converts an API test to a spec.
+func FromAPITest(test datadogV1.SyntheticsAPITest, r *Resolver) (*Test, error) {
+	if test.UnparsedObject != nil {
+		return nil, fmt.Errorf("test %q contains values unknown to this client", test.Name)
+	}
+	t := &Test{
+		PublicID:  test.GetPublicId(),
+		Name:      test.Name,
+		Type:      TypeAPI,
+		Subtype:   string(test.GetSubtype()),
+		Status:    string(test.GetStatus()),
+		Message:   test.Message,
+		Tags:      test.Tags,
+		Locations: locationNames(test.Locations, r),
+	}
+	var err error
+	if t.Options, err = toMap(test.Options); err != nil {
+		return nil, err
+	}
+	if test.Config.Request != nil {
+		if t.Request, err = toMap(test.Config.Request); err != nil {
+			return nil, err
+		}
+	}
+	if t.Assertions, err = assertionsFromModel(test.Config.Assertions); err != nil {
+		return nil, fmt.Errorf("test %q: %w", test.Name, err)
+	}
+	t.Variables = configVariablesFromModel(test.Config.ConfigVariables, r)
+	for _, s := range test.Config.Steps {
+		step, err := apiStepFromModel(s)
+		if err != nil {
+			return nil, fmt.Errorf("test %q: step %q: %w", test.Name, s.Name, err)
+		}
+		t.Steps = append(t.Steps, step)
+	}
+	return t, nil
+}
+
+// FromBrowserTest converts a browser test to a spec.
+func FromBrowserTest(test datadogV1.SyntheticsBrowserTest, r *Resolver) (*Test, error) {
+	if test.UnparsedObject != nil {
+		return nil, fmt.Errorf("test %q contains values unknown to this client", test.Name)
+	}
+	t := &Test{
+		PublicID:  test.GetPublicId(),
+		Name:      test.Name,
+		Type:      TypeBrowser,
+		Status:    string(test.GetStatus()),
+		Message:   test.Message,
+		Tags:      test.Tags,
+		Locations: locationNames(test.Locations, r),
+		SetCookie: test.Config.GetSetCookie(),
+	}
+	var err error
+	if t.Options, err = toMap(test.Options); err != nil {
+		return nil, err
+	}
+	if t.Request, err = toMap(test.Config.Request); err != nil {
+		return nil, err
+	}
+	if t.Assertions, err = assertionsFromModel(test.Config.Assertions); err != nil {
+		return nil, fmt.Errorf("test %q: %w", test.Name, err)
+	}
+	t.Variables = configVariablesFromModel(test.Config.ConfigVariables, r)
+	for _, v := range test.Config.Variables {
+		t.BrowserVariables = append(t.BrowserVariables, variableFromModel(v.Name, string(v.Type), v.GetId(), v.GetPattern(), v.GetExample(), r))
+	}
+	for _, s := range test.Steps {
+		step := Step{
+			Name:         s.GetName(),
+			Type:         string(s.GetType()),
+			Params:       normalize(s.Params),
+			Timeout:      s.GetTimeout(),
+			NoScreenshot: s.NoScreenshot,
+			AllowFailure: s.AllowFailure,
+			IsCritical:   s.IsCritical,
+		}
+		t.Steps = append(t.Steps, step)
+	}
+	return t, nil
+}
+
+func (s Step) apiStep() (datadogV1.SyntheticsAPIStep, error) {
+	step := datadogV1.SyntheticsAPIStep{
+		Name:         s.Name,
+		Subtype:      datadogV1.SYNTHETICSAPISTEPSUBTYPE_HTTP,
+		AllowFailure: s.AllowFailure,
+		IsCritical:   s.IsCritical,
+	}
+	if s.Subtype != "" {
+		subtype, err := datadogV1.NewSyntheticsAPIStepSubtypeFromValue(s.Subtype)
+		if err != nil {
+			return step, err
+		}
+		step.Subtype = *subtype
+	}
+	if err := fromMap(s.Request, &step.Request); err != nil {
+		return step, fmt.Errorf("request: %w", err)
+	}
+	var err error
+	if step.Assertions, err = assertionsToModel(s.Assertions); err != nil {
+		return step, err
+	}
+	for _, e := range s.Extract {
+		var value datadogV1.SyntheticsParsingOptions
+		if err := fromMap(e, &value); err != nil {
+			return step, fmt.Errorf("extract: %w", err)
+		}
+		step.ExtractedValues = append(step.ExtractedValues, value)
+	}
+	if s.Retry != nil {
+		step.Retry = &datadogV1.SyntheticsTestOptionsRetry{}
+		if err := fromMap(s.Retry, step.Retry); err != nil {
+			return step, fmt.Errorf("retry: %w", err)
+		}
+	}
+	return step, nil
+}
+
+func apiStepFromModel(m datadogV1.SyntheticsAPIStep) (Step, error) {
+	s := Step{
+		Name:         m.Name,
+		Subtype:      string(m.Subtype),
+		AllowFailure: m.AllowFailure,
+		IsCritical:   m.IsCritical,
+	}
+	var err error
+	if s.Request, err = toMap(m.Request); err != nil {
+		return s, err
+	}
+	if s.Assertions, err = assertionsFromModel(m.Assertions); err != nil {
+		return s, err
+	}
+	for _, e := range m.ExtractedValues {
+		value, err := toMap(e)
+		if err != nil {
+			return s, err
+		}
+		s.Extract = append(s.Extract, value)
+	}
+	if m.Retry != nil {
+		if s.Retry, err = toMap(m.Retry); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+func (s Step) browserStep() (datadogV1.SyntheticsStep, error) {
+	step := datadogV1.SyntheticsStep{
+		AllowFailure: s.AllowFailure,
+		IsCritical:   s.IsCritical,
+		NoScreenshot: s.NoScreenshot,
+		Params:       s.Params,
+	}
+	if s.Name != "" {
+		step.Name = datadog.PtrString(s.Name)
+	}
+	if s.Timeout != 0 {
+		step.Timeout = datadog.PtrInt64(s.Timeout)
+	}
+	if s.Type != "" {
+		typ, err := datadogV1.NewSyntheticsStepTypeFromValue(s.Type)
+		if err != nil {
+			return step, err
+		}
+		step.Type = typ
+	}
+	return step, nil
+}
+
+func configVariablesToModel(in []Variable, r *Resolver) ([]datadogV1.SyntheticsConfigVariable, error) {
+	var out []datadogV1.SyntheticsConfigVariable
+	for _, v := range in {
+		typ, id, err := v.resolve(r)
+		if err != nil {
+			return nil, err
+		}
+		variableType, err := datadogV1.NewSyntheticsConfigVariableTypeFromValue(typ)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q: %w", v.Name, err)
+		}
+		out = append(out, datadogV1.SyntheticsConfigVariable{
+			Name:    v.Name,
+			Type:    *variableType,
+			Id:      optionalString(id),
+			Pattern: optionalString(v.Pattern),
+			Example: optionalString(v.Example),
+		})
+	}
+	return out, nil
+}
+
+func browserVariableToModel(v Variable, r *Resolver) (datadogV1.SyntheticsBrowserVariable, error) {
+	typ, id, err := v.resolve(r)
+	if err != nil {
+		return datadogV1.SyntheticsBrowserVariable{}, err
+	}
+	variableType, err := datadogV1.NewSyntheticsBrowserVariableTypeFromValue(typ)
+	if err != nil {
+		return datadogV1.SyntheticsBrowserVariable{}, fmt.Errorf("variable %q: %w", v.Name, err)
+	}
+	return datadogV1.SyntheticsBrowserVariable{
+		Name:    v.Name,
+		Type:    *variableType,
+		Id:      optionalString(id),
+		Pattern: optionalString(v.Pattern),
+		Example: optionalString(v.Example),
+	}, nil
+}
+
+// resolve returns the type of the variable and the ID of the global variable it references.
+func (v Variable) resolve(r *Resolver) (string, string, error) {
+	typ := v.Type
+	if typ == "" {
+		typ = string(datadogV1.SYNTHETICSCONFIGVARIABLETYPE_TEXT)
+		if v.Global != "" || v.ID != "" {
+			typ = string(datadogV1.SYNTHETICSCONFIGVARIABLETYPE_GLOBAL)
+		}
+	}
+	if v.Global == "" {
+		return typ, v.ID, nil
+	}
+	id, err := r.GlobalVariableID(v.Global)
+	if err != nil {
+		return "", "", fmt.Errorf("variable %q: %w", v.Name, err)
+	}
+	return typ, id, nil
+}
+
+func configVariablesFromModel(in []datadogV1.SyntheticsConfigVariable, r *Resolver) []Variable {
+	var out []Variable
+	for _, v := range in {
+		out = append(out, variableFromModel(v.Name, string(v.Type), v.GetId(), v.GetPattern(), v.GetExample(), r))
+	}
+	return out
+}
+
+func variableFromModel(name, typ, id, pattern, example string, r *Resolver) Variable {
+	v := Variable{Name: name, Type: typ, Pattern: pattern, Example: example}
+	switch typ {
+	case string(datadogV1.SYNTHETICSCONFIGVARIABLETYPE_GLOBAL):
+		v.Type = ""
+		if global, ok := r.GlobalVariableName(id); ok {
+			v.Global = global
+		} else {
+			v.ID = id
+		}
+	case string(datadogV1.SYNTHETICSCONFIGVARIABLETYPE_TEXT):
+		v.Type = ""
+		v.ID = id
+	default:
+		v.ID = id
+	}
+	return v
+}
+
+func locationNames(ids []string, r *Resolver) []string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, r.LocationName(id))
+	}
+	return names
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return datadog.PtrString(s)
+}
+
+// toMap converts a model to a map using its JSON keys.
+func toMap(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return normalize(out).(map[string]interface{}), nil
+}
+
+// fromMap fills a model from a map using its JSON keys. A key that is not
+// a field of the model is an error, so that a misspelled option is not
+// silently ignored.
+func fromMap(in interface{}, out interface{}) error {
+	if in == nil {
+		return nil
+	}
+	if err := checkKeys(in, reflect.TypeOf(out), ""); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(out)
+}
+
+// checkKeys returns an error for the first key of the maps of a value that
+// is not a JSON field of the model filled with it. The generated models
+// decode themselves, which DisallowUnknownFields does not reach. Models
+// without JSON fields, such as unions of models, are not checked.
+func checkKeys(in interface{}, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch in := in.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Map:
+			for k, v := range in {
+				if err := checkKeys(v, t.Elem(), fieldPath(path, k)); err != nil {
+					return err
+				}
+			}
+		case reflect.Struct:
+			fields := jsonFields(t)
+			if len(fields) == 0 {
+				return nil
+			}
+			for k, v := range in {
+				field, ok := fields[k]
+				if !ok {
+					return fmt.Errorf("unknown field %q", fieldPath(path, k))
+				}
+				if err := checkKeys(v, field, fieldPath(path, k)); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, v := range in {
+				if err := checkKeys(v, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonFields returns the types of the fields of a struct by JSON key.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func fieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// normalize represents integral numbers as int64, so values decoded from
+// JSON and from YAML compare and render the same way.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+			return int64(t)
+		}
+	case int:
+		return int64(t)
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = normalize(item)
+		}
+	case []interface{}:
+		for i, item := range t {
+			t[i] = normalize(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package spec
+
+import (
+	_context "context"
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// privateLocationPrefix starts the ID of every private location.
+const privateLocationPrefix = "pl:"
+
+// Resolver maps location and global variable names to their IDs and back.
+// A nil Resolver keeps locations and variables as written.
+type Resolver struct {
+	locationIDs   map[string]string
+	locationNames map[string]string
+	variableIDs   map[string]string
+	variableNames map[string]string
+}
+
+// NewResolver returns a resolver for the given locations and global variables.
+func NewResolver(locations []datadogV1.SyntheticsLocation, variables []datadogV1.SyntheticsGlobalVariable) *Resolver {
+	r := &Resolver{
+		locationIDs:   map[string]string{},
+		locationNames: map[string]string{},
+		variableIDs:   map[string]string{},
+		variableNames: map[string]string{},
+	}
+	for _, l := range locations {
+		if l.GetId() == "" {
+			continue
+		}
+		r.locationNames[l.GetId()] = l.GetName()
+		if l.GetName() != "" {
+			r.locationIDs[l.GetName()] = l.GetId()
+		}
+	}
+	for _, v := range variables {
+		if v.GetId() == "" {
+			continue
+		}
+		r.variableNames[v.GetId()] = v.Name
+		r.variableIDs[v.Name] = v.GetId()
+	}
+	return r
+}
+
+// LoadResolver lists the locations and global variables of the organization.
+func LoadResolver(ctx _context.Context, api *datadogV1.SyntheticsApi) (*Resolver, error) {
+	locations, _, err := api.ListLocations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing locations: %w", err)
+	}
+	variables, _, err := api.ListGlobalVariables(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing global variables: %w", err)
+	}
+	return NewResolver(locations.Locations, variables.Variables), nil
+}
+
+// LocationID returns the ID of a location given by ID or by name.
+func (r *Resolver) LocationID(location string) (string, error) {
+	if r == nil {
+		return location, nil
+	}
+	if _, ok := r.locationNames[location]; ok {
+		return location, nil
+	}
+	if id, ok := r.locationIDs[location]; ok {
+		return id, nil
+	}
+	for name, id := range r.locationIDs {
+		if strings.EqualFold(name, location) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("unknown location %q", location)
+}
+
+// LocationName returns the name to write for a location: the name of private
+// locations, and the ID of managed ones.
+func (r *Resolver) LocationName(id string) string {
+	if r == nil || !strings.HasPrefix(id, privateLocationPrefix) {
+		return id
+	}
+	name := r.locationNames[id]
+	if name == "" || r.locationIDs[name] != id {
+		return id
+	}
+	return name
+}
+
+// GlobalVariableID returns the ID of a global variable given by name.
+func (r *Resolver) GlobalVariableID(name string) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("global variable %q cannot be resolved without a resolver", name)
+	}
+	id, ok := r.variableIDs[name]
+	if !ok {
+		return "", fmt.Errorf("unknown global variable %q", name)
+	}
+	return id, nil
+}
+
+// GlobalVariableName returns the name of a global variable given by ID.
+func (r *Resolver) GlobalVariableName(id string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	name, ok := r.variableNames[id]
+	return name, ok
+}
@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package spec defines a compact YAML format for Synthetic API and browser tests.
+//
+// A spec file holds one or more YAML documents, one per test:
+//
+//	name: Checkout API
+//	type: api
+//	subtype: http
+//	locations: [aws:eu-west-1, My private location]
+//	tags: [env:prod]
+//	options: {tick_every: 300, min_location_failed: 1}
+//	request: {method: GET, url: "https://example.com/api/checkout"}
+//	assertions:
+//	  - statusCode is 200
+//	  - header content-type contains json
+//	  - body $.status is "ok"
+//	variables:
+//	  - name: TOKEN
+//	    global: checkout_token
+//
+// Assertions are written as "<type> [<property>] <operator> [<target>]", where
+// a property starting with "$" is a JSON path and one starting with "/" is an
+// XPath. The mapping form (type, property, operator, target, jsonPath, xPath)
+// is accepted everywhere. Options, requests, step retries, extracted values and
+// browser step parameters use the keys of the API models.
+//
+// Private locations and global variables are referenced by name and resolved
+// with a Resolver.
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// List of test types.
+const (
+	TypeAPI     = "api"
+	TypeBrowser = "browser"
+)
+
+// Test is the spec of a Synthetic API or browser test.
+type Test struct {
+	PublicID  string   `yaml:"public_id,omitempty"`
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`
+	Subtype   string   `yaml:"subtype,omitempty"`
+	Status    string   `yaml:"status,omitempty"`
+	Message   string   `yaml:"message,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
+	Locations []string `yaml:"locations"`
+
+	Options    map[string]interface{} `yaml:"options,omitempty"`
+	Request    map[string]interface{} `yaml:"request,omitempty"`
+	Assertions []Assertion            `yaml:"assertions,omitempty"`
+	// Variables are the config variables of the test.
+	Variables []Variable `yaml:"variables,omitempty"`
+	// BrowserVariables are the variables of a browser test.
+	BrowserVariables []Variable `yaml:"browser_variables,omitempty"`
+	SetCookie        string     `yaml:"set_cookie,omitempty"`
+	// Steps are the steps of a multistep API test or of a browser test.
+	Steps []Step `yaml:"steps,omitempty"`
+}
+
+// Variable is a test variable. Global variables are referenced by name.
+type Variable struct {
+	Name string `yaml:"name"`
+	// Type defaults to "text", or to "global" when Global or ID is set.
+	Type string `yaml:"type,omitempty"`
+	// Global is the name of the referenced global variable.
+	Global string `yaml:"global,omitempty"`
+	// ID is the ID of the referenced global variable, used when it has no known name.
+	ID      string `yaml:"id,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"`
+	Example string `yaml:"example,omitempty"`
+}
+
+// Step is a step of a multistep API test or of a browser test.
+type Step struct {
+	Name string `yaml:"name"`
+
+	// Subtype, Request, Assertions, Extract and Retry describe API steps.
+	Subtype    string                   `yaml:"subtype,omitempty"`
+	Request    map[string]interface{}   `yaml:"request,omitempty"`
+	Assertions []Assertion              `yaml:"assertions,omitempty"`
+	Extract    []map[string]interface{} `yaml:"extract,omitempty"`
+	Retry      map[string]interface{}   `yaml:"retry,omitempty"`
+
+	// Type, Params, Timeout and NoScreenshot describe browser steps.
+	Type         string      `yaml:"type,omitempty"`
+	Params       interface{} `yaml:"params,omitempty"`
+	Timeout      int64       `yaml:"timeout,omitempty"`
+	NoScreenshot *bool       `yaml:"no_screenshot,omitempty"`
+
+	AllowFailure *bool `yaml:"allow_failure,omitempty"`
+	IsCritical   *bool `yaml:"is_critical,omitempty"`
+}
+
+// Decode reads every test of a YAML stream.
+func Decode(r io.Reader) ([]*Test, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var tests []*Test
+	for {
+		var t Test
+		err := dec.Decode(&t)
+		if errors.Is(err, io.EOF) {
+			return tests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		t.normalizeValues()
+		tests = append(tests, &t)
+	}
+}
+
+// Unmarshal reads every test of a YAML document.
+func Unmarshal(data []byte) ([]*Test, error) {
+	return Decode(bytes.NewReader(data))
+}
+
+// Encode writes the tests as a YAML stream, one document per test.
+func Encode(w io.Writer, tests ...*Test) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, t := range tests {
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// Marshal returns the tests as a YAML stream.
+func Marshal(tests ...*Test) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, tests...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Validate checks the fields that do not depend on the API.
+func (t *Test) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("test has no name")
+	}
+	switch t.Type {
+	case TypeAPI, TypeBrowser:
+	default:
+		return fmt.Errorf("test %q: unknown type %q, expected %q or %q", t.Name, t.Type, TypeAPI, TypeBrowser)
+	}
+	for i, v := range t.Variables {
+		if v.Name == "" {
+			return fmt.Errorf("test %q: variable %d has no name", t.Name, i)
+		}
+	}
+	return nil
+}
+
+// normalizeValues gives the free-form values the representation of the values
+// read from the API.
+func (t *Test) normalizeValues() {
+	normalize(t.Options)
+	normalize(t.Request)
+	for i := range t.Steps {
+		s := &t.Steps[i]
+		normalize(s.Request)
+		normalize(s.Retry)
+		for _, e := range s.Extract {
+			normalize(e)
+		}
+		s.Params = normalize(s.Params)
+	}
+}
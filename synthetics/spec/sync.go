@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package spec
+
+import (
+	_context "context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// Action is what a sync did, or would do, to a test.
+type Action string
+
+// List of Action.
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+)
+
+// SyncResult is the outcome of the sync of one test.
+type SyncResult struct {
+	Test   *Test
+	Action Action
+}
+
+// Syncer creates and updates tests from their specs.
+type Syncer struct {
+	api      *datadogV1.SyntheticsApi
+	resolver *Resolver
+	// DryRun computes the actions without creating or updating tests.
+	DryRun bool
+}
+
+// NewSyncer returns a syncer. The resolver may be nil when specs only use
+// location and global variable IDs.
+func NewSyncer(api *datadogV1.SyntheticsApi, resolver *Resolver) *Syncer {
+	return &Syncer{api: api, resolver: resolver}
+}
+
+// Sync creates the tests without public ID and updates the tests that differ
+// from their spec. The public ID of created tests is set on their spec.
+func (s *Syncer) Sync(ctx _context.Context, tests []*Test) ([]SyncResult, error) {
+	var results []SyncResult
+	for _, t := range tests {
+		action, err := s.sync(ctx, t)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, SyncResult{Test: t, Action: action})
+	}
+	return results, nil
+}
+
+func (s *Syncer) sync(ctx _context.Context, t *Test) (Action, error) {
+	if t.PublicID == "" {
+		return ActionCreated, s.create(ctx, t)
+	}
+	remote, err := s.Export(ctx, t.PublicID)
+	if err != nil {
+		return "", err
+	}
+	if remote.Type != t.Type {
+		return "", fmt.Errorf("test %q: cannot change the type of %s from %s to %s", t.Name, t.PublicID, remote.Type, t.Type)
+	}
+	local, err := s.normalize(t)
+	if err != nil {
+		return "", err
+	}
+	if equal(local, remote) {
+		return ActionUnchanged, nil
+	}
+	if s.DryRun {
+		return ActionUpdated, nil
+	}
+	if t.Type == TypeBrowser {
+		test, err := t.BrowserTest(s.resolver)
+		if err != nil {
+			return "", err
+		}
+		if _, _, err := s.api.UpdateBrowserTest(ctx, t.PublicID, test); err != nil {
+			return "", fmt.Errorf("updating test %q: %w", t.Name, err)
+		}
+		return ActionUpdated, nil
+	}
+	test, err := t.APITest(s.resolver)
+	if err != nil {
+		return "", err
+	}
+	if _, _, err := s.api.UpdateAPITest(ctx, t.PublicID, test); err != nil {
+		return "", fmt.Errorf("updating test %q: %w", t.Name, err)
+	}
+	return ActionUpdated, nil
+}
+
+func (s *Syncer) create(ctx _context.Context, t *Test) error {
+	if t.Type == TypeBrowser {
+		test, err := t.BrowserTest(s.resolver)
+		if err != nil || s.DryRun {
+			return err
+		}
+		created, _, err := s.api.CreateSyntheticsBrowserTest(ctx, test)
+		if err != nil {
+			return fmt.Errorf("creating test %q: %w", t.Name, err)
+		}
+		t.PublicID = created.GetPublicId()
+		return nil
+	}
+	test, err := t.APITest(s.resolver)
+	if err != nil || s.DryRun {
+		return err
+	}
+	created, _, err := s.api.CreateSyntheticsAPITest(ctx, test)
+	if err != nil {
+		return fmt.Errorf("creating test %q: %w", t.Name, err)
+	}
+	t.PublicID = created.GetPublicId()
+	return nil
+}
+
+// Export returns the spec of an existing test.
+func (s *Syncer) Export(ctx _context.Context, publicID string) (*Test, error) {
+	details, _, err := s.api.GetTest(ctx, publicID)
+	if err != nil {
+		return nil, fmt.Errorf("getting test %s: %w", publicID, err)
+	}
+	if details.GetType() == datadogV1.SYNTHETICSTESTDETAILSTYPE_BROWSER {
+		test, _, err := s.api.GetBrowserTest(ctx, publicID)
+		if err != nil {
+			return nil, fmt.Errorf("getting browser test %s: %w", publicID, err)
+		}
+		return FromBrowserTest(test, s.resolver)
+	}
+	test, _, err := s.api.GetAPITest(ctx, publicID)
+	if err != nil {
+		return nil, fmt.Errorf("getting API test %s: %w", publicID, err)
+	}
+	return FromAPITest(test, s.resolver)
+}
+
+// normalize round-trips a spec through its model, so that defaults and
+// number representations match the exported tests.
+func (s *Syncer) normalize(t *Test) (*Test, error) {
+	if t.Type == TypeBrowser {
+		test, err := t.BrowserTest(s.resolver)
+		if err != nil {
+			return nil, err
+		}
+		return FromBrowserTest(test, s.resolver)
+	}
+	test, err := t.APITest(s.resolver)
+	if err != nil {
+		return nil, err
+	}
+	return FromAPITest(test, s.resolver)
+}
+
+// equal compares the fields managed by a spec. Tags and locations are sets:
+// their order does not make a test differ.
+func equal(a, b *Test) bool {
+	return reflect.DeepEqual(managed(a), managed(b))
+}
+
+// managed returns a copy of the test without its public ID, with its tags and
+// locations sorted.
+func managed(t *Test) Test {
+	m := *t
+	m.PublicID = ""
+	m.Tags = sorted(t.Tags)
+	m.Locations = sorted(t.Locations)
+	return m
+}
+
+func sorted(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	s := append([]string(nil), values...)
+	sort.Strings(s)
+	return s
+}
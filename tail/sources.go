@@ -0,0 +1,158 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package tail
+
+import (
+	_context "context"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// timeFormat is the format of the time filters of logs and events.
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+type logsSource struct {
+	api     *datadogV2.LogsApi
+	indexes []string
+}
+
+// Logs returns a source of the logs of the given indexes, or of all the
+// indexes when none are given.
+func Logs(api *datadogV2.LogsApi, indexes ...string) Source {
+	return &logsSource{api: api, indexes: indexes}
+}
+
+func (s *logsSource) List(ctx _context.Context, query string, from, to time.Time, cursor string, limit int32) ([]Item, string, error) {
+	filter := datadogV2.LogsQueryFilter{
+		From:    datadog.PtrString(from.UTC().Format(timeFormat)),
+		To:      datadog.PtrString(to.UTC().Format(timeFormat)),
+		Indexes: s.indexes,
+	}
+	if query != "" {
+		filter.Query = &query
+	}
+	page := datadogV2.LogsListRequestPage{Limit: &limit}
+	if cursor != "" {
+		page.Cursor = &cursor
+	}
+	body := datadogV2.LogsListRequest{Filter: &filter, Page: &page, Sort: datadogV2.LOGSSORT_TIMESTAMP_ASCENDING.Ptr()}
+	resp, _, err := s.api.ListLogs(ctx, *datadogV2.NewListLogsOptionalParameters().WithBody(body))
+	if err != nil {
+		return nil, "", fmt.Errorf("listing logs: %w", err)
+	}
+	items := make([]Item, len(resp.Data))
+	for i, l := range resp.Data {
+		attributes := l.GetAttributes()
+		items[i] = Item{ID: l.GetId(), Timestamp: attributes.GetTimestamp(), Value: l}
+	}
+	meta := resp.GetMeta()
+	return items, meta.Page.GetAfter(), nil
+}
+
+type eventsSource struct {
+	api *datadogV2.EventsApi
+}
+
+// Events returns a source of events. Listing events is an unstable
+// operation, to enable with SetUnstableOperationEnabled("v2.ListEvents").
+func Events(api *datadogV2.EventsApi) Source {
+	return &eventsSource{api: api}
+}
+
+func (s *eventsSource) List(ctx _context.Context, query string, from, to time.Time, cursor string, limit int32) ([]Item, string, error) {
+	params := datadogV2.NewListEventsOptionalParameters().
+		WithFilterFrom(from.UTC().Format(timeFormat)).
+		WithFilterTo(to.UTC().Format(timeFormat)).
+		WithSort(datadogV2.EVENTSSORT_TIMESTAMP_ASCENDING).
+		WithPageLimit(limit)
+	if query != "" {
+		params.WithFilterQuery(query)
+	}
+	if cursor != "" {
+		params.WithPageCursor(cursor)
+	}
+	resp, _, err := s.api.ListEvents(ctx, *params)
+	if err != nil {
+		return nil, "", fmt.Errorf("listing events: %w", err)
+	}
+	items := make([]Item, len(resp.Data))
+	for i, e := range resp.Data {
+		attributes := e.GetAttributes()
+		items[i] = Item{ID: e.GetId(), Timestamp: attributes.GetTimestamp(), Value: e}
+	}
+	meta := resp.GetMeta()
+	return items, meta.Page.GetAfter(), nil
+}
+
+type auditSource struct {
+	api *datadogV2.AuditApi
+}
+
+// AuditLogs returns a source of audit logs.
+func AuditLogs(api *datadogV2.AuditApi) Source {
+	return &auditSource{api: api}
+}
+
+func (s *auditSource) List(ctx _context.Context, query string, from, to time.Time, cursor string, limit int32) ([]Item, string, error) {
+	params := datadogV2.NewListAuditLogsOptionalParameters().
+		WithFilterFrom(from).
+		WithFilterTo(to).
+		WithSort(datadogV2.AUDITLOGSSORT_TIMESTAMP_ASCENDING).
+		WithPageLimit(limit)
+	if query != "" {
+		params.WithFilterQuery(query)
+	}
+	if cursor != "" {
+		params.WithPageCursor(cursor)
+	}
+	resp, _, err := s.api.ListAuditLogs(ctx, *params)
+	if err != nil {
+		return nil, "", fmt.Errorf("listing audit logs: %w", err)
+	}
+	items := make([]Item, len(resp.Data))
+	for i, e := range resp.Data {
+		attributes := e.GetAttributes()
+		items[i] = Item{ID: e.GetId(), Timestamp: attributes.GetTimestamp(), Value: e}
+	}
+	meta := resp.GetMeta()
+	return items, meta.Page.GetAfter(), nil
+}
+
+type rumSource struct {
+	api *datadogV2.RUMApi
+}
+
+// RUMEvents returns a source of RUM events.
+func RUMEvents(api *datadogV2.RUMApi) Source {
+	return &rumSource{api: api}
+}
+
+func (s *rumSource) List(ctx _context.Context, query string, from, to time.Time, cursor string, limit int32) ([]Item, string, error) {
+	params := datadogV2.NewListRUMEventsOptionalParameters().
+		WithFilterFrom(from).
+		WithFilterTo(to).
+		WithSort(datadogV2.RUMSORT_TIMESTAMP_ASCENDING).
+		WithPageLimit(limit)
+	if query != "" {
+		params.WithFilterQuery(query)
+	}
+	if cursor != "" {
+		params.WithPageCursor(cursor)
+	}
+	resp, _, err := s.api.ListRUMEvents(ctx, *params)
+	if err != nil {
+		return nil, "", fmt.Errorf("listing RUM events: %w", err)
+	}
+	items := make([]Item, len(resp.Data))
+	for i, e := range resp.Data {
+		attributes := e.GetAttributes()
+		items[i] = Item{ID: e.GetId(), Timestamp: attributes.GetTimestamp(), Value: e}
+	}
+	meta := resp.GetMeta()
+	return items, meta.Page.GetAfter(), nil
+}
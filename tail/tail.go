@@ -0,0 +1,189 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package tail follows the logs, events, audit logs or RUM events matching a
+// query as they are ingested, like tail -f.
+//
+// A Tail polls its Source for a window of time sliding with the clock. The
+// window starts at the end of the previous one and ends Delay before now, so
+// that items ingested up to Delay late are still caught. Items ingested later
+// than that are missed. Items on the bound of two windows are skipped by ID
+// the second time.
+//
+// Items are delivered in timestamp order, window after window: an item is
+// never older than the items delivered before it.
+package tail
+
+import (
+	_context "context"
+	"sort"
+	"time"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultDelay    = 2 * time.Minute
+	defaultPageSize = 100
+)
+
+// Item is a log, event, audit log or RUM event.
+type Item struct {
+	ID        string
+	Timestamp time.Time
+	// Value is the datadogV2.Log, datadogV2.EventResponse,
+	// datadogV2.AuditLogsEvent or datadogV2.RUMEvent.
+	Value interface{}
+}
+
+// Source lists the items of a search endpoint.
+type Source interface {
+	// List returns a page of the items matching the query with a timestamp
+	// between from and to, in timestamp order, and the cursor of the next
+	// page, empty on the last page.
+	List(ctx _context.Context, query string, from, to time.Time, cursor string, limit int32) ([]Item, string, error)
+}
+
+// Tail follows the items of a source matching a query.
+type Tail struct {
+	source Source
+	query  string
+
+	// Start is the start of the first window. It defaults to Delay before
+	// the first poll, so that only new items are delivered.
+	Start time.Time
+	// Interval is the delay between two polls.
+	Interval time.Duration
+	// Delay is how far behind the clock windows end, the longest an item
+	// can take to be ingested and still be delivered.
+	Delay    time.Duration
+	PageSize int32
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// New returns a tail of the items of the source matching the query.
+func New(source Source, query string) *Tail {
+	return &Tail{
+		source:   source,
+		query:    query,
+		Interval: defaultInterval,
+		Delay:    defaultDelay,
+		PageSize: defaultPageSize,
+		Now:      time.Now,
+	}
+}
+
+// Run polls the source and calls fn for every new item until the context is
+// done, the source fails or fn returns an error, and returns that error.
+func (t *Tail) Run(ctx _context.Context, fn func(Item) error) error {
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	first := t.Start
+	if first.IsZero() {
+		first = now().Add(-t.Delay)
+	}
+	from := first
+	seen := map[string]time.Time{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		to := now().Add(-t.Delay)
+		if to.After(from) {
+			items, err := t.fetch(ctx, from, to)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				return err
+			}
+			for id, ts := range seen {
+				if ts.Before(from) {
+					delete(seen, id)
+				}
+			}
+			for _, item := range items {
+				if item.ID != "" {
+					if _, ok := seen[item.ID]; ok {
+						continue
+					}
+					seen[item.ID] = item.Timestamp
+				}
+				if err := fn(item); err != nil {
+					return err
+				}
+			}
+			from = to
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(t.Interval):
+		}
+	}
+}
+
+// fetch returns the items of a window, following cursors.
+func (t *Tail) fetch(ctx _context.Context, from, to time.Time) ([]Item, error) {
+	var items []Item
+	cursor := ""
+	for {
+		page, next, err := t.source.List(ctx, t.query, from, to, cursor, t.pageSize())
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+		if next == "" || len(page) == 0 {
+			break
+		}
+		cursor = next
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Timestamp.Before(items[j].Timestamp)
+	})
+	return items, nil
+}
+
+func (t *Tail) pageSize() int32 {
+	if t.PageSize <= 0 {
+		return defaultPageSize
+	}
+	return t.PageSize
+}
+
+// Result is an item of a stream, or the error that ended it.
+type Result struct {
+	Item Item
+	Err  error
+}
+
+// Stream runs the tail in the background and returns a channel of its items,
+// and a function stopping it. The channel is closed once the tail stops; the
+// last result has the error that stopped it, unless it was stopped by the
+// context or the function.
+func (t *Tail) Stream(ctx _context.Context) (<-chan Result, func()) {
+	ctx, cancel := _context.WithCancel(ctx)
+	results := make(chan Result, t.pageSize())
+	go func() {
+		defer close(results)
+		err := t.Run(ctx, func(item Item) error {
+			select {
+			case results <- Result{Item: item}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			select {
+			case results <- Result{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return results, cancel
+}
@@ -0,0 +1,228 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/allowlist"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func snapshot() *allowlist.Snapshot {
+	return allowlist.NewSnapshot(datadogV1.IPRanges{
+		Modified: datadog.PtrString("2023-06-22-16-00-00"),
+		Agents:   &datadogV1.IPPrefixesAgents{PrefixesIpv4: []string{"192.0.2.0/24"}},
+		Logs:     &datadogV1.IPPrefixesLogs{PrefixesIpv4: []string{"198.51.100.0/24", "192.0.2.0/24"}, PrefixesIpv6: []string{"2001:db8::/32"}},
+		Webhooks: &datadogV1.IPPrefixesWebhooks{PrefixesIpv4: []string{"203.0.113.7/32"}},
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{
+			"modified": "2023-07-01-08-00-00",
+			"version":  42,
+			"agents":   map[string]interface{}{"prefixes_ipv4": []string{"192.0.2.0/24"}},
+			"logs":     map[string]interface{}{"prefixes_ipv4": []string{"192.0.2.0/24", "192.0.2.0/24"}, "prefixes_ipv6": []string{"2001:db8::/32"}},
+			"webhooks": map[string]interface{}{"prefixes_ipv4": []string{"203.0.113.8/32"}},
+		})
+	})
+	ctx := tests.Serve(t, mux)
+	assert := tests.Assert(ctx, t)
+
+	current, err := allowlist.Fetch(ctx, datadogV1.NewIPRangesApi(datadog.NewAPIClient(datadog.NewConfiguration())))
+	assert.NoError(err)
+	assert.Equal(int64(42), current.Version)
+	assert.Equal(&allowlist.Prefixes{IPv4: []string{"192.0.2.0/24"}, IPv6: []string{"2001:db8::/32"}}, current.Products[allowlist.ProductLogs])
+
+	path := filepath.Join(t.TempDir(), "ip-ranges.json")
+	previous, err := allowlist.LoadSnapshot(path)
+	assert.NoError(err)
+	diff, err := current.Diff(previous, allowlist.ProductAgents)
+	assert.NoError(err)
+	assert.Equal([]allowlist.Prefix{{Product: allowlist.ProductAgents, Prefix: "192.0.2.0/24"}}, diff.Added)
+
+	assert.NoError(snapshot().Save(path))
+	previous, err = allowlist.LoadSnapshot(path)
+	assert.NoError(err)
+	assert.Equal(snapshot(), previous)
+	diff, err = current.Diff(previous)
+	assert.NoError(err)
+	var text bytes.Buffer
+	assert.NoError(diff.WriteText(&text))
+	assert.Equal(`IP ranges modified 2023-06-22-16-00-00 -> 2023-07-01-08-00-00
++ webhooks 203.0.113.8/32
+- logs 198.51.100.0/24
+- webhooks 203.0.113.7/32
+
+1 added, 2 removed.
+`, text.String())
+
+	diff, err = previous.Diff(previous)
+	assert.NoError(err)
+	assert.True(diff.Empty())
+	_, err = previous.Diff(current)
+	assert.EqualError(err, "snapshot modified at 2023-06-22-16-00-00 is older than the previous one, modified at 2023-07-01-08-00-00")
+}
+
+func TestGenerate(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	s := snapshot()
+	generate := func(g *allowlist.Generator) string {
+		var b bytes.Buffer
+		assert.NoError(g.Generate(&b, s))
+		return b.String()
+	}
+
+	g := allowlist.NewGenerator(allowlist.FormatIPTables)
+	assert.Equal(`#!/bin/sh
+# Datadog IP ranges modified 2023-06-22-16-00-00.
+set -e
+iptables -N DATADOG-EGRESS 2>/dev/null || iptables -F DATADOG-EGRESS
+iptables -A DATADOG-EGRESS -d 192.0.2.0/24 -p tcp --dport 443 -m comment --comment "datadog agents,logs" -j ACCEPT
+iptables -A DATADOG-EGRESS -d 198.51.100.0/24 -p tcp --dport 443 -m comment --comment "datadog logs" -j ACCEPT
+ip6tables -N DATADOG-EGRESS 2>/dev/null || ip6tables -F DATADOG-EGRESS
+ip6tables -A DATADOG-EGRESS -d 2001:db8::/32 -p tcp --dport 443 -m comment --comment "datadog logs" -j ACCEPT
+iptables -N DATADOG-INGRESS 2>/dev/null || iptables -F DATADOG-INGRESS
+iptables -A DATADOG-INGRESS -s 203.0.113.7/32 -p tcp --dport 443 -m comment --comment "datadog webhooks" -j ACCEPT
+`, generate(g))
+
+	g = allowlist.NewGenerator(allowlist.FormatNFTables, allowlist.ProductLogs, allowlist.ProductWebhooks)
+	g.Ports = []int{443, 10516}
+	assert.Equal(`# Datadog IP ranges modified 2023-06-22-16-00-00.
+table inet datadog
+delete table inet datadog
+
+table inet datadog {
+	set egress_ipv4 {
+		type ipv4_addr
+		flags interval
+		elements = {
+			192.0.2.0/24,
+			198.51.100.0/24,
+		}
+	}
+
+	set egress_ipv6 {
+		type ipv6_addr
+		flags interval
+		elements = {
+			2001:db8::/32,
+		}
+	}
+
+	set ingress_ipv4 {
+		type ipv4_addr
+		flags interval
+		elements = {
+			203.0.113.7/32,
+		}
+	}
+
+	chain egress {
+		ip daddr @egress_ipv4 tcp dport { 443, 10516 } accept
+		ip6 daddr @egress_ipv6 tcp dport { 443, 10516 } accept
+	}
+
+	chain ingress {
+		ip saddr @ingress_ipv4 tcp dport { 443, 10516 } accept
+	}
+}
+`, generate(g))
+
+	g = allowlist.NewGenerator(allowlist.FormatAWSSecurityGroup, allowlist.ProductLogs, allowlist.ProductWebhooks)
+	var group map[string][]map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(generate(g)), &group))
+	assert.Len(group["IpPermissions"], 1)
+	assert.Equal([]interface{}{map[string]interface{}{"CidrIp": "203.0.113.7/32", "Description": "datadog webhooks"}}, group["IpPermissions"][0]["IpRanges"])
+	assert.Len(group["IpPermissionsEgress"][0]["IpRanges"], 2)
+	assert.Equal([]interface{}{map[string]interface{}{"CidrIpv6": "2001:db8::/32", "Description": "datadog logs"}}, group["IpPermissionsEgress"][0]["Ipv6Ranges"])
+
+	g = allowlist.NewGenerator(allowlist.FormatGCPFirewall, allowlist.ProductLogs)
+	var firewalls []map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(generate(g)), &firewalls))
+	assert.Len(firewalls, 2)
+	assert.Equal("datadog-egress-ipv4", firewalls[0]["name"])
+	assert.Equal("EGRESS", firewalls[0]["direction"])
+	assert.Equal([]interface{}{"192.0.2.0/24", "198.51.100.0/24"}, firewalls[0]["destinationRanges"])
+	assert.Equal([]interface{}{"2001:db8::/32"}, firewalls[1]["destinationRanges"])
+
+	g = allowlist.NewGenerator(allowlist.FormatNetworkPolicy, allowlist.ProductAgents, allowlist.ProductWebhooks)
+	g.Namespace, g.PodSelector = "monitoring", map[string]string{"app": "agent"}
+	assert.Equal(`apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: datadog
+  namespace: monitoring
+spec:
+  podSelector:
+    matchLabels:
+      app: agent
+  policyTypes:
+    - Egress
+    - Ingress
+  egress:
+    - to:
+        - ipBlock:
+            cidr: 192.0.2.0/24
+      ports:
+        - protocol: TCP
+          port: 443
+  ingress:
+    - from:
+        - ipBlock:
+            cidr: 203.0.113.7/32
+      ports:
+        - protocol: TCP
+          port: 443
+`, generate(g))
+
+	g = allowlist.NewGenerator(allowlist.FormatNginx)
+	assert.Equal(`# Datadog IP ranges modified 2023-06-22-16-00-00.
+allow 203.0.113.7/32; # datadog webhooks
+deny all;
+`, generate(g))
+	err := allowlist.NewGenerator(allowlist.FormatNginx, allowlist.ProductLogs).Generate(&bytes.Buffer{}, s)
+	assert.EqualError(err, "nginx allows incoming traffic only, and no ingress product has IP ranges")
+
+	s = allowlist.NewSnapshot(datadogV1.IPRanges{
+		Modified: datadog.PtrString("2023-06-22-16-00-00"),
+		Agents:   &datadogV1.IPPrefixesAgents{PrefixesIpv4: []string{"192.0.2.128/25", "198.51.100.0/24"}},
+		Logs:     &datadogV1.IPPrefixesLogs{PrefixesIpv4: []string{"192.0.2.0/24", "198.51.100.7/32"}},
+	})
+	g = allowlist.NewGenerator(allowlist.FormatNFTables, allowlist.ProductAgents, allowlist.ProductLogs)
+	assert.Equal(`# Datadog IP ranges modified 2023-06-22-16-00-00.
+table inet datadog
+delete table inet datadog
+
+table inet datadog {
+	set egress_ipv4 {
+		type ipv4_addr
+		flags interval
+		elements = {
+			198.51.100.0/24,
+			192.0.2.0/24,
+		}
+	}
+
+	chain egress {
+		ip daddr @egress_ipv4 tcp dport { 443 } accept
+	}
+}
+`, generate(g))
+
+	assert.EqualError(allowlist.NewGenerator("pf").Generate(&bytes.Buffer{}, s), `unsupported format "pf"`)
+}
@@ -0,0 +1,280 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/audit"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func auditEvent(id string, ts time.Time, action string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   id,
+		"type": "audit",
+		"attributes": map[string]interface{}{
+			"service":   "datadog",
+			"timestamp": ts,
+			"tags":      []string{"env:prod"},
+			"attributes": map[string]interface{}{
+				"status":  "info",
+				"action":  action,
+				"evt":     map[string]interface{}{"name": "Dashboard"},
+				"usr":     map[string]interface{}{"id": "u-1", "email": "jane@example.com"},
+				"network": map[string]interface{}{"client": map[string]interface{}{"ip": "10.0.0.1"}},
+				"http":    map[string]interface{}{"method": "PUT", "status_code": 200, "url_details": map[string]interface{}{"path": "/api/v1/dashboard/abc"}},
+				"asset":   map[string]interface{}{"type": "dashboard", "name": "Ops | prod", "id": "abc"},
+			},
+		},
+	}
+}
+
+type server struct {
+	mu     sync.Mutex
+	events []map[string]interface{}
+}
+
+func (s *server) add(events ...map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+}
+
+func serve(t *testing.T, s *server) context.Context {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/audit/events/search", func(w http.ResponseWriter, r *http.Request) {
+		var body datadogV2.AuditLogsSearchEventsRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		filter := body.GetFilter()
+		from, _ := time.Parse(time.RFC3339, filter.GetFrom())
+		to, _ := time.Parse(time.RFC3339, filter.GetTo())
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var data []interface{}
+		for _, e := range s.events {
+			ts := e["attributes"].(map[string]interface{})["timestamp"].(time.Time)
+			if !ts.Before(from) && ts.Before(to) {
+				data = append(data, e)
+			}
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": data})
+	})
+	return tests.Serve(t, mux)
+}
+
+// sink records the events sent, and cancels the export after n events.
+type sink struct {
+	records []string
+	n       int
+	cancel  func()
+	// state is the state file, and saved whether it existed at every send.
+	state string
+	saved []bool
+}
+
+func (s *sink) Send(record []byte) error {
+	s.records = append(s.records, string(record))
+	_, err := os.Stat(s.state)
+	s.saved = append(s.saved, err == nil)
+	if len(s.records) == s.n {
+		s.cancel()
+	}
+	return nil
+}
+
+func export(ctx context.Context, t *testing.T, state string, n int) *sink {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s := &sink{n: n, cancel: cancel, state: state}
+	api := datadogV2.NewAuditApi(datadog.NewAPIClient(datadog.NewConfiguration()))
+	exporter := audit.NewExporter(api, "@evt.name:Dashboard", audit.NewEncoder(audit.FormatCEF), s)
+	exporter.StatePath = state
+	exporter.Tail.Start = base
+	exporter.Tail.Interval = time.Millisecond
+	exporter.Tail.Delay = 0
+	now := base
+	exporter.Tail.Now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	err := exporter.Run(ctx)
+	if err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestExporter(t *testing.T) {
+	s := &server{}
+	s.add(
+		auditEvent("e1", base.Add(10*time.Second), "created"),
+		auditEvent("e2", base.Add(20*time.Second), "modified"),
+		auditEvent("e3", base.Add(20*time.Second), "deleted"),
+	)
+	ctx := serve(t, s)
+	assert := tests.Assert(ctx, t)
+	state := filepath.Join(t.TempDir(), "audit.json")
+
+	exported := export(ctx, t, state, 3)
+	records := exported.records
+	assert.Len(records, 3)
+	assert.Equal([]bool{false, false, false}, exported.saved)
+	assert.Contains(records[0], "externalId=e1")
+	checkpoint, err := audit.LoadCheckpoint(state)
+	assert.NoError(err)
+	assert.Equal(audit.Checkpoint{Timestamp: base.Add(20 * time.Second), IDs: []string{"e2", "e3"}}, checkpoint)
+
+	s.add(auditEvent("e4", base.Add(30*time.Second), "accessed"))
+	records = export(ctx, t, state, 1).records
+	assert.Len(records, 1)
+	assert.Contains(records[0], "externalId=e4")
+	checkpoint, err = audit.LoadCheckpoint(state)
+	assert.NoError(err)
+	assert.Equal([]string{"e4"}, checkpoint.IDs)
+}
+
+func TestEncoder(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	data, _ := json.Marshal(auditEvent("e1", base, "modified"))
+	var event datadogV2.AuditLogsEvent
+	assert.NoError(json.Unmarshal(data, &event))
+
+	encoder := audit.NewEncoder(audit.FormatCEF)
+	record, err := encoder.Encode(event)
+	assert.NoError(err)
+	assert.Equal(`CEF:0|Datadog|Audit Trail|1.0|Dashboard|Dashboard modified|3|rt=1709294400000 externalId=e1 cat=Dashboard act=modified suser=jane@example.com suid=u-1 src=10.0.0.1 requestMethod=PUT request=/api/v1/dashboard/abc outcome=200 cs1Label=assetType cs1=dashboard cs2Label=assetName cs2=Ops | prod cs3Label=assetId cs3=abc`, string(record))
+
+	encoder.Format = audit.FormatLEEF
+	record, err = encoder.Encode(event)
+	assert.NoError(err)
+	assert.Equal("LEEF:1.0|Datadog|Audit Trail|1.0|modified|devTime=Mar 01 2024 12:00:00.000\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS\tcat=Dashboard\tsev=3\tusrName=jane@example.com\tsrc=10.0.0.1\turl=/api/v1/dashboard/abc\teventId=e1\taction=modified\thttpMethod=PUT\thttpStatus=200\tassetType=dashboard\tassetName=Ops \\| prod\tassetId=abc\tservice=datadog", string(record))
+
+	encoder.Format = audit.FormatOCSF
+	record, err = encoder.Encode(event)
+	assert.NoError(err)
+	var ocsf map[string]interface{}
+	assert.NoError(json.Unmarshal(record, &ocsf))
+	assert.Equal(600303.0, ocsf["type_uid"])
+	assert.Equal("Update", ocsf["activity_name"])
+	assert.Equal(1709294400000.0, ocsf["time"])
+	assert.Equal(map[string]interface{}{"user": map[string]interface{}{"uid": "u-1", "email_addr": "jane@example.com"}}, ocsf["actor"])
+	assert.Equal([]interface{}{map[string]interface{}{"type": "dashboard", "name": "Ops | prod", "uid": "abc"}}, ocsf["resources"])
+
+	encoder.Format = audit.FormatSyslog
+	encoder.Hostname = "collector host"
+	record, err = encoder.Encode(event)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(string(record), `<110>1 2024-03-01T12:00:00.000Z collectorhost datadog-audit - Dashboard - {"attributes":{`), string(record))
+
+	encoder.Format = "xml"
+	_, err = encoder.Encode(event)
+	assert.EqualError(err, `unsupported format "xml"`)
+}
+
+func TestSyslogSink(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var frames []string
+		for len(frames) < 2 {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			io.ReadFull(r, msg)
+			frames = append(frames, length+string(msg))
+		}
+		received <- strings.Join(frames, "")
+	}()
+
+	sink, err := audit.DialSyslog("tcp", listener.Addr().String())
+	assert.NoError(err)
+	defer sink.Close()
+	assert.NoError(sink.Send([]byte("<110>1 first")))
+	assert.NoError(sink.Send([]byte("<110>1 second")))
+	assert.Equal("12 <110>1 first13 <110>1 second", <-received)
+
+	_, err = audit.DialSyslog("unix", "/tmp/syslog")
+	assert.EqualError(err, `unsupported network "unix"`)
+}
+
+func TestSyslogSinkPartialWrite(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer listener.Close()
+	record := []byte(strings.Repeat("x", 4<<20))
+	frame := strconv.Itoa(len(record)) + " " + string(record)
+	received := make(chan string, 1)
+	var mu sync.Mutex
+	conns := 0
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns++
+			mu.Unlock()
+			go func() {
+				defer conn.Close()
+				// Read slowly, so that writes time out after sending part of
+				// the frame.
+				var data []byte
+				chunk := make([]byte, 64<<10)
+				for len(data) < len(frame) {
+					time.Sleep(2 * time.Millisecond)
+					n, err := conn.Read(chunk)
+					data = append(data, chunk[:n]...)
+					if err != nil {
+						return
+					}
+				}
+				received <- string(data)
+			}()
+		}
+	}()
+
+	sink, err := audit.DialSyslog("tcp", listener.Addr().String())
+	assert.NoError(err)
+	defer sink.Close()
+	sink.Timeout = 20 * time.Millisecond
+	assert.NoError(sink.Send(record))
+	assert.True(<-received == frame)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(1, conns)
+}
@@ -0,0 +1,413 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/backup"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+// source serves the org backed up.
+func source(t *testing.T) context.Context {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/monitor", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "0" {
+			tests.WriteJSON(w, []interface{}{})
+			return
+		}
+		tests.WriteJSON(w, []interface{}{
+			map[string]interface{}{"id": 11, "name": "CPU", "type": "metric alert", "query": "avg(last_5m):avg:system.cpu.user{*} > 90",
+				"created": "2024-01-01T00:00:00Z", "creator": map[string]interface{}{"email": "a@example.com"}, "overall_state": "OK",
+				"restricted_roles": []string{"role-1"}},
+			map[string]interface{}{"id": 12, "name": "Disk", "type": "metric alert", "query": "avg(last_5m):avg:system.disk.in_use{*} > 0.9"},
+			map[string]interface{}{"id": 13, "name": "Host down", "type": "composite", "query": "11 && 15"},
+			map[string]interface{}{"id": 14, "name": "Synthetics", "type": "synthetics alert", "query": "n/a"},
+			map[string]interface{}{"id": 15, "name": "Disk or CPU", "type": "composite", "query": "12 || 11"},
+		})
+	})
+	mux.HandleFunc("/api/v1/slo", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "slo-1", "name": "Availability", "type": "monitor", "monitor_ids": []int{11, 12},
+				"thresholds": []interface{}{map[string]interface{}{"timeframe": "7d", "target": 99.9}}, "created_at": 1700000000},
+		}})
+	})
+	mux.HandleFunc("/api/v1/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"dashboards": []interface{}{map[string]interface{}{"id": "abc-def-ghi"}}})
+	})
+	mux.HandleFunc("/api/v1/dashboard/abc-def-ghi", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{
+			"id": "abc-def-ghi", "title": "Service", "layout_type": "ordered", "author_handle": "a@example.com",
+			"url": "/dashboard/abc-def-ghi/service",
+			"widgets": []interface{}{
+				map[string]interface{}{"id": 1, "definition": map[string]interface{}{"type": "alert_graph", "alert_id": "11", "viz_type": "timeseries"}},
+				map[string]interface{}{"id": 2, "definition": map[string]interface{}{"type": "slo", "slo_id": "slo-1", "view_type": "detail", "view_mode": "overall", "time_windows": []string{"7d"}}},
+			},
+		})
+	})
+	mux.HandleFunc("/api/v1/dashboard/lists/manual", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"dashboard_lists": []interface{}{
+			map[string]interface{}{"id": 7, "name": "Team", "type": "manual_dashboard_list"},
+			map[string]interface{}{"id": 8, "name": "Integrations", "type": "automatic_dashboard_list"},
+		}})
+	})
+	mux.HandleFunc("/api/v2/dashboard/lists/manual/7/dashboards", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"dashboards": []interface{}{
+			map[string]interface{}{"id": "abc-def-ghi", "type": "custom_timeboard"},
+			map[string]interface{}{"id": "30", "type": "integration_timeboard"},
+		}})
+	})
+	mux.HandleFunc("/api/v1/logs/config/pipelines", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, []interface{}{
+			map[string]interface{}{"id": "p1", "name": "Nginx", "type": "pipeline", "is_read_only": true, "filter": map[string]interface{}{"query": "source:nginx"}},
+			map[string]interface{}{"id": "p2", "name": "App", "type": "pipeline", "is_enabled": true, "filter": map[string]interface{}{"query": "service:app"}, "processors": []interface{}{}},
+		})
+	})
+	mux.HandleFunc("/api/v1/logs/config/pipeline-order", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"pipeline_ids": []string{"p2", "p1"}})
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"tests": []interface{}{
+			map[string]interface{}{"public_id": "aaa-aaa-aaa", "name": "Login", "type": "api"},
+		}})
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/api/aaa-aaa-aaa", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{
+			"public_id": "aaa-aaa-aaa", "name": "Login", "type": "api", "subtype": "http", "message": "", "monitor_id": 14,
+			"locations": []string{"aws:eu-west-1"}, "options": map[string]interface{}{"tick_every": 300},
+			"config": map[string]interface{}{
+				"request":         map[string]interface{}{"method": "POST", "url": "https://example.com/login"},
+				"assertions":      []interface{}{},
+				"configVariables": []interface{}{map[string]interface{}{"name": "PASSWORD", "type": "global", "id": "var-1"}},
+			},
+		})
+	})
+	mux.HandleFunc("/api/v1/synthetics/variables", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"variables": []interface{}{
+			map[string]interface{}{"id": "var-1", "name": "PASSWORD", "description": "", "tags": []string{}, "value": map[string]interface{}{"value": "secret"}},
+			map[string]interface{}{"id": "var-2", "name": "SESSION", "description": "", "tags": []string{}, "value": map[string]interface{}{"value": ""},
+				"parse_test_public_id": "aaa-aaa-aaa"},
+		}})
+	})
+	return tests.Serve(t, mux)
+}
+
+// collection holds the objects of a kind of the org restored to. Objects are
+// listed with GET and created with POST on the path of the collection, and
+// read with GET and updated with PUT on the path of an object.
+type collection struct {
+	idField string
+	objects []map[string]interface{}
+	created []map[string]interface{}
+	updated []map[string]interface{}
+}
+
+func (c *collection) get(id string) int {
+	for i, o := range c.objects {
+		if fmt.Sprint(o[c.idField]) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// serveCollection serves a collection. newID returns the ID of the n-th object created,
+// list the body of a listing and one the body of an object.
+func serveCollection(mux *http.ServeMux, path, idField string, newID func(n int) interface{}, list func([]map[string]interface{}) interface{}, one func(map[string]interface{}) interface{}) *collection {
+	c := &collection{idField: idField}
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			tests.WriteJSON(w, list(c.objects))
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		body[idField] = newID(len(c.created) + 1)
+		c.created = append(c.created, body)
+		c.objects = append(c.objects, body)
+		tests.WriteJSON(w, one(body))
+	})
+	mux.HandleFunc(path+"/", func(w http.ResponseWriter, r *http.Request) {
+		i := c.get(strings.TrimPrefix(r.URL.Path, path+"/"))
+		if i < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			tests.WriteJSON(w, map[string]interface{}{"errors": []string{"Not found"}})
+			return
+		}
+		if r.Method == http.MethodPut {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			body[idField] = c.objects[i][idField]
+			c.updated = append(c.updated, body)
+			c.objects[i] = body
+		}
+		tests.WriteJSON(w, one(c.objects[i]))
+	})
+	return c
+}
+
+func itself(o map[string]interface{}) interface{} {
+	return o
+}
+
+// target is the org restored to.
+type target struct {
+	monitors, slos, dashboards, lists, pipelines, tests, variables *collection
+	// items are the dashboards of the dashboard lists by list ID.
+	items map[string][]interface{}
+	order []string
+}
+
+// newTarget serves the org restored to, which keeps the objects restored.
+func newTarget(t *testing.T) (context.Context, *target) {
+	s := &target{items: map[string][]interface{}{}}
+	mux := http.NewServeMux()
+	s.monitors = serveCollection(mux, "/api/v1/monitor", "id", func(n int) interface{} { return 100 + n },
+		func(objects []map[string]interface{}) interface{} { return objects }, itself)
+	slo := func(o map[string]interface{}) interface{} { return map[string]interface{}{"data": []interface{}{o}} }
+	s.slos = serveCollection(mux, "/api/v1/slo", "id", func(n int) interface{} { return fmt.Sprintf("new-slo-%d", n) },
+		func(objects []map[string]interface{}) interface{} { return map[string]interface{}{"data": objects} }, slo)
+	s.dashboards = serveCollection(mux, "/api/v1/dashboard", "id", func(n int) interface{} { return fmt.Sprintf("new-dash-%d", n) },
+		func(objects []map[string]interface{}) interface{} {
+			var summaries []interface{}
+			for _, o := range objects {
+				summaries = append(summaries, map[string]interface{}{"id": o["id"], "title": o["title"]})
+			}
+			return map[string]interface{}{"dashboards": summaries}
+		}, itself)
+	s.lists = serveCollection(mux, "/api/v1/dashboard/lists/manual", "id", func(n int) interface{} { return 70 + n },
+		func(objects []map[string]interface{}) interface{} {
+			for _, o := range objects {
+				o["type"] = "manual_dashboard_list"
+			}
+			return map[string]interface{}{"dashboard_lists": objects}
+		}, itself)
+	mux.HandleFunc("/api/v2/dashboard/lists/manual/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/dashboard/lists/manual/"), "/dashboards")
+		if r.Method == http.MethodPut {
+			var body map[string][]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			s.items[id] = body["dashboards"]
+		}
+		tests.WriteJSON(w, map[string]interface{}{"dashboards": s.items[id]})
+	})
+	s.pipelines = serveCollection(mux, "/api/v1/logs/config/pipelines", "id", func(n int) interface{} { return fmt.Sprintf("new-p%d", n) },
+		func(objects []map[string]interface{}) interface{} {
+			return append([]map[string]interface{}{{"id": "t1", "name": "Nginx", "is_read_only": true, "filter": map[string]interface{}{}}}, objects...)
+		}, itself)
+	mux.HandleFunc("/api/v1/logs/config/pipeline-order", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var body map[string][]string
+			json.NewDecoder(r.Body).Decode(&body)
+			s.order = body["pipeline_ids"]
+		}
+		ids := []string{"t1"}
+		for _, p := range s.pipelines.objects {
+			ids = append(ids, p["id"].(string))
+		}
+		tests.WriteJSON(w, map[string]interface{}{"pipeline_ids": ids})
+	})
+	s.tests = serveCollection(mux, "/api/v1/synthetics/tests/api", "public_id", func(n int) interface{} { return fmt.Sprintf("new-tst-%03d", n) },
+		func(objects []map[string]interface{}) interface{} { return objects }, itself)
+	mux.HandleFunc("/api/v1/synthetics/tests", func(w http.ResponseWriter, r *http.Request) {
+		var summaries []interface{}
+		for _, o := range s.tests.objects {
+			summaries = append(summaries, map[string]interface{}{"public_id": o["public_id"], "name": o["name"], "type": o["type"]})
+		}
+		tests.WriteJSON(w, map[string]interface{}{"tests": summaries})
+	})
+	s.variables = serveCollection(mux, "/api/v1/synthetics/variables", "id", func(n int) interface{} { return fmt.Sprintf("new-var-%d", n) },
+		func(objects []map[string]interface{}) interface{} {
+			return map[string]interface{}{"variables": objects}
+		}, itself)
+	return tests.Serve(t, mux), s
+}
+
+func TestExportRestore(t *testing.T) {
+	ctx := source(t)
+	assert := tests.Assert(ctx, t)
+	dir := t.TempDir()
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "monitors"), 0o755))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "monitors", "99.json"), []byte("{}"), 0o644))
+
+	b := backup.New(datadog.NewAPIClient(datadog.NewConfiguration()))
+	b.Kinds = []string{"monitors", "slos", "dashboards", "dashboard_lists", "logs_pipelines", "logs_pipeline_order"}
+	report, err := b.Export(ctx, dir)
+	assert.NoError(err)
+	assert.Equal(map[string]int{"monitors": 4, "slos": 1, "dashboards": 1, "dashboard_lists": 1, "logs_pipelines": 1, "logs_pipeline_order": 1}, report.Counts)
+
+	var files []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	assert.Equal([]string{
+		"dashboard_lists/7.json",
+		"dashboards/abc-def-ghi.json",
+		"logs_pipeline_order.json",
+		"logs_pipelines/p2.json",
+		"monitors/11.json",
+		"monitors/12.json",
+		"monitors/13.json",
+		"monitors/15.json",
+		"slos/slo-1.json",
+	}, files)
+
+	monitor, err := os.ReadFile(filepath.Join(dir, "monitors", "11.json"))
+	assert.NoError(err)
+	assert.Equal(`{
+  "name": "CPU",
+  "query": "avg(last_5m):avg:system.cpu.user{*} > 90",
+  "restricted_roles": [
+    "role-1"
+  ],
+  "type": "metric alert"
+}
+`, string(monitor))
+	dashboard, err := os.ReadFile(filepath.Join(dir, "dashboards", "abc-def-ghi.json"))
+	assert.NoError(err)
+	assert.NotContains(string(dashboard), `"id"`)
+	assert.NotContains(string(dashboard), "author_handle")
+
+	again, err := b.Export(ctx, dir)
+	assert.NoError(err)
+	assert.Equal(report.Counts, again.Counts)
+	unchanged, _ := os.ReadFile(filepath.Join(dir, "monitors", "11.json"))
+	assert.Equal(monitor, unchanged)
+
+	targetCtx, s := newTarget(t)
+	b.Kinds = append(b.Kinds, "roles")
+	report, err = b.Restore(targetCtx, dir)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"11": "101", "12": "102", "13": "104", "15": "103"}, report.IDs["monitors"])
+	assert.Equal(map[string]string{"slo-1": "new-slo-1"}, report.IDs["slos"])
+
+	// Composite monitors come after the composite monitors they compose.
+	monitors := s.monitors.created
+	assert.Equal("Disk or CPU", monitors[2]["name"])
+	assert.Equal("102 || 101", monitors[2]["query"])
+	assert.Equal("101 && 103", monitors[3]["query"])
+	assert.Equal([]interface{}{"role-1"}, monitors[0]["restricted_roles"])
+	assert.Equal([]string{"monitors/11: roles role-1 is not in the backup"}, report.Warnings)
+	assert.Equal([]interface{}{101.0, 102.0}, s.slos.created[0]["monitor_ids"])
+
+	widgets := s.dashboards.created[0]["widgets"].([]interface{})
+	assert.Equal("101", widgets[0].(map[string]interface{})["definition"].(map[string]interface{})["alert_id"])
+	assert.Equal("new-slo-1", widgets[1].(map[string]interface{})["definition"].(map[string]interface{})["slo_id"])
+
+	assert.Equal("Team", s.lists.created[0]["name"])
+	assert.Equal([]interface{}{
+		map[string]interface{}{"id": "new-dash-1", "type": "custom_timeboard"},
+		map[string]interface{}{"id": "30", "type": "integration_timeboard"},
+	}, s.items["71"])
+
+	assert.Equal([]string{"new-p1", "t1"}, s.order)
+
+	// Restoring again updates the objects restored the first time.
+	again, err = b.Restore(targetCtx, dir)
+	assert.NoError(err)
+	assert.Equal(report.IDs, again.IDs)
+	for _, c := range []*collection{s.monitors, s.slos, s.dashboards, s.pipelines} {
+		assert.Len(c.objects, len(c.created))
+		assert.Len(c.updated, len(c.created))
+	}
+	assert.Equal("101 && 103", s.monitors.objects[3]["query"])
+	assert.Len(s.lists.objects, 1)
+	assert.Len(s.items["71"], 2)
+}
+
+func TestRestoreCompositeCycle(t *testing.T) {
+	ctx, s := newTarget(t)
+	assert := tests.Assert(ctx, t)
+	dir := t.TempDir()
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "monitors"), 0o755))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "monitors", "1.json"), []byte(`{"name": "A", "type": "composite", "query": "2 && 3"}`), 0o644))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "monitors", "2.json"), []byte(`{"name": "B", "type": "composite", "query": "1 || 3"}`), 0o644))
+
+	b := backup.New(datadog.NewAPIClient(datadog.NewConfiguration()))
+	_, err := b.Restore(ctx, dir)
+	assert.EqualError(err, "restoring monitors: 1: composite monitors compose each other")
+	assert.Empty(s.monitors.created)
+}
+
+func TestRestoreSynthetics(t *testing.T) {
+	ctx := source(t)
+	assert := tests.Assert(ctx, t)
+	dir := t.TempDir()
+
+	b := backup.New(datadog.NewAPIClient(datadog.NewConfiguration()))
+	b.Kinds = []string{"synthetics_global_variables", "synthetics_tests"}
+	_, err := b.Export(ctx, dir)
+	assert.NoError(err)
+
+	targetCtx, s := newTarget(t)
+	report, err := b.Restore(targetCtx, dir)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"aaa-aaa-aaa": "new-tst-001"}, report.IDs["synthetics_tests"])
+	assert.Equal(map[string]string{"var-1": "new-var-1", "var-2": "new-var-2"}, report.IDs["synthetics_global_variables"])
+	assert.Empty(report.Warnings)
+
+	// The test is created before the variable parsed from it, then updated
+	// with the variable it uses.
+	assert.Nil(s.tests.created[0]["config"].(map[string]interface{})["configVariables"])
+	assert.Equal("new-tst-001", s.variables.created[1]["parse_test_public_id"])
+	assert.Len(s.tests.updated, 1)
+	assert.Equal([]interface{}{map[string]interface{}{"name": "PASSWORD", "type": "global", "id": "new-var-1"}},
+		s.tests.objects[0]["config"].(map[string]interface{})["configVariables"])
+
+	again, err := b.Restore(targetCtx, dir)
+	assert.NoError(err)
+	assert.Equal(report.IDs, again.IDs)
+	assert.Len(s.tests.objects, 1)
+	assert.Len(s.variables.objects, 2)
+	assert.Len(s.variables.updated, 2)
+}
+
+func TestExportYAML(t *testing.T) {
+	ctx := source(t)
+	assert := tests.Assert(ctx, t)
+	dir := t.TempDir()
+
+	b := backup.New(datadog.NewAPIClient(datadog.NewConfiguration()))
+	b.Kinds = []string{"slos"}
+	b.Format = backup.FormatYAML
+	_, err := b.Export(ctx, dir)
+	assert.NoError(err)
+	slo, err := os.ReadFile(filepath.Join(dir, "slos", "slo-1.yaml"))
+	assert.NoError(err)
+	assert.Equal(`monitor_ids:
+  - 11
+  - 12
+name: Availability
+thresholds:
+  - target: 99.9
+    timeframe: 7d
+type: monitor
+`, string(slo))
+
+	targetCtx, s := newTarget(t)
+	report, err := b.Restore(targetCtx, dir)
+	assert.NoError(err)
+	assert.Equal(1, report.Counts["slos"])
+	assert.Equal([]interface{}{11.0, 12.0}, s.slos.created[0]["monitor_ids"])
+
+	b.Kinds = []string{"alerts"}
+	_, err = b.Export(ctx, dir)
+	assert.EqualError(err, `unknown kind "alerts"`)
+}
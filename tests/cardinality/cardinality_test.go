@@ -0,0 +1,189 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/cardinality"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func tags(key string, n int) []string {
+	var tags []string
+	for i := 0; i < n; i++ {
+		tags = append(tags, key+":"+string(rune('a'+i)))
+	}
+	return tags
+}
+
+type metric struct {
+	volumes map[string]interface{}
+	tags    []string
+	active  []string
+}
+
+var metrics = map[string]metric{
+	"app.requests": {
+		volumes: map[string]interface{}{"type": "metric_volumes", "attributes": map[string]interface{}{"ingested_volume": 5000, "indexed_volume": 4000}},
+		tags:    append(append(append(tags("env", 2), tags("host", 5)...), tags("pod", 10)...), "service:web"),
+	},
+	"app.latency": {
+		volumes: map[string]interface{}{"type": "distinct_metric_volumes", "attributes": map[string]interface{}{"distinct_volume": 900}},
+		tags:    append(append(tags("env", 1), tags("host", 3)...), "service:web"),
+		active:  []string{"service"},
+	},
+	"app.errors": {
+		volumes: map[string]interface{}{"type": "metric_volumes", "attributes": map[string]interface{}{"ingested_volume": 300, "indexed_volume": 300}},
+		tags:    tags("host", 3),
+	},
+	"app.unused": {
+		volumes: map[string]interface{}{"type": "metric_volumes", "attributes": map[string]interface{}{"ingested_volume": 100, "indexed_volume": 100}},
+		tags:    tags("env", 1),
+	},
+}
+
+type server struct {
+	requests   []string
+	metricType string
+}
+
+func newAdvisor(t *testing.T, s *server) (context.Context, *cardinality.Advisor) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/metrics", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"type": "metrics", "id": "app.requests"},
+			map[string]interface{}{"type": "manage_tags", "id": "app.latency",
+				"attributes": map[string]interface{}{"metric_type": "distribution", "tags": []string{"env", "host", "service"}}},
+			map[string]interface{}{"type": "metrics", "id": "app.errors"},
+			map[string]interface{}{"type": "metrics", "id": "app.unused"},
+		}})
+	})
+	mux.HandleFunc("/api/v2/metrics/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v2/metrics/")
+		if r.Method != http.MethodGet {
+			body, _ := io.ReadAll(r.Body)
+			s.requests = append(s.requests, r.Method+" "+r.URL.Path+" "+strings.TrimSpace(string(body)))
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"type": "manage_tags", "id": "x"}})
+			return
+		}
+		name, endpoint, _ := strings.Cut(path, "/")
+		m := metrics[name]
+		switch endpoint {
+		case "volumes":
+			tests.WriteJSON(w, map[string]interface{}{"data": m.volumes})
+		case "all-tags":
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"type": "metrics", "id": name, "attributes": map[string]interface{}{"tags": m.tags}}})
+		case "active-configurations":
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"type": "actively_queried_configurations", "id": name, "attributes": map[string]interface{}{"active_tags": m.active}}})
+		case "estimate":
+			s.requests = append(s.requests, "GET "+r.URL.RequestURI())
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"type": "metric_cardinality_estimate", "id": name, "attributes": map[string]interface{}{"estimated_output_series": 400}}})
+		}
+	})
+	mux.HandleFunc("/api/v1/metrics/", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"type": s.metricType})
+	})
+	mux.HandleFunc("/api/v1/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"dashboards": []interface{}{map[string]interface{}{"id": "abc-def-ghi"}}})
+	})
+	mux.HandleFunc("/api/v1/dashboard/abc-def-ghi", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{
+			"title":              "Requests",
+			"layout_type":        "ordered",
+			"template_variables": []interface{}{map[string]interface{}{"name": "environment", "prefix": "env"}},
+			"widgets": []interface{}{
+				map[string]interface{}{"definition": map[string]interface{}{"type": "timeseries", "requests": []interface{}{
+					map[string]interface{}{"q": "avg:app.requests{$environment} by {service}"},
+				}}},
+				map[string]interface{}{"definition": map[string]interface{}{"type": "query_value", "requests": []interface{}{
+					map[string]interface{}{"queries": []interface{}{map[string]interface{}{"data_source": "metrics", "name": "q", "query": "sum:app.requests{region IN (eu, us)}"}}},
+				}}},
+			},
+		})
+	})
+	mux.HandleFunc("/api/v1/monitor", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, []interface{}{
+			map[string]interface{}{"id": 1, "type": "query alert", "query": "avg(last_5m):p95:app.latency{env:prod} by {host} > 1"},
+			map[string]interface{}{"id": 2, "type": "query alert", "query": "sum(last_5m):sum:app.errors{*}.as_count() > 10"},
+			map[string]interface{}{"id": 3, "type": "log alert", "query": `logs("service:web").index("*").rollup("count").last("5m") > 1`},
+		})
+	})
+	ctx := tests.Serve(t, mux)
+	return ctx, cardinality.NewAdvisor(datadog.NewAPIClient(datadog.NewConfiguration()))
+}
+
+func TestAddQuery(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	usage := cardinality.NewUsage()
+	usage.AddQuery("sum:a.b{env:prod, !host:x AND (service:web OR service:api)} by {pod, region}.as_count() / sum:c.d{*}", nil)
+	usage.AddQuery("avg(last_1h):avg:e.f{zone IN (a, b),$dc} > 1", map[string]string{"dc": "datacenter"})
+	assert.Equal([]string{"env", "host", "pod", "region", "service"}, usage.Keys("a.b"))
+	assert.True(usage.Queried("c.d"))
+	assert.Empty(usage.Keys("c.d"))
+	assert.Equal([]string{"datacenter", "zone"}, usage.Keys("e.f"))
+	assert.False(usage.Queried("avg"))
+}
+
+func TestAdvise(t *testing.T) {
+	s := &server{metricType: "count"}
+	ctx, advisor := newAdvisor(t, s)
+	assert := tests.Assert(ctx, t)
+
+	usage, err := advisor.Usage(ctx)
+	assert.NoError(err)
+	assert.Equal([]string{"env", "region", "service"}, usage.Keys("app.requests"))
+	assert.Equal([]string{"env", "host"}, usage.Keys("app.latency"))
+
+	report, err := advisor.Advise(ctx, usage)
+	assert.NoError(err)
+	var text bytes.Buffer
+	assert.NoError(report.WriteText(&text))
+	assert.Equal(`METRIC        INGESTED  INDEXED  RATIO  UNUSED TAGS
+app.requests  5000      4000     1.2    pod, host
+app.latency   900       900      1.0    -
+app.errors    300       300      1.0    host
+app.unused    100       100      1.0    (not queried)
+
+Proposals:
+  app.requests: allow env, service, 4000 -> 400 series (-3600)
+
+1 proposal(s) saving 3600 indexed series.
+`, text.String())
+	assert.Equal("count", string(report.Metrics[0].Type))
+	assert.Equal([]string{"GET /api/v2/metrics/app.requests/estimate?filter%5Bgroups%5D=env%2Cservice"}, s.requests)
+
+	s.requests = nil
+	assert.NoError(advisor.Apply(ctx, report))
+	assert.NoError(advisor.ApplyPrefix(ctx, "app.", []string{"env"}, "ops@example.com"))
+	assert.Equal([]string{
+		`POST /api/v2/metrics/app.requests/tags {"data":{"attributes":{"metric_type":"count","tags":["env","service"]},"id":"app.requests","type":"manage_tags"}}`,
+		`POST /api/v2/metrics/config/bulk-tags {"data":{"attributes":{"emails":["ops@example.com"],"tags":["env"]},"id":"app.","type":"metric_bulk_configure_tags"}}`,
+	}, s.requests)
+
+	advisor.ProposeUntagged = true
+	report, err = advisor.Advise(ctx, usage)
+	assert.NoError(err)
+	var proposals []string
+	for _, m := range report.Proposals() {
+		proposals = append(proposals, m.Name)
+	}
+	assert.Equal([]string{"app.requests", "app.errors"}, proposals)
+	assert.Equal([]string{}, report.Metrics[2].Allowlist)
+
+	s.metricType = "histogram"
+	report, err = advisor.Advise(ctx, usage)
+	assert.NoError(err)
+	assert.Empty(report.Proposals())
+}
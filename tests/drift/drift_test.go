@@ -0,0 +1,202 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/drift"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+const declaration = `
+aws:
+  - account_id: "111111111111"
+    role_name: DatadogRole
+    host_tags: [env:prod, team:core]
+    namespace_rules: {auto_scaling: false}
+    metrics_collection: true
+    tag_filters: {elb: "env:prod", lambda: ""}
+    log_services: [s3, elb]
+    lambdas: [arn:new]
+  - account_id: "222222222222"
+    role_name: DatadogRole
+    host_tags: [env:dev]
+gcp:
+  - project_id: project
+    client_email: datadog@project
+    host_filters: env:prod
+    automute: true
+azure:
+  - tenant_name: contoso
+    client_id: app
+    client_secret: ${DRIFT_TEST_SECRET}
+    host_filters: env:prod
+confluent:
+  - api_key: KEY
+    api_secret: ${DRIFT_TEST_SECRET}
+    tags: [team:streaming]
+    resources:
+      - id: lkc-1
+        resource_type: kafka
+        tags: [env:prod]
+      - id: lkc-2
+        resource_type: kafka
+`
+
+var responses = map[string]interface{}{
+	"/api/v1/integration/aws": map[string]interface{}{"accounts": []interface{}{
+		map[string]interface{}{"account_id": "111111111111", "role_name": "DatadogRole", "host_tags": []string{"team:core", "env:prod"},
+			"account_specific_namespace_rules": map[string]bool{"auto_scaling": true, "s3": false}, "metrics_collection_enabled": true},
+		map[string]interface{}{"account_id": "333333333333", "role_name": "DatadogRole"},
+	}},
+	"/api/v1/integration/aws/filtering": map[string]interface{}{"filters": []interface{}{
+		map[string]interface{}{"namespace": "elb", "tag_filter_str": "env:staging"},
+		map[string]interface{}{"namespace": "lambda", "tag_filter_str": "service:x"},
+		map[string]interface{}{"namespace": "sqs", "tag_filter_str": ""},
+	}},
+	"/api/v1/integration/aws/logs": []interface{}{
+		map[string]interface{}{"account_id": "111111111111", "services": []string{"s3"}, "lambdas": []interface{}{map[string]interface{}{"arn": "arn:old"}}},
+	},
+	"/api/v1/integration/gcp": []interface{}{
+		map[string]interface{}{"project_id": "project", "client_email": "datadog@project", "host_filters": "env:dev", "automute": true},
+	},
+	"/api/v1/integration/azure": []interface{}{},
+	"/api/v2/integrations/confluent-cloud/accounts": map[string]interface{}{"data": []interface{}{
+		map[string]interface{}{"id": "cf-1", "type": "confluent-cloud-accounts", "attributes": map[string]interface{}{"api_key": "KEY", "tags": []string{"team:streaming"}}},
+	}},
+	"/api/v2/integrations/confluent-cloud/accounts/cf-1/resources": map[string]interface{}{"data": []interface{}{
+		map[string]interface{}{"id": "lkc-1", "type": "confluent-cloud-resources", "attributes": map[string]interface{}{"resource_type": "kafka", "tags": []string{"env:dev"}}},
+		map[string]interface{}{"id": "lkc-3", "type": "confluent-cloud-resources", "attributes": map[string]interface{}{"resource_type": "kafka"}},
+	}},
+}
+
+type server struct {
+	requests []string
+}
+
+func newSyncer(t *testing.T, s *server) (context.Context, *drift.Syncer) {
+	ctx := tests.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			tests.WriteJSON(w, responses[r.URL.Path])
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.requests = append(s.requests, strings.TrimSpace(r.Method+" "+r.URL.RequestURI()+" "+string(body)))
+		if r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/v2/") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{})
+	}))
+	return ctx, drift.NewSyncer(datadog.NewAPIClient(datadog.NewConfiguration()))
+}
+
+func TestDecode(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	os.Setenv("DRIFT_TEST_SECRET", "s3cr3t")
+	defer os.Unsetenv("DRIFT_TEST_SECRET")
+
+	config, err := drift.Unmarshal([]byte(declaration))
+	assert.NoError(err)
+	assert.Equal("s3cr3t", config.Azure[0].ClientSecret)
+	assert.Equal("s3cr3t", config.Confluent[0].APISecret)
+	assert.Nil(config.AWS[1].Lambdas)
+
+	config, err = drift.Unmarshal([]byte("gcp: []\n"))
+	assert.NoError(err)
+	assert.NotNil(config.GCP)
+	assert.Nil(config.AWS)
+
+	for input, message := range map[string]string{
+		"aws: [{role_name: r}]":                                                   "aws account 0: no account ID or access key ID",
+		"aws: [{account_id: '1'}]":                                                "aws account 0: account 1 has no role name",
+		"aws: [{access_key_id: A, lambdas: [arn]}]":                               "aws account 0: account A has tag filters, log services or lambdas but no account ID",
+		"aws: [{account_id: '1', role_name: r, tag_filters: {ec3: x}}]":           "aws account 0: account 1: invalid value 'ec3' for AWSNamespace: valid values are [elb application_elb sqs rds custom network_elb lambda]",
+		"azure: [{tenant_name: t, client_id: c}, {tenant_name: t, client_id: c}]": `azure "t" is declared twice`,
+		"confluent: [{api_key: K, resources: [{id: a}, {id: a}]}]":                "confluent account 0: resource a of account K is declared twice",
+	} {
+		_, err := drift.Unmarshal([]byte(input))
+		assert.EqualError(err, message, input)
+	}
+	_, err = drift.Unmarshal([]byte("datadog: []"))
+	assert.Error(err)
+}
+
+func TestSync(t *testing.T) {
+	s := &server{}
+	ctx, syncer := newSyncer(t, s)
+	assert := tests.Assert(ctx, t)
+	os.Setenv("DRIFT_TEST_SECRET", "s3cr3t")
+	defer os.Unsetenv("DRIFT_TEST_SECRET")
+	config, err := drift.Unmarshal([]byte(declaration))
+	assert.NoError(err)
+
+	syncer.Prune = true
+	plan, err := syncer.Diff(ctx, config)
+	assert.NoError(err)
+	var text bytes.Buffer
+	assert.NoError(plan.WriteText(&text))
+	assert.Equal(`~ update aws 111111111111
+    namespace_rules: {auto_scaling: true} -> {auto_scaling: false}
+~ update aws 111111111111 tag filter elb
+    tag_filter_str: "env:staging" -> "env:prod"
+- delete aws 111111111111 tag filter lambda
+~ update aws 111111111111 log services
+    services: [s3] -> [elb, s3]
++ create aws 111111111111 lambda arn:new
+- delete aws 111111111111 lambda arn:old
++ create aws 222222222222
+    role_name: "DatadogRole"
+    host_tags: [env:dev]
+- delete aws 333333333333
+~ update gcp project
+    host_filters: "env:dev" -> "env:prod"
++ create azure contoso
+    host_filters: "env:prod"
+    client_secret: ********
+~ update confluent KEY resource lkc-1
+    tags: [env:dev] -> [env:prod]
++ create confluent KEY resource lkc-2
+    resource_type: "kafka"
+- delete confluent KEY resource lkc-3
+
+Plan: 4 to create, 5 to update, 4 to delete.
+`, text.String())
+	assert.NotContains(text.String(), "s3cr3t")
+
+	assert.NoError(syncer.Apply(ctx, plan))
+	assert.Equal([]string{
+		`PUT /api/v1/integration/aws?account_id=111111111111&role_name=DatadogRole {"account_id":"111111111111","account_specific_namespace_rules":{"auto_scaling":false,"s3":false},"host_tags":["env:prod","team:core"],"metrics_collection_enabled":true,"role_name":"DatadogRole"}`,
+		`POST /api/v1/integration/aws/filtering {"account_id":"111111111111","namespace":"elb","tag_filter_str":"env:prod"}`,
+		`DELETE /api/v1/integration/aws/filtering {"account_id":"111111111111","namespace":"lambda"}`,
+		`POST /api/v1/integration/aws/logs/services {"account_id":"111111111111","services":["s3","elb"]}`,
+		`POST /api/v1/integration/aws/logs {"account_id":"111111111111","lambda_arn":"arn:new"}`,
+		`DELETE /api/v1/integration/aws/logs {"account_id":"111111111111","lambda_arn":"arn:old"}`,
+		`POST /api/v1/integration/aws {"account_id":"222222222222","host_tags":["env:dev"],"role_name":"DatadogRole"}`,
+		`DELETE /api/v1/integration/aws {"account_id":"333333333333","role_name":"DatadogRole"}`,
+		`PUT /api/v1/integration/gcp {"automute":true,"client_email":"datadog@project","host_filters":"env:prod","project_id":"project"}`,
+		`POST /api/v1/integration/azure {"client_id":"app","client_secret":"s3cr3t","host_filters":"env:prod","tenant_name":"contoso"}`,
+		`PATCH /api/v2/integrations/confluent-cloud/accounts/cf-1/resources/lkc-1 {"data":{"attributes":{"resource_type":"kafka","tags":["env:prod"]},"id":"lkc-1","type":"confluent-cloud-resources"}}`,
+		`POST /api/v2/integrations/confluent-cloud/accounts/cf-1/resources {"data":{"attributes":{"resource_type":"kafka"},"id":"lkc-2","type":"confluent-cloud-resources"}}`,
+		`DELETE /api/v2/integrations/confluent-cloud/accounts/cf-1/resources/lkc-3`,
+	}, s.requests)
+
+	syncer.Prune = false
+	plan, err = syncer.Diff(ctx, &drift.Config{GCP: []drift.GCPProject{{ProjectID: "project", ClientEmail: "datadog@project"}}})
+	assert.NoError(err)
+	assert.True(plan.Empty())
+	_, err = syncer.Diff(ctx, &drift.Config{GCP: []drift.GCPProject{{ProjectID: "other", ClientEmail: "datadog@other"}}})
+	assert.EqualError(err, "GCP project other does not exist and has no client ID or private key")
+}
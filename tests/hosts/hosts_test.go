@@ -0,0 +1,151 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/hosts"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+type server struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (s *server) record(r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, strings.TrimSpace(r.Method+" "+r.URL.RequestURI()+" "+string(body)))
+}
+
+func (s *server) sorted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := append([]string(nil), s.requests...)
+	sort.Strings(requests)
+	s.requests = nil
+	return requests
+}
+
+func newBulk(t *testing.T, s *server) (context.Context, *hosts.Bulk) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/hosts", func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		tests.WriteJSON(w, map[string]interface{}{"host_list": []interface{}{
+			map[string]interface{}{"name": "web-1", "apps": []string{"agent", "nginx"}, "tags_by_source": map[string][]string{"Users": {"team:web"}}},
+			map[string]interface{}{"name": "web-2", "apps": []string{"agent", "nginx"}, "tags_by_source": map[string][]string{"Datadog": {"team:web"}}},
+			map[string]interface{}{"name": "db-1", "apps": []string{"agent"}, "tags_by_source": map[string][]string{"Users": {"team:web"}}},
+		}})
+	})
+	mux.HandleFunc("/api/v1/tags/hosts/", func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		host := strings.TrimPrefix(r.URL.Path, "/api/v1/tags/hosts/")
+		if r.Method == http.MethodGet {
+			switch host {
+			case "web-1":
+				tests.WriteJSON(w, map[string]interface{}{"host": host, "tags": []string{"team:web", "env:old"}})
+			case "web-2":
+				tests.WriteJSON(w, map[string]interface{}{"host": host, "tags": []string{"env:old"}})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				tests.WriteJSON(w, map[string]interface{}{"errors": []string{"Not found"}})
+			}
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"host": host})
+	})
+	mux.HandleFunc("/api/v1/host/", func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		if strings.HasPrefix(r.URL.Path, "/api/v1/host/db-1/") {
+			w.WriteHeader(http.StatusBadRequest)
+			tests.WriteJSON(w, map[string]interface{}{"errors": []string{"Host is already muted"}})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"action": "Muted"})
+	})
+	ctx := tests.Serve(t, mux)
+	return ctx, hosts.NewBulk(datadog.NewAPIClient(datadog.NewConfiguration()))
+}
+
+func TestSelect(t *testing.T) {
+	s := &server{}
+	ctx, b := newBulk(t, s)
+	assert := tests.Assert(ctx, t)
+
+	names, err := b.Select(ctx, hosts.Selector{
+		Filter: "env:prod",
+		From:   time.Unix(1700000000, 0),
+		Match:  hosts.All(hosts.HasApp("nginx"), hosts.HasTag("users", "team:web")),
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"web-1"}, names)
+	assert.Equal([]string{
+		"GET /api/v1/hosts?count=1000&filter=env%3Aprod&from=1700000000&include_hosts_metadata=true&include_muted_hosts_data=true&start=0",
+	}, s.sorted())
+}
+
+func TestRun(t *testing.T) {
+	s := &server{}
+	ctx, b := newBulk(t, s)
+	assert := tests.Assert(ctx, t)
+	b.CheckpointPath = filepath.Join(t.TempDir(), "checkpoint.json")
+	assert.NoError(hosts.Checkpoint{Operation: "add tags team:web from source users", Done: []string{"web-1"}}.Save(b.CheckpointPath))
+	var progress []hosts.Progress
+	b.Progress = func(p hosts.Progress) {
+		progress = append(progress, p)
+	}
+
+	all := []string{"web-1", "web-2", "db-1"}
+	assert.NoError(b.Run(ctx, all, hosts.AddTags("users", "team:web")))
+	assert.Equal([]string{
+		`POST /api/v1/tags/hosts/db-1?source=users {"host":"db-1","tags":["team:web"]}`,
+		`POST /api/v1/tags/hosts/web-2?source=users {"host":"web-2","tags":["team:web"]}`,
+	}, s.sorted())
+	assert.Len(progress, 2)
+	assert.Equal(hosts.Progress{Host: progress[1].Host, Total: 3, Skipped: 1, Done: 2}, progress[1])
+	checkpoint, err := hosts.LoadCheckpoint(b.CheckpointPath)
+	assert.NoError(err)
+	sort.Strings(checkpoint.Done)
+	assert.Equal(hosts.Checkpoint{Operation: "add tags team:web from source users", Done: []string{"db-1", "web-1", "web-2"}}, checkpoint)
+
+	assert.EqualError(b.Run(ctx, all, hosts.Unmute()), `checkpoint `+b.CheckpointPath+` is of operation "add tags team:web from source users"`)
+	assert.NoError(os.Remove(b.CheckpointPath))
+
+	b.CheckpointPath = ""
+	assert.NoError(b.Run(ctx, all, hosts.RemoveTags("users", "env:old")))
+	assert.Equal([]string{
+		`DELETE /api/v1/tags/hosts/web-2?source=users`,
+		`GET /api/v1/tags/hosts/db-1?source=users`,
+		`GET /api/v1/tags/hosts/web-1?source=users`,
+		`GET /api/v1/tags/hosts/web-2?source=users`,
+		`PUT /api/v1/tags/hosts/web-1?source=users {"host":"web-1","tags":["team:web"]}`,
+	}, s.sorted())
+
+	err = b.Run(ctx, []string{"web-1", "db-1"}, hosts.Mute(time.Unix(1700003600, 0), "maintenance"))
+	assert.EqualError(err, "1 of 2 host(s) failed: db-1: 400 Bad Request")
+	assert.Equal([]string{
+		`POST /api/v1/host/db-1/mute {"end":1700003600,"message":"maintenance","override":true}`,
+		`POST /api/v1/host/web-1/mute {"end":1700003600,"message":"maintenance","override":true}`,
+	}, s.sorted())
+}
@@ -0,0 +1,225 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/incident"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+var now = time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+
+type lifecycleServer struct {
+	state   string
+	updates []map[string]interface{}
+	creates []map[string]interface{}
+	linked  []int64
+}
+
+func newLifecycleServer(t *testing.T, s *lifecycleServer) (context.Context, *incident.Manager) {
+	mux := http.NewServeMux()
+	incidentData := func() map[string]interface{} {
+		return map[string]interface{}{
+			"id":   "inc-1",
+			"type": "incidents",
+			"attributes": map[string]interface{}{
+				"title":                 "Checkout down",
+				"public_id":             42,
+				"customer_impacted":     true,
+				"customer_impact_start": "2024-05-01T10:00:00Z",
+				"fields": map[string]interface{}{
+					"state":    map[string]interface{}{"type": "dropdown", "value": s.state},
+					"severity": map[string]interface{}{"type": "dropdown", "value": "SEV-2"},
+					"teams":    map[string]interface{}{"type": "multiselect", "value": []string{"payments"}},
+				},
+			},
+		}
+	}
+	mux.HandleFunc("/api/v2/incidents/inc-1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			s.updates = append(s.updates, body)
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": incidentData()})
+	})
+	mux.HandleFunc("/api/v2/incidents", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.creates = append(s.creates, body)
+		tests.WriteJSON(w, map[string]interface{}{"data": incidentData()})
+	})
+	mux.HandleFunc("/api/v2/users", func(w http.ResponseWriter, r *http.Request) {
+		data := []interface{}{}
+		switch r.URL.Query().Get("filter") {
+		case "ada@example.com":
+			data = append(data, user("u1", "Ada", "ada@example.com"))
+		case "grace@example.com":
+			data = append(data, user("u3", "Grace (old)", "grace@example.com.invalid"), user("u2", "Grace", "grace@example.com"))
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": data})
+	})
+	mux.HandleFunc("/api/v2/security_monitoring/signals/s1", func(w http.ResponseWriter, r *http.Request) {
+		sig := signal("s1", "2024-05-01T09:58:00Z", "## Unusual API calls\nFrom 10.0.0.1", 7)
+		sig["attributes"].(map[string]interface{})["attributes"].(map[string]interface{})["status"] = "high"
+		tests.WriteJSON(w, sig)
+	})
+	mux.HandleFunc("/api/v2/security_monitoring/signals/s1/incidents", func(w http.ResponseWriter, r *http.Request) {
+		var body datadogV2.SecurityMonitoringSignalIncidentsUpdateRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		s.linked = body.Data.Attributes.IncidentIds
+		tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "s1", "type": "signal_metadata"}})
+	})
+	mux.HandleFunc("/api/v1/monitor/12", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{
+			"id":            12,
+			"name":          "Checkout error rate",
+			"type":          "metric alert",
+			"query":         "avg(last_5m):sum:checkout.errors{*} > 10",
+			"message":       "Errors are high @slack-payments",
+			"priority":      1,
+			"overall_state": "Alert",
+		})
+	})
+	ctx := tests.Serve(t, mux)
+	config := datadog.NewConfiguration()
+	for _, op := range []string{"v2.GetIncident", "v2.UpdateIncident", "v2.CreateIncident"} {
+		config.SetUnstableOperationEnabled(op, true)
+	}
+	client := datadog.NewAPIClient(config)
+	manager := incident.NewManager(datadogV2.NewIncidentsApi(client), datadogV2.NewUsersApi(client))
+	manager.Signals = datadogV2.NewSecurityMonitoringApi(client)
+	manager.Monitors = datadogV1.NewMonitorsApi(client)
+	manager.Now = func() time.Time { return now }
+	return ctx, manager
+}
+
+func attributes(body map[string]interface{}) map[string]interface{} {
+	return body["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+}
+
+func TestTransitions(t *testing.T) {
+	s := &lifecycleServer{state: "active"}
+	ctx, manager := newLifecycleServer(t, s)
+	assert := tests.Assert(ctx, t)
+
+	assert.True(incident.CanTransition(incident.StateActive, incident.StateResolved))
+	assert.True(incident.CanTransition(incident.StateResolved, incident.StateActive))
+	assert.False(incident.CanTransition(incident.StateResolved, incident.StateStable))
+	assert.False(incident.CanTransition(incident.StateActive, incident.StateActive))
+
+	_, err := manager.Transition(ctx, "inc-1", incident.StateStable)
+	assert.NoError(err)
+	attrs := attributes(s.updates[0])
+	assert.Equal(map[string]interface{}{"type": "dropdown", "value": "stable"}, attrs["fields"].(map[string]interface{})["state"])
+	assert.Equal("2024-05-01T11:00:00Z", attrs["customer_impact_end"])
+	assert.NotContains(attrs, "resolved")
+
+	_, err = manager.Transition(ctx, "inc-1", incident.StateResolved)
+	assert.NoError(err)
+	attrs = attributes(s.updates[1])
+	assert.Equal(map[string]interface{}{"type": "dropdown", "value": "resolved"}, attrs["fields"].(map[string]interface{})["state"])
+	assert.Equal("2024-05-01T11:00:00Z", attrs["resolved"])
+
+	s.state = "resolved"
+	_, err = manager.Transition(ctx, "inc-1", incident.StateStable)
+	var transitionErr *incident.TransitionError
+	assert.True(errors.As(err, &transitionErr))
+	assert.EqualError(err, "incident inc-1: invalid transition from resolved to stable")
+	assert.Len(s.updates, 2)
+}
+
+func TestUpdate(t *testing.T) {
+	s := &lifecycleServer{state: "active"}
+	ctx, manager := newLifecycleServer(t, s)
+	assert := tests.Assert(ctx, t)
+
+	_, err := manager.Update(ctx, "inc-1", incident.Update{
+		Fields: []incident.Field{
+			{Name: "severity", Values: []string{"SEV-1"}},
+			{Name: "teams", Values: []string{"payments", "sre"}},
+		},
+		CommanderEmail:  "ada@example.com",
+		ResponderEmails: []string{"grace@example.com"},
+	})
+	assert.NoError(err)
+	body := s.updates[0]
+	attrs := attributes(body)
+	assert.Equal(map[string]interface{}{
+		"severity": map[string]interface{}{"type": "dropdown", "value": "SEV-1"},
+		"teams":    map[string]interface{}{"type": "multiselect", "value": []interface{}{"payments", "sre"}},
+	}, attrs["fields"])
+	assert.Equal([]interface{}{map[string]interface{}{"display_name": "Grace", "handle": "grace@example.com"}}, attrs["notification_handles"])
+	assert.NotContains(attrs, "customer_impact_end")
+	commander := body["data"].(map[string]interface{})["relationships"].(map[string]interface{})["commander_user"]
+	assert.Equal(map[string]interface{}{"data": map[string]interface{}{"id": "u1", "type": "users"}}, commander)
+
+	for _, tc := range []struct {
+		update   incident.Update
+		expected string
+	}{
+		{incident.Update{Fields: []incident.Field{{Name: "severity", Values: []string{"SEV-0"}}}}, `incident inc-1: invalid severity "SEV-0", expected one of UNKNOWN, SEV-1, SEV-2, SEV-3, SEV-4, SEV-5`},
+		{incident.Update{Fields: []incident.Field{{Name: "severity", Values: []string{"SEV-1", "SEV-2"}}}}, `incident inc-1: field "severity" takes a single value, got 2`},
+		{incident.Update{Fields: []incident.Field{{Name: "services", Values: []string{"checkout"}}}}, `incident inc-1: unknown field "services"`},
+		{incident.Update{CommanderEmail: "nobody@example.com"}, "incident inc-1: no enabled user with email nobody@example.com"},
+	} {
+		_, err := manager.Update(ctx, "inc-1", tc.update)
+		assert.EqualError(err, tc.expected)
+	}
+	assert.Len(s.updates, 1)
+}
+
+func TestDeclare(t *testing.T) {
+	s := &lifecycleServer{state: "active"}
+	ctx, manager := newLifecycleServer(t, s)
+	assert := tests.Assert(ctx, t)
+
+	created, err := manager.DeclareFromSignal(ctx, "s1", incident.Declaration{CommanderEmail: "ada@example.com"})
+	assert.NoError(err)
+	assert.Equal("inc-1", created.Id)
+	assert.Equal([]int64{7, 42}, s.linked)
+	attrs := attributes(s.creates[0])
+	assert.Equal("Unusual API calls", attrs["title"])
+	assert.Equal(map[string]interface{}{"type": "dropdown", "value": "SEV-2"}, attrs["fields"].(map[string]interface{})["severity"])
+	assert.Equal(map[string]interface{}{"type": "dropdown", "value": "monitor"}, attrs["fields"].(map[string]interface{})["detection_method"])
+	cell := attrs["initial_cells"].([]interface{})[0].(map[string]interface{})
+	assert.Equal("markdown", cell["cell_type"])
+	assert.Equal("Declared from security signal s1.\n\n## Unusual API calls\nFrom 10.0.0.1", cell["content"].(map[string]interface{})["content"])
+
+	_, err = manager.DeclareFromMonitor(ctx, 12, incident.Declaration{
+		Fields:          []incident.Field{{Name: "severity", Values: []string{"SEV-3"}}},
+		ResponderEmails: []string{"grace@example.com"},
+	})
+	assert.NoError(err)
+	attrs = attributes(s.creates[1])
+	assert.Equal("Checkout error rate", attrs["title"])
+	assert.Equal(map[string]interface{}{"type": "dropdown", "value": "SEV-3"}, attrs["fields"].(map[string]interface{})["severity"])
+	cell = attrs["initial_cells"].([]interface{})[0].(map[string]interface{})
+	assert.Equal("Declared from monitor 12 **Checkout error rate** in state Alert.\n\nErrors are high @slack-payments", cell["content"].(map[string]interface{})["content"])
+	assert.NotContains(s.creates[1]["data"], "relationships")
+	assert.NotContains(attrs, "customer_impact_start")
+
+	_, err = manager.Declare(ctx, incident.Declaration{Title: "Checkout down", CustomerImpacted: true})
+	assert.NoError(err)
+	attrs = attributes(s.creates[2])
+	assert.Equal(true, attrs["customer_impacted"])
+	assert.Equal("2024-05-01T11:00:00Z", attrs["customer_impact_start"])
+
+	_, err = manager.Declare(ctx, incident.Declaration{Title: "Broken", Fields: []incident.Field{{Name: "state", Values: []string{"closed"}}}})
+	assert.EqualError(err, `invalid state "closed"`)
+	assert.Len(s.creates, 3)
+}
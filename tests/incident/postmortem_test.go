@@ -0,0 +1,223 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/incident"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func user(id, name, email string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"type":       "users",
+		"attributes": map[string]interface{}{"name": name, "email": email, "handle": email},
+	}
+}
+
+func attachment(id, kind, title, url string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   id,
+		"type": "incident_attachments",
+		"attributes": map[string]interface{}{
+			"attachment_type": kind,
+			"attachment":      map[string]interface{}{"documentUrl": url, "title": title},
+		},
+		"relationships": map[string]interface{}{},
+	}
+}
+
+func signal(id, timestamp, message string, incidents ...int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   id,
+		"type": "signal",
+		"attributes": map[string]interface{}{
+			"message":   message,
+			"timestamp": timestamp,
+			"tags":      []string{"source:cloudtrail"},
+			"attributes": map[string]interface{}{
+				"workflow": map[string]interface{}{"triage": map[string]interface{}{"incident_ids": incidents}},
+			},
+		},
+	}
+}
+
+func newServer(t *testing.T, updates *[]datadogV2.IncidentAttachmentUpdateRequest, attachments *[]map[string]interface{}) (context.Context, *incident.Exporter) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/incidents/inc-1", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include") != "users,attachments" {
+			t.Errorf("unexpected include %q", r.URL.Query().Get("include"))
+		}
+		tests.WriteJSON(w, map[string]interface{}{
+			"data": map[string]interface{}{
+				"id":   "inc-1",
+				"type": "incidents",
+				"attributes": map[string]interface{}{
+					"title":                 "Checkout | payments down",
+					"public_id":             42,
+					"created":               "2024-05-01T10:05:00Z",
+					"detected":              "2024-05-01T10:00:00Z",
+					"resolved":              "2024-05-01T12:00:00Z",
+					"customer_impacted":     true,
+					"customer_impact_scope": "Payments failed for <all> EU customers",
+					"customer_impact_start": "2024-05-01T09:55:00Z",
+					"customer_impact_end":   "2024-05-01T11:30:00Z",
+					"time_to_resolve":       7200,
+					"fields": map[string]interface{}{
+						"severity": map[string]interface{}{"type": "dropdown", "value": "SEV-1"},
+						"teams":    map[string]interface{}{"type": "multiselect", "value": []string{"payments", "sre"}},
+						"summary":  map[string]interface{}{"type": "textbox", "value": nil},
+					},
+				},
+				"relationships": map[string]interface{}{
+					"commander_user":  map[string]interface{}{"data": map[string]interface{}{"id": "u1", "type": "users"}},
+					"created_by_user": map[string]interface{}{"data": map[string]interface{}{"id": "u2", "type": "users"}},
+					"responders": map[string]interface{}{"data": []interface{}{
+						map[string]interface{}{"id": "u1", "type": "users"},
+						map[string]interface{}{"id": "u3", "type": "users"},
+					}},
+				},
+			},
+			"included": []interface{}{
+				user("u1", "Ada", "ada@example.com"),
+				user("u2", "Grace", "grace@example.com"),
+				user("u3", "Linus", "linus@example.com"),
+				attachment("a1", "link", "Dashboard", "https://app.datadoghq.com/dashboard/abc"),
+			},
+		})
+	})
+	mux.HandleFunc("/api/v2/incidents/inc-1/attachments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			var body datadogV2.IncidentAttachmentUpdateRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			*updates = append(*updates, body)
+			id := "a3"
+			if body.Data[0].Id != nil {
+				id = *body.Data[0].Id
+			}
+			pm := body.Data[0].Attributes.IncidentAttachmentPostmortemAttributes
+			tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{attachment(id, "postmortem", pm.Attachment.Title, pm.Attachment.DocumentUrl)}})
+			return
+		}
+		data := []map[string]interface{}{}
+		for _, a := range *attachments {
+			kind := r.URL.Query().Get("filter[attachment_type]")
+			if kind == "" || a["attributes"].(map[string]interface{})["attachment_type"] == kind {
+				data = append(data, a)
+			}
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": data})
+	})
+	mux.HandleFunc("/api/v2/security_monitoring/signals", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filter[query]") != "@workflow.triage.incident_ids:42" {
+			t.Errorf("unexpected query %q", r.URL.Query().Get("filter[query]"))
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			signal("s1", "2024-05-01T09:58:00Z", "## Unusual API calls\nDetails", 42),
+			signal("s2", "2024-05-01T09:59:00Z", "Unrelated", 7),
+		}})
+	})
+	ctx := tests.Serve(t, mux)
+	config := datadog.NewConfiguration()
+	for _, op := range []string{"v2.GetIncident", "v2.ListIncidentAttachments", "v2.UpdateIncidentAttachments"} {
+		config.SetUnstableOperationEnabled(op, true)
+	}
+	client := datadog.NewAPIClient(config)
+	exporter := incident.NewExporter(datadogV2.NewIncidentsApi(client))
+	exporter.Signals = datadogV2.NewSecurityMonitoringApi(client)
+	return ctx, exporter
+}
+
+func TestPostmortem(t *testing.T) {
+	var updates []datadogV2.IncidentAttachmentUpdateRequest
+	attachments := []map[string]interface{}{
+		attachment("a1", "link", "Dashboard", "https://app.datadoghq.com/dashboard/abc"),
+		attachment("a2", "link", "Runbook", "https://wiki.example.com/runbook"),
+	}
+	ctx, exporter := newServer(t, &updates, &attachments)
+	assert := tests.Assert(ctx, t)
+
+	p, err := exporter.Postmortem(ctx, "inc-1")
+	assert.NoError(err)
+	assert.Equal(int64(42), p.PublicID)
+	assert.Equal("Ada <ada@example.com>", p.Commander.String())
+	// Grace created the incident without responding to it.
+	assert.Equal([]incident.Person{{ID: "u3", Name: "Linus", Email: "linus@example.com", Handle: "linus@example.com"}}, p.Responders)
+	assert.Equal([]incident.Field{{Name: "severity", Values: []string{"SEV-1"}}, {Name: "teams", Values: []string{"payments", "sre"}}}, p.Fields)
+	assert.Len(p.Attachments, 2)
+	assert.Len(p.Signals, 1)
+	assert.Equal("Unusual API calls", p.Signals[0].Title)
+
+	var kinds []string
+	for _, e := range p.Timeline {
+		kinds = append(kinds, e.Kind)
+	}
+	assert.Equal([]string{
+		incident.EventCustomerImpactStart,
+		incident.EventSignal,
+		incident.EventDetected,
+		incident.EventCreated,
+		incident.EventCustomerImpactEnd,
+		incident.EventResolved,
+	}, kinds)
+	assert.Equal("Incident declared by Grace <grace@example.com>", p.Timeline[3].Text)
+
+	var md bytes.Buffer
+	assert.NoError(p.WriteMarkdown(&md))
+	for _, expected := range []string{
+		"# Incident #42: Checkout | payments down\n",
+		"| Commander | Ada <ada@example.com> |\n",
+		"| teams | payments, sre |\n",
+		"| Time to resolve | 2h0m0s |\n",
+		"## Customer impact\n\nPayments failed for <all> EU customers\n",
+		"| 2024-05-01 09:58:00 UTC | Security signal: Unusual API calls |\n",
+		"| 2024-05-01 09:55:00 UTC | Customer impact started: Payments failed for <all> EU customers |\n",
+		"- [Runbook](https://wiki.example.com/runbook) (link)\n",
+	} {
+		assert.Contains(md.String(), expected)
+	}
+
+	var html bytes.Buffer
+	assert.NoError(p.WriteHTML(&html))
+	assert.Contains(html.String(), "<title>Incident #42: Checkout | payments down</title>")
+	assert.Contains(html.String(), "<p>Payments failed for &lt;all&gt; EU customers</p>")
+	assert.Contains(html.String(), `<li><a href="https://wiki.example.com/runbook">Runbook</a> (link)</li>`)
+
+	var raw bytes.Buffer
+	assert.NoError(p.WriteJSON(&raw))
+	var decoded incident.Postmortem
+	assert.NoError(json.Unmarshal(raw.Bytes(), &decoded))
+	assert.Equal(p.Timeline, decoded.Timeline)
+	assert.Equal(incident.Duration(7200), *decoded.TimeToResolve)
+}
+
+func TestPublishPostmortem(t *testing.T) {
+	var updates []datadogV2.IncidentAttachmentUpdateRequest
+	var attachments []map[string]interface{}
+	ctx, exporter := newServer(t, &updates, &attachments)
+	assert := tests.Assert(ctx, t)
+
+	created, err := exporter.PublishPostmortem(ctx, "inc-1", "Postmortem", "https://docs.example.com/pm/42")
+	assert.NoError(err)
+	assert.Equal("a3", created.Id)
+	assert.Nil(updates[0].Data[0].Id)
+
+	attachments = append(attachments, attachment("a3", "postmortem", "Postmortem", "https://docs.example.com/pm/42"))
+	updated, err := exporter.PublishPostmortem(ctx, "inc-1", "Postmortem v2", "https://docs.example.com/pm/42-v2")
+	assert.NoError(err)
+	assert.Equal("a3", updated.Id)
+	assert.Equal("a3", *updates[1].Data[0].Id)
+	assert.Equal("Postmortem v2", updated.Attributes.IncidentAttachmentPostmortemAttributes.Attachment.Title)
+}
@@ -0,0 +1,199 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/keys"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func owner(relationship, id string) map[string]interface{} {
+	return map[string]interface{}{relationship: map[string]interface{}{"data": map[string]interface{}{"id": id, "type": "users"}}}
+}
+
+type server struct {
+	requests []string
+	invalid  bool
+}
+
+func newManager(t *testing.T, s *server) (context.Context, *keys.Manager) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/users", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "u-jane", "type": "users", "attributes": map[string]interface{}{"email": "jane@example.com"}},
+			map[string]interface{}{"id": "u-ci", "type": "users", "attributes": map[string]interface{}{"email": "ci@example.com", "service_account": true}},
+			map[string]interface{}{"id": "u-old", "type": "users", "attributes": map[string]interface{}{"email": "old@example.com", "disabled": true}},
+		}})
+	})
+	mux.HandleFunc("/api/v2/api_keys", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			s.requests = append(s.requests, "POST /api/v2/api_keys")
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "k-new", "type": "api_keys",
+				"attributes": map[string]interface{}{"name": "prod", "key": "NEWAPIKEY", "last4": "IKEY", "created_at": "2024-06-01T00:00:00.000000+00:00"}}})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "k-staging", "type": "api_keys", "relationships": owner("created_by", "u-jane"),
+				"attributes": map[string]interface{}{"name": "staging", "last4": "2222", "created_at": "2024-05-20T00:00:00.000000+00:00"}},
+			map[string]interface{}{"id": "k-prod", "type": "api_keys", "relationships": owner("created_by", "u-old"),
+				"attributes": map[string]interface{}{"name": "prod", "last4": "1111", "created_at": "2024-01-01T00:00:00.000000+00:00"}},
+		}})
+	})
+	mux.HandleFunc("/api/v2/application_keys", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "a-ci", "type": "application_keys", "relationships": owner("owned_by", "u-ci"),
+				"attributes": map[string]interface{}{"name": "ci", "last4": "3333", "created_at": "2024-05-01T00:00:00.000000+00:00", "scopes": []string{"dashboards_read"}}},
+			map[string]interface{}{"id": "a-admin", "type": "application_keys", "relationships": owner("owned_by", "u-jane"),
+				"attributes": map[string]interface{}{"name": "admin", "last4": "4444", "created_at": "2024-03-15T00:00:00.000000+00:00"}},
+			map[string]interface{}{"id": "a-ops", "type": "application_keys", "relationships": owner("owned_by", "u-jane"),
+				"attributes": map[string]interface{}{"name": "ops", "last4": "5555", "created_at": "2024-05-01T00:00:00.000000+00:00", "scopes": []string{"dashboards_read", "org_management"}}},
+		}})
+	})
+	mux.HandleFunc("/api/v2/service_accounts/u-ci/application_keys", func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "a-new", "type": "application_keys", "relationships": owner("owned_by", "u-ci"),
+			"attributes": map[string]interface{}{"name": "ci", "key": "NEWAPPKEY", "last4": "PKEY", "scopes": []string{"dashboards_read"}}}})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/api/v1/validate", func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, "GET /api/v1/validate "+r.Header.Get("DD-API-KEY"))
+		tests.WriteJSON(w, map[string]interface{}{"valid": !s.invalid})
+	})
+	mux.HandleFunc("/api/v2/current_user/application_keys/", func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, "GET "+r.URL.Path+" "+r.Header.Get("DD-API-KEY")+" "+r.Header.Get("DD-APPLICATION-KEY"))
+		tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "a-new", "type": "application_keys"}})
+	})
+	ctx := tests.Serve(t, mux)
+	ctx = context.WithValue(ctx, datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: "APIKEY"},
+		"appKeyAuth": {Key: "APPKEY"},
+	})
+	m := keys.NewManager(datadog.NewAPIClient(datadog.NewConfiguration()))
+	m.Now = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+	m.LastUsed = func(ctx context.Context, key keys.Key) (time.Time, error) {
+		if key.ID == "a-admin" {
+			return time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), nil
+		}
+		return time.Time{}, nil
+	}
+	return ctx, m
+}
+
+func TestAudit(t *testing.T) {
+	ctx, m := newManager(t, &server{})
+	assert := tests.Assert(ctx, t)
+
+	inventory, err := m.Inventory(ctx)
+	assert.NoError(err)
+	assert.Len(inventory, 5)
+	assert.Equal(keys.Key{
+		Kind:      keys.KindServiceAccountKey,
+		ID:        "a-ci",
+		Name:      "ci",
+		Last4:     "3333",
+		OwnerID:   "u-ci",
+		Owner:     "ci@example.com",
+		CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Scopes:    []string{"dashboards_read"},
+	}, inventory[3])
+
+	var findings []string
+	for _, f := range m.Audit(inventory) {
+		findings = append(findings, f.String())
+	}
+	assert.Equal([]string{
+		`api key "prod" (...1111) of old@example.com: created 152 days ago`,
+		`api key "prod" (...1111) of old@example.com: owner is disabled`,
+		`application key "admin" (...4444) of jane@example.com: last used 61 days ago`,
+		`application key "admin" (...4444) of jane@example.com: unscoped, with all the permissions of its owner`,
+		`application key "ops" (...5555) of jane@example.com: privileged scopes org_management`,
+	}, findings)
+}
+
+type failingSink struct{}
+
+func (failingSink) Store(ctx context.Context, key keys.Key, secret string) error {
+	return errors.New("read-only")
+}
+
+func TestRotate(t *testing.T) {
+	s := &server{}
+	ctx, m := newManager(t, s)
+	assert := tests.Assert(ctx, t)
+	inventory, err := m.Inventory(ctx)
+	assert.NoError(err)
+	prod, ci := inventory[0], inventory[3]
+
+	env := filepath.Join(t.TempDir(), ".env")
+	assert.NoError(os.WriteFile(env, []byte("# keys\nexport DD_API_KEY=OLD\nDD_SITE=datadoghq.com\n"), 0o600))
+	created, err := m.Rotate(ctx, prod, keys.EnvFileSink(env, "DD_API_KEY"))
+	assert.NoError(err)
+	assert.Equal("k-new", created.ID)
+	data, err := os.ReadFile(env)
+	assert.NoError(err)
+	assert.Equal("# keys\nexport DD_API_KEY=NEWAPIKEY\nDD_SITE=datadoghq.com\n", string(data))
+	assert.Equal([]string{
+		"POST /api/v2/api_keys",
+		"GET /api/v1/validate NEWAPIKEY",
+		"DELETE /api/v2/api_keys/k-prod",
+	}, s.requests)
+
+	var stored map[string]interface{}
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/v1/secret/data/datadog", r.URL.Path)
+		assert.Equal("root", r.Header.Get("X-Vault-Token"))
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &stored)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer vault.Close()
+	s.requests = nil
+	_, err = m.Rotate(ctx, ci, keys.NewVaultSink(vault.URL, "root", "/secret/data/datadog", "app_key"))
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"data": map[string]interface{}{"app_key": "NEWAPPKEY"}}, stored)
+	assert.Equal([]string{
+		"POST /api/v2/service_accounts/u-ci/application_keys",
+		"GET /api/v2/current_user/application_keys/a-new APIKEY NEWAPPKEY",
+		"DELETE /api/v2/service_accounts/u-ci/application_keys/a-ci",
+	}, s.requests)
+
+	s.requests = nil
+	_, err = m.Rotate(ctx, prod, failingSink{})
+	assert.EqualError(err, "storing new key: read-only")
+	assert.Equal([]string{"POST /api/v2/api_keys", "DELETE /api/v2/api_keys/k-new"}, s.requests)
+
+	s.requests = nil
+	s.invalid = true
+	_, err = m.Rotate(ctx, prod, keys.FileSink(filepath.Join(t.TempDir(), "api_key")))
+	assert.EqualError(err, "verifying new key k-new: key is not valid; the old key is kept")
+	assert.Equal([]string{"POST /api/v2/api_keys", "GET /api/v1/validate NEWAPIKEY"}, s.requests)
+
+	s.requests = nil
+	m.Verify = func(ctx context.Context, key keys.Key, secret string) error { return errors.New("forbidden") }
+	file := filepath.Join(t.TempDir(), "api_key")
+	_, err = m.Rotate(ctx, prod, keys.FileSink(file))
+	assert.EqualError(err, "verifying new key k-new: forbidden; the old key is kept")
+	assert.Equal([]string{"POST /api/v2/api_keys"}, s.requests)
+	data, err = os.ReadFile(file)
+	assert.NoError(err)
+	assert.Equal("NEWAPIKEY\n", string(data))
+}
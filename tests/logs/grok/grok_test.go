@@ -0,0 +1,235 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/grok"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func TestParse(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	parser, err := grok.Compile(
+		`access.common %{_client_ip} %{_ident} %{_auth} \[%{_date_access}\] "%{_method} %{_url} HTTP\/%{_version}" %{_status_code} %{_bytes_written}
+access.json %{data::json}
+kv %{word:event}: %{data::keyvalue("=")}`,
+		`_auth %{notSpace:http.auth:nullIf("-")}
+_bytes_written %{integer:network.bytes_written}
+_client_ip %{ipOrHost:network.client.ip}
+_version %{regex("[\\d\\.]+"):http.version}
+_url %{notSpace:http.url}
+_ident %{notSpace:http.ident:nullIf("-")}
+_status_code %{number:http.status_code}
+_method %{word:http.method}
+_date_access %{date("dd/MMM/yyyy:HH:mm:ss Z"):date_access}`,
+	)
+	assert.NoError(err)
+	assert.Equal([]string{"access.common", "access.json", "kv"}, parser.Rules())
+
+	m, err := parser.Parse(`127.0.0.1 - frank [13/Jul/2016:10:55:36 +0000] "GET /apache_pb.gif HTTP/1.0" 200 2326`)
+	assert.NoError(err)
+	assert.Equal("access.common", m.Rule)
+	assert.Equal(map[string]interface{}{
+		"network": map[string]interface{}{
+			"client":        map[string]interface{}{"ip": "127.0.0.1"},
+			"bytes_written": float64(2326),
+		},
+		"http": map[string]interface{}{
+			"auth":        "frank",
+			"method":      "GET",
+			"url":         "/apache_pb.gif",
+			"version":     "1.0",
+			"status_code": float64(200),
+		},
+		"date_access": float64(1468407336000),
+	}, m.Attributes)
+
+	m, err = parser.Parse(`{"user": "john", "count": 3}`)
+	assert.NoError(err)
+	assert.Equal("access.json", m.Rule)
+	assert.Equal(map[string]interface{}{"user": "john", "count": float64(3)}, m.Attributes)
+
+	m, err = parser.Parse(`login: user=john ip="10.0.0.1" result=ok`)
+	assert.NoError(err)
+	assert.Equal("kv", m.Rule)
+	assert.Equal(map[string]interface{}{"event": "login", "user": "john", "ip": "10.0.0.1", "result": "ok"}, m.Attributes)
+
+	// the json rule matches any text but fails to decode it
+	m, err = parser.Parse(`not matching anything`)
+	assert.Error(err)
+	assert.Nil(m)
+}
+
+func TestFilters(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	parser, err := grok.Compile(
+		`rule %{word:level:uppercase} took=%{numberStr:took:scale(1000)} ids=%{notSpace:ids:array("[]", ",", integer)} ok=%{boolean("yes","no"):ok} at %{date("yyyy-MM-dd'T'HH:mm:ss.SSSZZ"):ts}`,
+		"",
+	)
+	assert.NoError(err)
+	m, err := parser.Parse(`info took=1.5 ids=[1,2,3] ok=YES at 2020-01-01T01:00:00.250+01:00`)
+	assert.NoError(err)
+	assert.NotNil(m)
+	assert.Equal("INFO", m.Attributes["level"])
+	assert.Equal(float64(1500), m.Attributes["took"])
+	assert.Equal([]interface{}{float64(1), float64(2), float64(3)}, m.Attributes["ids"])
+	assert.Equal(true, m.Attributes["ok"])
+	assert.Equal(float64(1577836800250), m.Attributes["ts"])
+}
+
+func TestCompileErrors(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	for _, rules := range []string{
+		"",
+		"rule %{unknown:attr}",
+		"rule %{word:attr:unknown}",
+		"rule %{word:attr",
+		"rule %{_self}",
+		"rule\nrule %{word}",
+	} {
+		_, err := grok.Compile(rules, "_self %{_self}")
+		assert.Error(err, rules)
+	}
+}
+
+func TestValidateSamples(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	results, err := grok.ValidateSamples(datadogV1.LogsGrokParser{
+		Grok: datadogV1.LogsGrokParserRules{
+			MatchRules:   "rule %{_user} connected on %{date(\"MM/dd/yyyy\"):connect_date}",
+			SupportRules: datadog.PtrString("_user %{word:user.name}"),
+		},
+		Samples: []string{"john connected on 11/08/2017", "john disconnected"},
+		Source:  "message",
+		Type:    datadogV1.LOGSGROKPARSERTYPE_GROK_PARSER,
+	})
+	assert.NoError(err)
+	assert.Len(results, 2)
+	assert.True(results[0].Matched)
+	assert.Equal("rule", results[0].Rule)
+	assert.Equal(map[string]interface{}{
+		"user":         map[string]interface{}{"name": "john"},
+		"connect_date": float64(1510099200000),
+	}, results[0].Attributes)
+	assert.False(results[1].Matched)
+	assert.Equal("no rule matched", results[1].Error)
+}
+
+func TestDateTimezone(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	for _, tt := range []struct {
+		timezone string
+		offset   int64
+		err      bool
+	}{
+		{timezone: "Z"},
+		{timezone: "UTC"},
+		{timezone: "+1", offset: 3600},
+		{timezone: "-5", offset: -5 * 3600},
+		{timezone: "+01", offset: 3600},
+		{timezone: "+0130", offset: 5400},
+		{timezone: "-01:30", offset: -5400},
+		{timezone: "+", err: true},
+		{timezone: "-", err: true},
+		{timezone: "+123", err: true},
+		{timezone: "+1:30", err: true},
+		{timezone: "+01:3", err: true},
+		{timezone: "+0a", err: true},
+		{timezone: "+012345", err: true},
+		{timezone: "+19", err: true},
+		{timezone: "+0160", err: true},
+	} {
+		parser, err := grok.Compile(`rule %{date("yyyy-MM-dd HH:mm", "`+tt.timezone+`"):ts}`, "")
+		if tt.err {
+			assert.Error(err, tt.timezone)
+			continue
+		}
+		assert.NoError(err, tt.timezone)
+		m, err := parser.Parse("2020-01-01 00:00")
+		assert.NoError(err, tt.timezone)
+		assert.Equal(float64((1577836800-tt.offset)*1000), m.Attributes["ts"], tt.timezone)
+	}
+}
+
+func TestDateRanges(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	parser, err := grok.Compile(`rule %{date("yyyy-MM-dd HH:mm:ss"):ts}`, "")
+	assert.NoError(err)
+	for _, tt := range []struct {
+		date string
+		err  bool
+	}{
+		{date: "2024-02-29 23:59:59"},
+		{date: "2023-02-29 00:00:00", err: true},
+		{date: "2024-02-30 00:00:00", err: true},
+		{date: "2024-13-01 00:00:00", err: true},
+		{date: "2024-00-01 00:00:00", err: true},
+		{date: "2024-04-31 00:00:00", err: true},
+		{date: "2024-01-00 00:00:00", err: true},
+		{date: "2024-01-01 24:00:00", err: true},
+		{date: "2024-01-01 00:60:00", err: true},
+		{date: "2024-01-01 00:00:60", err: true},
+	} {
+		m, err := parser.Parse(tt.date)
+		if tt.err {
+			assert.Error(err, tt.date)
+			assert.Nil(m, tt.date)
+			continue
+		}
+		assert.NoError(err, tt.date)
+	}
+
+	parser, err = grok.Compile(`rule %{date("hh:mm a"):ts}`, "")
+	assert.NoError(err)
+	_, err = parser.Parse("13:00 PM")
+	assert.Error(err)
+}
+
+func TestDateZoneNames(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	parser, err := grok.Compile(`rule %{date("yyyy-MM-dd HH:mm z"):ts}`, "")
+	assert.NoError(err)
+	for _, tt := range []struct {
+		zone   string
+		offset int64
+		err    bool
+	}{
+		{zone: "UTC"},
+		{zone: "PST", offset: -8 * 3600},
+		{zone: "PDT", offset: -7 * 3600},
+		{zone: "CEST", offset: 2 * 3600},
+		{zone: "est", offset: -5 * 3600},
+		{zone: "Asia/Tokyo", offset: 9 * 3600},
+		{zone: "XYZ", err: true},
+	} {
+		m, err := parser.Parse("2020-01-01 00:00 " + tt.zone)
+		if tt.err {
+			assert.Error(err, tt.zone)
+			continue
+		}
+		assert.NoError(err, tt.zone)
+		assert.Equal(float64((1577836800-tt.offset)*1000), m.Attributes["ts"], tt.zone)
+	}
+}
+
+func TestDateLocale(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	for _, locale := range []string{"en", "en_US", "en-GB"} {
+		parser, err := grok.Compile(`rule %{date("dd MMM yyyy", "UTC", "`+locale+`"):ts}`, "")
+		assert.NoError(err, locale)
+		m, err := parser.Parse("01 Jan 2020")
+		assert.NoError(err, locale)
+		assert.Equal(float64(1577836800000), m.Attributes["ts"], locale)
+	}
+	_, err := grok.Compile(`rule %{date("dd MMM yyyy", "UTC", "fr_FR"):ts}`, "")
+	assert.Error(err)
+}
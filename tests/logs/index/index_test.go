@@ -0,0 +1,105 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/index"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+const indexes = `{"indexes": [
+	{"name": "main", "filter": {"query": "*"}, "daily_limit": 20000, "num_retention_days": 15, "exclusion_filters": [
+		{"name": "disabled", "is_enabled": false, "filter": {"query": "*", "sample_rate": 1}},
+		{"name": "health checks", "is_enabled": true, "filter": {"query": "@http.url:/health", "sample_rate": 0.5}},
+		{"name": "debug", "is_enabled": true, "filter": {"query": "status:debug", "sample_rate": 1}}
+	]},
+	{"name": "payments", "filter": {"query": "service:payments"}, "num_retention_days": 30}
+]}`
+
+const logs = `{"service": "web", "status": "info", "http": {"url": "/health"}}
+{"service": "web", "status": "debug"}
+
+{"service": "payments", "status": "debug"}
+{"service": "web", "status": "info"}
+`
+
+func TestSimulator(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	all, err := index.ReadIndexes(strings.NewReader(indexes))
+	assert.NoError(err)
+	s := index.NewSimulator(index.OrderIndexes(all, []string{"payments", "main"})...)
+	results, err := s.RunJSONLines(strings.NewReader(logs))
+	assert.NoError(err)
+
+	var routes []string
+	for _, r := range results {
+		routes = append(routes, r.String())
+	}
+	assert.Equal([]string{
+		`index "main", 50% excluded by "health checks"`,
+		`index "main", excluded by "debug"`,
+		`index "payments"`,
+		`index "main"`,
+	}, routes)
+	assert.True(results[1].Excluded())
+	assert.Equal(0.5, results[0].Kept())
+
+	report := s.Report(results, time.Hour)
+	var text bytes.Buffer
+	assert.NoError(report.WriteText(&text))
+	assert.Equal(`INDEX     MATCHED  EXCLUDED  INDEXED  DAILY  DAILY LIMIT  RETENTION
+payments  1        0.0       1.0      24     -            30d
+main      3        1.5       1.5      36     20000        15d
+
+4 log(s) over 1h0m0s, 0 not matching any index.
+`, text.String())
+
+	s = index.NewSimulator(datadogV1.LogsIndex{Name: "errors", Filter: datadogV1.LogsFilter{Query: datadog.PtrString("status:error")}, DailyLimit: datadog.PtrInt64(10)})
+	result := s.Run(map[string]interface{}{"status": "info"})
+	assert.Equal("no index", result.String())
+	assert.Equal(0.0, result.Kept())
+	result = s.Run(map[string]interface{}{"status": "error"})
+	report = s.Report([]*index.Result{result, result, result}, 6*time.Hour)
+	assert.Equal(12.0, report.Indexes[0].Daily)
+	assert.True(report.Indexes[0].OverLimit())
+	text.Reset()
+	assert.NoError(report.WriteText(&text))
+	assert.Contains(text.String(), "errors  3        0.0       3.0      12     10 (exceeded)  -")
+
+	_, err = s.RunJSONLines(strings.NewReader("{}\nnot json\n"))
+	assert.Error(err)
+	assert.True(strings.HasPrefix(err.Error(), "line 2: "))
+}
+
+func TestNewSimulatorFromAPI(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/logs/config/index-order", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"index_names": []string{"payments", "main"}})
+	})
+	mux.HandleFunc("/api/v1/logs/config/indexes", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(indexes))
+	})
+	ctx := tests.Serve(t, mux)
+	assert := tests.Assert(ctx, t)
+
+	s, err := index.NewSimulatorFromAPI(ctx, datadogV1.NewLogsIndexesApi(datadog.NewAPIClient(datadog.NewConfiguration())))
+	assert.NoError(err)
+	assert.Len(s.Indexes, 2)
+	assert.Equal("payments", s.Indexes[0].Name)
+	assert.Equal(`index "payments"`, s.Run(map[string]interface{}{"service": "payments"}).String())
+}
@@ -0,0 +1,200 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/logs/pipeline"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func testPipeline() datadogV1.LogsPipeline {
+	return datadogV1.LogsPipeline{
+		Name:   "nginx",
+		Filter: &datadogV1.LogsFilter{Query: datadog.PtrString("source:nginx")},
+		Processors: []datadogV1.LogsProcessor{
+			datadogV1.LogsStatusRemapperAsLogsProcessor(&datadogV1.LogsStatusRemapper{
+				Name:    datadog.PtrString("status"),
+				Sources: []string{"level"},
+				Type:    datadogV1.LOGSSTATUSREMAPPERTYPE_STATUS_REMAPPER,
+			}),
+			datadogV1.LogsDateRemapperAsLogsProcessor(&datadogV1.LogsDateRemapper{
+				Sources: []string{"timestamp"},
+				Type:    datadogV1.LOGSDATEREMAPPERTYPE_DATE_REMAPPER,
+			}),
+			datadogV1.LogsAttributeRemapperAsLogsProcessor(&datadogV1.LogsAttributeRemapper{
+				Sources:      []string{"code"},
+				Target:       "http.status_code",
+				TargetFormat: datadogV1.TARGETFORMATTYPE_INTEGER.Ptr(),
+				Type:         datadogV1.LOGSATTRIBUTEREMAPPERTYPE_ATTRIBUTE_REMAPPER,
+			}),
+			datadogV1.LogsURLParserAsLogsProcessor(&datadogV1.LogsURLParser{
+				Sources: []string{"http.url"},
+				Target:  "http.url_details",
+				Type:    datadogV1.LOGSURLPARSERTYPE_URL_PARSER,
+			}),
+			datadogV1.LogsCategoryProcessorAsLogsProcessor(&datadogV1.LogsCategoryProcessor{
+				Categories: []datadogV1.LogsCategoryProcessorCategory{
+					{Filter: &datadogV1.LogsFilter{Query: datadog.PtrString("@http.status_code:[500 TO 599]")}, Name: datadog.PtrString("5xx")},
+					{Filter: &datadogV1.LogsFilter{Query: datadog.PtrString("@http.status_code:[200 TO 299]")}, Name: datadog.PtrString("2xx")},
+				},
+				Target: "http.status_category",
+				Type:   datadogV1.LOGSCATEGORYPROCESSORTYPE_CATEGORY_PROCESSOR,
+			}),
+			datadogV1.LogsArithmeticProcessorAsLogsProcessor(&datadogV1.LogsArithmeticProcessor{
+				Expression: "duration_ms * 1000000",
+				Target:     "duration",
+				Type:       datadogV1.LOGSARITHMETICPROCESSORTYPE_ARITHMETIC_PROCESSOR,
+			}),
+			datadogV1.LogsPipelineProcessorAsLogsProcessor(&datadogV1.LogsPipelineProcessor{
+				Name:   datadog.PtrString("errors"),
+				Filter: &datadogV1.LogsFilter{Query: datadog.PtrString("status:error")},
+				Processors: []datadogV1.LogsProcessor{
+					datadogV1.LogsStringBuilderProcessorAsLogsProcessor(&datadogV1.LogsStringBuilderProcessor{
+						Template: "%{http.url_details.path} failed with %{http.status_code}",
+						Target:   "error.message",
+						Type:     datadogV1.LOGSSTRINGBUILDERPROCESSORTYPE_STRING_BUILDER_PROCESSOR,
+					}),
+					datadogV1.LogsLookupProcessorAsLogsProcessor(&datadogV1.LogsLookupProcessor{
+						Source:      "http.url_details.path",
+						Target:      "owner",
+						LookupTable: []string{"/checkout,payments", "/search,discovery"},
+						Type:        datadogV1.LOGSLOOKUPPROCESSORTYPE_LOOKUP_PROCESSOR,
+					}),
+				},
+				Type: datadogV1.LOGSPIPELINEPROCESSORTYPE_PIPELINE,
+			}),
+		},
+	}
+}
+
+func TestSimulatorRun(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	simulator := pipeline.NewSimulator(testPipeline())
+
+	result, err := simulator.RunJSON([]byte(`{
+		"ddsource": "nginx",
+		"level": "err",
+		"timestamp": 1577836800000,
+		"code": "502",
+		"duration_ms": 12,
+		"http": {"url": "https://shop.example.com:8443/checkout?cart=1"}
+	}`))
+	assert.NoError(err)
+
+	out := result.Output
+	assert.Equal("error", out["status"])
+	assert.Equal("2020-01-01T00:00:00.000Z", out["date"])
+	assert.NotContains(out, "code")
+	assert.Equal(float64(12000000), out["duration"])
+	http := out["http"].(map[string]interface{})
+	assert.Equal(float64(502), http["status_code"])
+	assert.Equal("5xx", http["status_category"])
+	details := http["url_details"].(map[string]interface{})
+	assert.Equal("shop.example.com", details["host"])
+	assert.Equal(float64(8443), details["port"])
+	assert.Equal(map[string]interface{}{"cart": "1"}, details["queryString"])
+	assert.Equal("/checkout failed with 502", out["error"].(map[string]interface{})["message"])
+	assert.Equal("payments", out["owner"])
+
+	// pipeline, 6 processors, nested pipeline processor and its 2 processors
+	assert.Len(result.Trace, 10)
+	for _, step := range result.Trace {
+		assert.True(step.Applied, "%s %s: %s", step.Type, step.Name, step.Reason)
+	}
+	assert.Equal([]string{"nginx", "errors"}, result.Trace[9].Pipeline)
+	assert.Equal([]pipeline.Change{{Attribute: "owner", After: "payments"}}, result.Trace[9].Changes)
+}
+
+func TestSimulatorFilters(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	disabled := testPipeline()
+	disabled.Name = "disabled"
+	disabled.IsEnabled = datadog.PtrBool(false)
+	simulator := pipeline.NewSimulator(disabled, testPipeline())
+
+	results, err := simulator.RunJSONLines(strings.NewReader("{\"ddsource\":\"apache\",\"level\":\"warn\"}\n\n{\"ddsource\":\"nginx\",\"level\":\"info\"}\n"))
+	assert.NoError(err)
+	assert.Len(results, 2)
+
+	assert.Len(results[0].Trace, 2)
+	assert.Equal("pipeline disabled", results[0].Trace[0].Reason)
+	assert.Equal("filter did not match", results[0].Trace[1].Reason)
+	assert.Equal("warn", results[0].Output["level"])
+	assert.NotContains(results[0].Output, "status")
+
+	assert.Equal("info", results[1].Output["status"])
+	last := results[1].Trace[len(results[1].Trace)-1]
+	assert.Equal("errors", last.Name)
+	assert.False(last.Applied)
+}
+
+func TestOrderPipelines(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	pipelines := []datadogV1.LogsPipeline{
+		{Id: datadog.PtrString("a"), Name: "a"},
+		{Id: datadog.PtrString("b"), Name: "b"},
+		{Id: datadog.PtrString("c"), Name: "c"},
+	}
+	ordered := pipeline.OrderPipelines(pipelines, []string{"c", "a"})
+	assert.Equal("c", ordered[0].Name)
+	assert.Equal("a", ordered[1].Name)
+	assert.Equal("b", ordered[2].Name)
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	assert.Equal("critical", pipeline.NormalizeStatus(float64(2)))
+	assert.Equal("emergency", pipeline.NormalizeStatus("FATAL"))
+	assert.Equal("warning", pipeline.NormalizeStatus("WARN"))
+	assert.Equal("debug", pipeline.NormalizeStatus("trace"))
+	assert.Equal("ok", pipeline.NormalizeStatus("success"))
+	assert.Equal("info", pipeline.NormalizeStatus("???"))
+}
+
+func TestSimulatorGrok(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	simulator := pipeline.NewSimulator(datadogV1.LogsPipeline{
+		Name: "app",
+		Processors: []datadogV1.LogsProcessor{
+			datadogV1.LogsGrokParserAsLogsProcessor(&datadogV1.LogsGrokParser{
+				Grok:   datadogV1.LogsGrokParserRules{MatchRules: `rule %{word:level} %{data:msg}`},
+				Source: "message",
+				Type:   datadogV1.LOGSGROKPARSERTYPE_GROK_PARSER,
+			}),
+			datadogV1.LogsStatusRemapperAsLogsProcessor(&datadogV1.LogsStatusRemapper{
+				Sources: []string{"level"},
+				Type:    datadogV1.LOGSSTATUSREMAPPERTYPE_STATUS_REMAPPER,
+			}),
+		},
+	})
+
+	result := simulator.Run(map[string]interface{}{"message": "WARN disk almost full"})
+	assert.Equal("rule", result.Trace[1].Rule)
+	assert.Equal("WARN", result.Output["level"])
+	assert.Equal("disk almost full", result.Output["msg"])
+	assert.Equal("warning", result.Output["status"])
+
+	// the caches of queries and grok parsers are shared by concurrent runs
+	simulator = pipeline.NewSimulator(simulator.Pipelines[0], testPipeline())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := simulator.Run(map[string]interface{}{"message": "ERROR out of memory", "ddsource": "nginx"})
+			assert.Equal("error", result.Output["status"])
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/logs/query"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+var testLog = map[string]interface{}{
+	"message":  "GET /api/v1/users returned 500 in 120ms",
+	"host":     "web-1",
+	"service":  "frontend",
+	"ddsource": "nginx",
+	"status":   "Error",
+	"ddtags":   "env:prod,team:web",
+	"http": map[string]interface{}{
+		"status_code": float64(500),
+		"method":      "GET",
+	},
+	"duration": float64(120),
+}
+
+func TestMatch(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	testCases := []struct {
+		query    string
+		expected bool
+	}{
+		{"", true},
+		{"*", true},
+		{"service:frontend", true},
+		{"service:backend", false},
+		{"source:nginx", true},
+		{"status:error", true},
+		{"env:prod", true},
+		{"env:staging", false},
+		{"team:w*", true},
+		{"@http.status_code:500", true},
+		{"@http.status_code:>=500", true},
+		{"@http.status_code:[400 TO 499]", false},
+		{"@duration:{100 TO 120]", true},
+		{"@http.method:(POST OR GET)", true},
+		{"returned", true},
+		{`"returned 500"`, true},
+		{"return*", true},
+		{"service:frontend AND -env:prod", false},
+		{"service:frontend NOT env:staging", true},
+		{"(service:backend OR host:web-*) env:prod", true},
+		{"@missing:*", false},
+		{"@http.method:*", true},
+	}
+	for _, tc := range testCases {
+		matched, err := query.Match(tc.query, testLog)
+		assert.NoError(err, tc.query)
+		assert.Equal(tc.expected, matched, tc.query)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	for _, q := range []string{"(service:web", "@duration:[1 TO]", "NOT"} {
+		_, err := query.Parse(q)
+		assert.Error(err, q)
+	}
+}
+
+func TestLookupSetDelete(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+	log := map[string]interface{}{"a.b": "flat"}
+	query.Set(log, "c.d", float64(1))
+
+	value, ok := query.Lookup(log, "a.b")
+	assert.True(ok)
+	assert.Equal("flat", value)
+	value, ok = query.Lookup(log, "c.d")
+	assert.True(ok)
+	assert.Equal(float64(1), value)
+
+	query.Delete(log, "c.d")
+	_, ok = query.Lookup(log, "c.d")
+	assert.False(ok)
+}
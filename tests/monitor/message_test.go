@@ -0,0 +1,96 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/monitor"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+const message = `{{#is_alert}}CPU is {{value}}% on {{host.name}} (threshold {{threshold}}) @pagerduty-infra{{/is_alert}}
+{{#is_warning}}CPU is getting high on {{host.name}}{{/is_warning}}
+{{#is_recovery}}Recovered{{#is_alert_recovery}} from alert{{/is_alert_recovery}}.{{/is_recovery}}
+{{^is_recovery}}{{#is_match "env.name" "prod" "staging"}}@slack-ops-{{env.name}}{{/is_match}}{{/is_recovery}}
+{{#is_exact_match "team.name" "sre"}}@sre@example.com.{{/is_exact_match}}
+{{! routing notes }}{{#is_priority 'P1'}}@oncall-primary{{/is_priority}}
+@webhook-audit`
+
+func TestRender(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	tags := map[string]string{"host": "web-1", "env": "prod", "team": "sre"}
+	values := map[string]string{"value": "97.5", "threshold": "90"}
+
+	m, err := monitor.Render(message, datadogV1.MONITORTYPE_METRIC_ALERT, monitor.Context{State: monitor.StateAlert, Priority: "P1", Tags: tags, Values: values})
+	assert.NoError(err)
+	assert.Equal("CPU is 97.5% on web-1 (threshold 90) @pagerduty-infra\n\n\n@slack-ops-prod\n@sre@example.com.\n@oncall-primary\n@webhook-audit", m.Text)
+	assert.Equal([]string{"pagerduty-infra", "slack-ops-prod", "sre@example.com", "oncall-primary", "webhook-audit"}, m.Handles)
+	assert.Empty(m.Unknown)
+
+	m, err = monitor.Render(message, datadogV1.MONITORTYPE_METRIC_ALERT, monitor.Context{State: monitor.StateOK, Previous: monitor.StateAlert, Tags: map[string]string{"host": "web-1", "env": "dev"}})
+	assert.NoError(err)
+	assert.Equal("\n\nRecovered from alert.\n\n\n\n@webhook-audit", m.Text)
+	assert.Equal([]string{"webhook-audit"}, m.Handles)
+
+	m, err = monitor.Render(message, datadogV1.MONITORTYPE_METRIC_ALERT, monitor.Context{State: monitor.StateWarning, Tags: map[string]string{"env": "staging-eu"}})
+	assert.NoError(err)
+	assert.Equal([]string{"slack-ops-staging-eu", "webhook-audit"}, m.Handles)
+	assert.Contains(m.Text, "CPU is getting high on \n")
+}
+
+func TestRenderUnknown(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	template := "{{#is_alert}}{{log.message}}{{/is_alert}} {{#is_alrt}}x{{/is_alrt}} {{#is_match \"span.service\" \"web\"}}{{/is_match}} {{service.name}} {{valeu}}"
+	m, err := monitor.Render(template, datadogV1.MONITORTYPE_LOG_ALERT, monitor.Context{State: monitor.StateOK, Values: map[string]string{"log.message": "boom"}})
+	assert.NoError(err)
+	assert.Equal([]string{"#is_alrt", "span.service", "valeu"}, m.Unknown)
+
+	m, err = monitor.Render(template, datadogV1.MONITORTYPE_TRACE_ANALYTICS_ALERT, monitor.Context{State: monitor.StateAlert})
+	assert.NoError(err)
+	assert.Equal([]string{"#is_alrt", "log.message", "valeu"}, m.Unknown)
+
+	for template, expected := range map[string]string{
+		"{{#is_alert}}down":                    "unclosed section {{#is_alert}}",
+		"{{#is_alert}}down{{/is_warning}}":     "{{/is_warning}} closes {{#is_alert}}",
+		"{{/is_alert}}":                        "unexpected {{/is_alert}}",
+		"CPU {{value":                          "unclosed tag at offset 4",
+		`{{#is_match "env.name}}{{/is_match}}`: `unterminated string "env.name`,
+	} {
+		_, err := monitor.Render(template, datadogV1.MONITORTYPE_METRIC_ALERT, monitor.Context{})
+		assert.EqualError(err, expected)
+	}
+}
+
+func TestRenderMonitor(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	m := datadogV1.NewMonitor("avg(last_5m):avg:system.cpu.user{*} by {host} > 90", datadogV1.MONITORTYPE_QUERY_ALERT)
+	m.SetId(12)
+	m.SetMessage("{{#is_alert}}CPU high on {{host.name}} @slack-ops{{/is_alert}}")
+	m.Options = datadogV1.NewMonitorOptions()
+	m.Options.SetEscalationMessage("Still high @pagerduty-infra")
+
+	c := monitor.Context{State: monitor.StateAlert, Tags: map[string]string{"host": "web-1"}}
+	rendered, err := monitor.RenderMonitor(*m, c)
+	assert.NoError(err)
+	assert.Equal([]string{"slack-ops"}, rendered.Handles)
+
+	c.Renotify = true
+	rendered, err = monitor.RenderMonitor(*m, c)
+	assert.NoError(err)
+	assert.Equal("CPU high on web-1 @slack-ops\n\nStill high @pagerduty-infra", rendered.Text)
+	assert.Equal([]string{"slack-ops", "pagerduty-infra"}, rendered.Handles)
+
+	m.SetMessage("{{#is_alert}}")
+	_, err = monitor.RenderMonitor(*m, c)
+	assert.EqualError(err, "monitor 12: unclosed section {{#is_alert}}")
+}
@@ -0,0 +1,188 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/multiorg"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+const orgs = `
+- public_id: parent
+  name: Parent
+  api_key: ${PARENT_API_KEY}
+  app_key: parent-app
+- public_id: child-a
+  api_key: a-api
+  app_key: a-app
+  site: datadoghq.eu
+- public_id: child-b
+  api_key: b-api
+  app_key: b-app
+`
+
+type server struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func newRegistry(t *testing.T, s *server) (context.Context, *multiorg.Registry) {
+	t.Setenv("PARENT_API_KEY", "parent-api")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/monitor", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.inFlight++
+		if s.inFlight > s.max {
+			s.max = s.inFlight
+		}
+		s.mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+
+		key := r.Header.Get("DD-API-KEY")
+		if key == "b-api" {
+			w.WriteHeader(http.StatusForbidden)
+			tests.WriteJSON(w, map[string]interface{}{"errors": []string{"Forbidden"}})
+			return
+		}
+		tests.WriteJSON(w, []interface{}{
+			map[string]interface{}{"id": 1, "name": key + " monitor", "type": "metric alert", "query": "avg(last_5m):avg:system.load.1{*} > 1"},
+		})
+	})
+	mux.HandleFunc("/api/v1/org", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var body datadogV1.OrganizationCreateBody
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Name == "Child E" {
+				tests.WriteJSON(w, map[string]interface{}{
+					"org":     map[string]interface{}{"public_id": "child-e", "name": "Child E"},
+					"api_key": map[string]interface{}{"key": "e-api"},
+				})
+				return
+			}
+			tests.WriteJSON(w, map[string]interface{}{
+				"org":             map[string]interface{}{"public_id": "child-c", "name": "Child C"},
+				"api_key":         map[string]interface{}{"key": "c-api"},
+				"application_key": map[string]interface{}{"hash": "c-app"},
+			})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"orgs": []interface{}{
+			map[string]interface{}{"public_id": "parent"},
+			map[string]interface{}{"public_id": "child-a"},
+			map[string]interface{}{"public_id": "child-d", "name": "Child D"},
+		}})
+	})
+	ctx := tests.Serve(t, mux)
+	decoded, err := multiorg.Unmarshal([]byte(orgs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := multiorg.NewRegistry(datadog.NewAPIClient(datadog.NewConfiguration()), decoded...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctx, registry
+}
+
+func listMonitors(ctx context.Context, client *datadog.APIClient, org multiorg.Org) (interface{}, error) {
+	monitors, _, err := datadogV1.NewMonitorsApi(client).ListMonitors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range monitors {
+		names = append(names, m.GetName())
+	}
+	return names, nil
+}
+
+func TestRun(t *testing.T) {
+	s := &server{}
+	ctx, registry := newRegistry(t, s)
+	assert := tests.Assert(ctx, t)
+
+	registry.Concurrency = 2
+	results := registry.Run(ctx, listMonitors)
+	assert.Len(results, 3)
+	assert.Equal("child-a", results[0].Org.PublicID)
+	assert.Equal([]string{"a-api monitor"}, results[0].Value)
+	assert.Error(results[1].Err)
+	assert.Equal([]string{"parent-api monitor"}, results[2].Value)
+	assert.Equal(2, s.max)
+
+	err := multiorg.Errors(results)
+	assert.IsType(&multiorg.Error{}, err)
+	assert.Contains(err.(*multiorg.Error).Errs, "child-b")
+	assert.True(strings.HasPrefix(err.Error(), "1 of 3 org(s) failed: child-b: 403 Forbidden"))
+
+	results = registry.Run(ctx, listMonitors, "parent", "child-x")
+	assert.Equal([]string{"parent-api monitor"}, results[0].Value)
+	assert.EqualError(results[1].Err, "org child-x is not registered")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	registry.Concurrency = 1
+	results = registry.Run(canceled, listMonitors)
+	assert.EqualError(multiorg.Errors(results), "3 of 3 org(s) failed: child-a: context canceled; child-b: context canceled; parent: context canceled")
+}
+
+func TestContext(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	ctx := context.WithValue(context.Background(), datadog.ContextServerVariables, map[string]string{"protocol": "http"})
+	org := multiorg.Org{PublicID: "a", Site: "datadoghq.eu", APIKey: "k", AppKey: "h"}
+	ctx = org.Context(ctx)
+	assert.Equal(map[string]string{"protocol": "http", "site": "datadoghq.eu"}, ctx.Value(datadog.ContextServerVariables))
+	assert.Equal("h", ctx.Value(datadog.ContextAPIKeys).(map[string]datadog.APIKey)["appKeyAuth"].Key)
+
+	_, err := multiorg.Unmarshal([]byte("- public_id: a\n  api_key: k\n"))
+	assert.EqualError(err, "org a has no API or application key")
+
+	_, err = multiorg.Unmarshal([]byte("- public_id: a\n  api_key: k\n  app_keys: h\n"))
+	assert.EqualError(err, "yaml: unmarshal errors:\n  line 3: field app_keys not found in type multiorg.Org")
+}
+
+func TestChildOrgs(t *testing.T) {
+	ctx, registry := newRegistry(t, &server{})
+	assert := tests.Assert(ctx, t)
+
+	unregistered, err := registry.Unregistered(ctx, "parent")
+	assert.NoError(err)
+	assert.Len(unregistered, 1)
+	assert.Equal("child-d", unregistered[0].GetPublicId())
+
+	child, err := registry.CreateChild(ctx, "parent", "Child C")
+	assert.NoError(err)
+	assert.Equal(multiorg.Org{PublicID: "child-c", Name: "Child C", APIKey: "c-api", AppKey: "c-app"}, child)
+	_, ok := registry.Get("child-c")
+	assert.True(ok)
+
+	_, err = registry.CreateChild(ctx, "child-x", "Child")
+	assert.EqualError(err, "org child-x is not registered")
+
+	// The org exists once created, so its keys are returned even though it
+	// cannot be registered without an application key.
+	child, err = registry.CreateChild(ctx, "parent", "Child E")
+	assert.EqualError(err, `registering child org "Child E" of parent: org child-e has no API or application key`)
+	assert.Equal(multiorg.Org{PublicID: "child-e", Name: "Child E", APIKey: "e-api"}, child)
+	_, ok = registry.Get("child-e")
+	assert.False(ok)
+}
@@ -0,0 +1,195 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/provision"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+const desired = `email,name,groups,active
+jane@example.com,Jane Doe,eng;sre
+new@example.com,New Person,eng
+OLD@example.com,,ops
+ci@example.com,,,false
+carl@example.com,,,no
+`
+
+func user(id, email string, attributes map[string]interface{}, roles ...string) map[string]interface{} {
+	attributes["email"] = email
+	attributes["handle"] = email
+	var data []interface{}
+	for _, r := range roles {
+		data = append(data, map[string]interface{}{"id": r, "type": "roles"})
+	}
+	return map[string]interface{}{"id": id, "type": "users", "attributes": attributes,
+		"relationships": map[string]interface{}{"roles": map[string]interface{}{"data": data}}}
+}
+
+type server struct {
+	requests []string
+}
+
+func (s *server) record(r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.requests = append(s.requests, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+string(body)))
+}
+
+func newProvisioner(t *testing.T, s *server) (context.Context, *provision.Provisioner) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/roles", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "r-std", "type": "roles", "attributes": map[string]interface{}{"name": "Standard"}},
+			map[string]interface{}{"id": "r-ro", "type": "roles", "attributes": map[string]interface{}{"name": "Read Only"}},
+			map[string]interface{}{"id": "r-adm", "type": "roles", "attributes": map[string]interface{}{"name": "Admin"}},
+		}})
+	})
+	mux.HandleFunc("/api/v2/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			s.record(r)
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "u-new", "type": "users"}})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			user("u-jane", "jane@example.com", map[string]interface{}{"name": "Jane"}, "r-ro"),
+			user("u-bob", "bob@example.com", map[string]interface{}{}, "r-std"),
+			user("u-old", "old@example.com", map[string]interface{}{"disabled": true}),
+			user("u-ci", "ci@example.com", map[string]interface{}{"service_account": true}),
+			user("u-carl", "carl@example.com", map[string]interface{}{}),
+			user("u-boss", "boss@example.com", map[string]interface{}{}),
+			user("u-gone", "gone@example.com", map[string]interface{}{"disabled": true}),
+		}})
+	})
+	mux.HandleFunc("/api/v2/users/", func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		if r.URL.Path == "/api/v2/users/u-carl" {
+			w.WriteHeader(http.StatusNotFound)
+			tests.WriteJSON(w, map[string]interface{}{"errors": []string{"Not found"}})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "u", "type": "users"}})
+	})
+	mux.HandleFunc("/api/v2/roles/", func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{}})
+	})
+	mux.HandleFunc("/api/v2/user_invitations", func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{}})
+	})
+	mux.HandleFunc("/api/v2/authn_mappings", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			s.record(r)
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "m", "type": "authn_mappings"}})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "m-eng", "type": "authn_mappings",
+				"attributes":    map[string]interface{}{"attribute_key": "memberOf", "attribute_value": "eng"},
+				"relationships": map[string]interface{}{"role": map[string]interface{}{"data": map[string]interface{}{"id": "r-std", "type": "roles"}}}},
+		}})
+	})
+	ctx := tests.Serve(t, mux)
+	p := provision.NewProvisioner(datadog.NewAPIClient(datadog.NewConfiguration()))
+	p.Groups = map[string][]string{"eng": {"Standard"}, "sre": {"Standard", "Admin"}}
+	p.AuthNAttribute = "memberOf"
+	p.DisableMissing = true
+	p.Protected = []string{"Boss@example.com"}
+	return ctx, p
+}
+
+func TestProvision(t *testing.T) {
+	s := &server{}
+	ctx, p := newProvisioner(t, s)
+	assert := tests.Assert(ctx, t)
+
+	users, err := provision.ReadCSV(strings.NewReader(desired))
+	assert.NoError(err)
+	plan, err := p.Plan(ctx, users)
+	assert.NoError(err)
+
+	var report bytes.Buffer
+	assert.NoError(plan.WriteText(&report))
+	assert.Equal(`rename user jane@example.com to "Jane Doe"
+add role "Admin" to user jane@example.com
+add role "Standard" to user jane@example.com
+remove role "Read Only" from user jane@example.com
+create user new@example.com with roles Standard
+enable user OLD@example.com
+disable user carl@example.com
+disable user bob@example.com
+map group "sre" to role "Standard"
+map group "sre" to role "Admin"
+warning: group "ops" maps to no role
+warning: user ci@example.com is a service account and is not provisioned
+warning: user boss@example.com is protected and is not disabled
+
+10 change(s): 1 create, 1 update, 1 enable, 2 disable, 2 add_role, 1 remove_role, 2 create_mapping.
+`, report.String())
+
+	assert.NoError(p.Apply(ctx, plan))
+	assert.Equal([]string{
+		`PATCH /api/v2/users/u-jane {"data":{"attributes":{"name":"Jane Doe"},"id":"u-jane","type":"users"}}`,
+		`POST /api/v2/roles/r-adm/users {"data":{"id":"u-jane","type":"users"}}`,
+		`POST /api/v2/roles/r-std/users {"data":{"id":"u-jane","type":"users"}}`,
+		`DELETE /api/v2/roles/r-ro/users {"data":{"id":"u-jane","type":"users"}}`,
+		`POST /api/v2/users {"data":{"attributes":{"email":"new@example.com","name":"New Person"},"relationships":{"roles":{"data":[{"id":"r-std","type":"roles"}]}},"type":"users"}}`,
+		`PATCH /api/v2/users/u-old {"data":{"attributes":{"disabled":false},"id":"u-old","type":"users"}}`,
+		`DELETE /api/v2/users/u-carl`,
+		`DELETE /api/v2/users/u-bob`,
+		`POST /api/v2/authn_mappings {"data":{"attributes":{"attribute_key":"memberOf","attribute_value":"sre"},"relationships":{"role":{"data":{"id":"r-std","type":"roles"}}},"type":"authn_mappings"}}`,
+		`POST /api/v2/authn_mappings {"data":{"attributes":{"attribute_key":"memberOf","attribute_value":"sre"},"relationships":{"role":{"data":{"id":"r-adm","type":"roles"}}},"type":"authn_mappings"}}`,
+		`POST /api/v2/user_invitations {"data":[{"relationships":{"user":{"data":{"id":"u-new","type":"users"}}},"type":"user_invitations"}]}`,
+	}, s.requests)
+
+	_, err = p.Plan(ctx, []provision.User{{Email: "x@example.com", Roles: []string{"Owner"}, Active: true}})
+	assert.EqualError(err, "unknown roles: Owner")
+}
+
+func TestSources(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	_, err := provision.ReadCSV(strings.NewReader("name\nJane\n"))
+	assert.EqualError(err, "missing email column")
+	_, err = provision.ReadCSV(strings.NewReader("email,active\njane@example.com,maybe\n"))
+	assert.EqualError(err, `line 2: invalid active value "maybe"`)
+
+	users, err := provision.ReadJSON(strings.NewReader(`[{"email": "jane@example.com", "roles": ["Admin"]}, {"email": "bob@example.com", "active": false}]`))
+	assert.NoError(err)
+	assert.Equal([]provision.User{
+		{Email: "jane@example.com", Roles: []string{"Admin"}, Active: true},
+		{Email: "bob@example.com"},
+	}, users)
+
+	users, err = provision.ReadSCIM(strings.NewReader(`{
+  "schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"],
+  "Resources": [
+    {"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "id": "1", "userName": "jdoe",
+     "name": {"givenName": "Jane", "familyName": "Doe"},
+     "emails": [{"value": "jane@home.example.com"}, {"value": "jane@example.com", "primary": true}],
+     "groups": [{"value": "g1", "display": "eng"}]},
+    {"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "id": "2", "userName": "bob@example.com", "displayName": "Bob", "active": false}
+  ]
+}`), strings.NewReader(`{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"], "id": "g2", "displayName": "sre", "members": [{"value": "1"}, {"value": "2"}]}`))
+	assert.NoError(err)
+	assert.Equal([]provision.User{
+		{Email: "jane@example.com", Name: "Jane Doe", Groups: []string{"eng", "sre"}, Active: true},
+		{Email: "bob@example.com", Name: "Bob", Groups: []string{"sre"}},
+	}, users)
+}
@@ -0,0 +1,210 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/rbac"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+	"gopkg.in/yaml.v3"
+)
+
+const declaration = `
+roles:
+  - name: Responders
+    permissions: [incident_read, incident_write]
+    users: [jane@example.com]
+  - name: Commanders
+    clone_from: Responders
+    permissions: [incident_read, incident_write, incident_settings_write]
+`
+
+func permissions(names ...string) map[string]interface{} {
+	var data []interface{}
+	for _, n := range names {
+		data = append(data, map[string]interface{}{"id": "p-" + n, "type": "permissions", "attributes": map[string]interface{}{"name": n}})
+	}
+	return map[string]interface{}{"data": data}
+}
+
+func users(handles ...string) map[string]interface{} {
+	var data []interface{}
+	for _, h := range handles {
+		data = append(data, map[string]interface{}{"id": "u-" + h, "type": "users", "attributes": map[string]interface{}{"handle": h}})
+	}
+	return map[string]interface{}{"data": data}
+}
+
+type server struct {
+	requests []string
+}
+
+// handle serves a response, and records the requests other than GET with
+// the ID or name of their data.
+func (s *server) handle(mux *http.ServeMux, path string, response interface{}) {
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			var body struct {
+				Data struct {
+					ID         string `json:"id"`
+					Attributes struct {
+						Name string `json:"name"`
+					} `json:"attributes"`
+				} `json:"data"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			s.requests = append(s.requests, strings.TrimSpace(r.Method+" "+path+" "+body.Data.ID+body.Data.Attributes.Name))
+		}
+		tests.WriteJSON(w, response)
+	})
+}
+
+func newSyncer(t *testing.T, s *server) (context.Context, *rbac.Syncer) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/permissions", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, permissions("incident_read", "incident_write", "incident_settings_write", "dashboards_write"))
+	})
+	mux.HandleFunc("/api/v2/roles", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			s.requests = append(s.requests, "POST /api/v2/roles")
+			tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{"id": "r-new", "type": "roles"}})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "r-resp", "type": "roles", "attributes": map[string]interface{}{"name": "Responders"}},
+			map[string]interface{}{"id": "r-ro", "type": "roles", "attributes": map[string]interface{}{"name": "Read Only"}},
+			map[string]interface{}{"id": "r-obs", "type": "roles", "attributes": map[string]interface{}{"name": "Observers"}},
+		}})
+	})
+	mux.HandleFunc("/api/v2/users", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": "u-jane@example.com", "type": "users", "attributes": map[string]interface{}{"handle": "jane@example.com"},
+				"relationships": map[string]interface{}{"roles": map[string]interface{}{"data": []interface{}{
+					map[string]interface{}{"id": "r-resp", "type": "roles"}, map[string]interface{}{"id": "r-ro", "type": "roles"},
+				}}}},
+			map[string]interface{}{"id": "u-bob@example.com", "type": "users", "attributes": map[string]interface{}{"handle": "bob@example.com"},
+				"relationships": map[string]interface{}{"roles": map[string]interface{}{"data": []interface{}{
+					map[string]interface{}{"id": "r-resp", "type": "roles"},
+				}}}},
+			map[string]interface{}{"id": "u-old@example.com", "type": "users", "attributes": map[string]interface{}{"handle": "old@example.com", "disabled": true}},
+		}})
+	})
+	s.handle(mux, "/api/v2/roles/r-resp/permissions", permissions("incident_read", "dashboards_write"))
+	s.handle(mux, "/api/v2/roles/r-ro/permissions", permissions("incident_read"))
+	s.handle(mux, "/api/v2/roles/r-resp/users", users("bob@example.com"))
+	s.handle(mux, "/api/v2/roles/r-ro/users", users("jane@example.com"))
+	s.handle(mux, "/api/v2/roles/r-obs/permissions", permissions("incident_read"))
+	s.handle(mux, "/api/v2/roles/r-obs/users", users())
+	s.handle(mux, "/api/v2/roles/r-resp/clone", map[string]interface{}{"data": map[string]interface{}{"id": "r-new", "type": "roles"}})
+	s.handle(mux, "/api/v2/roles/r-new/permissions", permissions())
+	ctx := tests.Serve(t, mux)
+	client := datadog.NewAPIClient(datadog.NewConfiguration())
+	return ctx, rbac.NewSyncer(datadogV2.NewRolesApi(client), datadogV2.NewUsersApi(client))
+}
+
+func TestSync(t *testing.T) {
+	s := &server{}
+	ctx, syncer := newSyncer(t, s)
+	assert := tests.Assert(ctx, t)
+
+	config, err := rbac.Unmarshal([]byte(declaration))
+	assert.NoError(err)
+	plan, err := syncer.Diff(ctx, config)
+	assert.NoError(err)
+
+	var text bytes.Buffer
+	assert.NoError(plan.WriteText(&text))
+	assert.Equal(`+ add permission incident_write to role "Responders"
+- remove permission dashboards_write from role "Responders"
++ add user jane@example.com to role "Responders"
+- remove user bob@example.com from role "Responders"
++ create role "Commanders" as a clone of "Responders"
++ add permission incident_settings_write to role "Commanders"
+
+Plan: 4 to add, 2 to remove.
+`, text.String())
+
+	assert.NoError(syncer.Apply(ctx, plan))
+	assert.Equal([]string{
+		"POST /api/v2/roles/r-resp/permissions p-incident_write",
+		"DELETE /api/v2/roles/r-resp/permissions p-dashboards_write",
+		"POST /api/v2/roles/r-resp/users u-jane@example.com",
+		"DELETE /api/v2/roles/r-resp/users u-bob@example.com",
+		"POST /api/v2/roles/r-resp/clone Commanders",
+		"POST /api/v2/roles/r-new/permissions p-incident_settings_write",
+	}, s.requests)
+
+	config.Roles[0].Permissions = append(config.Roles[0].Permissions, "logs_admin")
+	_, err = syncer.Diff(ctx, config)
+	assert.EqualError(err, "unknown permissions or users: logs_admin")
+
+	_, err = rbac.Unmarshal([]byte("roles:\n  - name: A\n  - name: A\n"))
+	assert.EqualError(err, `role "A" is declared twice`)
+}
+
+func TestExport(t *testing.T) {
+	ctx, syncer := newSyncer(t, &server{})
+	assert := tests.Assert(ctx, t)
+
+	config, err := syncer.Export(ctx, "Responders")
+	assert.NoError(err)
+	assert.Equal(&rbac.Config{Roles: []rbac.Role{
+		{Name: "Responders", Permissions: []string{"dashboards_write", "incident_read"}, Users: []string{"bob@example.com"}},
+	}}, config)
+
+	config, err = syncer.Export(ctx, "Observers", "Responders")
+	assert.NoError(err)
+	data, err := yaml.Marshal(config)
+	assert.NoError(err)
+	assert.Equal(`roles:
+    - name: Observers
+      permissions:
+        - incident_read
+      users: []
+    - name: Responders
+      permissions:
+        - dashboards_write
+        - incident_read
+      users:
+        - bob@example.com
+`, string(data))
+	decoded, err := rbac.Unmarshal(data)
+	assert.NoError(err)
+	assert.Equal(config, decoded)
+
+	data, err = yaml.Marshal(rbac.Role{Name: "Observers", Permissions: []string{"incident_read"}})
+	assert.NoError(err)
+	assert.Equal("name: Observers\npermissions:\n    - incident_read\n", string(data))
+
+	_, err = syncer.Export(ctx, "Admins")
+	assert.EqualError(err, "unknown roles: Admins")
+}
+
+func TestAccess(t *testing.T) {
+	ctx, syncer := newSyncer(t, &server{})
+	assert := tests.Assert(ctx, t)
+
+	access, err := syncer.Access(ctx)
+	assert.NoError(err)
+	assert.Equal([]string{"dashboards_write", "incident_read"}, access.Permissions)
+	assert.Len(access.Users, 2)
+
+	var out bytes.Buffer
+	assert.NoError(access.WriteCSV(&out))
+	assert.Equal(`user,roles,dashboards_write,incident_read
+bob@example.com,Responders,Responders,Responders
+jane@example.com,Read Only; Responders,Responders,Read Only; Responders
+`, out.String())
+}
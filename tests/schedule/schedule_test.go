@@ -0,0 +1,305 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/schedule"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %v", name, err)
+	}
+	return loc
+}
+
+func format(times []time.Time) []string {
+	out := make([]string, len(times))
+	for i, t := range times {
+		out[i] = t.Format(time.RFC3339)
+	}
+	return out
+}
+
+func TestRuleBetween(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+	paris := mustLocation(t, "Europe/Paris")
+
+	testCases := map[string]struct {
+		rule     string
+		dtstart  time.Time
+		within   schedule.Interval
+		expected []string
+	}{
+		"daily across DST keeps the wall clock": {
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2024, 3, 29, 9, 0, 0, 0, paris),
+			within:  schedule.Interval{End: time.Date(2024, 4, 2, 0, 0, 0, 0, paris)},
+			expected: []string{
+				"2024-03-29T09:00:00+01:00",
+				"2024-03-30T09:00:00+01:00",
+				"2024-03-31T09:00:00+02:00",
+				"2024-04-01T09:00:00+02:00",
+			},
+		},
+		"nonexistent times are skipped": {
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2024, 3, 30, 2, 30, 0, 0, paris),
+			expected: []string{
+				"2024-03-30T02:30:00+01:00",
+				"2024-04-01T02:30:00+02:00",
+				"2024-04-02T02:30:00+02:00",
+			},
+		},
+		"weekly on several days with an interval": {
+			rule:    "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;UNTIL=20240118",
+			dtstart: time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-02T22:00:00Z",
+				"2024-01-04T22:00:00Z",
+				"2024-01-16T22:00:00Z",
+				"2024-01-18T22:00:00Z",
+			},
+		},
+		"last friday of the month": {
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-26T18:00:00Z",
+				"2024-02-23T18:00:00Z",
+				"2024-03-29T18:00:00Z",
+			},
+		},
+		"last weekday of the month": {
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3",
+			dtstart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-31T00:00:00Z",
+				"2024-02-29T00:00:00Z",
+				"2024-03-29T00:00:00Z",
+			},
+		},
+		"monthly on the 31st skips short months": {
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-31T00:00:00Z",
+				"2024-03-31T00:00:00Z",
+				"2024-05-31T00:00:00Z",
+			},
+		},
+		"yearly with months and hours": {
+			rule:    "FREQ=YEARLY;BYMONTH=6,12;BYMONTHDAY=-1;BYHOUR=8,20",
+			dtstart: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			within:  schedule.Interval{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expected: []string{
+				"2024-06-30T08:00:00Z",
+				"2024-06-30T20:00:00Z",
+				"2024-12-31T08:00:00Z",
+				"2024-12-31T20:00:00Z",
+			},
+		},
+		"hourly far from the start": {
+			rule:    "FREQ=HOURLY;INTERVAL=6;BYMINUTE=15",
+			dtstart: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			within:  schedule.Interval{Start: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+			expected: []string{
+				"2024-05-01T00:15:00Z",
+				"2024-05-01T06:15:00Z",
+				"2024-05-01T12:15:00Z",
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := tests.Assert(ctx, t)
+			rule, err := schedule.ParseRule(tc.rule)
+			assert.NoError(err)
+			times, err := rule.Between(tc.dtstart, tc.within)
+			assert.NoError(err)
+			assert.Equal(tc.expected, format(times))
+		})
+	}
+
+	rule, err := schedule.ParseRule("FREQ=DAILY")
+	assert.NoError(err)
+	_, err = rule.Between(time.Now(), schedule.Interval{})
+	assert.ErrorIs(err, schedule.ErrUnbounded)
+
+	rule, err = schedule.ParseRule("freq=weekly;byday=mo,fr;interval=2;wkst=su;count=4")
+	assert.NoError(err)
+	assert.Equal("FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=MO,FR;WKST=SU", rule.String())
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	for rule, message := range map[string]string{
+		"":                                  "empty recurrence rule",
+		"INTERVAL=2":                        "has no FREQ",
+		"FREQ=SECONDLY":                     "unsupported frequency",
+		"FREQ=DAILY;COUNT=0":                "invalid COUNT",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101": "mutually exclusive",
+		"FREQ=WEEKLY;BYDAY=1MO":             "needs a MONTHLY or YEARLY frequency",
+		"FREQ=MONTHLY;BYMONTHDAY=32":        "out of range",
+		"FREQ=DAILY;DTSTART=20240101":       "unsupported recurrence rule part DTSTART",
+		"FREQ=DAILY;UNTIL=tomorrow":         "is not a date",
+	} {
+		_, err := schedule.ParseRule(rule)
+		assert.Error(err, rule)
+		assert.Contains(err.Error(), message, rule)
+	}
+}
+
+func TestDowntimes(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+	newYork := mustLocation(t, "America/New_York")
+
+	start := time.Date(2024, 3, 4, 22, 0, 0, 0, newYork) // Monday
+	weekly := datadogV1.Downtime{
+		Id:       datadog.PtrInt64(1),
+		Scope:    []string{"env:prod,service:web"},
+		Start:    datadog.PtrInt64(start.Unix()),
+		End:      *datadog.NewNullableInt64(datadog.PtrInt64(start.Add(2 * time.Hour).Unix())),
+		Timezone: datadog.PtrString("America/New_York"),
+		Recurrence: *datadogV1.NewNullableDowntimeRecurrence(&datadogV1.DowntimeRecurrence{
+			Type:             datadog.PtrString("weeks"),
+			Period:           datadog.PtrInt32(1),
+			WeekDays:         []string{"Mon", "Wed"},
+			UntilOccurrences: *datadog.NewNullableInt32(datadog.PtrInt32(4)),
+		}),
+	}
+	child := datadogV1.Downtime{
+		Id:       datadog.PtrInt64(2),
+		ParentId: *datadog.NewNullableInt64(datadog.PtrInt64(1)),
+		Scope:    []string{"env:prod,service:web"},
+		Start:    datadog.PtrInt64(start.Unix()),
+		End:      *datadog.NewNullableInt64(datadog.PtrInt64(start.Add(2 * time.Hour).Unix())),
+	}
+	host := datadogV1.Downtime{
+		Id:       datadog.PtrInt64(3),
+		Scope:    []string{"host:web-1"},
+		Start:    datadog.PtrInt64(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC).Unix()),
+		Canceled: *datadog.NewNullableInt64(datadog.PtrInt64(time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC).Unix())),
+	}
+	monitor := datadogV1.Downtime{
+		Id:        datadog.PtrInt64(4),
+		Scope:     []string{"*"},
+		MonitorId: *datadog.NewNullableInt64(datadog.PtrInt64(42)),
+		Start:     datadog.PtrInt64(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		End:       *datadog.NewNullableInt64(datadog.PtrInt64(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Unix())),
+	}
+	disabled := monitor
+	disabled.Id = datadog.PtrInt64(5)
+	disabled.Disabled = datadog.PtrBool(true)
+	downtimes := []datadogV1.Downtime{weekly, child, host, monitor, disabled}
+
+	windows, err := schedule.DowntimeWindows(downtimes, schedule.Interval{
+		Start: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(err)
+	var got []string
+	for _, w := range windows {
+		got = append(got, fmt.Sprintf("%d %s %s", w.Downtime.GetId(), w.Start.UTC().Format(time.RFC3339), w.End.UTC().Format(time.RFC3339)))
+	}
+	assert.Equal([]string{
+		"4 2024-03-01T00:00:00Z 2024-04-01T00:00:00Z",
+		"1 2024-03-05T03:00:00Z 2024-03-05T05:00:00Z",
+		"1 2024-03-07T03:00:00Z 2024-03-07T05:00:00Z",
+		"3 2024-03-11T00:00:00Z 2024-03-12T00:00:00Z",
+		// DST started on March 10th in New York.
+		"1 2024-03-12T02:00:00Z 2024-03-12T04:00:00Z",
+		"1 2024-03-14T02:00:00Z 2024-03-14T04:00:00Z",
+	}, got)
+
+	web := schedule.Target{Tags: []string{"host:web-1", "env:prod", "service:web"}}
+	ids := func(at time.Time, target schedule.Target) []int64 {
+		muting, err := schedule.MutedBy(downtimes, target, at)
+		assert.NoError(err)
+		var out []int64
+		for _, d := range muting {
+			out = append(out, d.GetId())
+		}
+		return out
+	}
+	assert.Equal([]int64{4, 1}, ids(time.Date(2024, 3, 7, 4, 0, 0, 0, time.UTC), web))
+	assert.Equal([]int64{4, 3}, ids(time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC), web))
+	assert.Equal([]int64{4}, ids(time.Date(2024, 3, 12, 12, 0, 0, 0, time.UTC), web))
+	web.MonitorID = 7
+	assert.Empty(ids(time.Date(2024, 3, 12, 12, 0, 0, 0, time.UTC), web))
+	assert.Empty(ids(time.Date(2024, 3, 7, 4, 0, 0, 0, time.UTC), schedule.Target{Tags: []string{"env:prod"}, MonitorID: 7}))
+}
+
+func TestCorrectionWindows(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	day := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	corrections := []datadogV1.SLOCorrection{
+		{
+			Id: datadog.PtrString("deploys"),
+			Attributes: &datadogV1.SLOCorrectionResponseAttributes{
+				Start:    datadog.PtrInt64(day.Add(-7*24*time.Hour + 23*time.Hour).Unix()),
+				Duration: *datadog.NewNullableInt64(datadog.PtrInt64(7200)),
+				Rrule:    *datadog.NewNullableString(datadog.PtrString("FREQ=DAILY;INTERVAL=1")),
+				Timezone: datadog.PtrString("UTC"),
+			},
+		},
+		{
+			Id: datadog.PtrString("outage"),
+			Attributes: &datadogV1.SLOCorrectionResponseAttributes{
+				Start: datadog.PtrInt64(day.Add(3 * time.Hour).Unix()),
+				End:   datadog.PtrInt64(day.Add(4 * time.Hour).Unix()),
+			},
+		},
+		{
+			Id: datadog.PtrString("old"),
+			Attributes: &datadogV1.SLOCorrectionResponseAttributes{
+				Start: datadog.PtrInt64(day.Add(-48 * time.Hour).Unix()),
+				End:   datadog.PtrInt64(day.Add(-47 * time.Hour).Unix()),
+			},
+		},
+	}
+	windows, err := schedule.CorrectionWindows(corrections, schedule.Interval{Start: day, End: day.Add(24 * time.Hour)})
+	assert.NoError(err)
+	var got []string
+	for _, w := range windows {
+		got = append(got, w.Correction.GetId()+" "+w.Start.UTC().Format("02T15:04")+"-"+w.End.UTC().Format("02T15:04"))
+	}
+	assert.Equal([]string{
+		"deploys 02T23:00-03T01:00",
+		"outage 03T03:00-03T04:00",
+		"deploys 03T23:00-04T01:00",
+	}, got)
+
+	intervals := make([]schedule.Interval, 0, len(windows))
+	for _, w := range windows {
+		intervals = append(intervals, w.Interval)
+	}
+	intervals = append(intervals, schedule.Interval{Start: day.Add(30 * time.Minute), End: day.Add(3*time.Hour + 30*time.Minute)})
+	merged := schedule.Merge(intervals)
+	assert.Len(merged, 2)
+	assert.Equal(day.Add(-time.Hour), merged[0].Start)
+	assert.Equal(day.Add(4*time.Hour), merged[0].End)
+
+	corrections[0].Attributes.Rrule = *datadog.NewNullableString(datadog.PtrString("FREQ=DAILY;BYWEEKNO=1"))
+	_, err = schedule.CorrectionWindows(corrections, schedule.Interval{Start: day, End: day.Add(24 * time.Hour)})
+	assert.EqualError(err, "correction deploys: unsupported recurrence rule part BYWEEKNO")
+}
@@ -0,0 +1,42 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+)
+
+// WriteJSON writes a response body as JSON.
+func WriteJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// WithServer returns a context sending the requests of API clients to a
+// server over HTTP.
+func WithServer(ctx context.Context, url string) context.Context {
+	ctx = context.WithValue(ctx, datadog.ContextServerIndex, 1)
+	return context.WithValue(ctx, datadog.ContextServerVariables, map[string]string{
+		"protocol": "http",
+		"name":     strings.TrimPrefix(url, "http://"),
+	})
+}
+
+// Serve starts a server closed at the end of the test, and returns a
+// context sending the requests of API clients to it.
+func Serve(t *testing.T, handler http.Handler) context.Context {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return WithServer(context.Background(), server.URL)
+}
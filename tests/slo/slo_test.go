@@ -0,0 +1,183 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/schedule"
+	"github.com/DataDog/datadog-api-client-go/v2/slo"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+var at = time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+// metricHistory returns 30 daily buckets of 1000 events, with 0.5% of bad
+// events, 10% on the 10th day and 4% on the last day.
+func metricHistory() map[string]interface{} {
+	var times, good, total []float64
+	for day := 0; day < 30; day++ {
+		start := at.AddDate(0, 0, day-30)
+		times = append(times, float64(start.UnixMilli()))
+		g := 995.0
+		switch day {
+		case 9:
+			g = 900
+		case 29:
+			g = 960
+		}
+		good = append(good, g)
+		total = append(total, 1000)
+	}
+	return map[string]interface{}{
+		"from_ts": at.AddDate(0, 0, -30).Unix(),
+		"to_ts":   at.Unix(),
+		"type":    "metric",
+		"series": map[string]interface{}{
+			"interval":     86400,
+			"query":        "sum:good{*}.as_count(),sum:total{*}.as_count()",
+			"res_type":     "time_series",
+			"resp_version": 2,
+			"times":        times,
+			"numerator":    map[string]interface{}{"count": 30, "sum": 0, "values": good},
+			"denominator":  map[string]interface{}{"count": 30, "sum": 0, "values": total},
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	var historyQuery string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/slo/abc", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{
+			"id":         "abc",
+			"name":       "Checkout availability",
+			"type":       "metric",
+			"thresholds": []map[string]interface{}{{"timeframe": "7d", "target": 99.5}, {"timeframe": "30d", "target": 99}},
+		}})
+	})
+	mux.HandleFunc("/api/v1/slo/abc/history", func(w http.ResponseWriter, r *http.Request) {
+		historyQuery = r.URL.RawQuery
+		tests.WriteJSON(w, map[string]interface{}{"data": metricHistory()})
+	})
+	mux.HandleFunc("/api/v1/slo/abc/corrections", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"data": []map[string]interface{}{{
+			"id":   "incident",
+			"type": "correction",
+			"attributes": map[string]interface{}{
+				"slo_id":   "abc",
+				"category": "Scheduled Maintenance",
+				"start":    at.AddDate(0, 0, -21).Unix(),
+				"end":      at.AddDate(0, 0, -20).Unix(),
+			},
+		}}})
+	})
+	ctx := tests.Serve(t, mux)
+	analyzer := slo.NewAnalyzer(datadogV1.NewServiceLevelObjectivesApi(datadog.NewAPIClient(datadog.NewConfiguration())))
+	report, err := analyzer.Analyze(ctx, "abc", datadogV1.SLOTIMEFRAME_THIRTY_DAYS, at)
+	assert.NoError(err)
+	assert.Contains(historyQuery, "apply_correction=false")
+	assert.Contains(historyQuery, "from_ts=1717200000")
+
+	assert.Equal("Checkout availability", report.SLO.Name)
+	assert.Len(report.Corrections, 1)
+	// The corrected 10th day is excluded: 180 bad events out of 29000.
+	assert.InDelta(29000, report.Budget.Total, 1e-6)
+	assert.InDelta(180, report.Budget.Consumed, 1e-6)
+	assert.InDelta(290, report.Budget.Allowed, 1e-6)
+	assert.InDelta(37.931, report.Budget.Remaining, 1e-3)
+	assert.InDelta(99.379, report.Budget.SLI, 1e-3)
+
+	var firing []string
+	for _, a := range report.Alerts {
+		if a.Firing {
+			firing = append(firing, a.Alert.Name+" "+a.Alert.Long.String())
+		}
+	}
+	assert.Equal([]string{"ticket 24h0m0s", "ticket 72h0m0s"}, firing)
+	assert.InDelta(14.4, report.Alerts[0].Threshold, 1e-9)
+	assert.InDelta(4, report.Alerts[0].LongBurnRate, 1e-9)
+
+	assert.True(report.Projection.Exhausts)
+	assert.InDelta(4, report.Projection.BurnRate, 1e-9)
+	assert.InDelta(0.37931*30/4, report.Projection.TimeToExhaustion.Hours()/24, 1e-3)
+
+	spending := slo.Spending(mustSeries(t, metricHistory()), report.Budget, 24*time.Hour)
+	assert.Len(spending, 30)
+	assert.InDelta(100.0/290*100, spending[9].Percent, 1e-6)
+	assert.InDelta(40.0/290*100, spending[29].Percent, 1e-6)
+
+	_, err = analyzer.Analyze(ctx, "abc", datadogV1.SLOTIMEFRAME_NINETY_DAYS, at)
+	assert.EqualError(err, "SLO has no 90d threshold")
+}
+
+func mustSeries(t *testing.T, history map[string]interface{}) slo.Series {
+	raw, _ := json.Marshal(history)
+	var data datadogV1.SLOHistoryResponseData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	series, err := slo.HistorySeries(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return series
+}
+
+func TestMonitorSeries(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	start := at.Add(-7 * 24 * time.Hour)
+	data := datadogV1.SLOHistoryResponseData{
+		FromTs: datadog.PtrInt64(start.Unix()),
+		ToTs:   datadog.PtrInt64(at.Unix()),
+		Overall: &datadogV1.SLOHistorySLIData{History: [][]float64{
+			{float64(start.Unix()), 0},
+			{float64(at.Add(-10 * time.Hour).Unix()), 1},
+			{float64(at.Add(-8 * time.Hour).Unix()), 0},
+		}},
+		Groups: []datadogV1.SLOHistoryMonitor{
+			{Group: datadog.PtrString("host:a"), History: [][]float64{{float64(start.Unix()), 0}}},
+			{Group: datadog.PtrString("host:b"), History: [][]float64{{float64(start.Unix()), 0}, {float64(at.Add(-10 * time.Hour).Unix()), 1}}},
+		},
+	}
+	series, err := slo.HistorySeries(data)
+	assert.NoError(err)
+	assert.Len(series, 3)
+
+	window := schedule.Interval{Start: start, End: at}
+	budget := slo.ComputeBudget(series, 99.9, window)
+	assert.InDelta(7*24*3600, budget.Total, 1e-6)
+	assert.InDelta(2*3600, budget.Consumed, 1e-6)
+	assert.Less(budget.Remaining, 0.0)
+	assert.True(slo.Project(budget, 7*24*time.Hour, 0).Exhausts)
+
+	// Half of the outage was a maintenance window.
+	corrected := series.Exclude([]schedule.Interval{{Start: at.Add(-9 * time.Hour), End: at.Add(-8 * time.Hour)}})
+	budget = slo.ComputeBudget(corrected, 99, window)
+	assert.InDelta(3600, budget.Consumed, 1e-6)
+	assert.InDelta(float64(7*24*3600-3600)*0.01, budget.Allowed, 1e-6)
+
+	groups, err := slo.GroupSeries(data)
+	assert.NoError(err)
+	assert.Len(groups, 2)
+	assert.Equal(100.0, slo.ComputeBudget(groups["host:a"], 99, window).SLI)
+	assert.InDelta(10*3600, slo.ComputeBudget(groups["host:b"], 99, window).Consumed, 1e-6)
+	assert.InDelta(0, slo.BurnRate(groups["host:a"], 99, schedule.Interval{Start: at.Add(-time.Hour), End: at}), 1e-9)
+	assert.InDelta(100, slo.BurnRate(groups["host:b"], 99, schedule.Interval{Start: at.Add(-time.Hour), End: at}), 1e-9)
+
+	_, err = slo.Timeframe(datadogV1.SLOTIMEFRAME_CUSTOM)
+	assert.EqualError(err, `unsupported timeframe "custom"`)
+}
@@ -0,0 +1,254 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/synthetics/ci"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+// fakeSynthetics serves a batch of two tests: a blocking API test that fails
+// and a non blocking browser test that passes.
+func fakeSynthetics(t *testing.T, triggered *datadogV1.SyntheticsCITestBody) context.Context {
+	var polls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/synthetics/tests", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"tests": []map[string]interface{}{
+			{"public_id": "aaa-aaa-aaa", "tags": []string{"env:prod", "team:web"}},
+			{"public_id": "bbb-bbb-bbb", "tags": []string{"env:prod"}},
+			{"public_id": "ccc-ccc-ccc", "tags": []string{"env:staging", "team:web"}},
+		}})
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/trigger/ci", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(triggered)
+		tests.WriteJSON(w, map[string]interface{}{
+			"batch_id":            "batch-1",
+			"triggered_check_ids": []string{"aaa-aaa-aaa", "bbb-bbb-bbb"},
+		})
+	})
+	mux.HandleFunc("/api/v1/synthetics/ci/batch/batch-1", func(w http.ResponseWriter, r *http.Request) {
+		status := "in_progress"
+		if atomic.AddInt32(&polls, 1) > 1 {
+			status = "failed"
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": map[string]interface{}{
+			"status": status,
+			"results": []map[string]interface{}{
+				{"result_id": "r1", "status": status, "test_public_id": "aaa-aaa-aaa", "test_name": "API", "test_type": "api", "location": "aws:eu-west-1", "duration": 1500, "execution_rule": "blocking"},
+				{"result_id": "r2", "status": "passed", "test_public_id": "bbb-bbb-bbb", "test_name": "Browser", "test_type": "browser", "location": "aws:eu-west-1", "device": "laptop_large", "duration": 2000, "execution_rule": "non_blocking"},
+			},
+		}})
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/aaa-aaa-aaa/results/r1", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"result": map[string]interface{}{
+			"failure":        map[string]interface{}{"code": "INCORRECT_ASSERTION", "message": "status code is 500"},
+			"httpStatusCode": 500,
+		}})
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/browser/bbb-bbb-bbb/results/r2", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, map[string]interface{}{"result": map[string]interface{}{}})
+	})
+	return tests.Serve(t, mux)
+}
+
+func newRunner(config ci.Config) *ci.Runner {
+	api := datadogV1.NewSyntheticsApi(datadog.NewAPIClient(datadog.NewConfiguration()))
+	if config.PollInterval == 0 {
+		config.PollInterval = time.Millisecond
+	}
+	return ci.NewRunner(api, config)
+}
+
+func TestRunnerPollsUntilFinished(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	var triggered datadogV1.SyntheticsCITestBody
+	ctx = fakeSynthetics(t, &triggered)
+	runner := newRunner(ci.Config{
+		PublicIDs:          []string{"ccc-ccc-ccc"},
+		Tags:               []string{"env:prod"},
+		Overrides:          &datadogV1.SyntheticsCITest{Locations: []string{"aws:eu-west-1"}},
+		Metadata:           &datadogV1.SyntheticsCIBatchMetadata{Git: &datadogV1.SyntheticsCIBatchMetadataGit{Branch: datadog.PtrString("main")}},
+		FailOnMissingTests: true,
+	})
+	summary, err := runner.Run(ctx)
+	assert.NoError(err)
+
+	assert.Len(triggered.Tests, 3)
+	assert.Equal("ccc-ccc-ccc", triggered.Tests[0].PublicId)
+	assert.Equal([]string{"aws:eu-west-1"}, triggered.Tests[1].Locations)
+	assert.Equal("main", triggered.Tests[2].Metadata.Git.GetBranch())
+
+	assert.Equal("batch-1", summary.BatchID)
+	assert.Equal([]string{"ccc-ccc-ccc"}, summary.Missing)
+	assert.Len(summary.Results, 2)
+	assert.Equal(ci.StatusFailed, summary.Results[0].Status)
+	assert.Equal("status code is 500", summary.Results[0].Failure)
+	assert.Equal("INCORRECT_ASSERTION", summary.Results[0].FailureCode)
+	assert.Equal(500, summary.Results[0].HTTPStatusCode)
+	assert.Equal(1500*time.Millisecond, summary.Results[0].Duration)
+	assert.False(summary.Results[1].Blocking)
+	assert.Equal(1, summary.Failed)
+	assert.Equal(1, summary.Passed)
+	assert.False(summary.Success)
+	assert.Len(summary.Reasons, 2)
+
+	var text bytes.Buffer
+	assert.NoError(summary.WriteText(&text))
+	assert.Contains(text.String(), "[FAILED] API (aaa-aaa-aaa) on aws:eu-west-1")
+	assert.Contains(text.String(), "[NOT FOUND] ccc-ccc-ccc")
+
+	var report bytes.Buffer
+	assert.NoError(summary.WriteJUnit(&report))
+	var parsed struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Cases []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	assert.NoError(xml.Unmarshal(report.Bytes(), &parsed))
+	assert.Equal(3, parsed.Tests)
+	assert.Equal(1, parsed.Failures)
+	assert.Equal(1, parsed.Errors)
+	assert.Equal("API (aaa-aaa-aaa)", parsed.Suites[0].Name)
+	assert.Equal("status code is 500", parsed.Suites[0].Cases[0].Failure.Message)
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/synthetics/tests/trigger/ci", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"batch_id": "batch-2", "triggered_check_ids": ["aaa-aaa-aaa"]}`))
+	})
+	mux.HandleFunc("/api/v1/synthetics/ci/batch/batch-2", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"data": {"status": "in_progress", "results": [{"result_id": "r1", "status": "in_progress", "test_public_id": "aaa-aaa-aaa", "execution_rule": "blocking"}]}}`))
+	})
+	ctx = tests.Serve(t, mux)
+
+	for _, failOnTimeout := range []bool{true, false} {
+		runner := newRunner(ci.Config{
+			PublicIDs:     []string{"aaa-aaa-aaa"},
+			Metadata:      &datadogV1.SyntheticsCIBatchMetadata{},
+			Timeout:       20 * time.Millisecond,
+			FailOnTimeout: failOnTimeout,
+		})
+		summary, err := runner.Run(ctx)
+		assert.NoError(err)
+		assert.Equal(ci.StatusTimedOut, summary.Results[0].Status)
+		assert.Equal(1, summary.TimedOut)
+		assert.Equal(!failOnTimeout, summary.Success)
+	}
+}
+
+func TestRunnerPollsAtDeadline(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	var polls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/synthetics/tests/trigger/ci", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"batch_id": "batch-3", "triggered_check_ids": ["aaa-aaa-aaa"]}`))
+	})
+	mux.HandleFunc("/api/v1/synthetics/ci/batch/batch-3", func(w http.ResponseWriter, r *http.Request) {
+		status := "in_progress"
+		if atomic.AddInt32(&polls, 1) > 1 {
+			status = "passed"
+		}
+		tests.WriteJSON(w, json.RawMessage(`{"data": {"status": "`+status+`", "results": [{"result_id": "r1", "status": "`+status+`", "test_public_id": "aaa-aaa-aaa", "execution_rule": "blocking"}]}}`))
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/aaa-aaa-aaa/results/r1", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"result": {}}`))
+	})
+	ctx = tests.Serve(t, mux)
+
+	// The second poll lands exactly on the deadline and sees the result.
+	runner := newRunner(ci.Config{
+		PublicIDs:     []string{"aaa-aaa-aaa"},
+		Metadata:      &datadogV1.SyntheticsCIBatchMetadata{},
+		PollInterval:  50 * time.Millisecond,
+		Timeout:       50 * time.Millisecond,
+		FailOnTimeout: true,
+	})
+	summary, err := runner.Run(ctx)
+	assert.NoError(err)
+	assert.Equal(int32(2), atomic.LoadInt32(&polls))
+	assert.Equal(ci.StatusPassed, summary.Results[0].Status)
+	assert.Equal(0, summary.TimedOut)
+	assert.True(summary.Success)
+}
+
+func TestRunnerBrowserFinishedWithError(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/synthetics/tests/trigger/ci", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"batch_id": "batch-4", "triggered_check_ids": ["bbb-bbb-bbb"]}`))
+	})
+	mux.HandleFunc("/api/v1/synthetics/ci/batch/batch-4", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"data": {"status": "failed", "results": [{"result_id": "r2", "status": "failed", "test_public_id": "bbb-bbb-bbb", "test_type": "browser", "execution_rule": "non_blocking"}]}}`))
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/browser/bbb-bbb-bbb/results/r2", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"result": {"eventType": "finished_with_error", "error": "browser crashed"}}`))
+	})
+	ctx = tests.Serve(t, mux)
+
+	for _, failOnCritical := range []bool{true, false} {
+		runner := newRunner(ci.Config{
+			PublicIDs:            []string{"bbb-bbb-bbb"},
+			Metadata:             &datadogV1.SyntheticsCIBatchMetadata{},
+			FailOnCriticalErrors: failOnCritical,
+		})
+		summary, err := runner.Run(ctx)
+		assert.NoError(err)
+		assert.Equal("test finished with error: browser crashed", summary.Results[0].CriticalError)
+		assert.Equal(1, summary.CriticalErrors)
+		assert.Equal(!failOnCritical, summary.Success)
+	}
+}
+
+func TestMetadataFromEnv(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_HEAD_REF", "")
+	t.Setenv("GITHUB_REF", "refs/heads/feature")
+	t.Setenv("GITHUB_SHA", "abc123")
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("DD_GIT_COMMIT_SHA", "def456")
+
+	metadata := ci.MetadataFromEnv()
+	assert.Equal("feature", metadata.Git.GetBranch())
+	assert.Equal("def456", metadata.Git.GetCommitSha())
+	assert.Equal("github", metadata.Ci.Provider.GetName())
+	assert.Equal("https://github.com/org/repo/actions/runs/42", metadata.Ci.Pipeline.GetUrl())
+}
@@ -0,0 +1,207 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/synthetics/local"
+	"github.com/DataDog/datadog-api-client-go/v2/synthetics/spec"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "jane" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		tests.WriteJSON(w, json.RawMessage(`{"token": "abc123", "user": {"roles": ["admin", "dev"], "age": 42}}`))
+	})
+	mux.HandleFunc("/api/orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(`<orders><order id="1"><total>10.5</total></order><order id="2" status="open"><total>99</total></order></orders>`))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/api/login", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func assertions(t *testing.T, lines ...string) []datadogV1.SyntheticsAssertion {
+	specs, err := spec.Unmarshal([]byte("name: x\ntype: api\nlocations: []\nassertions:\n" + yamlList(lines)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := specs[0].APITest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return test.Config.Assertions
+}
+
+func yamlList(lines []string) string {
+	var out string
+	for _, l := range lines {
+		out += "  - '" + l + "'\n"
+	}
+	return out
+}
+
+func TestRunHTTPTest(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+	server := newServer(t)
+
+	test := datadogV1.SyntheticsAPITest{
+		Name:    "login",
+		Subtype: datadogV1.SYNTHETICSTESTDETAILSSUBTYPE_HTTP.Ptr(),
+		Config: datadogV1.SyntheticsAPITestConfig{
+			Request: &datadogV1.SyntheticsTestRequest{
+				Method: datadog.PtrString("POST"),
+				Url:    datadog.PtrString("https://api.example.com/api/login"),
+				BasicAuth: &datadogV1.SyntheticsBasicAuth{SyntheticsBasicAuthWeb: &datadogV1.SyntheticsBasicAuthWeb{
+					Username: "{{ USER }}",
+					Password: "secret",
+				}},
+			},
+			Assertions: assertions(t,
+				"statusCode is 200",
+				"responseTime lessThan 5000",
+				"header content-type contains json",
+				"header x-missing doesNotExist",
+				"body $.token is abc123",
+				"body $.user.roles[*] is dev",
+				"body $..age moreThan 40",
+				"body $.user.roles isNot nobody",
+				"body matches \"token\"",
+				"statusCode is 201",
+				"body $.missing is 1",
+				"latency lessThan 10",
+			),
+			ConfigVariables: []datadogV1.SyntheticsConfigVariable{
+				{Name: "USER", Type: datadogV1.SYNTHETICSCONFIGVARIABLETYPE_TEXT, Example: datadog.PtrString("jane")},
+			},
+		},
+	}
+	runner := &local.Runner{Client: server.Client(), BaseURL: server.URL}
+	result, err := runner.Run(ctx, test)
+	assert.NoError(err)
+	assert.Len(result.Steps, 1)
+	step := result.Steps[0]
+	assert.Empty(step.Error)
+	assert.Equal(200, step.Response.StatusCode)
+	assert.Equal(server.URL+"/api/login", step.URL)
+
+	var passed, failed, skipped []string
+	for _, a := range step.Assertions {
+		switch {
+		case a.Skipped:
+			skipped = append(skipped, a.Assertion.String())
+		case a.Passed:
+			passed = append(passed, a.Assertion.String())
+		default:
+			failed = append(failed, a.Assertion.String())
+		}
+	}
+	assert.Len(passed, 9)
+	assert.Equal([]string{"statusCode is 201", "body $.missing is 1"}, failed)
+	assert.Equal([]string{"latency lessThan 10"}, skipped)
+	assert.Equal("FAIL statusCode is 201: got \"200\"", step.Assertions[9].String())
+	assert.False(result.Passed)
+}
+
+func TestRunMultistepTest(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+	server := newServer(t)
+
+	test := datadogV1.SyntheticsAPITest{
+		Name:    "orders",
+		Subtype: datadogV1.SYNTHETICSTESTDETAILSSUBTYPE_MULTI.Ptr(),
+		Config: datadogV1.SyntheticsAPITestConfig{
+			Steps: []datadogV1.SyntheticsAPIStep{
+				{
+					Name: "login",
+					Request: datadogV1.SyntheticsTestRequest{
+						Method: datadog.PtrString("POST"),
+						Url:    datadog.PtrString("http://localhost/api/login"),
+						BasicAuth: &datadogV1.SyntheticsBasicAuth{SyntheticsBasicAuthWeb: &datadogV1.SyntheticsBasicAuthWeb{
+							Username: "jane",
+							Password: "secret",
+						}},
+					},
+					Assertions: assertions(t, "statusCode is 200"),
+					ExtractedValues: []datadogV1.SyntheticsParsingOptions{{
+						Name:   datadog.PtrString("TOKEN"),
+						Type:   datadogV1.SYNTHETICSGLOBALVARIABLEPARSETESTOPTIONSTYPE_HTTP_BODY.Ptr(),
+						Parser: &datadogV1.SyntheticsVariableParser{Type: datadogV1.SYNTHETICSGLOBALVARIABLEPARSERTYPE_JSON_PATH, Value: datadog.PtrString("$.token")},
+					}},
+				},
+				{
+					Name: "orders",
+					Request: datadogV1.SyntheticsTestRequest{
+						Method:  datadog.PtrString("GET"),
+						Url:     datadog.PtrString("http://localhost/api/orders"),
+						Headers: map[string]string{"Authorization": "Bearer {{ TOKEN }}"},
+					},
+					Assertions: assertions(t,
+						"statusCode is 200",
+						"body /orders/order[2]/@status is open",
+						"body //order[@id=\"1\"]/total is 10.5",
+						"body /orders/order[last()]/total moreThan 50",
+					),
+				},
+			},
+		},
+	}
+	runner := &local.Runner{Client: server.Client(), BaseURL: server.URL}
+	result, err := runner.Run(ctx, test)
+	assert.NoError(err)
+	assert.Len(result.Steps, 2)
+	assert.Equal("abc123", result.Steps[0].Extracted["TOKEN"])
+	for _, a := range result.Steps[1].Assertions {
+		assert.True(a.Passed, a.String())
+	}
+	assert.True(result.Passed)
+}
+
+func TestRedirects(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+	server := newServer(t)
+
+	runner := &local.Runner{Client: server.Client(), BaseURL: server.URL}
+	request := datadogV1.SyntheticsTestRequest{
+		Method:          datadog.PtrString("GET"),
+		Url:             datadog.PtrString("http://localhost/redirect"),
+		FollowRedirects: datadog.PtrBool(false),
+	}
+	step := runner.RunRequest(ctx, request, assertions(t, "statusCode is 302", "header location is /api/login"))
+	assert.True(step.Passed)
+
+	request.FollowRedirects = nil
+	step = runner.RunRequest(ctx, request, assertions(t, "statusCode is 401"))
+	assert.True(step.Passed)
+
+	step = runner.RunRequest(ctx, request, assertions(t, "statusCode is 401", "body $.token is x"))
+	assert.False(step.Passed)
+	assert.Contains(step.Assertions[1].Message, "invalid JSON")
+}
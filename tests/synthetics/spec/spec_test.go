@@ -0,0 +1,265 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/synthetics/spec"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+const apiSpec = `
+name: Checkout API
+type: api
+subtype: http
+status: live
+message: Checkout is down @team-web
+tags: [env:prod, team:web]
+locations: [aws:eu-west-1, My private location]
+options:
+  tick_every: 300
+  min_location_failed: 1
+  retry: {count: 2, interval: 300}
+request:
+  method: GET
+  url: https://example.com/api/checkout
+  headers: {Authorization: "Bearer {{ TOKEN }}"}
+assertions:
+  - statusCode is 200
+  - responseTime lessThan 500
+  - header content-type contains json
+  - body $.status is "ok"
+  - body /html/head/title contains Checkout
+  - type: body
+    operator: contains
+    target: needs  spaces
+variables:
+  - name: TOKEN
+    global: checkout_token
+  - name: ORDER
+    pattern: "{{ numeric(6) }}"
+    example: "123456"
+---
+name: Checkout flow
+type: browser
+locations: [aws:us-east-1]
+request: {method: GET, url: https://example.com}
+options: {device_ids: [laptop_large], tick_every: 900}
+browser_variables:
+  - name: EMAIL
+    type: email
+steps:
+  - name: Click on checkout
+    type: click
+    params: {element: {userLocator: {failTestOnCannotLocate: true}}}
+    allow_failure: true
+`
+
+func testResolver() *spec.Resolver {
+	return spec.NewResolver(
+		[]datadogV1.SyntheticsLocation{
+			{Id: datadog.PtrString("aws:eu-west-1"), Name: datadog.PtrString("Ireland (AWS)")},
+			{Id: datadog.PtrString("aws:us-east-1"), Name: datadog.PtrString("N. Virginia (AWS)")},
+			{Id: datadog.PtrString("pl:my-private-location-1234"), Name: datadog.PtrString("My private location")},
+		},
+		[]datadogV1.SyntheticsGlobalVariable{
+			{Id: datadog.PtrString("var-1"), Name: "checkout_token"},
+		},
+	)
+}
+
+func TestAPITestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	specs, err := spec.Unmarshal([]byte(apiSpec))
+	assert.NoError(err)
+	assert.Len(specs, 2)
+
+	r := testResolver()
+	test, err := specs[0].APITest(r)
+	assert.NoError(err)
+	assert.Equal([]string{"aws:eu-west-1", "pl:my-private-location-1234"}, test.Locations)
+	assert.Equal(int64(300), test.Options.GetTickEvery())
+	assert.Equal(int64(2), test.Options.Retry.GetCount())
+	assert.Equal("GET", test.Config.Request.GetMethod())
+	assert.Len(test.Config.Assertions, 6)
+	assert.Equal(datadogV1.SYNTHETICSASSERTIONTYPE_STATUS_CODE, test.Config.Assertions[0].SyntheticsAssertionTarget.Type)
+	assert.Equal(int64(200), test.Config.Assertions[0].SyntheticsAssertionTarget.Target)
+	assert.Equal("content-type", test.Config.Assertions[2].SyntheticsAssertionTarget.GetProperty())
+	jsonPath := test.Config.Assertions[3].SyntheticsAssertionJSONPathTarget
+	assert.Equal("$.status", jsonPath.Target.GetJsonPath())
+	assert.Equal("ok", jsonPath.Target.TargetValue)
+	assert.Equal("/html/head/title", test.Config.Assertions[4].SyntheticsAssertionXPathTarget.Target.GetXPath())
+	assert.Equal("var-1", test.Config.ConfigVariables[0].GetId())
+	assert.Equal(datadogV1.SYNTHETICSCONFIGVARIABLETYPE_GLOBAL, test.Config.ConfigVariables[0].Type)
+	assert.Equal(datadogV1.SYNTHETICSCONFIGVARIABLETYPE_TEXT, test.Config.ConfigVariables[1].Type)
+
+	// Go through the JSON representation, as when reading the test from the API.
+	raw, err := json.Marshal(test)
+	assert.NoError(err)
+	var fetched datadogV1.SyntheticsAPITest
+	assert.NoError(json.Unmarshal(raw, &fetched))
+	exported, err := spec.FromAPITest(fetched, r)
+	assert.NoError(err)
+	assert.Equal([]string{"aws:eu-west-1", "My private location"}, exported.Locations)
+	assert.Equal("checkout_token", exported.Variables[0].Global)
+	assert.Equal(specs[0].Assertions, exported.Assertions)
+
+	out, err := spec.Marshal(exported)
+	assert.NoError(err)
+	assert.Contains(string(out), "- statusCode is 200\n")
+	assert.Contains(string(out), `- body $.status is ok`)
+	assert.Contains(string(out), "- body contains needs  spaces\n")
+	reread, err := spec.Unmarshal(out)
+	assert.NoError(err)
+	assert.Equal(exported, reread[0])
+}
+
+func TestBrowserTestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	specs, err := spec.Unmarshal([]byte(apiSpec))
+	assert.NoError(err)
+	test, err := specs[1].BrowserTest(testResolver())
+	assert.NoError(err)
+	assert.Equal(datadogV1.SYNTHETICSBROWSERTESTTYPE_BROWSER, test.Type)
+	assert.Equal([]datadogV1.SyntheticsDeviceID{datadogV1.SYNTHETICSDEVICEID_LAPTOP_LARGE}, test.Options.DeviceIds)
+	assert.Equal(datadogV1.SYNTHETICSBROWSERVARIABLETYPE_EMAIL, test.Config.Variables[0].Type)
+	assert.Equal(datadogV1.SYNTHETICSSTEPTYPE_CLICK, test.Steps[0].GetType())
+	assert.True(test.Steps[0].GetAllowFailure())
+
+	exported, err := spec.FromBrowserTest(test, testResolver())
+	assert.NoError(err)
+	assert.Equal(specs[1].Steps[0].Params, exported.Steps[0].Params)
+	assert.Equal("email", exported.BrowserVariables[0].Type)
+
+	_, err = specs[1].APITest(nil)
+	assert.Error(err)
+}
+
+func TestAssertionErrors(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	_, err := spec.Unmarshal([]byte("name: x\ntype: api\nassertions: [statusCode]\n"))
+	assert.Error(err)
+
+	specs, err := spec.Unmarshal([]byte("name: x\ntype: api\nlocations: [nowhere]\nassertions: [statusCode equals 200]\n"))
+	assert.NoError(err)
+	_, err = specs[0].APITest(nil)
+	assert.Error(err)
+	_, err = specs[0].APITest(testResolver())
+	assert.Error(err)
+
+	_, err = spec.Unmarshal([]byte("name: x\ntype: synthetic\n"))
+	assert.Error(err)
+
+	a, err := spec.ParseAssertion(`header x-count is "42"`)
+	assert.NoError(err)
+	assert.Equal("42", a.Target)
+	assert.Equal(`header x-count is "42"`, a.String())
+}
+
+func TestUnknownFields(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	_, err := spec.Unmarshal([]byte("name: x\ntype: api\nlocation: [aws:eu-west-1]\n"))
+	assert.Error(err)
+
+	for _, options := range []string{"{tick_evry: 300}", "{retry: {count: 2, intervl: 300}}"} {
+		specs, err := spec.Unmarshal([]byte("name: x\ntype: api\nlocations: [aws:eu-west-1]\noptions: " + options + "\n"))
+		assert.NoError(err)
+		_, err = specs[0].APITest(testResolver())
+		assert.Error(err, options)
+	}
+
+	specs, err := spec.Unmarshal([]byte("name: x\ntype: api\nlocations: [aws:eu-west-1]\nrequest: {method: GET, ulr: https://example.com}\n"))
+	assert.NoError(err)
+	_, err = specs[0].APITest(testResolver())
+	assert.EqualError(err, `test "x": request: unknown field "ulr"`)
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	assert := tests.Assert(ctx, t)
+
+	stored := map[string][]byte{}
+	var updates, creates int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/synthetics/tests/api", func(w http.ResponseWriter, r *http.Request) {
+		creates++
+		body, _ := io.ReadAll(r.Body)
+		var test map[string]interface{}
+		json.Unmarshal(body, &test)
+		test["public_id"] = "new-api-tst"
+		stored["new-api-tst"], _ = json.Marshal(test)
+		tests.WriteJSON(w, json.RawMessage(stored["new-api-tst"]))
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/api/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/synthetics/tests/api/")
+		if r.Method == http.MethodPut {
+			updates++
+			stored[id], _ = io.ReadAll(r.Body)
+		}
+		tests.WriteJSON(w, json.RawMessage(stored[id]))
+	})
+	mux.HandleFunc("/api/v1/synthetics/tests/", func(w http.ResponseWriter, r *http.Request) {
+		tests.WriteJSON(w, json.RawMessage(`{"type": "api"}`))
+	})
+	ctx = tests.Serve(t, mux)
+	api := datadogV1.NewSyntheticsApi(datadog.NewAPIClient(datadog.NewConfiguration()))
+	syncer := spec.NewSyncer(api, testResolver())
+
+	specs, err := spec.Unmarshal([]byte(apiSpec))
+	assert.NoError(err)
+	specs = specs[:1]
+
+	results, err := syncer.Sync(ctx, specs)
+	assert.NoError(err)
+	assert.Equal(spec.ActionCreated, results[0].Action)
+	assert.Equal("new-api-tst", specs[0].PublicID)
+
+	results, err = syncer.Sync(ctx, specs)
+	assert.NoError(err)
+	assert.Equal(spec.ActionUnchanged, results[0].Action)
+
+	// Tags and locations are sets.
+	specs[0].Tags = []string{"team:web", "env:prod"}
+	specs[0].Locations = []string{"My private location", "aws:eu-west-1"}
+	results, err = syncer.Sync(ctx, specs)
+	assert.NoError(err)
+	assert.Equal(spec.ActionUnchanged, results[0].Action)
+
+	specs[0].Tags = append(specs[0].Tags, "owner:checkout")
+	syncer.DryRun = true
+	results, err = syncer.Sync(ctx, specs)
+	assert.NoError(err)
+	assert.Equal(spec.ActionUpdated, results[0].Action)
+	assert.Equal(0, updates)
+
+	syncer.DryRun = false
+	results, err = syncer.Sync(ctx, specs)
+	assert.NoError(err)
+	assert.Equal(spec.ActionUpdated, results[0].Action)
+	assert.Equal(1, updates)
+	assert.Equal(1, creates)
+
+	exported, err := syncer.Export(ctx, "new-api-tst")
+	assert.NoError(err)
+	assert.Contains(exported.Tags, "owner:checkout")
+}
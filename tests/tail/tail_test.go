@@ -0,0 +1,175 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/tail"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type item struct {
+	id string
+	ts time.Time
+}
+
+type store struct {
+	mu      sync.Mutex
+	items   []item
+	queries []string
+	late    *item
+}
+
+// page returns the items of the window from a cursor, and the next cursor.
+func (s *store) page(query, from, to, cursor string, limit int) ([]item, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, query)
+	start, _ := time.Parse(time.RFC3339, from)
+	end, _ := time.Parse(time.RFC3339, to)
+	if s.late != nil && !end.Before(base.Add(50*time.Second)) {
+		s.items = append(s.items, *s.late)
+		s.late = nil
+	}
+	var window []item
+	for _, i := range s.items {
+		if !i.ts.Before(start) && i.ts.Before(end) {
+			window = append(window, i)
+		}
+	}
+	sort.Slice(window, func(a, b int) bool { return window[a].ts.Before(window[b].ts) })
+
+	offset, _ := strconv.Atoi(cursor)
+	if offset+limit >= len(window) {
+		return window[offset:], ""
+	}
+	return window[offset : offset+limit], strconv.Itoa(offset + limit)
+}
+
+func serve(t *testing.T, s *store) context.Context {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/logs/events/search", func(w http.ResponseWriter, r *http.Request) {
+		var body datadogV2.LogsListRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		filter := body.GetFilter()
+		page := body.GetPage()
+		items, next := s.page(filter.GetQuery()+" "+strings.Join(filter.Indexes, ","), filter.GetFrom(), filter.GetTo(), page.GetCursor(), int(page.GetLimit()))
+		var data []interface{}
+		for _, i := range items {
+			data = append(data, map[string]interface{}{"id": i.id, "type": "log", "attributes": map[string]interface{}{"timestamp": i.ts}})
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": data, "meta": map[string]interface{}{"page": map[string]interface{}{"after": next}}})
+	})
+	mux.HandleFunc("/api/v2/events", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("page[limit]"))
+		items, next := s.page(q.Get("filter[query]"), q.Get("filter[from]"), q.Get("filter[to]"), q.Get("page[cursor]"), limit)
+		var data []interface{}
+		for _, i := range items {
+			data = append(data, map[string]interface{}{"id": i.id, "type": "event", "attributes": map[string]interface{}{"timestamp": i.ts}})
+		}
+		tests.WriteJSON(w, map[string]interface{}{"data": data, "meta": map[string]interface{}{"page": map[string]interface{}{"after": next}}})
+	})
+	return tests.Serve(t, mux)
+}
+
+func newStore() *store {
+	return &store{
+		items: []item{
+			{"l1", base.Add(10 * time.Second)},
+			{"l3", base.Add(40 * time.Second)},
+			{"l2", base.Add(30 * time.Second)},
+			{"l4", base.Add(70 * time.Second)},
+			{"l6", base.Add(150 * time.Second)},
+		},
+		late: &item{"l5", base.Add(55 * time.Second)},
+	}
+}
+
+// clock advances a minute at every call.
+func clock() func() time.Time {
+	var mu sync.Mutex
+	now := base
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Minute)
+		return now
+	}
+}
+
+func newTail(source tail.Source, query string) *tail.Tail {
+	t := tail.New(source, query)
+	t.Start = base
+	t.Interval = time.Millisecond
+	t.Delay = 70 * time.Second
+	t.PageSize = 2
+	t.Now = clock()
+	return t
+}
+
+func TestStream(t *testing.T) {
+	s := newStore()
+	ctx := serve(t, s)
+	assert := tests.Assert(ctx, t)
+	client := datadog.NewAPIClient(datadog.NewConfiguration())
+
+	results, cancel := newTail(tail.Logs(datadogV2.NewLogsApi(client), "main"), "service:web").Stream(ctx)
+	var ids []string
+	for res := range results {
+		assert.NoError(res.Err)
+		ids = append(ids, res.Item.ID)
+		assert.IsType(datadogV2.Log{}, res.Item.Value)
+		if len(ids) == 6 {
+			cancel()
+		}
+	}
+	assert.Equal([]string{"l1", "l2", "l3", "l5", "l4", "l6"}, ids)
+	assert.Equal("service:web main", s.queries[0])
+}
+
+func TestRun(t *testing.T) {
+	s := newStore()
+	ctx := serve(t, s)
+	assert := tests.Assert(ctx, t)
+	configuration := datadog.NewConfiguration()
+	configuration.SetUnstableOperationEnabled("v2.ListEvents", true)
+	client := datadog.NewAPIClient(configuration)
+
+	done := errors.New("done")
+	var items []tail.Item
+	err := newTail(tail.Events(datadogV2.NewEventsApi(client)), "source:deploy").Run(ctx, func(item tail.Item) error {
+		items = append(items, item)
+		if len(items) == 4 {
+			return done
+		}
+		return nil
+	})
+	assert.Equal(done, err)
+	assert.Equal("l5", items[3].ID)
+	assert.Equal(base.Add(55*time.Second), items[3].Timestamp.UTC())
+	assert.Equal("source:deploy", s.queries[0])
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = tail.New(tail.Events(datadogV2.NewEventsApi(client)), "").Run(canceled, func(tail.Item) error { return nil })
+	assert.Equal(context.Canceled, err)
+}
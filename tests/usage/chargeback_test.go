@@ -0,0 +1,161 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+	"github.com/DataDog/datadog-api-client-go/v2/usage"
+)
+
+const chargeback = `
+hierarchy: [team, service]
+warn_percent: 80
+budgets:
+  - tags: {team: payments}
+    limits: {infra_host_usage: 10, apm_host_usage: 5}
+    notify: ["@slack-payments"]
+  - tags: {team: payments, service: checkout}
+    limits: {infra_host_usage: 8}
+  - tags: {team: search}
+    limits: {infra_host_usage: 100}
+`
+
+func attribution(month, team, service string, values map[string]interface{}) map[string]interface{} {
+	tags := map[string]interface{}{"team": []string{team}, "service": []string{service}}
+	if team == "" {
+		tags = map[string]interface{}{"service": []string{service}}
+	}
+	return map[string]interface{}{
+		"month":     month,
+		"org_name":  "Parent",
+		"public_id": "abc",
+		"tags":      tags,
+		"values":    values,
+	}
+}
+
+func TestMonthlyAttribution(t *testing.T) {
+	const may, june = "2024-05-01T00:00:00Z", "2024-06-01T00:00:00Z"
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/usage/monthly-attribution", func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("next_record_id") == "" {
+			tests.WriteJSON(w, map[string]interface{}{
+				"usage": []interface{}{
+					attribution(may, "payments", "checkout", map[string]interface{}{"infra_host_usage": 6, "infra_host_percentage": 60, "apm_host_usage": 2}),
+					attribution(may, "payments", "ledger", map[string]interface{}{"infra_host_usage": 3, "apm_host_usage": 2.5}),
+					attribution(june, "payments", "checkout", map[string]interface{}{"infra_host_usage": 6}),
+				},
+				"metadata": map[string]interface{}{"pagination": map[string]interface{}{"next_record_id": "r2"}},
+			})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{
+			"usage": []interface{}{
+				attribution(may, "search", "api", map[string]interface{}{"infra_host_usage": 40}),
+				attribution(may, "", "batch", map[string]interface{}{"infra_host_usage": 1}),
+			},
+			"metadata": map[string]interface{}{"pagination": map[string]interface{}{"next_record_id": nil}},
+		})
+	})
+	var events []datadogV1.EventCreateRequest
+	mux.HandleFunc("/api/v1/events", func(w http.ResponseWriter, r *http.Request) {
+		var e datadogV1.EventCreateRequest
+		json.NewDecoder(r.Body).Decode(&e)
+		events = append(events, e)
+		tests.WriteJSON(w, map[string]interface{}{"status": "ok"})
+	})
+	var payload datadogV2.MetricPayload
+	mux.HandleFunc("/api/v2/series", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusAccepted)
+		tests.WriteJSON(w, map[string]interface{}{"errors": []string{}})
+	})
+	ctx := tests.Serve(t, mux)
+	assert := tests.Assert(ctx, t)
+	client := datadog.NewAPIClient(datadog.NewConfiguration())
+	reporter := usage.NewReporter(datadogV1.NewUsageMeteringApi(client), datadogV2.NewUsageMeteringApi(client))
+
+	attributions, err := reporter.MonthlyAttribution(ctx, start, end, []string{"team", "service"})
+	assert.NoError(err)
+	assert.Len(queries, 2)
+	assert.Contains(queries[0], "tag_breakdown_keys=team%2Cservice")
+	assert.Contains(queries[1], "next_record_id=r2")
+	assert.Len(attributions, 7)
+
+	config, err := usage.UnmarshalChargeback([]byte(chargeback))
+	assert.NoError(err)
+	roots, findings := config.Check(attributions)
+	assert.Len(roots, 2)
+	root := roots[0]
+	assert.Equal(start, root.Month)
+	assert.Equal(map[string]float64{"infra_host_usage": 50, "apm_host_usage": 4.5}, root.Usage)
+	assert.Equal(map[string]float64{"infra_host_usage": 6}, roots[1].Usage)
+	assert.Equal(start.AddDate(0, 1, 0), roots[1].Children[0].Children[0].Month)
+	var teams []string
+	for _, g := range root.Children {
+		teams = append(teams, g.Tags[0])
+	}
+	assert.Equal([]string{"team:(untagged)", "team:payments", "team:search"}, teams)
+	assert.Equal([]string{"team:payments", "service:checkout"}, root.Children[1].Children[0].Tags)
+
+	var b bytes.Buffer
+	assert.NoError(usage.WriteFindings(&b, findings))
+	assert.Equal("[WARNING] 2024-05 team:payments apm_host_usage: 4.5 of 5 (90.0%)\n"+
+		"[WARNING] 2024-05 team:payments infra_host_usage: 9 of 10 (90.0%)\n"+
+		"2 finding(s)\n", b.String())
+
+	config.WarnPercent = 0
+	config.Budgets[1].Limits["infra_host_usage"] = 5
+	_, findings = config.Check(attributions)
+	assert.Len(findings, 2)
+	assert.Equal(usage.StatusOver, findings[0].Status)
+	assert.Equal(120.0, findings[0].Percent())
+	assert.Equal(start, findings[0].Month)
+	assert.Equal(start.AddDate(0, 1, 0), findings[1].Month)
+	findings = findings[:1]
+
+	notifier := usage.NewNotifier(datadogV1.NewEventsApi(client), datadogV2.NewMetricsApi(client))
+	notifier.Tags = []string{"source:chargeback"}
+	notifier.Now = func() time.Time { return end }
+	assert.NoError(notifier.Notify(ctx, findings))
+	assert.Len(events, 1)
+	assert.Equal("infra_host_usage usage of team:payments,service:checkout is over budget in May 2024", events[0].Title)
+	assert.Equal("Usage is 6 of a budget of 5 (120.0%).", events[0].Text)
+	assert.Equal(datadogV1.EVENTALERTTYPE_ERROR, events[0].GetAlertType())
+	assert.Equal([]string{"source:chargeback", "team:payments", "service:checkout", "month:2024-05", "usage_type:infra_host_usage", "status:over"}, events[0].Tags)
+	assert.Len(payload.Series, 2)
+	assert.Equal("usage.chargeback.usage", payload.Series[0].Metric)
+	assert.Equal(6.0, payload.Series[0].Points[0].GetValue())
+	assert.Equal(end.Unix(), payload.Series[1].Points[0].GetTimestamp())
+}
+
+func TestChargebackValidate(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	for config, expected := range map[string]string{
+		"budgets: []":                          "chargeback has no hierarchy",
+		"hierarchy: [team]\nwarn_percent: 120": "warn percent 120 is not between 0 and 100",
+		"hierarchy: [team]\nbudgets: [{tags: {service: a}, limits: {x: 1}}]":          `budget 0: missing tag "team" of the hierarchy`,
+		"hierarchy: [team]\nbudgets: [{tags: {team: a, service: b}, limits: {x: 1}}]": "budget 0: expected between 1 and 1 tags",
+		"hierarchy: [team]\nbudgets: [{tags: {team: a}}]":                             "budget 0: no limits",
+	} {
+		_, err := usage.UnmarshalChargeback([]byte(config))
+		assert.EqualError(err, expected)
+	}
+}
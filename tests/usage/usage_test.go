@@ -0,0 +1,206 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+	"github.com/DataDog/datadog-api-client-go/v2/usage"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+var (
+	start = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end   = time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+)
+
+func hourly(timestamp, org, publicID, product string, measurements map[string]interface{}) map[string]interface{} {
+	var ms []interface{}
+	for usageType, value := range measurements {
+		ms = append(ms, map[string]interface{}{"usage_type": usageType, "value": value})
+	}
+	return map[string]interface{}{
+		"id":   publicID + timestamp,
+		"type": "usage_timeseries",
+		"attributes": map[string]interface{}{
+			"timestamp":      timestamp,
+			"org_name":       org,
+			"public_id":      publicID,
+			"region":         "us",
+			"product_family": product,
+			"measurements":   ms,
+		},
+	}
+}
+
+func cost(org, publicID string, charges ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   publicID,
+		"type": "cost_by_org",
+		"attributes": map[string]interface{}{
+			"date":      "2024-05-01T00:00:00Z",
+			"org_name":  org,
+			"public_id": publicID,
+			"charges":   charges,
+		},
+	}
+}
+
+func newReporter(t *testing.T, queries map[string]string) (context.Context, *usage.Reporter) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/usage/hourly_usage", func(w http.ResponseWriter, r *http.Request) {
+		queries["hourly_usage"] = r.URL.RawQuery
+		if r.URL.Query().Get("page[next_record_id]") == "" {
+			tests.WriteJSON(w, map[string]interface{}{
+				"data": []interface{}{
+					hourly("2024-05-01T01:00:00Z", "Parent", "abc", "infra_hosts", map[string]interface{}{"agent_host_count": 4}),
+					hourly("2024-05-01T00:00:00Z", "Child", "def", "logs", map[string]interface{}{"indexed_events_count": 1200}),
+				},
+				"meta": map[string]interface{}{"pagination": map[string]interface{}{"next_record_id": "r2"}},
+			})
+			return
+		}
+		tests.WriteJSON(w, map[string]interface{}{
+			"data": []interface{}{
+				hourly("2024-05-01T00:00:00Z", "Parent", "abc", "logs", map[string]interface{}{"ingested_events_bytes": 2048, "missing": nil}),
+			},
+			"meta": map[string]interface{}{"pagination": map[string]interface{}{"next_record_id": nil}},
+		})
+	})
+	mux.HandleFunc("/api/v1/usage/summary", func(w http.ResponseWriter, r *http.Request) {
+		queries["summary"] = r.URL.RawQuery
+		tests.WriteJSON(w, map[string]interface{}{
+			"usage": []interface{}{map[string]interface{}{
+				"date": "2024-05-01T00:00:00Z",
+				"orgs": []interface{}{
+					map[string]interface{}{"name": "Parent", "public_id": "abc", "id": "1", "region": "us", "infra_host_top99p": 12, "apm_host_top99p": 3},
+				},
+			}},
+		})
+	})
+	mux.HandleFunc("/api/v2/usage/estimated_cost", func(w http.ResponseWriter, r *http.Request) {
+		queries["estimated_cost"] = r.URL.RawQuery
+		tests.WriteJSON(w, map[string]interface{}{"data": []interface{}{
+			cost("Parent", "abc",
+				map[string]interface{}{"product_name": "infra_host", "charge_type": "committed", "cost": 150.5},
+				map[string]interface{}{"product_name": "infra_host", "charge_type": "total", "cost": 170.5},
+				map[string]interface{}{"product_name": "synthetics_api", "charge_type": "on_demand", "cost": 5}),
+			cost("Child", "def", map[string]interface{}{"product_name": "logs", "charge_type": "on_demand", "cost": 10}),
+		}})
+	})
+	ctx := tests.Serve(t, mux)
+	client := datadog.NewAPIClient(datadog.NewConfiguration())
+	return ctx, usage.NewReporter(datadogV1.NewUsageMeteringApi(client), datadogV2.NewUsageMeteringApi(client))
+}
+
+func TestReport(t *testing.T) {
+	queries := map[string]string{}
+	ctx, reporter := newReporter(t, queries)
+	assert := tests.Assert(ctx, t)
+
+	records, err := reporter.Report(ctx, usage.Query{
+		Start:    start,
+		End:      end,
+		Products: []string{"infra_hosts", "logs"},
+		Summary:  true,
+		Cost:     usage.SourceEstimatedCost,
+	})
+	assert.NoError(err)
+	assert.Contains(queries["hourly_usage"], "filter%5Bproduct_families%5D=infra_hosts%2Clogs")
+	assert.Contains(queries["hourly_usage"], "filter%5Binclude_descendants%5D=true")
+	assert.Contains(queries["summary"], "include_org_details=true")
+	assert.Contains(queries["estimated_cost"], "view=sub-org")
+
+	var rows []string
+	for _, r := range records {
+		cost := "-"
+		if r.Cost != nil {
+			cost = strconv.FormatFloat(*r.Cost, 'f', -1, 64)
+		}
+		rows = append(rows, strings.Join([]string{r.Timestamp.Format("02T15"), r.PublicID, r.Product, r.UsageType, r.Unit, cost, string(r.Source)}, " "))
+	}
+	assert.Equal([]string{
+		"01T00 abc apm apm_host_top99p count - summary",
+		"01T00 abc infra_hosts infra_host_top99p count 170.5 summary",
+		"01T00 abc logs ingested_events_bytes bytes - hourly_usage",
+		"01T00 abc synthetics_api on_demand dollars 5 estimated_cost",
+		"01T00 def logs indexed_events_count count 10 hourly_usage",
+		"01T01 abc infra_hosts agent_host_count count - hourly_usage",
+	}, rows)
+
+	reporter.Orgs = []string{"Child"}
+	records, err = reporter.Report(ctx, usage.Query{Start: start, End: end, Products: []string{"all"}})
+	assert.NoError(err)
+	assert.Len(records, 1)
+	assert.Equal("Child", records[0].OrgName)
+
+	_, err = reporter.Costs(ctx, "forecast", start, end)
+	assert.EqualError(err, `unsupported cost source "forecast"`)
+}
+
+type parquetRecord struct {
+	Timestamp int64    `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	OrgName   string   `parquet:"name=org_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UsageType string   `parquet:"name=usage_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Value     float64  `parquet:"name=value, type=DOUBLE"`
+	Cost      *float64 `parquet:"name=cost, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Source    string   `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+func TestExport(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	amount := 1.25
+	records := []usage.Record{
+		{Timestamp: start, OrgName: "Parent", PublicID: "abc", Region: "us", Product: "logs", UsageType: "ingested_events_bytes", Value: 2048, Unit: "bytes", Source: usage.SourceHourlyUsage},
+		{Timestamp: start, OrgName: "Parent, Inc.", PublicID: "abc", Product: "logs", UsageType: "on_demand", Value: amount, Unit: "dollars", Cost: &amount, Source: usage.SourceCost},
+	}
+
+	var b bytes.Buffer
+	assert.NoError(usage.WriteCSV(&b, records))
+	assert.Equal("timestamp,org_name,public_id,region,product,usage_type,value,unit,cost,source\n"+
+		"2024-05-01T00:00:00Z,Parent,abc,us,logs,ingested_events_bytes,2048,bytes,,hourly_usage\n"+
+		"2024-05-01T00:00:00Z,\"Parent, Inc.\",abc,,logs,on_demand,1.25,dollars,1.25,cost\n", b.String())
+
+	b.Reset()
+	assert.NoError(usage.WriteJSONLines(&b, records))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(lines, 2)
+	var decoded usage.Record
+	assert.NoError(json.Unmarshal([]byte(lines[1]), &decoded))
+	assert.Equal(records[1], decoded)
+
+	b.Reset()
+	assert.NoError(usage.WriteParquet(&b, records))
+	file, err := buffer.NewBufferFile(b.Bytes())
+	assert.NoError(err)
+	pr, err := reader.NewParquetReader(file, new(parquetRecord), 1)
+	assert.NoError(err)
+	defer pr.ReadStop()
+	assert.Equal(int64(2), pr.GetNumRows())
+	rows := make([]parquetRecord, 2)
+	assert.NoError(pr.Read(&rows))
+	assert.Equal(start.UnixMilli(), rows[0].Timestamp)
+	assert.Equal("ingested_events_bytes", rows[0].UsageType)
+	assert.Equal(2048.0, rows[0].Value)
+	assert.Nil(rows[0].Cost)
+	assert.Equal("Parent, Inc.", rows[1].OrgName)
+	assert.Equal(amount, *rows[1].Cost)
+	assert.Equal("cost", rows[1].Source)
+}
@@ -0,0 +1,133 @@
+/*
+ * Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+ * This product includes software developed at Datadog (https://www.datadoghq.com/).
+ * Copyright 2019-Present Datadog, Inc.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/tests"
+	"github.com/DataDog/datadog-api-client-go/v2/webhook"
+)
+
+// render replaces the variables of the payload template like Datadog does,
+// leaving the variables without value as is.
+func render(t *testing.T, template string, values map[string]string) string {
+	for name, value := range values {
+		quoted, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template = strings.ReplaceAll(template, `"$`+name+`"`, string(quoted))
+	}
+	return template
+}
+
+var values = map[string]string{
+	"ID":               "7312834619347192",
+	"EVENT_TYPE":       "query_alert_monitor",
+	"EVENT_TITLE":      `[Triggered on {host:web-1}] CPU "high"`,
+	"EVENT_MSG":        "%%%\nCPU is high @webhook-receiver\n%%%",
+	"DATE":             "1714557600000",
+	"ALERT_ID":         "123456",
+	"ALERT_TRANSITION": "Triggered",
+	"ALERT_PRIORITY":   "P2",
+	"HOSTNAME":         "web-1",
+	"TAGS":             "env:prod, host:web-1,team:sre",
+	"ORG_ID":           "11287",
+	"ORG_NAME":         "Example",
+}
+
+func TestIntegration(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	integration := webhook.Integration("receiver", "https://hooks.example.com/datadog", "s3cr3t")
+	assert.Equal(datadogV1.WEBHOOKSINTEGRATIONENCODING_JSON, integration.GetEncodeAs())
+	var headers map[string]string
+	assert.NoError(json.Unmarshal([]byte(integration.GetCustomHeaders()), &headers))
+	assert.Equal(map[string]string{"X-Datadog-Webhook-Secret": "s3cr3t"}, headers)
+	assert.True(json.Valid([]byte(integration.GetPayload())))
+	assert.Contains(integration.GetPayload(), `"alert_transition": "$ALERT_TRANSITION"`)
+
+	n, err := webhook.Parse([]byte(render(t, integration.GetPayload(), values)))
+	assert.NoError(err)
+	assert.Equal("7312834619347192", n.ID)
+	assert.Equal(`[Triggered on {host:web-1}] CPU "high"`, n.EventTitle)
+	assert.Equal(int64(123456), n.AlertID)
+	assert.Equal(webhook.TransitionTriggered, n.AlertTransition)
+	assert.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), n.Date)
+	assert.Equal([]string{"env:prod", "host:web-1", "team:sre"}, n.Tags)
+	assert.Equal(int64(11287), n.OrgID)
+	assert.Equal("", n.AlertQuery)
+	assert.True(n.LastUpdated.IsZero())
+
+	noSecret := webhook.Integration("receiver", "https://hooks.example.com/datadog", "")
+	assert.False(noSecret.CustomHeaders.IsSet())
+
+	_, err = webhook.Parse([]byte(`{"alert_id": "abc"}`))
+	assert.EqualError(err, `invalid alert_id: strconv.ParseInt: parsing "abc": invalid syntax`)
+}
+
+func TestHandler(t *testing.T) {
+	assert := tests.Assert(context.Background(), t)
+
+	var received []*webhook.Notification
+	handler := webhook.NewHandler("s3cr3t", func(ctx context.Context, n *webhook.Notification) error {
+		if n.Hostname == "fail" {
+			return errors.New("downstream unavailable")
+		}
+		received = append(received, n)
+		return nil
+	})
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	post := func(secret, contentType, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		if secret != "" {
+			req.Header.Set(webhook.SecretHeader, secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	payload := render(t, webhook.Payload(), values)
+
+	assert.Equal(http.StatusNoContent, post("s3cr3t", "application/json", payload).StatusCode)
+	assert.Equal(http.StatusUnauthorized, post("", "application/json", payload).StatusCode)
+	assert.Equal(http.StatusUnauthorized, post("wrong", "application/json", payload).StatusCode)
+	assert.Equal(http.StatusBadRequest, post("s3cr3t", "application/json", "{").StatusCode)
+	assert.Equal(http.StatusInternalServerError, post("s3cr3t", "application/json", `{"hostname": "fail"}`).StatusCode)
+
+	form := url.Values{"alert_id": {"42"}, "alert_transition": {"Recovered"}, "tags": {"env:prod"}}
+	assert.Equal(http.StatusNoContent, post("s3cr3t", "application/x-www-form-urlencoded", form.Encode()).StatusCode)
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+
+	assert.Len(received, 2)
+	assert.Equal(int64(123456), received[0].AlertID)
+	assert.Equal(int64(42), received[1].AlertID)
+	assert.Equal(webhook.TransitionRecovered, received[1].AlertTransition)
+}
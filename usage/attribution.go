@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package usage
+
+import (
+	_context "context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// Attribution is the usage of a set of tags, as configured for usage
+// attribution.
+type Attribution struct {
+	Timestamp time.Time
+	OrgName   string
+	PublicID  string
+	// Tags are the values of the tag keys of the breakdown. They are nil when
+	// the breakdown does not match the tags configured for usage attribution.
+	Tags map[string][]string
+	// UsageType is the usage type, such as "infra_host_usage".
+	UsageType string
+	Value     float64
+}
+
+// MonthlyAttribution pulls the monthly usage of all usage types broken down
+// by the tag keys, three at most, including child orgs.
+func (r *Reporter) MonthlyAttribution(ctx _context.Context, start, end time.Time, tagKeys []string) ([]Attribution, error) {
+	var attributions []Attribution
+	params := datadogV1.NewGetMonthlyUsageAttributionOptionalParameters().
+		WithEndMonth(month(end)).
+		WithTagBreakdownKeys(strings.Join(tagKeys, ",")).
+		WithIncludeDescendants(true)
+	for {
+		resp, _, err := r.v1.GetMonthlyUsageAttribution(ctx, month(start), datadogV1.MONTHLYUSAGEATTRIBUTIONSUPPORTEDMETRICS_ALL, *params)
+		if err != nil {
+			return nil, fmt.Errorf("getting monthly usage attribution: %w", err)
+		}
+		for _, body := range resp.Usage {
+			if !r.selected(body.GetPublicId(), body.GetOrgName()) || body.Values == nil {
+				continue
+			}
+			values, err := numericFields(body.Values)
+			if err != nil {
+				return nil, err
+			}
+			for usageType, value := range values {
+				if !strings.HasSuffix(usageType, "_usage") {
+					continue
+				}
+				attributions = append(attributions, Attribution{
+					Timestamp: body.GetMonth().UTC(),
+					OrgName:   body.GetOrgName(),
+					PublicID:  body.GetPublicId(),
+					Tags:      body.Tags,
+					UsageType: usageType,
+					Value:     value,
+				})
+			}
+		}
+		next := resp.GetMetadata().Pagination.GetNextRecordId()
+		if next == "" {
+			return attributions, nil
+		}
+		params = params.WithNextRecordId(next)
+	}
+}
+
+// HourlyAttribution pulls the hourly usage of a usage type broken down by
+// the tag keys, three at most, including child orgs.
+func (r *Reporter) HourlyAttribution(ctx _context.Context, start, end time.Time, usageType datadogV1.HourlyUsageAttributionUsageType, tagKeys []string) ([]Attribution, error) {
+	var attributions []Attribution
+	params := datadogV1.NewGetHourlyUsageAttributionOptionalParameters().
+		WithEndHr(end).
+		WithTagBreakdownKeys(strings.Join(tagKeys, ",")).
+		WithIncludeDescendants(true)
+	for {
+		resp, _, err := r.v1.GetHourlyUsageAttribution(ctx, start, usageType, *params)
+		if err != nil {
+			return nil, fmt.Errorf("getting hourly usage attribution: %w", err)
+		}
+		for _, body := range resp.Usage {
+			if !r.selected(body.GetPublicId(), body.GetOrgName()) || body.TotalUsageSum == nil {
+				continue
+			}
+			attributions = append(attributions, Attribution{
+				Timestamp: body.GetHour().UTC(),
+				OrgName:   body.GetOrgName(),
+				PublicID:  body.GetPublicId(),
+				Tags:      body.Tags,
+				UsageType: string(body.GetUsageType()),
+				Value:     body.GetTotalUsageSum(),
+			})
+		}
+		next := resp.GetMetadata().Pagination.GetNextRecordId()
+		if next == "" {
+			return attributions, nil
+		}
+		params = params.WithNextRecordId(next)
+	}
+}
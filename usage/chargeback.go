@@ -0,0 +1,352 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package usage
+
+import (
+	"bytes"
+	_context "context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/DataDog/datadog-api-client-go/v2/internal/strictyaml"
+)
+
+// Untagged is the value of the groups of usage without a value for a tag
+// key.
+const Untagged = "(untagged)"
+
+// Group is the usage of the attributions sharing the values of the first
+// tag keys of a hierarchy.
+type Group struct {
+	// Month is the month of the attributions when grouped by month.
+	Month time.Time
+	// Tags are the "key:value" tags shared by the attributions, empty for the
+	// root group.
+	Tags []string
+	// Usage is the usage by usage type.
+	Usage    map[string]float64
+	Children []*Group
+}
+
+// GroupBy groups the attributions by the values of the tag keys, from the
+// broadest to the narrowest, such as "team" then "service". Multiple values
+// of a tag key are joined by commas.
+func GroupBy(attributions []Attribution, hierarchy []string) *Group {
+	root := &Group{Usage: map[string]float64{}}
+	for _, a := range attributions {
+		g := root
+		g.Usage[a.UsageType] += a.Value
+		for _, key := range hierarchy {
+			value := Untagged
+			if values := a.Tags[key]; len(values) > 0 {
+				value = strings.Join(values, ",")
+			}
+			g = g.child(key + ":" + value)
+			g.Usage[a.UsageType] += a.Value
+		}
+	}
+	root.sort()
+	return root
+}
+
+// GroupByMonth groups the attributions of each month by the values of the
+// tag keys, as GroupBy does, and returns the groups of the months in order.
+func GroupByMonth(attributions []Attribution, hierarchy []string) []*Group {
+	byMonth := map[time.Time][]Attribution{}
+	var months []time.Time
+	for _, a := range attributions {
+		m := month(a.Timestamp)
+		if _, ok := byMonth[m]; !ok {
+			months = append(months, m)
+		}
+		byMonth[m] = append(byMonth[m], a)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	roots := make([]*Group, len(months))
+	for i, m := range months {
+		roots[i] = GroupBy(byMonth[m], hierarchy)
+		roots[i].Walk(func(g *Group) { g.Month = m })
+	}
+	return roots
+}
+
+func (g *Group) child(tag string) *Group {
+	for _, c := range g.Children {
+		if c.Tags[len(c.Tags)-1] == tag {
+			return c
+		}
+	}
+	tags := make([]string, len(g.Tags), len(g.Tags)+1)
+	copy(tags, g.Tags)
+	c := &Group{Month: g.Month, Tags: append(tags, tag), Usage: map[string]float64{}}
+	g.Children = append(g.Children, c)
+	return c
+}
+
+func (g *Group) sort() {
+	sort.Slice(g.Children, func(i, j int) bool {
+		return g.Children[i].Tags[len(g.Tags)] < g.Children[j].Tags[len(g.Tags)]
+	})
+	for _, c := range g.Children {
+		c.sort()
+	}
+}
+
+// Walk calls fn for the group and its descendants, depth first.
+func (g *Group) Walk(fn func(*Group)) {
+	fn(g)
+	for _, c := range g.Children {
+		c.Walk(fn)
+	}
+}
+
+// Budget limits the usage of a group.
+type Budget struct {
+	// Tags are the values of the first tag keys of the hierarchy identifying
+	// the group, such as {team: payments}.
+	Tags map[string]string `yaml:"tags"`
+	// Limits are the maximum usage in a month by usage type.
+	Limits map[string]float64 `yaml:"limits"`
+	// Notify are the handles mentioned by the events of the findings, such as
+	// "@slack-payments".
+	Notify []string `yaml:"notify,omitempty"`
+}
+
+// Chargeback configures how usage is grouped and budgeted.
+type Chargeback struct {
+	// Hierarchy are the tag keys grouping usage, from the broadest to the
+	// narrowest.
+	Hierarchy []string `yaml:"hierarchy"`
+	// WarnPercent is the percentage of a budget from which usage is reported
+	// as a warning, none when zero.
+	WarnPercent float64  `yaml:"warn_percent,omitempty"`
+	Budgets     []Budget `yaml:"budgets"`
+}
+
+// DecodeChargeback reads a chargeback configuration in YAML.
+func DecodeChargeback(r io.Reader) (*Chargeback, error) {
+	var c Chargeback
+	if err := strictyaml.Decode(r, &c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UnmarshalChargeback reads a chargeback configuration in YAML.
+func UnmarshalChargeback(data []byte) (*Chargeback, error) {
+	return DecodeChargeback(bytes.NewReader(data))
+}
+
+// Validate checks that every budget identifies a group of the hierarchy.
+func (c *Chargeback) Validate() error {
+	if len(c.Hierarchy) == 0 {
+		return fmt.Errorf("chargeback has no hierarchy")
+	}
+	if c.WarnPercent < 0 || c.WarnPercent >= 100 {
+		return fmt.Errorf("warn percent %v is not between 0 and 100", c.WarnPercent)
+	}
+	for i, b := range c.Budgets {
+		if len(b.Tags) == 0 || len(b.Tags) > len(c.Hierarchy) {
+			return fmt.Errorf("budget %d: expected between 1 and %d tags", i, len(c.Hierarchy))
+		}
+		for _, key := range c.Hierarchy[:len(b.Tags)] {
+			if _, ok := b.Tags[key]; !ok {
+				return fmt.Errorf("budget %d: missing tag %q of the hierarchy", i, key)
+			}
+		}
+		if len(b.Limits) == 0 {
+			return fmt.Errorf("budget %d: no limits", i)
+		}
+	}
+	return nil
+}
+
+// tags returns the "key:value" tags of the budget, in order of the
+// hierarchy.
+func (c *Chargeback) tags(b Budget) []string {
+	tags := make([]string, len(b.Tags))
+	for i, key := range c.Hierarchy[:len(b.Tags)] {
+		tags[i] = key + ":" + b.Tags[key]
+	}
+	return tags
+}
+
+// Status is the status of the usage of a group against its budget.
+type Status string
+
+// List of Status.
+const (
+	StatusWarning Status = "warning"
+	StatusOver    Status = "over"
+)
+
+// Finding is a usage type of a group over, or close to, its budget in a
+// month.
+type Finding struct {
+	Status    Status
+	Month     time.Time
+	Tags      []string
+	UsageType string
+	Usage     float64
+	Limit     float64
+	Notify    []string
+}
+
+// Percent returns the usage as a percentage of the limit.
+func (f Finding) Percent() float64 {
+	if f.Limit == 0 {
+		return 0
+	}
+	return f.Usage / f.Limit * 100
+}
+
+// Check groups the attributions by month and compares the usage of the
+// groups of each month to their budgets. Findings are sorted by decreasing
+// percentage of the limit.
+func (c *Chargeback) Check(attributions []Attribution) ([]*Group, []Finding) {
+	roots := GroupByMonth(attributions, c.Hierarchy)
+	var findings []Finding
+	for _, root := range roots {
+		findings = append(findings, c.check(root)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if pi, pj := findings[i].Percent(), findings[j].Percent(); pi != pj {
+			return pi > pj
+		}
+		if !findings[i].Month.Equal(findings[j].Month) {
+			return findings[i].Month.Before(findings[j].Month)
+		}
+		return strings.Join(findings[i].Tags, ",")+findings[i].UsageType < strings.Join(findings[j].Tags, ",")+findings[j].UsageType
+	})
+	return roots, findings
+}
+
+// check compares the usage of the groups of a month to their budgets.
+func (c *Chargeback) check(root *Group) []Finding {
+	groups := map[string]*Group{}
+	root.Walk(func(g *Group) { groups[strings.Join(g.Tags, ",")] = g })
+
+	var findings []Finding
+	for _, b := range c.Budgets {
+		tags := c.tags(b)
+		usage := map[string]float64{}
+		if g := groups[strings.Join(tags, ",")]; g != nil {
+			usage = g.Usage
+		}
+		for usageType, limit := range b.Limits {
+			f := Finding{Month: root.Month, Tags: tags, UsageType: usageType, Usage: usage[usageType], Limit: limit, Notify: b.Notify}
+			switch {
+			case f.Usage > limit:
+				f.Status = StatusOver
+			case c.WarnPercent > 0 && f.Percent() >= c.WarnPercent:
+				f.Status = StatusWarning
+			default:
+				continue
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// WriteFindings writes a line per finding.
+func WriteFindings(w io.Writer, findings []Finding) error {
+	var b strings.Builder
+	for _, f := range findings {
+		fmt.Fprintf(&b, "[%s] %s %s %s: %g of %g (%.1f%%)\n", strings.ToUpper(string(f.Status)), f.Month.Format("2006-01"), strings.Join(f.Tags, ","), f.UsageType, f.Usage, f.Limit, f.Percent())
+	}
+	fmt.Fprintf(&b, "%d finding(s)\n", len(findings))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Notifier posts findings as events or custom metrics.
+type Notifier struct {
+	events  *datadogV1.EventsApi
+	metrics *datadogV2.MetricsApi
+	// Tags are added to the events and metrics.
+	Tags []string
+	// MetricPrefix prefixes the names of the metrics, "usage.chargeback" by
+	// default.
+	MetricPrefix string
+	Now          func() time.Time
+}
+
+// NewNotifier returns a notifier posting events, metrics or both when the
+// APIs are not nil.
+func NewNotifier(events *datadogV1.EventsApi, metrics *datadogV2.MetricsApi) *Notifier {
+	return &Notifier{events: events, metrics: metrics, MetricPrefix: "usage.chargeback", Now: time.Now}
+}
+
+// Notify posts an event per finding and submits the usage and limit of the
+// findings as gauges, tagged by the tags of the group and the usage type.
+func (n *Notifier) Notify(ctx _context.Context, findings []Finding) error {
+	if n.events != nil {
+		for _, f := range findings {
+			if _, _, err := n.events.CreateEvent(ctx, n.event(f)); err != nil {
+				return fmt.Errorf("creating event for %s %s: %w", strings.Join(f.Tags, ","), f.UsageType, err)
+			}
+		}
+	}
+	if n.metrics != nil && len(findings) > 0 {
+		if _, _, err := n.metrics.SubmitMetrics(ctx, n.payload(findings)); err != nil {
+			return fmt.Errorf("submitting metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+func (n *Notifier) tags(f Finding) []string {
+	tags := append([]string{}, n.Tags...)
+	tags = append(tags, f.Tags...)
+	return append(tags, "month:"+f.Month.Format("2006-01"), "usage_type:"+f.UsageType, "status:"+string(f.Status))
+}
+
+func (n *Notifier) event(f Finding) datadogV1.EventCreateRequest {
+	group := strings.Join(f.Tags, ",")
+	title := fmt.Sprintf("%s usage of %s is over budget in %s", f.UsageType, group, f.Month.Format("January 2006"))
+	alertType := datadogV1.EVENTALERTTYPE_ERROR
+	if f.Status == StatusWarning {
+		title = fmt.Sprintf("%s usage of %s is close to budget in %s", f.UsageType, group, f.Month.Format("January 2006"))
+		alertType = datadogV1.EVENTALERTTYPE_WARNING
+	}
+	text := fmt.Sprintf("Usage is %g of a budget of %g (%.1f%%).", f.Usage, f.Limit, f.Percent())
+	if len(f.Notify) > 0 {
+		text += "\n\n" + strings.Join(f.Notify, " ")
+	}
+	e := datadogV1.NewEventCreateRequest(text, title)
+	e.SetAlertType(alertType)
+	e.SetAggregationKey("usage-chargeback:" + f.Month.Format("2006-01") + ":" + group)
+	e.SetDateHappened(n.Now().Unix())
+	e.SetTags(n.tags(f))
+	return *e
+}
+
+func (n *Notifier) payload(findings []Finding) datadogV2.MetricPayload {
+	now := n.Now().Unix()
+	gauge := func(name string, value float64, tags []string) datadogV2.MetricSeries {
+		point := datadogV2.NewMetricPoint()
+		point.SetTimestamp(now)
+		point.SetValue(value)
+		s := datadogV2.NewMetricSeries(n.MetricPrefix+"."+name, []datadogV2.MetricPoint{*point})
+		s.SetType(datadogV2.METRICINTAKETYPE_GAUGE)
+		s.SetTags(tags)
+		return *s
+	}
+	var series []datadogV2.MetricSeries
+	for _, f := range findings {
+		tags := n.tags(f)
+		series = append(series, gauge("usage", f.Usage, tags), gauge("limit", f.Limit, tags))
+	}
+	return *datadogV2.NewMetricPayload(series)
+}
@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Columns are the columns of the exported records.
+var Columns = []string{"timestamp", "org_name", "public_id", "region", "product", "usage_type", "value", "unit", "cost", "source"}
+
+// WriteCSV writes records as CSV with a header row. Timestamps are formatted
+// as RFC 3339 and unknown costs are empty.
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return err
+	}
+	for _, r := range records {
+		cost := ""
+		if r.Cost != nil {
+			cost = strconv.FormatFloat(*r.Cost, 'f', -1, 64)
+		}
+		row := []string{
+			r.Timestamp.UTC().Format(time.RFC3339),
+			r.OrgName,
+			r.PublicID,
+			r.Region,
+			r.Product,
+			r.UsageType,
+			strconv.FormatFloat(r.Value, 'f', -1, 64),
+			r.Unit,
+			cost,
+			string(r.Source),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes records as JSON Lines, one object per record.
+func WriteJSONLines(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parquetRecord is the Parquet schema of a record.
+type parquetRecord struct {
+	Timestamp int64    `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	OrgName   string   `parquet:"name=org_name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	PublicID  string   `parquet:"name=public_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Region    string   `parquet:"name=region, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Product   string   `parquet:"name=product, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	UsageType string   `parquet:"name=usage_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value     float64  `parquet:"name=value, type=DOUBLE"`
+	Unit      string   `parquet:"name=unit, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Cost      *float64 `parquet:"name=cost, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Source    string   `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// WriteParquet writes records as a Snappy compressed Parquet file.
+// Timestamps are stored as milliseconds and unknown costs as nulls.
+func WriteParquet(w io.Writer, records []Record) error {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRecord), 1)
+	if err != nil {
+		return fmt.Errorf("creating parquet writer: %w", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, r := range records {
+		row := parquetRecord{
+			Timestamp: r.Timestamp.UnixMilli(),
+			OrgName:   r.OrgName,
+			PublicID:  r.PublicID,
+			Region:    r.Region,
+			Product:   r.Product,
+			UsageType: r.UsageType,
+			Value:     r.Value,
+			Unit:      r.Unit,
+			Cost:      r.Cost,
+			Source:    string(r.Source),
+		}
+		if err := pw.Write(row); err != nil {
+			return fmt.Errorf("writing parquet row: %w", err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("writing parquet footer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,427 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package usage reports usage and costs as tidy records.
+//
+// A Reporter pulls the hourly usage of product families, the monthly usage
+// summary and the actual, estimated or historical costs of the orgs of an
+// account over a date range. Each measurement becomes a Record, one per
+// timestamp, org, product and usage type, which export to CSV, JSON Lines or
+// Parquet. The monthly cost of a product is set on a usage record of that
+// product, so that the costs of the records add up to the bill.
+//
+// Usage attribution breaks usage down by tags such as team or service. A
+// Chargeback groups the attributions of each month by a hierarchy of tag keys
+// and compares the groups to their monthly budgets, and a Notifier posts the
+// findings as events or custom metrics.
+package usage
+
+import (
+	_context "context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Record is a measurement of the usage or cost of a product by an org.
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgName   string    `json:"org_name"`
+	PublicID  string    `json:"public_id"`
+	Region    string    `json:"region,omitempty"`
+	Product   string    `json:"product"`
+	UsageType string    `json:"usage_type"`
+	Value     float64   `json:"value"`
+	Unit      string    `json:"unit"`
+	// Cost is the cost of the usage in dollars, when known.
+	Cost *float64 `json:"cost,omitempty"`
+	// Source is the report the record comes from.
+	Source Source `json:"source"`
+}
+
+// Source is a report of usage or costs.
+type Source string
+
+// List of Source.
+const (
+	SourceHourlyUsage    Source = "hourly_usage"
+	SourceSummary        Source = "summary"
+	SourceCost           Source = "cost"
+	SourceEstimatedCost  Source = "estimated_cost"
+	SourceHistoricalCost Source = "historical_cost"
+)
+
+// Query selects the usage and costs to report.
+type Query struct {
+	Start time.Time
+	End   time.Time
+	// Products are the product families of the hourly usage, such as
+	// "infra_hosts" or "logs", or "all".
+	Products []string
+	// Summary includes the monthly usage summary.
+	Summary bool
+	// Cost is the cost to set on the records, among SourceCost,
+	// SourceEstimatedCost and SourceHistoricalCost, none when empty.
+	Cost Source
+}
+
+// Reporter pulls usage and costs.
+type Reporter struct {
+	v1 *datadogV1.UsageMeteringApi
+	v2 *datadogV2.UsageMeteringApi
+	// Orgs are the public IDs or names of the orgs to report, all the orgs of
+	// the account when empty.
+	Orgs []string
+}
+
+// NewReporter returns a reporter using the given APIs.
+func NewReporter(v1 *datadogV1.UsageMeteringApi, v2 *datadogV2.UsageMeteringApi) *Reporter {
+	return &Reporter{v1: v1, v2: v2}
+}
+
+// Report pulls the usage and costs selected by the query, sorted by
+// timestamp, org, product and usage type.
+func (r *Reporter) Report(ctx _context.Context, q Query) ([]Record, error) {
+	var records []Record
+	if len(q.Products) > 0 {
+		hourly, err := r.HourlyUsage(ctx, q.Start, q.End, q.Products)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, hourly...)
+	}
+	if q.Summary {
+		summary, err := r.Summary(ctx, q.Start, q.End)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, summary...)
+	}
+	Sort(records)
+	if q.Cost != "" {
+		costs, err := r.Costs(ctx, q.Cost, q.Start, q.End)
+		if err != nil {
+			return nil, err
+		}
+		records = setCosts(records, costs)
+		Sort(records)
+	}
+	return records, nil
+}
+
+// HourlyUsage pulls the hourly usage of the product families, including
+// child orgs.
+func (r *Reporter) HourlyUsage(ctx _context.Context, start, end time.Time, products []string) ([]Record, error) {
+	var records []Record
+	params := datadogV2.NewGetHourlyUsageOptionalParameters().
+		WithFilterTimestampEnd(end).
+		WithFilterIncludeDescendants(true)
+	for {
+		resp, _, err := r.v2.GetHourlyUsage(ctx, start, strings.Join(products, ","), *params)
+		if err != nil {
+			return nil, fmt.Errorf("getting hourly usage: %w", err)
+		}
+		for _, item := range resp.Data {
+			attrs := item.Attributes
+			if attrs == nil || !r.selected(attrs.GetPublicId(), attrs.GetOrgName()) {
+				continue
+			}
+			for _, m := range attrs.Measurements {
+				value := m.Value.Get()
+				if value == nil {
+					continue
+				}
+				records = append(records, Record{
+					Timestamp: attrs.GetTimestamp().UTC(),
+					OrgName:   attrs.GetOrgName(),
+					PublicID:  attrs.GetPublicId(),
+					Region:    attrs.GetRegion(),
+					Product:   attrs.GetProductFamily(),
+					UsageType: m.GetUsageType(),
+					Value:     float64(*value),
+					Unit:      Unit(m.GetUsageType()),
+					Source:    SourceHourlyUsage,
+				})
+			}
+		}
+		next := resp.GetMeta().Pagination.GetNextRecordId()
+		if next == "" {
+			return records, nil
+		}
+		params = params.WithPageNextRecordId(next)
+	}
+}
+
+// Summary pulls the monthly usage summary of the orgs. The product of the
+// usage types is derived from their name.
+func (r *Reporter) Summary(ctx _context.Context, start, end time.Time) ([]Record, error) {
+	resp, _, err := r.v1.GetUsageSummary(ctx, month(start), *datadogV1.NewGetUsageSummaryOptionalParameters().
+		WithEndMonth(month(end)).
+		WithIncludeOrgDetails(true))
+	if err != nil {
+		return nil, fmt.Errorf("getting usage summary: %w", err)
+	}
+	var records []Record
+	for _, date := range resp.Usage {
+		for _, org := range date.Orgs {
+			if !r.selected(org.GetPublicId(), org.GetName()) {
+				continue
+			}
+			values, err := numericFields(org)
+			if err != nil {
+				return nil, err
+			}
+			for usageType, value := range values {
+				records = append(records, Record{
+					Timestamp: date.GetDate().UTC(),
+					OrgName:   org.GetName(),
+					PublicID:  org.GetPublicId(),
+					Region:    org.GetRegion(),
+					Product:   Product(usageType),
+					UsageType: usageType,
+					Value:     value,
+					Unit:      Unit(usageType),
+					Source:    SourceSummary,
+				})
+			}
+		}
+	}
+	return records, nil
+}
+
+// Costs pulls the costs of the orgs by product and charge type.
+func (r *Reporter) Costs(ctx _context.Context, source Source, start, end time.Time) ([]Record, error) {
+	var resp datadogV2.CostByOrgResponse
+	var err error
+	switch source {
+	case SourceCost:
+		resp, _, err = r.v2.GetCostByOrg(ctx, month(start), *datadogV2.NewGetCostByOrgOptionalParameters().WithEndMonth(month(end)))
+	case SourceEstimatedCost:
+		resp, _, err = r.v2.GetEstimatedCostByOrg(ctx, *datadogV2.NewGetEstimatedCostByOrgOptionalParameters().
+			WithView("sub-org").WithStartMonth(month(start)).WithEndMonth(month(end)))
+	case SourceHistoricalCost:
+		resp, _, err = r.v2.GetHistoricalCostByOrg(ctx, month(start), *datadogV2.NewGetHistoricalCostByOrgOptionalParameters().
+			WithView("sub-org").WithEndMonth(month(end)))
+	default:
+		return nil, fmt.Errorf("unsupported cost source %q", source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", strings.ReplaceAll(string(source), "_", " "), err)
+	}
+	var records []Record
+	for _, item := range resp.Data {
+		attrs := item.Attributes
+		if attrs == nil || !r.selected(attrs.GetPublicId(), attrs.GetOrgName()) {
+			continue
+		}
+		for _, charge := range attrs.Charges {
+			cost := charge.GetCost()
+			records = append(records, Record{
+				Timestamp: attrs.GetDate().UTC(),
+				OrgName:   attrs.GetOrgName(),
+				PublicID:  attrs.GetPublicId(),
+				Product:   charge.GetProductName(),
+				UsageType: charge.GetChargeType(),
+				Value:     cost,
+				Unit:      "dollars",
+				Cost:      &cost,
+				Source:    source,
+			})
+		}
+	}
+	return records, nil
+}
+
+// setCosts sets the cost of each product of an org in a month on the first
+// of the sorted usage records of that product, org and month. The records of
+// the costs of products without usage are appended as is.
+func setCosts(records, costs []Record) []Record {
+	type key struct {
+		month    time.Time
+		publicID string
+		product  string
+	}
+	charges := map[key][]Record{}
+	var keys []key
+	for _, c := range costs {
+		k := key{month(c.Timestamp), c.PublicID, family(c.Product)}
+		if _, ok := charges[k]; !ok {
+			keys = append(keys, k)
+		}
+		charges[k] = append(charges[k], c)
+	}
+	for i := range records {
+		k := key{month(records[i].Timestamp), records[i].PublicID, records[i].Product}
+		if c, ok := charges[k]; ok {
+			cost := total(c)
+			records[i].Cost = &cost
+			delete(charges, k)
+		}
+	}
+	for _, k := range keys {
+		records = append(records, charges[k]...)
+	}
+	return records
+}
+
+// total returns the total charge of a product, the sum of its charges when
+// none is of the "total" charge type.
+func total(charges []Record) float64 {
+	var sum float64
+	for _, c := range charges {
+		if c.UsageType == "total" {
+			return *c.Cost
+		}
+		sum += *c.Cost
+	}
+	return sum
+}
+
+// family returns the product family of the usage of a product of the costs.
+func family(product string) string {
+	if f := Product(product); f != "other" {
+		return f
+	}
+	return product
+}
+
+func (r *Reporter) selected(publicID, name string) bool {
+	if len(r.Orgs) == 0 {
+		return true
+	}
+	for _, org := range r.Orgs {
+		if org == publicID || org == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort sorts records by timestamp, org, product, usage type and source.
+func Sort(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		switch {
+		case !a.Timestamp.Equal(b.Timestamp):
+			return a.Timestamp.Before(b.Timestamp)
+		case a.PublicID != b.PublicID:
+			return a.PublicID < b.PublicID
+		case a.Product != b.Product:
+			return a.Product < b.Product
+		case a.UsageType != b.UsageType:
+			return a.UsageType < b.UsageType
+		}
+		return a.Source < b.Source
+	})
+}
+
+// Unit returns the unit of a usage type from its name.
+func Unit(usageType string) string {
+	switch {
+	case strings.Contains(usageType, "bytes"):
+		return "bytes"
+	case strings.HasSuffix(usageType, "_hours") || strings.HasSuffix(usageType, "_hrs"):
+		return "hours"
+	case strings.Contains(usageType, "minutes"):
+		return "minutes"
+	case strings.Contains(usageType, "seconds"):
+		return "seconds"
+	}
+	return "count"
+}
+
+// products are the product families of the prefixes of usage types, longest
+// prefixes first.
+var products = []struct{ prefix, product string }{
+	{"synthetics_browser", "synthetics_browser"},
+	{"synthetics_", "synthetics_api"},
+	{"browser_rum", "rum"},
+	{"mobile_rum", "rum"},
+	{"rum_", "rum"},
+	{"apm_", "apm"},
+	{"appsec_", "application_security"},
+	{"asm_", "application_security"},
+	{"audit_", "audit_trail"},
+	{"aws_lambda", "serverless"},
+	{"serverless_", "serverless"},
+	{"ci_", "ci_app"},
+	{"container", "containers"},
+	{"cspm_", "cspm"},
+	{"cws_", "cws"},
+	{"dbm_", "dbm"},
+	{"fargate_", "fargate"},
+	{"incident_management", "incident_management"},
+	{"indexed_events", "indexed_logs"},
+	{"billable_ingested", "logs"},
+	{"ingested_events", "logs"},
+	{"logs_", "logs"},
+	{"live_", "logs"},
+	{"rehydrated_", "logs"},
+	{"online_archive", "online_archive"},
+	{"ingested_spans", "ingested_spans"},
+	{"twol_ingested_spans", "ingested_spans"},
+	{"indexed_spans", "indexed_spans"},
+	{"trace_search", "indexed_spans"},
+	{"iot_", "iot"},
+	{"netflow_", "network_flows"},
+	{"npm_", "network_hosts"},
+	{"observability_pipelines", "observability_pipelines"},
+	{"profiling_", "profiling"},
+	{"sds_", "sds"},
+	{"snmp_", "snmp"},
+	{"custom_", "timeseries"},
+	{"agent_host", "infra_hosts"},
+	{"aws_host", "infra_hosts"},
+	{"azure_", "infra_hosts"},
+	{"gcp_host", "infra_hosts"},
+	{"heroku_host", "infra_hosts"},
+	{"host_", "infra_hosts"},
+	{"infra_host", "infra_hosts"},
+	{"opentelemetry_", "infra_hosts"},
+	{"vsphere_host", "infra_hosts"},
+}
+
+func init() {
+	sort.SliceStable(products, func(i, j int) bool { return len(products[i].prefix) > len(products[j].prefix) })
+}
+
+// Product returns the product family of a usage type of the usage summary,
+// "other" when unknown.
+func Product(usageType string) string {
+	for _, p := range products {
+		if strings.HasPrefix(usageType, p.prefix) {
+			return p.product
+		}
+	}
+	return "other"
+}
+
+// numericFields returns the numeric fields of a model by JSON name.
+func numericFields(model interface{}) (map[string]float64, error) {
+	raw, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	values := map[string]float64{}
+	for name, v := range fields {
+		if f, ok := v.(float64); ok {
+			values[name] = f
+		}
+	}
+	return values, nil
+}
+
+func month(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
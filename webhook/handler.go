@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+package webhook
+
+import (
+	_context "context"
+	"crypto/subtle"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// maxPayloadSize is the maximum size of the payload read by the handler.
+const maxPayloadSize = 1 << 20
+
+// Handler serves webhook notifications.
+//
+// Requests without the secret respond with 401, invalid payloads with 400
+// and failures of the callback with 500. Notifications handled successfully
+// respond with 204.
+type Handler struct {
+	secret string
+	handle func(_context.Context, *Notification) error
+	// Header is the header carrying the secret, SecretHeader when empty.
+	Header string
+}
+
+// NewHandler returns a handler calling the function for every notification.
+// When set, the secret must be sent with every request.
+func NewHandler(secret string, handle func(ctx _context.Context, n *Notification) error) *Handler {
+	return &Handler{secret: secret, handle: handle}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.secret != "" {
+		header := h.Header
+		if header == "" {
+			header = SecretHeader
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(header)), []byte(h.secret)) != 1 {
+			http.Error(w, "invalid secret", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var n *Notification
+	var err error
+	r.Body = http.MaxBytesReader(w, r.Body, maxPayloadSize)
+	if contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType == "application/x-www-form-urlencoded" {
+		if err = r.ParseForm(); err == nil {
+			n, err = ParseForm(r.PostForm)
+		}
+	} else {
+		var payload []byte
+		if payload, err = io.ReadAll(r.Body); err == nil {
+			n, err = Parse(payload)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.handle(r.Context(), n); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
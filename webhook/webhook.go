@@ -0,0 +1,226 @@
+// Unless explicitly stated otherwise all files in this repository are licensed under the Apache-2.0 License.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2019-Present Datadog, Inc.
+
+// Package webhook receives Datadog webhook notifications.
+//
+// Integration generates the definition of a webhook whose payload maps the
+// Datadog webhook variables to JSON keys, and Parse reads such a payload
+// back into a Notification. Both are built from the same list of variables
+// so the format sent and the format parsed cannot drift apart. Handler
+// serves these notifications over HTTP, checking the shared secret sent in
+// the custom headers of the webhook.
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// SecretHeader is the header carrying the shared secret.
+const SecretHeader = "X-Datadog-Webhook-Secret"
+
+// AlertTransition is the transition of the alert of a monitor notification.
+type AlertTransition string
+
+// List of AlertTransition.
+const (
+	TransitionTriggered         AlertTransition = "Triggered"
+	TransitionReTriggered       AlertTransition = "Re-Triggered"
+	TransitionRecovered         AlertTransition = "Recovered"
+	TransitionWarn              AlertTransition = "Warn"
+	TransitionReWarn            AlertTransition = "Re-Warn"
+	TransitionRecoveredFromWarn AlertTransition = "Recovered from Warn"
+	TransitionNoData            AlertTransition = "No Data"
+	TransitionReNoData          AlertTransition = "Re-No Data"
+	TransitionRenotify          AlertTransition = "Renotify"
+)
+
+// Notification is a webhook notification.
+type Notification struct {
+	// ID is the ID of the event.
+	ID           string
+	EventType    string
+	EventTitle   string
+	EventMessage string
+	// TextOnlyMessage is the message of the event without Markdown.
+	TextOnlyMessage string
+	Date            time.Time
+	LastUpdated     time.Time
+	Priority        string
+	AggregationKey  string
+	// AlertID is the ID of the monitor.
+	AlertID         int64
+	AlertTitle      string
+	AlertMetric     string
+	AlertQuery      string
+	AlertScope      string
+	AlertStatus     string
+	AlertTransition AlertTransition
+	AlertType       string
+	AlertPriority   string
+	AlertCycleKey   string
+	Hostname        string
+	Tags            []string
+	Link            string
+	Snapshot        string
+	OrgID           int64
+	OrgName         string
+	User            string
+	Username        string
+}
+
+type variable struct {
+	name string
+	key  string
+	set  func(n *Notification, value string) error
+}
+
+// variables are the webhook variables of a notification, with the key of
+// their value in the payload.
+var variables = []variable{
+	{"ID", "id", setString(func(n *Notification) *string { return &n.ID })},
+	{"EVENT_TYPE", "event_type", setString(func(n *Notification) *string { return &n.EventType })},
+	{"EVENT_TITLE", "event_title", setString(func(n *Notification) *string { return &n.EventTitle })},
+	{"EVENT_MSG", "event_msg", setString(func(n *Notification) *string { return &n.EventMessage })},
+	{"TEXT_ONLY_MSG", "text_only_msg", setString(func(n *Notification) *string { return &n.TextOnlyMessage })},
+	{"DATE", "date", setTime(func(n *Notification) *time.Time { return &n.Date })},
+	{"LAST_UPDATED", "last_updated", setTime(func(n *Notification) *time.Time { return &n.LastUpdated })},
+	{"PRIORITY", "priority", setString(func(n *Notification) *string { return &n.Priority })},
+	{"AGGREG_KEY", "aggreg_key", setString(func(n *Notification) *string { return &n.AggregationKey })},
+	{"ALERT_ID", "alert_id", setInt(func(n *Notification) *int64 { return &n.AlertID })},
+	{"ALERT_TITLE", "alert_title", setString(func(n *Notification) *string { return &n.AlertTitle })},
+	{"ALERT_METRIC", "alert_metric", setString(func(n *Notification) *string { return &n.AlertMetric })},
+	{"ALERT_QUERY", "alert_query", setString(func(n *Notification) *string { return &n.AlertQuery })},
+	{"ALERT_SCOPE", "alert_scope", setString(func(n *Notification) *string { return &n.AlertScope })},
+	{"ALERT_STATUS", "alert_status", setString(func(n *Notification) *string { return &n.AlertStatus })},
+	{"ALERT_TRANSITION", "alert_transition", func(n *Notification, value string) error {
+		n.AlertTransition = AlertTransition(value)
+		return nil
+	}},
+	{"ALERT_TYPE", "alert_type", setString(func(n *Notification) *string { return &n.AlertType })},
+	{"ALERT_PRIORITY", "alert_priority", setString(func(n *Notification) *string { return &n.AlertPriority })},
+	{"ALERT_CYCLE_KEY", "alert_cycle_key", setString(func(n *Notification) *string { return &n.AlertCycleKey })},
+	{"HOSTNAME", "hostname", setString(func(n *Notification) *string { return &n.Hostname })},
+	{"TAGS", "tags", func(n *Notification, value string) error {
+		n.Tags = nil
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				n.Tags = append(n.Tags, tag)
+			}
+		}
+		return nil
+	}},
+	{"LINK", "link", setString(func(n *Notification) *string { return &n.Link })},
+	{"SNAPSHOT", "snapshot", setString(func(n *Notification) *string { return &n.Snapshot })},
+	{"ORG_ID", "org_id", setInt(func(n *Notification) *int64 { return &n.OrgID })},
+	{"ORG_NAME", "org_name", setString(func(n *Notification) *string { return &n.OrgName })},
+	{"USER", "user", setString(func(n *Notification) *string { return &n.User })},
+	{"USERNAME", "username", setString(func(n *Notification) *string { return &n.Username })},
+}
+
+func setString(field func(n *Notification) *string) func(*Notification, string) error {
+	return func(n *Notification, value string) error {
+		*field(n) = value
+		return nil
+	}
+}
+
+func setInt(field func(n *Notification) *int64) func(*Notification, string) error {
+	return func(n *Notification, value string) error {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(n) = i
+		return nil
+	}
+}
+
+// setTime parses epoch timestamps, in milliseconds as sent by Datadog or in
+// seconds.
+func setTime(field func(n *Notification) *time.Time) func(*Notification, string) error {
+	return func(n *Notification, value string) error {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if i < 1e11 {
+			*field(n) = time.Unix(i, 0).UTC()
+		} else {
+			*field(n) = time.UnixMilli(i).UTC()
+		}
+		return nil
+	}
+}
+
+// Payload returns the payload template of the webhook.
+func Payload() string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, v := range variables {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, "  %q: \"$%s\"", v.key, v.name)
+	}
+	b.WriteString("\n}")
+	return b.String()
+}
+
+// Integration returns the definition of a webhook sending notifications in
+// the format read by Parse. When set, the secret is sent in the SecretHeader
+// header.
+func Integration(name, url, secret string) datadogV1.WebhooksIntegration {
+	webhook := datadogV1.NewWebhooksIntegration(name, url)
+	webhook.SetEncodeAs(datadogV1.WEBHOOKSINTEGRATIONENCODING_JSON)
+	webhook.SetPayload(Payload())
+	if secret != "" {
+		headers, _ := json.Marshal(map[string]string{SecretHeader: secret})
+		webhook.SetCustomHeaders(string(headers))
+	}
+	return *webhook
+}
+
+// Parse reads a JSON payload sent by a webhook defined by Integration.
+func Parse(payload []byte) (*Notification, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal(payload, &values); err != nil {
+		return nil, fmt.Errorf("decoding payload: %w", err)
+	}
+	return parse(func(key string) string {
+		switch v := values[key].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	})
+}
+
+// ParseForm reads the payload of a webhook encoded as a form.
+func ParseForm(form url.Values) (*Notification, error) {
+	return parse(form.Get)
+}
+
+func parse(get func(key string) string) (*Notification, error) {
+	n := &Notification{}
+	for _, v := range variables {
+		value := get(v.key)
+		// Variables that do not apply to the event are left unreplaced.
+		if value == "" || value == "$"+v.name {
+			continue
+		}
+		if err := v.set(n, value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", v.key, err)
+		}
+	}
+	return n, nil
+}